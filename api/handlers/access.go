@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"github.com/10yihang/resume-ai-interview/internal/auth"
+	"github.com/10yihang/resume-ai-interview/internal/jobs"
+	"github.com/10yihang/resume-ai-interview/models"
+	"github.com/gin-gonic/gin"
+)
+
+// 以下查询只返回当前用户有权访问的数据
+// 无权访问与不存在返回相同的结果，不暴露其他用户的数据是否存在
+
+// resumeFor 获取当前用户可访问的简历
+func (s *Server) resumeFor(c *gin.Context, id string) (*models.Resume, bool) {
+	resume, ok := s.resumes.Get(id)
+	if !ok || !currentUser(c).CanAccess(resume.OwnerID, resume.TeamID) {
+		return nil, false
+	}
+	return resume, true
+}
+
+// jdFor 获取当前用户可访问的JD
+func (s *Server) jdFor(c *gin.Context, id string) (*models.JobDescription, bool) {
+	jd, ok := s.jds.Get(id)
+	if !ok || !currentUser(c).CanAccess(jd.OwnerID, jd.TeamID) {
+		return nil, false
+	}
+	return jd, true
+}
+
+// questionSetFor 获取当前用户可访问的问题集
+func (s *Server) questionSetFor(c *gin.Context, id string) (*models.QuestionSet, bool) {
+	questionSet, ok := s.questions.Get(id)
+	if !ok || !currentUser(c).CanAccess(questionSet.OwnerID, questionSet.TeamID) {
+		return nil, false
+	}
+	return questionSet, true
+}
+
+// sessionVisible 判断用户能否访问面试会话，面试官只能访问自己创建或分配给自己的会话
+func sessionVisible(user *auth.User, session *models.Session) bool {
+	if !user.CanAccess(session.OwnerID, session.TeamID) {
+		return false
+	}
+	if user.Role == auth.RoleInterviewer {
+		return session.OwnerID == user.ID || session.InterviewerID == user.ID
+	}
+	return true
+}
+
+// evaluationVisible 判断用户能否访问评估，面试官只能访问自己的评估和分配给自己的会话中的评估
+func (s *Server) evaluationVisible(user *auth.User, evaluation *models.Evaluation) bool {
+	if !user.CanAccess(evaluation.OwnerID, evaluation.TeamID) {
+		return false
+	}
+	if user.Role == auth.RoleInterviewer && evaluation.OwnerID != user.ID {
+		session, ok := s.sessions.Get(evaluation.SessionID)
+		return ok && sessionVisible(user, session)
+	}
+	return true
+}
+
+// sessionFor 获取当前用户可访问的面试会话
+func (s *Server) sessionFor(c *gin.Context, id string) (*models.Session, bool) {
+	session, ok := s.sessions.Get(id)
+	if !ok || !sessionVisible(currentUser(c), session) {
+		return nil, false
+	}
+	return session, true
+}
+
+// evaluationFor 获取当前用户可访问的评估
+func (s *Server) evaluationFor(c *gin.Context, id string) (*models.Evaluation, bool) {
+	evaluation, ok := s.evaluations.Get(id)
+	if !ok || !s.evaluationVisible(currentUser(c), evaluation) {
+		return nil, false
+	}
+	return evaluation, true
+}
+
+// jobFor 获取当前用户可访问的解析任务
+func (s *Server) jobFor(c *gin.Context, id string) (jobs.Job, bool) {
+	job, ok := s.jobQueue.Get(id)
+	if !ok || !currentUser(c).CanAccess(job.OwnerID, job.TeamID) {
+		return jobs.Job{}, false
+	}
+	return job, true
+}
+
+// visibleTo 过滤出当前用户可访问的数据
+func visibleTo[T any](c *gin.Context, items []T, owner func(T) (ownerID, teamID string)) []T {
+	user := currentUser(c)
+	visible := make([]T, 0, len(items))
+	for _, item := range items {
+		if user.CanAccess(owner(item)) {
+			visible = append(visible, item)
+		}
+	}
+	return visible
+}
+
+// canSeeAnswerKey 判断当前用户能否看到参考答案和评分要点，候选人练习时看不到
+func canSeeAnswerKey(c *gin.Context) bool {
+	return !currentUser(c).HasRole(auth.RoleCandidate)
+}
+
+// questionView 按当前用户的角色返回问题，必要时去掉参考答案和评分要点
+func questionView(c *gin.Context, q models.Question) models.Question {
+	if !canSeeAnswerKey(c) {
+		q.ReferenceAnswer = ""
+		q.Rubric = nil
+	}
+	return q
+}
+
+// questionSetView 按当前用户的角色返回问题集，不修改存储中的数据
+func questionSetView(c *gin.Context, questionSet *models.QuestionSet) *models.QuestionSet {
+	if canSeeAnswerKey(c) {
+		return questionSet
+	}
+	view := *questionSet
+	view.Questions = make([]models.Question, len(questionSet.Questions))
+	for i, q := range questionSet.Questions {
+		view.Questions[i] = questionView(c, q)
+	}
+	return &view
+}
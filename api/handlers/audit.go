@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/10yihang/resume-ai-interview/internal/ai"
+	"github.com/10yihang/resume-ai-interview/internal/audit"
+	"github.com/10yihang/resume-ai-interview/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// 审计记录中的资源类型，与REST API的标签一致
+const (
+	resourceResume      = "resumes"
+	resourceJD          = "jds"
+	resourceQuestionSet = "question-sets"
+	resourceSession     = "sessions"
+	resourceEvaluation  = "evaluations"
+	resourceJob         = "jobs"
+	resourceFile        = "files"
+)
+
+// 处理器通过gin上下文向审计中间件补充的信息
+const (
+	auditResourceKey = "auditResource"
+	auditProviderKey = "auditProvider"
+	auditModelKey    = "auditModel"
+	auditDetailKey   = "auditDetail"
+)
+
+// audited 在请求处理完成后记录一条审计日志
+// 资源ID依次取处理器设置的ID、路径参数id和创建资源时的Location头
+func (s *Server) audited(action, resourceType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		entry := audit.Entry{
+			Action:       action,
+			ResourceType: resourceType,
+			ResourceID:   c.GetString(auditResourceKey),
+			Provider:     c.GetString(auditProviderKey),
+			Model:        c.GetString(auditModelKey),
+			Detail:       c.GetString(auditDetailKey),
+			Status:       c.Writer.Status(),
+		}
+		if entry.ResourceID == "" {
+			entry.ResourceID = c.Param("id")
+		}
+		if location := c.Writer.Header().Get("Location"); entry.ResourceID == "" && location != "" {
+			entry.ResourceID = path.Base(location)
+		}
+		if value, ok := c.Get(userContextKey); ok {
+			user := value.(*auth.User)
+			entry.ActorID, entry.TeamID = user.ID, user.TeamID
+		}
+		s.auditLog.Record(entry)
+	}
+}
+
+// auditResource 设置审计记录中的资源ID
+func auditResource(c *gin.Context, id string) {
+	c.Set(auditResourceKey, id)
+}
+
+// auditModel 在审计记录中记下本次请求调用的AI服务和模型
+func auditModel(c *gin.Context, component any) {
+	provider, model := ai.DescribeModel(component)
+	c.Set(auditProviderKey, provider)
+	c.Set(auditModelKey, model)
+}
+
+// auditServedBy 配置了多个AI服务时，把审计记录中的服务和模型改为实际提供服务的服务和模型
+func auditServedBy(c *gin.Context, provider, model string) {
+	if provider == "" {
+		return
+	}
+	c.Set(auditProviderKey, provider)
+	c.Set(auditModelKey, model)
+}
+
+// auditDetail 设置审计记录的补充说明
+func auditDetail(c *gin.Context, format string, args ...any) {
+	c.Set(auditDetailKey, fmt.Sprintf(format, args...))
+}
+
+// auditReturned 在审计记录中记下列表或批量接口返回的资源ID，便于追查哪些数据被读取
+func auditReturned(c *gin.Context, ids []string) {
+	auditDetail(c, "ids=%s", strings.Join(ids, ","))
+}
+
+// recordDeletion 记录当前用户删除数据的审计日志，用于连带删除的数据
+func (s *Server) recordDeletion(c *gin.Context, action, resourceType, resourceID string) {
+	user := currentUser(c)
+	s.auditLog.Record(audit.Entry{
+		ActorID:      user.ID,
+		TeamID:       user.TeamID,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+	})
+}
+
+// ListAuditEntriesHandler 分页查询当前团队的审计记录，最新的在前
+func (s *Server) ListAuditEntriesHandler(c *gin.Context) {
+	filter := audit.Filter{
+		TeamID:       currentUser(c).TeamID,
+		ActorID:      c.Query("actorId"),
+		Action:       c.Query("action"),
+		ResourceType: c.Query("resourceType"),
+		ResourceID:   c.Query("resourceId"),
+	}
+	var ok bool
+	if filter.Since, filter.Until, ok = timeRange(c); !ok {
+		return
+	}
+
+	entries, err := s.auditLog.Query(filter)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+
+	result, ok := paginate(c, entries)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// sessionCookie 是网页登录会话的Cookie名
+const sessionCookie = "rai_session"
+
+// userContextKey 是gin上下文中保存当前用户的键
+const userContextKey = "user"
+
+// inviteTTL 是团队邀请码的有效期
+const inviteTTL = 7 * 24 * time.Hour
+
+// registerRequest 注册请求参数
+type registerRequest struct {
+	Email      string    `json:"email" binding:"required"`
+	Name       string    `json:"name"`
+	Password   string    `json:"password" binding:"required"`
+	InviteCode string    `json:"inviteCode" doc:"团队邀请码，为空时创建新团队"`
+	Role       auth.Role `json:"role" enum:"recruiter,candidate" doc:"不带邀请码注册时的角色，默认recruiter；candidate为练习模式。使用邀请码时由邀请码决定"`
+}
+
+// loginRequest 登录请求参数
+type loginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// tokenRequest 创建API令牌的请求参数
+type tokenRequest struct {
+	Name string `json:"name" binding:"required" doc:"令牌用途说明"`
+}
+
+// createdToken 是新建的API令牌，令牌原文只返回这一次
+type createdToken struct {
+	auth.Token
+	Secret string `json:"token" binding:"required" doc:"令牌原文，请求时放在Authorization: Bearer头中"`
+}
+
+// inviteRequest 创建团队邀请码的请求参数
+type inviteRequest struct {
+	Role auth.Role `json:"role" binding:"required" enum:"recruiter,interviewer,candidate" doc:"受邀用户加入团队后的角色"`
+}
+
+// createdInvite 是新建的团队邀请码，邀请码原文只返回这一次
+type createdInvite struct {
+	Code      string    `json:"code" binding:"required"`
+	TeamID    string    `json:"teamId"`
+	Role      auth.Role `json:"role"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// memberUpdate 修改团队成员角色的请求参数
+type memberUpdate struct {
+	Role auth.Role `json:"role" binding:"required" enum:"recruiter,interviewer,candidate"`
+}
+
+// requestUser 从API令牌或登录Cookie中识别当前用户，未启用认证时返回本地用户
+func (s *Server) requestUser(c *gin.Context) *auth.User {
+	if !s.cfg.AuthEnabled {
+		return auth.LocalUser
+	}
+
+	if header := c.GetHeader("Authorization"); header != "" {
+		secret, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return nil
+		}
+		user, ok := s.auth.TokenUser(strings.TrimSpace(secret))
+		if !ok {
+			return nil
+		}
+		return user
+	}
+
+	if secret, err := c.Cookie(sessionCookie); err == nil && secret != "" {
+		if user, ok := s.auth.SessionUser(secret); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+// authenticate 要求请求已登录，并将当前用户保存到上下文中
+func (s *Server) authenticate(c *gin.Context) {
+	user := s.requestUser(c)
+	if user == nil {
+		if strings.HasPrefix(c.Request.URL.Path, apiBasePath) {
+			abortWithError(c, http.StatusUnauthorized, codeUnauthorized, "未登录或登录已过期")
+		} else {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未登录或登录已过期"})
+		}
+		return
+	}
+
+	c.Set(userContextKey, user)
+	c.Next()
+}
+
+// requireRole 要求当前用户具有roles中的任意一个角色，需要放在authenticate之后
+func requireRole(roles ...auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !currentUser(c).HasRole(roles...) {
+			if strings.HasPrefix(c.Request.URL.Path, apiBasePath) {
+				abortWithError(c, http.StatusForbidden, codeForbidden, "当前角色无权执行此操作")
+			} else {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "当前角色无权执行此操作"})
+			}
+			return
+		}
+		c.Next()
+	}
+}
+
+// currentUser 返回authenticate中间件识别出的当前用户
+func currentUser(c *gin.Context) *auth.User {
+	user, _ := c.MustGet(userContextKey).(*auth.User)
+	return user
+}
+
+// requireAuthEnabled 未启用认证时账号相关接口不可用
+func (s *Server) requireAuthEnabled(c *gin.Context) bool {
+	if !s.cfg.AuthEnabled {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "未启用用户认证")
+		return false
+	}
+	return true
+}
+
+// setSessionCookie 为用户创建登录会话并写入Cookie
+func (s *Server) setSessionCookie(c *gin.Context, user *auth.User) {
+	secret, expiresAt := s.auth.CreateSession(user.ID)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, secret, int(time.Until(expiresAt).Seconds()), "/", "", c.Request.TLS != nil, true)
+}
+
+// RegisterHandler 注册新用户并直接登录
+func (s *Server) RegisterHandler(c *gin.Context) {
+	if !s.requireAuthEnabled(c) {
+		return
+	}
+
+	var request registerRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "无效的请求参数: "+err.Error())
+		return
+	}
+	if request.InviteCode == "" && !s.cfg.AllowSignup {
+		abortWithError(c, http.StatusForbidden, codeForbidden, "未开放注册，请使用团队邀请码")
+		return
+	}
+
+	user, err := s.auth.Register(request.Email, request.Name, request.Password, request.InviteCode, request.Role)
+	switch {
+	case errors.Is(err, auth.ErrEmailTaken):
+		abortWithError(c, http.StatusConflict, codeConflict, err.Error())
+		return
+	case errors.Is(err, auth.ErrInvalidEmail), errors.Is(err, auth.ErrWeakPassword), errors.Is(err, auth.ErrInvalidInvite), errors.Is(err, auth.ErrInvalidRole):
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	case err != nil:
+		abortWithError(c, http.StatusInternalServerError, codeInternal, "注册失败: "+err.Error())
+		return
+	}
+
+	c.Set(userContextKey, user)
+	s.setSessionCookie(c, user)
+	c.JSON(http.StatusCreated, user)
+}
+
+// LoginHandler 使用邮箱和密码登录，登录会话保存在Cookie中
+func (s *Server) LoginHandler(c *gin.Context) {
+	if !s.requireAuthEnabled(c) {
+		return
+	}
+
+	var request loginRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "无效的请求参数: "+err.Error())
+		return
+	}
+
+	user, err := s.auth.Authenticate(request.Email, request.Password)
+	if err != nil {
+		abortWithError(c, http.StatusUnauthorized, codeUnauthorized, err.Error())
+		return
+	}
+
+	c.Set(userContextKey, user)
+	s.setSessionCookie(c, user)
+	c.JSON(http.StatusOK, user)
+}
+
+// LogoutHandler 退出登录
+func (s *Server) LogoutHandler(c *gin.Context) {
+	if secret, err := c.Cookie(sessionCookie); err == nil {
+		s.auth.EndSession(secret)
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+	c.Status(http.StatusNoContent)
+}
+
+// MeHandler 返回当前用户
+func (s *Server) MeHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, currentUser(c))
+}
+
+// ListTokensHandler 列出当前用户的API令牌
+func (s *Server) ListTokensHandler(c *gin.Context) {
+	if !s.requireAuthEnabled(c) {
+		return
+	}
+
+	result, ok := paginate(c, s.auth.ListTokens(currentUser(c).ID))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// CreateTokenHandler 为当前用户创建API令牌
+func (s *Server) CreateTokenHandler(c *gin.Context) {
+	if !s.requireAuthEnabled(c) {
+		return
+	}
+
+	var request tokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "无效的请求参数: "+err.Error())
+		return
+	}
+
+	secret, token, err := s.auth.CreateToken(currentUser(c).ID, request.Name)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, codeInternal, "创建令牌失败: "+err.Error())
+		return
+	}
+	auditResource(c, token.ID)
+	c.JSON(http.StatusCreated, createdToken{Token: *token, Secret: secret})
+}
+
+// DeleteTokenHandler 撤销当前用户的API令牌
+func (s *Server) DeleteTokenHandler(c *gin.Context) {
+	if !s.requireAuthEnabled(c) {
+		return
+	}
+
+	err := s.auth.RevokeToken(currentUser(c).ID, c.Param("id"))
+	if errors.Is(err, auth.ErrNotFound) {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "令牌不存在")
+		return
+	}
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, codeInternal, "撤销令牌失败: "+err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateInviteHandler 创建加入当前用户团队的一次性邀请码
+func (s *Server) CreateInviteHandler(c *gin.Context) {
+	if !s.requireAuthEnabled(c) {
+		return
+	}
+
+	var request inviteRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "无效的请求参数: "+err.Error())
+		return
+	}
+
+	code, invite, err := s.auth.CreateInvite(currentUser(c), request.Role, inviteTTL)
+	if errors.Is(err, auth.ErrInvalidRole) {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, codeInternal, "创建邀请码失败: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, createdInvite{Code: code, TeamID: invite.TeamID, Role: invite.Role, ExpiresAt: invite.ExpiresAt})
+}
+
+// ListTeamMembersHandler 列出当前团队的成员
+func (s *Server) ListTeamMembersHandler(c *gin.Context) {
+	if !s.requireAuthEnabled(c) {
+		return
+	}
+
+	result, ok := paginate(c, s.auth.TeamMembers(currentUser(c).TeamID))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// UpdateTeamMemberHandler 修改团队成员的角色，不能修改自己的角色
+func (s *Server) UpdateTeamMemberHandler(c *gin.Context) {
+	if !s.requireAuthEnabled(c) {
+		return
+	}
+
+	var update memberUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "无效的请求参数: "+err.Error())
+		return
+	}
+	user := currentUser(c)
+	if c.Param("id") == user.ID {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "不能修改自己的角色")
+		return
+	}
+
+	member, err := s.auth.SetRole(user.TeamID, c.Param("id"), update.Role)
+	switch {
+	case errors.Is(err, auth.ErrNotFound):
+		abortWithError(c, http.StatusNotFound, codeNotFound, "团队成员不存在")
+		return
+	case errors.Is(err, auth.ErrInvalidRole):
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	case err != nil:
+		abortWithError(c, http.StatusInternalServerError, codeInternal, "修改角色失败: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+// LoginPageHandler 显示登录和注册页面
+func (s *Server) LoginPageHandler(c *gin.Context) {
+	if !s.cfg.AuthEnabled || s.requestUser(c) != nil {
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+	c.HTML(http.StatusOK, "login.html", gin.H{
+		"title":       "登录 - AI简历面试助手",
+		"allowSignup": s.cfg.AllowSignup,
+	})
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/auth"
+	"github.com/10yihang/resume-ai-interview/internal/screening"
+	"github.com/10yihang/resume-ai-interview/models"
+	"github.com/gin-gonic/gin"
+)
+
+// BatchScreenHandler 批量解析简历并与指定JD匹配，返回按匹配分数排序的候选人名单
+// 表单字段：jdId（必填）、files（多个简历文件）、archive（zip压缩包）、parallelism（可选并发数）
+func (s *Server) BatchScreenHandler(c *gin.Context) {
+	jdID := c.PostForm("jdId")
+	if jdID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少jdId参数"})
+		return
+	}
+	jd, ok := s.jdFor(c, jdID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "JD不存在"})
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无法获取上传文件: " + err.Error()})
+		return
+	}
+
+	// 预算在开始筛选前检查一次，超出时按配置拒绝或不调用AI只保留简历原文
+	user := currentUser(c)
+	useAI, err := s.parseWithAI(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	auditResource(c, jdID)
+	auditModel(c, s.newAIParser(useAI))
+
+	// 写入磁盘前先检查文件数量，压缩包中的文件在解压时按剩余名额限制
+	files := form.File["files"]
+	if len(files) > s.cfg.BatchMaxFiles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("单次最多筛选%d份简历", s.cfg.BatchMaxFiles)})
+		return
+	}
+
+	// 每个批次使用单独的上传目录，筛选结束后整体删除，只保留解析结果
+	batchRoot := filepath.Join(s.cfg.UploadDir, "batch")
+	if err := os.MkdirAll(batchRoot, 0755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建上传目录失败: " + err.Error()})
+		return
+	}
+	batchDir, err := os.MkdirTemp(batchRoot, strconv.FormatInt(time.Now().Unix(), 10)+"-")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建上传目录失败: " + err.Error()})
+		return
+	}
+	defer func() {
+		if err := os.RemoveAll(batchDir); err != nil {
+			slog.Warn("删除批量筛选上传的文件失败", "dir", batchDir, "error", err)
+		}
+	}()
+
+	// 上传的文件和解压出的文件都加随机前缀保存，配置了密钥时加密后写入
+	subDir := filepath.Join("batch", filepath.Base(batchDir))
+	var candidates []screening.Candidate
+	for _, header := range files {
+		name := filepath.Base(header.Filename)
+		if !screening.SupportedExtensions[strings.ToLower(filepath.Ext(name))] {
+			candidates = append(candidates, screening.Candidate{FileName: name})
+			continue
+		}
+
+		path, err := s.storeUpload(header, subDir)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败: " + err.Error()})
+			return
+		}
+		candidates = append(candidates, screening.Candidate{FileName: name, FilePath: path})
+	}
+
+	for _, header := range form.File["archive"] {
+		remaining := s.cfg.BatchMaxFiles - len(candidates)
+		if remaining <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("单次最多筛选%d份简历", s.cfg.BatchMaxFiles)})
+			return
+		}
+		extracted, err := s.extractArchive(header, subDir, remaining)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "解压简历压缩包失败: " + err.Error()})
+			return
+		}
+		candidates = append(candidates, extracted...)
+	}
+
+	if len(candidates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未上传任何简历文件"})
+		return
+	}
+
+	// 请求中的并发数不能超过配置的上限
+	parallelism := s.cfg.BatchParallelism
+	if p, err := strconv.Atoi(c.PostForm("parallelism")); err == nil && p > 0 && p < parallelism {
+		parallelism = p
+	}
+
+	shortlist := screening.Screen(c.Request.Context(), candidates, jd, s.candidateParser(user, useAI), screening.NewKeywordMatcher(), parallelism)
+
+	// 保存解析成功的简历，便于后续为候选人生成面试问题
+	var ids []string
+	for _, result := range shortlist.Results {
+		if result.Resume != nil {
+			result.Resume.OwnerID = user.ID
+			result.Resume.TeamID = user.TeamID
+			s.resumes.Put(result.ResumeID, result.Resume)
+			ids = append(ids, result.ResumeID)
+		}
+	}
+	auditReturned(c, ids)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "批量筛选完成",
+		"jdId":      jdID,
+		"shortlist": shortlist,
+	})
+}
+
+// candidateParser 返回解析批量筛选中单份简历的函数，AI调用的用量记在发起筛选的用户名下
+func (s *Server) candidateParser(user *auth.User, useAI bool) screening.ParseFunc {
+	return func(ctx context.Context, candidate screening.Candidate) (*models.Resume, error) {
+		if candidate.FilePath == "" {
+			return nil, fmt.Errorf("不支持的文件格式: %s", filepath.Ext(candidate.FileName))
+		}
+
+		text, err := s.parseFileText(ctx, candidate.FilePath)
+		if err != nil {
+			return nil, err
+		}
+
+		aiParser := s.newAIParser(useAI)
+		resume, err := aiParser.ParseResumeText(ctx, text)
+		for _, usage := range aiParser.Usage() {
+			s.recordUsage(user, "", "batchScreen", usage)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// 上传的文件在筛选结束后删除，简历不记录文件路径
+		resume.ID = newResourceID()
+		resume.CreatedAt = time.Now()
+		return resume, nil
+	}
+}
+
+// extractArchive 保存上传的zip压缩包并把其中的简历解压到上传目录的子目录中，最多解压maxFiles个文件
+// 配置了密钥时压缩包和解压出的文件都加密保存，解压时压缩包先解密到临时文件
+func (s *Server) extractArchive(header *multipart.FileHeader, subDir string, maxFiles int) ([]screening.Candidate, error) {
+	archivePath, err := s.storeUpload(header, subDir)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(archivePath)
+
+	plainPath, cleanup, err := s.keyring.OpenToTemp(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	return screening.ExtractZipWith(plainPath, maxFiles, s.cfg.MaxFileSize, func(name string, r io.Reader) (string, error) {
+		return s.saveFile(filepath.Join(s.cfg.UploadDir, subDir), name, r)
+	})
+}
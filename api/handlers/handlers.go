@@ -1,248 +1,478 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync/atomic"
+	"time"
 
 	"github.com/10yihang/resume-ai-interview/config"
 	"github.com/10yihang/resume-ai-interview/internal/ai"
+	"github.com/10yihang/resume-ai-interview/internal/audit"
+	"github.com/10yihang/resume-ai-interview/internal/auth"
+	"github.com/10yihang/resume-ai-interview/internal/encryption"
 	"github.com/10yihang/resume-ai-interview/internal/interview"
-	"github.com/10yihang/resume-ai-interview/internal/ocr"
-	"github.com/10yihang/resume-ai-interview/internal/parser"
+	"github.com/10yihang/resume-ai-interview/internal/jobs"
+	"github.com/10yihang/resume-ai-interview/internal/logging"
+	"github.com/10yihang/resume-ai-interview/internal/redact"
+	"github.com/10yihang/resume-ai-interview/internal/store"
+	"github.com/10yihang/resume-ai-interview/internal/tracing"
+	"github.com/10yihang/resume-ai-interview/internal/usage"
 	"github.com/10yihang/resume-ai-interview/models"
 	"github.com/gin-gonic/gin"
 )
 
-// 存储上传的文件和处理过的数据
-var (
-	resumeStore = make(map[string]*models.Resume)
-	jdStore     = make(map[string]*models.JobDescription)
-	questions   = make(map[string]*models.QuestionSet)
-	// 加载配置
-	cfg *config.Config
-)
+// Server 持有处理器依赖的配置、生成器和数据存储
+// 所有存储都是并发安全的，处理器可以被gin并发调用
+type Server struct {
+	cfg       *config.Config
+	generator ai.QuestionGeneratorInterface
+	evaluator interview.AnswerEvaluatorInterface
+
+	// 存储上传的文件和处理过的数据
+	resumes     *store.Store[*models.Resume]
+	jds         *store.Store[*models.JobDescription]
+	questions   *store.Store[*models.QuestionSet]
+	sessions    *store.Store[*models.Session]
+	evaluations *store.Store[*models.Evaluation]
+
+	jobQueue  *jobs.Queue
+	auth      *auth.Service
+	redaction *redact.Policy // 发送给外部AI服务前的个人信息遮蔽策略
+	auditLog  *audit.Log
+	keyring   *encryption.Keyring // 未配置密钥时为nil，文件以明文保存
+	usage     *usage.Ledger       // 大模型调用的用量和费用
+
+	// 按保留期限清理上传文件的后台任务
+	stopRetention context.CancelFunc
+	retentionDone chan struct{}
+
+	draining atomic.Bool // 开始停止后就绪探针返回503
+}
 
-// InitHandlers 初始化处理器
-func InitHandlers(_config *config.Config) {
-	cfg = _config
-	// 如果配置为空，创建默认配置
+// NewServer 创建处理器服务，cfg为空时使用默认配置
+func NewServer(cfg *config.Config, generator ai.QuestionGeneratorInterface, evaluator interview.AnswerEvaluatorInterface) (*Server, error) {
 	if cfg == nil {
 		cfg = config.NewConfig()
 	}
-}
 
-// IndexHandler 处理首页请求
-func IndexHandler(c *gin.Context) {
-	c.HTML(http.StatusOK, "index.html", gin.H{
-		"title": "AI简历面试助手",
-	})
-}
-
-// UploadResumeHandler 处理简历上传
-func UploadResumeHandler(c *gin.Context) {
-	// 获取上传的文件
-	file, header, err := c.Request.FormFile("resume")
+	// 配置了持久化时账号和令牌保存到数据目录
+	authPath := ""
+	if cfg.PersistData {
+		authPath = filepath.Join(cfg.DataDir, "auth.json")
+	}
+	authService, err := auth.NewService(authPath, time.Duration(cfg.SessionTTLHours)*time.Hour)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无法获取上传文件: " + err.Error()})
-		return
+		return nil, fmt.Errorf("初始化认证服务失败: %w", err)
 	}
-	defer file.Close()
 
-	// 创建上传目录
-	uploadDir := "./uploads/resumes"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建上传目录失败: " + err.Error()})
-		return
+	keyring, err := encryption.ParseKeyring(cfg.EncryptionKeys)
+	if err != nil {
+		return nil, fmt.Errorf("加载加密密钥失败: %w", err)
 	}
 
-	// 保存文件
-	filename := filepath.Join(uploadDir, header.Filename)
-	out, err := os.Create(filename)
+	// 配置了持久化时审计记录追加到数据目录，否则只输出到日志
+	auditPath := ""
+	if cfg.PersistData {
+		auditPath = filepath.Join(cfg.DataDir, "audit.jsonl")
+	} else {
+		slog.Warn("未开启数据持久化，审计日志只保存在内存和运行日志中，重启后无法查询")
+	}
+	auditLog, err := audit.NewLog(auditPath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败: " + err.Error()})
-		return
+		return nil, fmt.Errorf("初始化审计日志失败: %w", err)
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, file)
+	if cfg.BudgetAction != "" && cfg.BudgetAction != budgetDegrade && cfg.BudgetAction != budgetBlock {
+		return nil, fmt.Errorf("BUDGET_ACTION只能是%s或%s: %s", budgetDegrade, budgetBlock, cfg.BudgetAction)
+	}
+	prices, err := usage.ParsePrices(cfg.UsagePrices)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "复制文件失败: " + err.Error()})
-		return
+		return nil, fmt.Errorf("加载模型价格失败: %w", err)
+	}
+	usagePath := ""
+	if cfg.PersistData {
+		usagePath = filepath.Join(cfg.DataDir, "usage.jsonl")
+	}
+	ledger, err := usage.NewLedger(usagePath, prices, usage.Budget{UserDaily: cfg.UserDailyBudget, Session: cfg.SessionBudget})
+	if err != nil {
+		return nil, fmt.Errorf("初始化用量记录失败: %w", err)
+	}
+	// 价格表中没有的模型费用记为0，配置了预算时这些模型的调用不会触发预算
+	if cfg.UserDailyBudget > 0 || cfg.SessionBudget > 0 {
+		var models []string
+		for _, provider := range cfg.Providers() {
+			models = append(models, provider.Model, provider.ParseModel)
+		}
+		if unpriced := prices.Unpriced(models...); len(unpriced) > 0 {
+			slog.Warn("以下模型没有配置价格，调用费用记为0，不会触发预算，请通过USAGE_PRICES配置", "models", unpriced)
+		}
 	}
 
-	// 初始化OCR处理器
-	var ocrProcessor ocr.OCRProcessor
-	if cfg.UseOCR {
-		ocrProcessor = ocr.GetOCRProcessor(cfg.OCRAPIKey, cfg.TesseractPath)
+	s := &Server{
+		cfg:         cfg,
+		generator:   generator,
+		evaluator:   evaluator,
+		resumes:     store.New[*models.Resume](),
+		jds:         store.New[*models.JobDescription](),
+		questions:   store.New[*models.QuestionSet](),
+		sessions:    store.New[*models.Session](),
+		evaluations: store.New[*models.Evaluation](),
+		auth:        authService,
+		redaction:   redact.NewPolicy(cfg.RedactProviders),
+		auditLog:    auditLog,
+		keyring:     keyring,
+		usage:       ledger,
 	}
+	s.jobQueue = s.newJobQueue()
+	return s, nil
+}
 
-	// 创建文件解析器
-	fileParser := parser.NewResumeFileParser(ocrProcessor, cfg.UseOCR)
-	// 使用AI解析简历文件
-	aiParser := parser.NewAITextParser(cfg.APIKey, cfg.UseGrok, fileParser)
-	resume, err := aiParser.ParseResumeFile(filename)
-	if err != nil {
-		// 如果OCR失败，尝试使用传统方法解析
-		if cfg.UseOCR && err.Error() == "文件解析失败: OCR处理失败" {
-			// 创建不使用OCR的文件解析器
-			fileParser := parser.NewResumeFileParser(nil, false)
-			aiParser := parser.NewAITextParser(cfg.APIKey, cfg.UseGrok, fileParser)
-			resume, err = aiParser.ParseResumeFile(filename)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "简历解析失败: " + err.Error()})
-				return
-			}
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "简历解析失败: " + err.Error()})
-			return
+// Start 启动后台解析任务队列，并恢复重启前已完成任务的结果
+// 配置了保留期限时同时启动上传文件的定期清理
+func (s *Server) Start(ctx context.Context) error {
+	if err := s.jobQueue.Start(ctx); err != nil {
+		return err
+	}
+	s.restoreJobResults()
+	s.rotateKeys()
+	s.startRetention(ctx)
+	return nil
+}
+
+// rotateKeys 配置了多个密钥时，把用旧密钥加密的上传文件和任务记录改用当前密钥
+func (s *Server) rotateKeys() {
+	for _, dir := range []string{s.cfg.UploadDir, filepath.Join(s.cfg.DataDir, "jobs")} {
+		count, err := s.keyring.RewrapDir(dir)
+		if err != nil {
+			slog.Error("轮换加密密钥失败", "dir", dir, "error", err)
 		}
+		if count > 0 {
+			slog.Info("已改用新密钥加密文件", "dir", dir, "count", count, "key_id", s.keyring.Primary())
+		}
+	}
+}
+
+// Drain 把服务标记为停止中，就绪探针此后返回503，请求和任务照常处理
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
+// Shutdown 开始停止服务：就绪探针改为返回503，不再处理排队的解析任务并等待正在处理的任务完成
+// ctx结束时取消仍在处理的任务，OCR子进程随之结束；持久化的任务保持排队，重启后继续处理
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.Drain()
+	return s.jobQueue.Shutdown(ctx)
+}
+
+// Close 停止后台任务队列和文件清理，关闭审计日志
+func (s *Server) Close() {
+	s.jobQueue.Stop()
+	if s.stopRetention != nil {
+		s.stopRetention()
+		<-s.retentionDone
 	}
+	s.auditLog.Close()
+	s.usage.Close()
+}
 
-	// 保存解析后的简历
-	resumeID := header.Filename
-	resumeStore[resumeID] = resume
+// RegisterRoutes 注册所有路由，除首页、登录页、指标和健康探针外都需要登录并记录审计日志
+func (s *Server) RegisterRoutes(r gin.IRouter) {
+	r.Use(assignRequestID, traceRequests, logRequests, observeRequests)
+	r.GET("/", s.IndexHandler)
+	r.GET("/login", s.LoginPageHandler)
+	r.GET("/metrics", s.MetricsHandler)
+	r.GET("/healthz", s.HealthzHandler)
+	r.GET("/readyz", s.ReadyzHandler)
+
+	protected := r.Group("")
+	materials := requireRole(auth.RoleRecruiter, auth.RoleCandidate)
+	protected.POST("/upload/resume", s.audited("uploadResume", resourceResume), s.authenticate, materials, s.UploadResumeHandler)
+	protected.POST("/upload/jd", s.audited("uploadJD", resourceJD), s.authenticate, materials, s.UploadJDHandler)
+	protected.POST("/generate/questions", s.audited("generateQuestions", resourceQuestionSet), s.authenticate, materials, s.GenerateQuestionsHandler)
+	protected.POST("/evaluate/answer", s.audited("evaluateAnswer", resourceEvaluation), s.authenticate, s.EvaluateAnswerHandler)
+	protected.POST("/generate/questions/stream", s.audited("generateQuestionsStream", resourceQuestionSet), s.authenticate, materials, s.GenerateQuestionsStreamHandler)
+	protected.POST("/evaluate/answer/stream", s.audited("evaluateAnswerStream", resourceEvaluation), s.authenticate, s.EvaluateAnswerStreamHandler)
+	protected.GET("/jobs/:id", s.audited("getJob", resourceJob), s.authenticate, s.GetJobHandler)
+	protected.GET("/jobs/:id/events", s.audited("jobEvents", resourceJob), s.authenticate, s.JobEventsHandler)
+	protected.POST("/batch/screen", s.audited("batchScreen", resourceResume), s.authenticate, requireRole(auth.RoleRecruiter), s.BatchScreenHandler)
+
+	// 版本化的REST API
+	s.registerAPIRoutes(r)
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":  "简历上传成功",
-		"resumeId": resumeID,
-		"resume":   resume,
+// IndexHandler 处理首页请求，启用认证且未登录时跳转到登录页
+func (s *Server) IndexHandler(c *gin.Context) {
+	user := s.requestUser(c)
+	if user == nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	c.HTML(http.StatusOK, "index.html", gin.H{
+		"title":       "AI简历面试助手",
+		"authEnabled": s.cfg.AuthEnabled,
+		"user":        user,
 	})
 }
 
-// UploadJDHandler 处理JD上传
-func UploadJDHandler(c *gin.Context) {
+// UploadResumeHandler 处理简历上传，文件保存后交由后台任务解析
+func (s *Server) UploadResumeHandler(c *gin.Context) {
+	s.handleUpload(c, "resume", "resumes", jobKindResume, "简历上传成功，正在解析")
+}
+
+// UploadJDHandler 处理JD上传，文件保存后交由后台任务解析
+func (s *Server) UploadJDHandler(c *gin.Context) {
+	s.handleUpload(c, "jd", "jds", jobKindJD, "JD上传成功，正在解析")
+}
+
+// handleUpload 保存上传的文件并提交解析任务
+func (s *Server) handleUpload(c *gin.Context, field, subDir, jobKind, message string) {
 	// 获取上传的文件
-	file, header, err := c.Request.FormFile("jd")
+	header, err := c.FormFile(field)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无法获取上传文件: " + err.Error()})
 		return
 	}
-	defer file.Close()
 
-	// 创建上传目录
-	uploadDir := "./uploads/jds"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建上传目录失败: " + err.Error()})
+	filename, err := s.storeUpload(header, subDir)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 保存文件
-	filename := filepath.Join(uploadDir, header.Filename)
-	out, err := os.Create(filename)
+	// 交由后台任务进行OCR和AI解析
+	job, err := s.submitUpload(c, jobKind, header.Filename, filename)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败: " + err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, jobs.ErrQueueFull) {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"error": "提交解析任务失败: " + err.Error()})
 		return
 	}
-	defer out.Close()
+	auditResource(c, job.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": message,
+		"jobId":   job.ID,
+		"status":  job.Status,
+	})
+}
 
-	_, err = io.Copy(out, file)
+// submitUpload 提交上传文件的解析任务，任务和解析结果归属当前用户
+func (s *Server) submitUpload(c *gin.Context, jobKind, fileName, filePath string) (jobs.Job, error) {
+	user := currentUser(c)
+	return s.jobQueue.Submit(jobs.Job{
+		Kind:        jobKind,
+		FileName:    filepath.Base(fileName),
+		FilePath:    filePath,
+		OwnerID:     user.ID,
+		TeamID:      user.TeamID,
+		RequestID:   logging.RequestID(c.Request.Context()),
+		TraceParent: tracing.TraceParent(c.Request.Context()),
+	})
+}
+
+// storeUpload 将上传的文件保存到上传目录的子目录中，返回保存路径
+// 文件名加随机前缀，同名文件不会互相覆盖
+func (s *Server) storeUpload(header *multipart.FileHeader, subDir string) (string, error) {
+	file, err := header.Open()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "复制文件失败: " + err.Error()})
-		return
+		return "", fmt.Errorf("无法读取上传文件: %w", err)
 	}
+	defer file.Close()
 
-	// 初始化OCR处理器
-	var ocrProcessor ocr.OCRProcessor
-	if cfg.UseOCR {
-		ocrProcessor = ocr.GetOCRProcessor(cfg.OCRAPIKey, cfg.TesseractPath)
+	// 创建上传目录
+	uploadDir := filepath.Join(s.cfg.UploadDir, subDir)
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return "", fmt.Errorf("创建上传目录失败: %w", err)
 	}
 
-	// 创建文件解析器
-	fileParser := parser.NewResumeFileParser(ocrProcessor, cfg.UseOCR)
-	// 使用AI解析JD文件
-	aiParser := parser.NewAITextParser(cfg.APIKey, cfg.UseGrok, fileParser)
-	jd, err := aiParser.ParseJDFile(filename)
+	return s.saveFile(uploadDir, header.Filename, file)
+}
+
+// saveFile 将file的内容保存到dir中，返回保存路径
+// 文件名加随机前缀，同名文件不会互相覆盖；配置了密钥时加密后写入，磁盘上不会出现明文；
+// 超过MaxFileSize的文件报错，最多读取MaxFileSize+1字节
+func (s *Server) saveFile(dir, name string, file io.Reader) (string, error) {
+	out, err := os.CreateTemp(dir, "*-"+filepath.Base(name))
 	if err != nil {
-		// 如果OCR失败，尝试使用传统方法解析
-		if cfg.UseOCR && err.Error() == "文件解析失败: OCR处理失败" {
-			// 创建不使用OCR的文件解析器
-			fileParser := parser.NewResumeFileParser(nil, false)
-			aiParser := parser.NewAITextParser(cfg.APIKey, cfg.UseGrok, fileParser)
-			jd, err = aiParser.ParseJDFile(filename)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "JD解析失败: " + err.Error()})
-				return
-			}
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "JD解析失败: " + err.Error()})
-			return
+		return "", fmt.Errorf("保存文件失败: %w", err)
+	}
+
+	// 关闭文件后再交给后台任务读取，配置了密钥时加密后写入
+	limited := io.LimitReader(file, s.cfg.MaxFileSize+1)
+	if s.keyring.Enabled() {
+		err = s.writeSealed(out, limited)
+	} else {
+		var n int64
+		n, err = io.Copy(out, limited)
+		if err == nil && n > s.cfg.MaxFileSize {
+			err = fmt.Errorf("文件超过%d字节", s.cfg.MaxFileSize)
 		}
 	}
+	out.Close()
+	if err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("复制文件失败: %w", err)
+	}
+	return out.Name(), nil
+}
 
-	// 保存解析后的JD
-	jdID := header.Filename
-	jdStore[jdID] = jd
+// writeSealed 读取上传文件的全部内容，加密后写入out，超过MaxFileSize的文件报错
+// 由调用方限制读取的长度，避免把过大的文件读入内存
+func (s *Server) writeSealed(out io.Writer, file io.Reader) error {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > s.cfg.MaxFileSize {
+		return fmt.Errorf("文件超过%d字节", s.cfg.MaxFileSize)
+	}
+	sealed, err := s.keyring.Seal(data)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(sealed)
+	return err
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "JD上传成功",
-		"jdId":    jdID,
-		"jd":      jd,
-	})
+// questionRequest 生成面试问题的请求参数
+type questionRequest struct {
+	ResumeID string `json:"resumeId" binding:"required"`
+	JDID     string `json:"jdId" binding:"required"`
 }
 
 // GenerateQuestionsHandler 生成面试问题
-func GenerateQuestionsHandler(c *gin.Context) {
-	var request struct {
-		ResumeID string `json:"resumeId" binding:"required"`
-		JDID     string `json:"jdId" binding:"required"`
-	}
-
+func (s *Server) GenerateQuestionsHandler(c *gin.Context) {
+	var request questionRequest
 	if err := c.BindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
 		return
 	}
 
 	// 获取简历和JD
-	resume, ok := resumeStore[request.ResumeID]
+	resume, jd, ok := s.lookupResumeAndJD(c, request)
 	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "简历不存在"})
 		return
 	}
-	jd, ok := jdStore[request.JDID]
-	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "JD不存在"})
+
+	// 生成问题，超出预算时按配置降级或拒绝
+	generator, err := s.questionGenerator(c, "")
+	if err != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
 		return
 	}
-
-	// 生成问题
-	generator := ai.GetQuestionGenerator(cfg.APIKey, cfg.UseGrok)
-	questionSet, err := generator.GenerateQuestions(resume, jd)
+	auditModel(c, generator)
+	questionSet, err := generator.GenerateQuestions(c.Request.Context(), resume, jd)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成问题失败: " + err.Error()})
 		return
 	}
+	auditServedBy(c, questionSet.Provider, questionSet.Model)
+	s.recordUsage(currentUser(c), "", "generateQuestions", questionSet.Usage)
 
 	// 保存生成的问题
-	questionID := request.ResumeID + "_" + request.JDID
-	questions[questionID] = questionSet
+	questionSetID := s.saveQuestionSet(c, request, questionSet)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":   "问题生成成功",
-		"questions": questionSet,
+		"message":       "问题生成成功",
+		"questionSetId": questionSetID,
+		"questions":     questionSetView(c, questionSet),
 	})
 }
 
-// EvaluateAnswerHandler 评估面试回答
-func EvaluateAnswerHandler(c *gin.Context) {
-	var request struct {
-		QuestionSetID string        `json:"questionSetId" binding:"required"`
-		QuestionID    int           `json:"questionId" binding:"required"`
-		Answer        models.Answer `json:"answer" binding:"required"`
+// lookupResumeAndJD 获取请求中的简历和JD，不存在时写入404响应
+func (s *Server) lookupResumeAndJD(c *gin.Context, request questionRequest) (*models.Resume, *models.JobDescription, bool) {
+	resume, ok := s.resumeFor(c, request.ResumeID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "简历不存在"})
+		return nil, nil, false
 	}
+	jd, ok := s.jdFor(c, request.JDID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "JD不存在"})
+		return nil, nil, false
+	}
+	return resume, jd, true
+}
+
+// saveQuestionSet 保存问题集并返回其ID
+// 每次生成使用新的ID，同一份简历和JD重复生成时不会覆盖其他用户的问题集；
+// 问题集记录请求中的简历和JD ID，评估时据此找回JD
+func (s *Server) saveQuestionSet(c *gin.Context, request questionRequest, questionSet *models.QuestionSet) string {
+	user := currentUser(c)
+	questionSet.ID = newResourceID()
+	questionSet.ResumeID = request.ResumeID
+	questionSet.JDID = request.JDID
+	questionSet.OwnerID = user.ID
+	questionSet.TeamID = user.TeamID
+	questionSet.CreatedAt = time.Now()
+
+	s.questions.Put(questionSet.ID, questionSet)
+	auditResource(c, questionSet.ID)
+	return questionSet.ID
+}
+
+// answerRequest 评估回答的请求参数
+type answerRequest struct {
+	QuestionSetID string        `json:"questionSetId" binding:"required"`
+	QuestionID    int           `json:"questionId" binding:"required"`
+	Answer        models.Answer `json:"answer" binding:"required"`
+}
 
+// EvaluateAnswerHandler 评估面试回答
+func (s *Server) EvaluateAnswerHandler(c *gin.Context) {
+	var request answerRequest
 	if err := c.BindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
 		return
 	}
 
+	question, jd, ok := s.lookupQuestion(c, request)
+	if !ok {
+		return
+	}
+
+	// 评估回答，超出预算时按配置降级或拒绝
+	auditResource(c, request.QuestionSetID)
+	evaluator, err := s.answerEvaluator(c, "")
+	if err != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	auditModel(c, evaluator)
+	evaluation, err := evaluator.EvaluateAnswer(c.Request.Context(), question, request.Answer, jd)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "评估回答失败: " + err.Error()})
+		return
+	}
+	auditServedBy(c, evaluation.Provider, evaluation.Model)
+	s.recordUsage(currentUser(c), "", "evaluateAnswer", evaluation.Usage)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "回答评估成功",
+		"evaluation": evaluation,
+	})
+}
+
+// lookupQuestion 获取请求中的问题及其JD，不存在时写入错误响应
+func (s *Server) lookupQuestion(c *gin.Context, request answerRequest) (models.Question, *models.JobDescription, bool) {
 	// 获取问题集
-	questionSet, ok := questions[request.QuestionSetID]
+	questionSet, ok := s.questionSetFor(c, request.QuestionSetID)
 	if !ok {
 		c.JSON(http.StatusNotFound, gin.H{"error": "问题集不存在"})
-		return
+		return models.Question{}, nil, false
 	}
 
 	// 获取问题
@@ -258,27 +488,14 @@ func EvaluateAnswerHandler(c *gin.Context) {
 
 	if !found {
 		c.JSON(http.StatusNotFound, gin.H{"error": "问题不存在"})
-		return
+		return models.Question{}, nil, false
 	}
 
 	// 获取JD
-	jd, ok := jdStore[questionSet.JDID]
+	jd, ok := s.jds.Get(questionSet.JDID)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "JD数据不存在"})
-		return
-	}
-	// 评估回答
-	// 评估回答
-	evaluator := interview.GetAnswerEvaluator(cfg.APIKey, cfg.UseGrok)
-	evaluation, err := evaluator.EvaluateAnswer(question, request.Answer, jd)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "评估回答失败: " + err.Error()})
-		return
+		return models.Question{}, nil, false
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message":    "回答评估成功",
-		"evaluation": evaluation,
-	})
+	return question, jd, true
 }
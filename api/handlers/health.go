@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/ai"
+	"github.com/10yihang/resume-ai-interview/internal/health"
+	"github.com/10yihang/resume-ai-interview/internal/ocr"
+	"github.com/gin-gonic/gin"
+)
+
+// 依赖检查的超时时间，就绪探针只检查本地依赖，超时更短
+const (
+	readinessTimeout   = 2 * time.Second
+	diagnosticsTimeout = 5 * time.Second
+)
+
+// diagnostics 是诊断接口的响应体
+type diagnostics struct {
+	Version string            `json:"version" binding:"required"`
+	Status  health.Status     `json:"status" binding:"required" doc:"必需的检查都通过时为ok，只有非必需的检查失败时为degraded，否则为fail" enum:"ok,degraded,fail"`
+	Checks  []health.Result   `json:"checks"`
+	Config  map[string]string `json:"config" doc:"以环境变量名为键的当前配置，密钥已遮蔽"`
+}
+
+// localChecks 返回本地依赖的检查：存储目录、Tesseract及配置的语言包和pdftoppm
+// 引擎链中没有Tesseract时不检查本地OCR工具，引擎链中还有OCR.space时Tesseract不是必需的；
+// pdftoppm缺失时PDF仍可使用文本层解析，不是必需的
+func (s *Server) localChecks() []health.Check {
+	checks := []health.Check{{Name: "storage", Required: true, Run: s.checkStorage}}
+
+	engines := s.cfg.OCREngineOrder()
+	localOCR := s.cfg.UseOCR && slices.Contains(engines, ocr.EngineTesseract)
+	tesseract := health.Check{Name: "tesseract", Required: localOCR && !slices.Contains(engines, ocr.EngineOCRSpace)}
+	pdftoppm := health.Check{Name: "pdftoppm"}
+	if localOCR {
+		tesseract.Run = func(ctx context.Context) (map[string]any, error) {
+			engine := ocr.NewTesseractOCR(s.cfg.TesseractPath)
+			version, err := engine.Version(ctx)
+			if err != nil {
+				return nil, err
+			}
+			languages, err := engine.Languages(ctx)
+			if err != nil {
+				return map[string]any{"version": version}, err
+			}
+			details := map[string]any{"version": version, "languages": languages, "configuredLanguages": s.cfg.TesseractLangs}
+			var missing []string
+			for _, language := range strings.Split(s.cfg.TesseractLangs, "+") {
+				if !slices.Contains(languages, language) {
+					missing = append(missing, language)
+				}
+			}
+			if len(missing) > 0 {
+				return details, fmt.Errorf("未安装语言包%s", strings.Join(missing, "、"))
+			}
+			return details, nil
+		}
+		pdftoppm.Run = func(ctx context.Context) (map[string]any, error) {
+			version, err := ocr.PDFToPPMVersion(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"version": version}, nil
+		}
+	}
+	return append(checks, tesseract, pdftoppm)
+}
+
+// remoteChecks 返回外部服务的检查：大模型服务和OCR.space，未配置密钥时跳过
+// 大模型服务都不可用时无法生成问题和评估，列为必需；配置了多个服务时任一可用即可
+func (s *Server) remoteChecks() []health.Check {
+	providers := s.cfg.Providers()
+	llm := health.Check{Name: "ai_provider", Required: len(providers) > 0}
+	if len(providers) > 0 {
+		llm.Run = func(ctx context.Context) (map[string]any, error) {
+			var results []map[string]any
+			var errs []error
+			for _, provider := range providers {
+				result := map[string]any{"provider": provider.Name, "model": provider.Model, "baseUrl": ai.ProviderBaseURL(provider.Name)}
+				if err := ai.Ping(ctx, provider.Name, provider.APIKey); err != nil {
+					result["error"] = err.Error()
+					errs = append(errs, fmt.Errorf("%s: %w", provider.Name, err))
+				}
+				results = append(results, result)
+			}
+			details := map[string]any{"providers": results}
+			if len(errs) == len(providers) {
+				return details, errors.Join(errs...)
+			}
+			return details, nil
+		}
+	}
+
+	ocrSpace := health.Check{Name: "ocrspace"}
+	if key := s.cfg.OCRSpaceKey(); s.cfg.UseOCR && slices.Contains(s.cfg.OCREngineOrder(), ocr.EngineOCRSpace) {
+		ocrSpace.Run = func(ctx context.Context) (map[string]any, error) {
+			return nil, ocr.NewOCRSpaceAPI(key).Ping(ctx)
+		}
+	}
+	return []health.Check{llm, ocrSpace}
+}
+
+// checkStorage 检查上传目录可写，配置了持久化时还检查数据目录
+func (s *Server) checkStorage(ctx context.Context) (map[string]any, error) {
+	details := map[string]any{"uploadDir": s.cfg.UploadDir, "persistData": s.cfg.PersistData}
+	if err := health.CheckWritable(s.cfg.UploadDir); err != nil {
+		return details, err
+	}
+	if s.cfg.PersistData {
+		details["dataDir"] = s.cfg.DataDir
+		if err := health.CheckWritable(s.cfg.DataDir); err != nil {
+			return details, err
+		}
+	}
+	return details, nil
+}
+
+// HealthzHandler 存活探针，进程能处理请求就返回200
+func (s *Server) HealthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// ReadyzHandler 就绪探针，检查存储和本地OCR工具，必需的依赖不可用或服务正在停止时返回503
+// 不检查外部服务，避免外部服务抖动导致所有实例被摘除；探针无需登录，响应中不包含检查详情
+func (s *Server) ReadyzHandler(c *gin.Context) {
+	if s.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, health.Report{Status: health.StatusFail, Checks: []health.Result{
+			{Name: "shutdown", Status: health.StatusFail, Required: true, Error: "服务正在停止"},
+		}})
+		return
+	}
+
+	report := health.Run(c.Request.Context(), readinessTimeout, s.localChecks()...)
+	for i := range report.Checks {
+		report.Checks[i].Details = nil
+	}
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+// DiagnosticsHandler 检查全部依赖并返回遮蔽了密钥的当前配置
+func (s *Server) DiagnosticsHandler(c *gin.Context) {
+	checks := append(s.localChecks(), s.remoteChecks()...)
+	report := health.Run(c.Request.Context(), diagnosticsTimeout, checks...)
+	c.JSON(http.StatusOK, diagnostics{
+		Version: APIVersion,
+		Status:  report.Status,
+		Checks:  report.Checks,
+		Config:  s.cfg.Masked(),
+	})
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/ai"
+	"github.com/10yihang/resume-ai-interview/internal/audit"
+	"github.com/10yihang/resume-ai-interview/internal/auth"
+	"github.com/10yihang/resume-ai-interview/internal/jobs"
+	"github.com/10yihang/resume-ai-interview/internal/ocr"
+	"github.com/10yihang/resume-ai-interview/internal/parser"
+	"github.com/10yihang/resume-ai-interview/models"
+	"github.com/gin-gonic/gin"
+)
+
+// 后台任务类型
+const (
+	jobKindResume = "resume"
+	jobKindJD     = "jd"
+)
+
+// newJobQueue 创建处理上传文件解析的任务队列，配置了持久化时任务写入数据目录
+func (s *Server) newJobQueue() *jobs.Queue {
+	var store jobs.Store
+	if s.cfg.PersistData {
+		fileStore, err := jobs.NewFileStore(filepath.Join(s.cfg.DataDir, "jobs"))
+		if err != nil {
+			slog.Error("初始化任务存储失败，任务将不会持久化", "error", err)
+		} else {
+			store = fileStore.WithKeyring(s.keyring)
+		}
+	}
+
+	queue := jobs.NewQueue(s.cfg.JobWorkers, s.cfg.JobQueueSize, store)
+	queue.Register(jobKindResume, s.processResumeJob)
+	queue.Register(jobKindJD, s.processJDJob)
+	queue.OnDone(s.storeJobResult)
+	return queue
+}
+
+// storeJobResult 保存完成的解析任务的结果，以任务ID作为简历或JD的ID，避免同名文件互相覆盖
+// 在任务队列的锁内调用，处理期间已被删除的任务不会调用，删除的简历和JD不会被重新保存
+func (s *Server) storeJobResult(job jobs.Job, result any) {
+	switch result := result.(type) {
+	case *models.Resume:
+		s.resumes.Put(job.ResultID, result)
+	case *models.JobDescription:
+		s.jds.Put(job.ResultID, result)
+	}
+}
+
+// restoreJobResults 将已完成任务的解析结果重新载入内存
+// 原始文件已按保留期限删除的只恢复解析结果
+func (s *Server) restoreJobResults() {
+	for _, job := range s.jobQueue.List() {
+		if job.Status != jobs.StatusDone || len(job.Result) == 0 {
+			continue
+		}
+
+		switch job.Kind {
+		case jobKindResume:
+			var resume models.Resume
+			if err := json.Unmarshal(job.Result, &resume); err == nil {
+				resume.FilePath = existingFile(resume.FilePath)
+				s.resumes.Put(job.ResultID, &resume)
+			}
+		case jobKindJD:
+			var jd models.JobDescription
+			if err := json.Unmarshal(job.Result, &jd); err == nil {
+				jd.FilePath = existingFile(jd.FilePath)
+				s.jds.Put(job.ResultID, &jd)
+			}
+		}
+	}
+}
+
+// existingFile 文件存在时返回原路径，否则返回空字符串
+func existingFile(path string) string {
+	if path == "" {
+		return ""
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// parseFileText 提取文件文本，OCR失败时尝试使用传统方法解析
+// 加密保存的文件先解密到临时文件，解析完成后删除
+func (s *Server) parseFileText(ctx context.Context, filePath string) (string, error) {
+	filePath, cleanup, err := s.keyring.OpenToTemp(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	// 初始化OCR引擎链
+	var ocrProcessor ocr.OCRProcessor
+	if s.cfg.UseOCR {
+		ocrProcessor = parser.NewOCRChain(s.cfg)
+	}
+
+	text, err := parser.NewResumeFileParser(ocrProcessor, s.cfg.UseOCR).ParseFile(ctx, filePath)
+	var ocrErr *ocr.Error
+	if err != nil && s.cfg.UseOCR && errors.As(err, &ocrErr) {
+		// OCR失败时改用不使用OCR的文件解析器，仍然失败时保留OCR的错误
+		if fallback, fallbackErr := parser.NewResumeFileParser(nil, false).ParseFile(ctx, filePath); fallbackErr == nil {
+			text, err = fallback, nil
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("文件解析失败: %w", err)
+	}
+	return text, nil
+}
+
+// newAIParser 创建按配置的AI服务、模型和遮蔽策略解析文本的AI解析器，useAI为false时只保留原文
+func (s *Server) newAIParser(useAI bool) *parser.AITextParser {
+	aiParser := parser.NewAITextParser("", false, nil).WithRedaction(s.redaction).WithChunkTokens(s.cfg.ParseChunkTokens)
+	if useAI {
+		aiParser.WithProviders(s.cfg.Providers(), time.Duration(s.cfg.AITimeout)*time.Second)
+	}
+	return aiParser
+}
+
+// processResumeJob 解析上传的简历文件
+func (s *Server) processResumeJob(ctx context.Context, job jobs.Job, report func(jobs.Status)) (string, any, error) {
+	report(jobs.StatusOCR)
+	text, err := s.parseFileText(ctx, job.FilePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("简历解析失败: %w", err)
+	}
+
+	report(jobs.StatusParsing)
+	useAI, err := s.parseWithAI(ctx, job.OwnerID)
+	if err != nil {
+		return "", nil, fmt.Errorf("简历解析失败: %w", err)
+	}
+	aiParser := s.newAIParser(useAI)
+	resume, err := aiParser.ParseResumeText(ctx, text)
+	if err != nil {
+		return "", nil, fmt.Errorf("简历解析失败: %w", err)
+	}
+	resume.ID = job.ID
+	resume.OwnerID = job.OwnerID
+	resume.TeamID = job.TeamID
+	resume.FilePath = job.FilePath
+	resume.CreatedAt = time.Now()
+
+	s.recordParse(job, resourceResume, "parseResume", aiParser)
+	return resume.ID, resume, nil
+}
+
+// processJDJob 解析上传的JD文件
+func (s *Server) processJDJob(ctx context.Context, job jobs.Job, report func(jobs.Status)) (string, any, error) {
+	report(jobs.StatusOCR)
+	text, err := s.parseFileText(ctx, job.FilePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("JD解析失败: %w", err)
+	}
+
+	report(jobs.StatusParsing)
+	useAI, err := s.parseWithAI(ctx, job.OwnerID)
+	if err != nil {
+		return "", nil, fmt.Errorf("JD解析失败: %w", err)
+	}
+	aiParser := s.newAIParser(useAI)
+	jd, err := aiParser.ParseJDText(ctx, text)
+	if err != nil {
+		return "", nil, fmt.Errorf("JD解析失败: %w", err)
+	}
+	jd.ID = job.ID
+	jd.OwnerID = job.OwnerID
+	jd.TeamID = job.TeamID
+	jd.FilePath = job.FilePath
+	jd.CreatedAt = time.Now()
+
+	s.recordParse(job, resourceJD, "parseJD", aiParser)
+	return jd.ID, jd, nil
+}
+
+// recordParse 记录后台任务调用AI解析上传文件的审计日志和用量，执行者是提交任务的用户
+func (s *Server) recordParse(job jobs.Job, resourceType, operation string, aiParser *parser.AITextParser) {
+	for _, usage := range aiParser.Usage() {
+		s.recordUsage(&auth.User{ID: job.OwnerID, TeamID: job.TeamID}, "", operation, usage)
+	}
+
+	provider, model := ai.DescribeModel(aiParser)
+	s.auditLog.Record(audit.Entry{
+		ActorID:      job.OwnerID,
+		TeamID:       job.TeamID,
+		Action:       audit.ActionParse,
+		ResourceType: resourceType,
+		ResourceID:   job.ID,
+		Provider:     provider,
+		Model:        model,
+	})
+}
+
+// GetJobHandler 查询后台任务状态
+func (s *Server) GetJobHandler(c *gin.Context) {
+	job, ok := s.jobFor(c, c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
+
+// JobEventsHandler 以SSE推送后台任务的状态变化，任务结束后关闭连接
+func (s *Server) JobEventsHandler(c *gin.Context) {
+	if _, ok := s.jobFor(c, c.Param("id")); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+	updates, cancel, ok := s.jobQueue.Subscribe(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+	defer cancel()
+
+	startSSE(c)
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case job, ok := <-updates:
+			if !ok {
+				return
+			}
+			sendEvent(c, "status", job)
+		}
+	}
+}
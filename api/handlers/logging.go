@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/auth"
+	"github.com/10yihang/resume-ai-interview/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDHeader 是传入和返回请求ID的请求头
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength 是接受的客户端请求ID的最大长度
+const maxRequestIDLength = 64
+
+// assignRequestID 为请求分配请求ID并放入请求的context，后续日志都会带上该ID
+// 客户端或网关传入了合法的X-Request-ID时沿用，否则生成新的，并在响应头中返回
+func assignRequestID(c *gin.Context) {
+	id := c.GetHeader(requestIDHeader)
+	if !validRequestID(id) {
+		id = logging.NewRequestID()
+	}
+	c.Header(requestIDHeader, id)
+	c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+	c.Next()
+}
+
+// validRequestID 只接受长度有限的字母、数字和-_.，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// logRequests 请求结束后记录一条访问日志，服务端错误记为error级别
+func logRequests(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	status := c.Writer.Status()
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	attrs := []slog.Attr{
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+		slog.Int("status", status),
+		slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+		slog.String("client_ip", c.ClientIP()),
+	}
+	if value, ok := c.Get(userContextKey); ok {
+		if user, ok := value.(*auth.User); ok {
+			attrs = append(attrs, slog.String("user_id", user.ID))
+		}
+	}
+	if len(c.Errors) > 0 {
+		attrs = append(attrs, slog.String("error", c.Errors.String()))
+	}
+	slog.LogAttrs(c.Request.Context(), level, "请求完成", attrs...)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute 是没有匹配到路由的请求在指标中的路由标签
+const unmatchedRoute = "unmatched"
+
+// observeRequests 按路由模板统计请求数和耗时，路由模板不含ID，标签取值有限
+func observeRequests(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = unmatchedRoute
+	}
+	method := c.Request.Method
+	metrics.HTTPRequests.Inc(method, route, strconv.Itoa(c.Writer.Status()))
+	metrics.HTTPDuration.ObserveSince(start, method, route)
+}
+
+// MetricsHandler 以Prometheus文本格式导出运行指标
+func (s *Server) MetricsHandler(c *gin.Context) {
+	metrics.Default.Handler().ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/audit"
+	"github.com/10yihang/resume-ai-interview/internal/retention"
+	"github.com/10yihang/resume-ai-interview/models"
+	"github.com/gin-gonic/gin"
+)
+
+// retentionInterval 是按保留期限清理上传文件的间隔
+const retentionInterval = time.Hour
+
+// startRetention 配置了保留期限时启动后台清理，启动时立即清理一次
+func (s *Server) startRetention(ctx context.Context) {
+	if s.cfg.RetentionDays <= 0 {
+		return
+	}
+
+	ctx, s.stopRetention = context.WithCancel(ctx)
+	s.retentionDone = make(chan struct{})
+	go func() {
+		defer close(s.retentionDone)
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+		for {
+			s.purgeExpiredUploads(time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// purgeExpiredUploads 删除超过保留期限的上传文件，简历和JD只保留解析结果
+func (s *Server) purgeExpiredUploads(now time.Time) {
+	cutoff := now.AddDate(0, 0, -s.cfg.RetentionDays)
+	removed, err := retention.Sweep(s.cfg.UploadDir, cutoff)
+	if err != nil {
+		slog.Error("清理过期上传文件失败", "error", err)
+	}
+	if len(removed) == 0 {
+		return
+	}
+
+	owners := make(map[string]audit.Entry, len(removed))
+	for _, path := range removed {
+		owners[filepath.Clean(path)] = audit.Entry{ResourceType: resourceFile, ResourceID: filepath.Base(path)}
+	}
+	for _, resume := range s.resumes.Values() {
+		if entry, ok := owners[filepath.Clean(resume.FilePath)]; ok && resume.FilePath != "" {
+			s.resumes.Update(resume.ID, func(old *models.Resume) *models.Resume {
+				updated := *old
+				updated.FilePath = ""
+				return &updated
+			})
+			entry.ResourceType, entry.ResourceID, entry.TeamID = resourceResume, resume.ID, resume.TeamID
+			owners[filepath.Clean(resume.FilePath)] = entry
+		}
+	}
+	for _, jd := range s.jds.Values() {
+		if entry, ok := owners[filepath.Clean(jd.FilePath)]; ok && jd.FilePath != "" {
+			s.jds.Update(jd.ID, func(old *models.JobDescription) *models.JobDescription {
+				updated := *old
+				updated.FilePath = ""
+				return &updated
+			})
+			entry.ResourceType, entry.ResourceID, entry.TeamID = resourceJD, jd.ID, jd.TeamID
+			owners[filepath.Clean(jd.FilePath)] = entry
+		}
+	}
+
+	detail := fmt.Sprintf("超过%d天保留期限，删除上传的原始文件", s.cfg.RetentionDays)
+	for _, entry := range owners {
+		entry.Time = now
+		entry.ActorID = audit.SystemActor
+		entry.Action = audit.ActionPurge
+		entry.Detail = detail
+		s.auditLog.Record(entry)
+	}
+	slog.Info("已删除超过保留期限的上传文件", "count", len(removed))
+}
+
+// forgetResult 是删除候选人数据的结果
+type forgetResult struct {
+	ResumeIDs      []string `json:"resumeIds" binding:"required" doc:"删除的简历，包括同一邮箱的其他简历"`
+	QuestionSetIDs []string `json:"questionSetIds" binding:"required" doc:"删除的问题集"`
+	SessionIDs     []string `json:"sessionIds" binding:"required" doc:"删除的面试会话"`
+	EvaluationIDs  []string `json:"evaluationIds" binding:"required" doc:"删除的评估"`
+	FilesDeleted   int      `json:"filesDeleted" doc:"删除的上传文件数"`
+}
+
+// ForgetCandidateHandler 删除候选人的所有数据：简历和上传的文件、解析任务、问题集、面试会话和评估
+// 同一邮箱的其他简历视为同一候选人一并删除，每项删除都记录审计日志
+func (s *Server) ForgetCandidateHandler(c *gin.Context) {
+	resume, ok := s.resumeFor(c, c.Param("id"))
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "简历不存在")
+		return
+	}
+	c.JSON(http.StatusOK, s.forgetCandidate(c, resume))
+}
+
+// forgetCandidate 删除当前用户可访问的、属于resume对应候选人的所有数据
+func (s *Server) forgetCandidate(c *gin.Context, resume *models.Resume) forgetResult {
+	user := currentUser(c)
+	result := forgetResult{
+		ResumeIDs:      []string{},
+		QuestionSetIDs: []string{},
+		SessionIDs:     []string{},
+		EvaluationIDs:  []string{},
+	}
+
+	// 候选人的所有简历
+	for _, r := range s.resumes.Values() {
+		sameCandidate := r.ID == resume.ID ||
+			(resume.Email != "" && strings.EqualFold(strings.TrimSpace(r.Email), strings.TrimSpace(resume.Email)))
+		if !sameCandidate || !user.CanAccess(r.OwnerID, r.TeamID) {
+			continue
+		}
+		fileExists := r.FilePath != "" && existingFile(r.FilePath) != ""
+		s.deleteUpload(r.ID, r.FilePath)
+		if !s.resumes.Delete(r.ID) {
+			continue
+		}
+		if fileExists {
+			result.FilesDeleted++
+		}
+		result.ResumeIDs = append(result.ResumeIDs, r.ID)
+		s.recordDeletion(c, audit.ActionForget, resourceResume, r.ID)
+	}
+
+	// 基于这些简历生成的问题集
+	for _, questionSet := range s.questions.Values() {
+		if !slices.Contains(result.ResumeIDs, questionSet.ResumeID) || !user.CanAccess(questionSet.OwnerID, questionSet.TeamID) {
+			continue
+		}
+		if s.questions.Delete(questionSet.ID) {
+			result.QuestionSetIDs = append(result.QuestionSetIDs, questionSet.ID)
+			s.recordDeletion(c, audit.ActionForget, resourceQuestionSet, questionSet.ID)
+		}
+	}
+
+	// 候选人的面试会话
+	for _, session := range s.sessions.Values() {
+		related := slices.Contains(result.ResumeIDs, session.ResumeID) || slices.Contains(result.QuestionSetIDs, session.QuestionSetID)
+		if !related || !sessionVisible(user, session) {
+			continue
+		}
+		if s.sessions.Delete(session.ID) {
+			result.SessionIDs = append(result.SessionIDs, session.ID)
+			s.recordDeletion(c, audit.ActionForget, resourceSession, session.ID)
+		}
+	}
+
+	// 会话中和针对这些问题集的评估
+	for _, evaluation := range s.evaluations.Values() {
+		related := slices.Contains(result.SessionIDs, evaluation.SessionID) || slices.Contains(result.QuestionSetIDs, evaluation.QuestionSetID)
+		if !related || !user.CanAccess(evaluation.OwnerID, evaluation.TeamID) {
+			continue
+		}
+		if s.evaluations.Delete(evaluation.ID) {
+			result.EvaluationIDs = append(result.EvaluationIDs, evaluation.ID)
+			s.recordDeletion(c, audit.ActionForget, resourceEvaluation, evaluation.ID)
+		}
+	}
+	return result
+}
@@ -0,0 +1,1226 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/config"
+	"github.com/10yihang/resume-ai-interview/internal/ai"
+	"github.com/10yihang/resume-ai-interview/internal/audit"
+	"github.com/10yihang/resume-ai-interview/internal/auth"
+	"github.com/10yihang/resume-ai-interview/internal/encryption"
+	"github.com/10yihang/resume-ai-interview/internal/health"
+	"github.com/10yihang/resume-ai-interview/internal/interview"
+	"github.com/10yihang/resume-ai-interview/internal/jobs"
+	"github.com/10yihang/resume-ai-interview/internal/logging"
+	"github.com/10yihang/resume-ai-interview/internal/metrics"
+	"github.com/10yihang/resume-ai-interview/internal/usage"
+	"github.com/10yihang/resume-ai-interview/models"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTestServer 创建使用模拟生成器和评估器、上传到临时目录的测试服务
+// configure可以在创建服务前修改配置
+func newTestServer(t *testing.T, configure ...func(*config.Config)) (*Server, http.Handler) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		MaxFileSize:      10 * 1024 * 1024,
+		DataDir:          t.TempDir(),
+		UploadDir:        t.TempDir(),
+		JobWorkers:       4,
+		JobQueueSize:     100,
+		BatchParallelism: 4,
+		BatchMaxFiles:    100,
+	}
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	s, err := NewServer(cfg, ai.NewMockQuestionGenerator(), interview.NewMockAnswerEvaluator())
+	if err != nil {
+		t.Fatalf("创建服务失败: %v", err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("启动服务失败: %v", err)
+	}
+	t.Cleanup(s.Close)
+
+	r := gin.New()
+	s.RegisterRoutes(r)
+	return s, r
+}
+
+// upload 上传文本文件并等待解析任务结束，返回解析结果ID
+func upload(t *testing.T, s *Server, h http.Handler, path, field, name, content string) string {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile(field, name)
+	if err != nil {
+		t.Errorf("创建表单失败: %v", err)
+		return ""
+	}
+	fw.Write([]byte(content))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("上传%s返回%d: %s", name, w.Code, w.Body.String())
+		return ""
+	}
+
+	// 旧接口返回jobId，v1接口直接返回任务
+	var resp struct {
+		JobID string `json:"jobId"`
+		ID    string `json:"id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.JobID == "" {
+		resp.JobID = resp.ID
+	}
+
+	updates, cancel, ok := s.jobQueue.Subscribe(resp.JobID)
+	if !ok {
+		t.Errorf("任务%s不存在", resp.JobID)
+		return ""
+	}
+	defer cancel()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case job, ok := <-updates:
+			if !ok {
+				t.Errorf("任务%s未结束即关闭", resp.JobID)
+				return ""
+			}
+			if job.Status == jobs.StatusFailed {
+				t.Errorf("任务%s失败: %s", resp.JobID, job.Error)
+				return ""
+			}
+			if job.Status == jobs.StatusDone {
+				return job.ResultID
+			}
+		case <-timeout:
+			t.Errorf("等待任务%s超时", resp.JobID)
+			return ""
+		}
+	}
+}
+
+// postJSON 发送JSON请求并返回响应
+func postJSON(h http.Handler, path string, payload any) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(payload)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// TestServerConcurrentRequests 并发执行上传、生成和评估，配合 go test -race 检查数据竞争
+func TestServerConcurrentRequests(t *testing.T) {
+	s, h := newTestServer(t)
+
+	const candidates = 8
+	var wg sync.WaitGroup
+	for i := 0; i < candidates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			resumeID := upload(t, s, h, "/upload/resume", "resume", fmt.Sprintf("resume%d.txt", i), "张三\nGo语言开发，熟悉Gin和MySQL")
+			jdID := upload(t, s, h, "/upload/jd", "jd", fmt.Sprintf("jd%d.txt", i), "Go后端工程师\n要求：熟悉Go语言和MySQL")
+			if resumeID == "" || jdID == "" {
+				return
+			}
+
+			path := "/generate/questions"
+			if i%2 == 1 {
+				path = "/generate/questions/stream"
+			}
+			w := postJSON(h, path, gin.H{"resumeId": resumeID, "jdId": jdID})
+			if w.Code != http.StatusOK {
+				t.Errorf("生成问题返回%d: %s", w.Code, w.Body.String())
+				return
+			}
+
+			match := regexp.MustCompile(`"questionSetId":"([^"]+)"`).FindStringSubmatch(w.Body.String())
+			if match == nil {
+				t.Errorf("响应中缺少questionSetId: %s", w.Body.String())
+				return
+			}
+			questionSetID := match[1]
+			questionSet, ok := s.questions.Get(questionSetID)
+			if !ok || len(questionSet.Questions) == 0 {
+				t.Errorf("问题集%s未保存", questionSetID)
+				return
+			}
+			if questionSet.JDID != jdID {
+				t.Errorf("问题集JD ID = %q，期望 %q", questionSet.JDID, jdID)
+			}
+
+			// 同一问题集的多个回答并发评估
+			var evalWG sync.WaitGroup
+			for j, q := range questionSet.Questions {
+				evalWG.Add(1)
+				go func(j, questionID int) {
+					defer evalWG.Done()
+
+					path := "/evaluate/answer"
+					if j%2 == 1 {
+						path = "/evaluate/answer/stream"
+					}
+					w := postJSON(h, path, gin.H{
+						"questionSetId": questionSetID,
+						"questionId":    questionID,
+						"answer":        gin.H{"questionId": questionID, "content": "我使用Go和MySQL实现了高并发服务"},
+					})
+					if w.Code != http.StatusOK {
+						t.Errorf("评估回答返回%d: %s", w.Code, w.Body.String())
+						return
+					}
+					if strings.Contains(w.Body.String(), "event:error") {
+						t.Errorf("评估回答失败: %s", w.Body.String())
+					}
+				}(j, q.ID)
+			}
+			evalWG.Wait()
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(s.resumes.Values()); got != candidates {
+		t.Fatalf("保存的简历数 = %d，期望 %d", got, candidates)
+	}
+	if got := len(s.questions.Values()); got != candidates {
+		t.Fatalf("保存的问题集数 = %d，期望 %d", got, candidates)
+	}
+}
+
+// TestServerMissingResources 请求不存在的简历或问题集时返回404
+func TestServerMissingResources(t *testing.T) {
+	_, h := newTestServer(t)
+
+	if w := postJSON(h, "/generate/questions", gin.H{"resumeId": "none", "jdId": "none"}); w.Code != http.StatusNotFound {
+		t.Fatalf("生成问题返回%d，期望404", w.Code)
+	}
+	w := postJSON(h, "/evaluate/answer/stream", gin.H{
+		"questionSetId": "none",
+		"questionId":    1,
+		"answer":        gin.H{"questionId": 1, "content": "回答"},
+	})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("评估回答返回%d，期望404", w.Code)
+	}
+}
+
+// doJSON 发送请求并将响应解析到out
+func doJSON(t *testing.T, h http.Handler, method, path string, payload any, out any) int {
+	t.Helper()
+
+	var body bytes.Buffer
+	if payload != nil {
+		json.NewEncoder(&body).Encode(payload)
+	}
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if out != nil && w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s 响应不是JSON: %s", method, path, w.Body.String())
+		}
+	}
+	return w.Code
+}
+
+// TestAPIV1Lifecycle 走通v1接口的上传、生成、会话、评估和删除流程
+func TestAPIV1Lifecycle(t *testing.T) {
+	s, h := newTestServer(t)
+
+	resumeID := upload(t, s, h, "/api/v1/resumes", "file", "resume.txt", "李四\nGo语言开发")
+	jdID := upload(t, s, h, "/api/v1/jds", "file", "jd.txt", "Go工程师\n要求：Go语言")
+	if resumeID == "" || jdID == "" {
+		t.FailNow()
+	}
+
+	var resume models.Resume
+	if code := doJSON(t, h, http.MethodGet, "/api/v1/resumes/"+resumeID, nil, &resume); code != http.StatusOK || resume.ID != resumeID {
+		t.Fatalf("获取简历返回%d，ID=%q", code, resume.ID)
+	}
+
+	resume.Name = "李四（已修正）"
+	var updated models.Resume
+	if code := doJSON(t, h, http.MethodPut, "/api/v1/resumes/"+resumeID, resume, &updated); code != http.StatusOK || updated.Name != resume.Name || updated.FilePath == "" {
+		t.Fatalf("修改简历返回%d: %+v", code, updated)
+	}
+
+	var questionSet models.QuestionSet
+	if code := doJSON(t, h, http.MethodPost, "/api/v1/question-sets", gin.H{"resumeId": resumeID, "jdId": jdID}, &questionSet); code != http.StatusCreated {
+		t.Fatalf("生成问题集返回%d", code)
+	}
+
+	var session models.Session
+	if code := doJSON(t, h, http.MethodPost, "/api/v1/sessions", gin.H{"questionSetId": questionSet.ID}, &session); code != http.StatusCreated {
+		t.Fatalf("创建会话返回%d", code)
+	}
+
+	for _, q := range questionSet.Questions {
+		var evaluation models.Evaluation
+		code := doJSON(t, h, http.MethodPost, "/api/v1/evaluations", gin.H{"sessionId": session.ID, "questionId": q.ID, "answer": "我做过Go项目"}, &evaluation)
+		if code != http.StatusCreated || evaluation.SessionID != session.ID {
+			t.Fatalf("评估回答返回%d: %+v", code, evaluation)
+		}
+	}
+
+	var list struct {
+		Items    []models.Evaluation `json:"items"`
+		Total    int                 `json:"total"`
+		PageSize int                 `json:"pageSize"`
+	}
+	if code := doJSON(t, h, http.MethodGet, "/api/v1/evaluations?sessionId="+session.ID+"&pageSize=2", nil, &list); code != http.StatusOK {
+		t.Fatalf("列出评估返回%d", code)
+	}
+	if list.Total != len(questionSet.Questions) || len(list.Items) != 2 || list.PageSize != 2 {
+		t.Fatalf("分页结果不正确: total=%d items=%d", list.Total, len(list.Items))
+	}
+
+	if code := doJSON(t, h, http.MethodPatch, "/api/v1/sessions/"+session.ID, gin.H{"status": "completed"}, &session); code != http.StatusOK || session.Status != models.SessionCompleted {
+		t.Fatalf("结束会话返回%d，状态=%q", code, session.Status)
+	}
+	if len(session.EvaluationIDs) != len(questionSet.Questions) {
+		t.Fatalf("会话记录了%d个评估，期望%d", len(session.EvaluationIDs), len(questionSet.Questions))
+	}
+
+	// 已结束的会话不能再提交评估
+	var errResp errorResponse
+	if code := doJSON(t, h, http.MethodPost, "/api/v1/evaluations", gin.H{"sessionId": session.ID, "questionId": 1, "answer": "补充"}, &errResp); code != http.StatusBadRequest || errResp.Error.Code != codeInvalidRequest {
+		t.Fatalf("向已结束会话提交评估返回%d: %+v", code, errResp)
+	}
+
+	// 删除会话时一并删除其评估
+	if code := doJSON(t, h, http.MethodDelete, "/api/v1/sessions/"+session.ID, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("删除会话返回%d", code)
+	}
+	if got := len(s.evaluations.Values()); got != 0 {
+		t.Fatalf("删除会话后剩余%d个评估", got)
+	}
+
+	if code := doJSON(t, h, http.MethodDelete, "/api/v1/resumes/"+resumeID, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("删除简历返回%d", code)
+	}
+	if code := doJSON(t, h, http.MethodGet, "/api/v1/resumes/"+resumeID, nil, &errResp); code != http.StatusNotFound || errResp.Error.Code != codeNotFound {
+		t.Fatalf("获取已删除简历返回%d: %+v", code, errResp)
+	}
+	if _, ok := s.jobQueue.Get(resumeID); ok {
+		t.Fatalf("删除简历后解析任务仍然存在")
+	}
+}
+
+// TestAPIV1Errors 检查参数错误时返回统一的错误格式
+func TestAPIV1Errors(t *testing.T) {
+	_, h := newTestServer(t)
+
+	tests := []struct {
+		method, path string
+		payload      any
+		status       int
+		code         string
+	}{
+		{http.MethodGet, "/api/v1/jds/none", nil, http.StatusNotFound, codeNotFound},
+		{http.MethodGet, "/api/v1/resumes?page=0", nil, http.StatusBadRequest, codeInvalidRequest},
+		{http.MethodGet, "/api/v1/resumes?pageSize=1000", nil, http.StatusBadRequest, codeInvalidRequest},
+		{http.MethodPost, "/api/v1/question-sets", gin.H{"resumeId": "x"}, http.StatusBadRequest, codeInvalidRequest},
+		{http.MethodPost, "/api/v1/evaluations", gin.H{"questionId": 1, "answer": "a"}, http.StatusBadRequest, codeInvalidRequest},
+		{http.MethodPatch, "/api/v1/sessions/none", gin.H{"status": "paused"}, http.StatusBadRequest, codeInvalidRequest},
+	}
+	for _, tt := range tests {
+		var resp errorResponse
+		code := doJSON(t, h, tt.method, tt.path, tt.payload, &resp)
+		if code != tt.status || resp.Error.Code != tt.code || resp.Error.Message == "" {
+			t.Errorf("%s %s 返回%d %+v，期望%d %s", tt.method, tt.path, code, resp, tt.status, tt.code)
+		}
+	}
+}
+
+// TestOpenAPIDocumentCoversRoutes 检查OpenAPI文档包含路由表中的每个接口
+func TestOpenAPIDocumentCoversRoutes(t *testing.T) {
+	s, h := newTestServer(t)
+
+	var doc struct {
+		OpenAPI string                               `json:"openapi"`
+		Paths   map[string]map[string]map[string]any `json:"paths"`
+	}
+	if code := doJSON(t, h, http.MethodGet, "/api/v1/openapi.json", nil, &doc); code != http.StatusOK {
+		t.Fatalf("获取OpenAPI文档返回%d", code)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("OpenAPI版本 = %q", doc.OpenAPI)
+	}
+
+	for _, route := range s.apiRoutes() {
+		path := "/api/v1" + strings.ReplaceAll(route.Path, ":id", "{id}")
+		if _, ok := doc.Paths[path][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("文档缺少 %s %s", route.Method, path)
+		}
+	}
+}
+
+// withToken 为每个请求加上API令牌
+func withToken(h http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+token)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// registerUser 通过接口以role角色注册用户，用登录Cookie创建API令牌并返回令牌原文
+func registerUser(t *testing.T, h http.Handler, email, inviteCode, role string) string {
+	t.Helper()
+
+	w := postJSON(h, "/api/v1/auth/register", gin.H{"email": email, "password": "password123", "inviteCode": inviteCode, "role": role})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("注册%s返回%d: %s", email, w.Code, w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) == 0 || cookies[0].Name != sessionCookie {
+		t.Fatalf("注册后没有设置登录Cookie")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/tokens", strings.NewReader(`{"name":"test"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var token createdToken
+	if err := json.Unmarshal(w.Body.Bytes(), &token); w.Code != http.StatusCreated || err != nil || token.Secret == "" {
+		t.Fatalf("创建令牌返回%d: %s", w.Code, w.Body.String())
+	}
+	return token.Secret
+}
+
+// TestAuthScopesDataByTeam 检查启用认证后未登录的请求被拒绝，不同团队看不到彼此的数据
+func TestAuthScopesDataByTeam(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *config.Config) {
+		cfg.AuthEnabled = true
+		cfg.AllowSignup = true
+	})
+
+	var resp errorResponse
+	if code := doJSON(t, h, http.MethodGet, "/api/v1/resumes", nil, &resp); code != http.StatusUnauthorized || resp.Error.Code != codeUnauthorized {
+		t.Fatalf("未登录访问返回%d %+v", code, resp)
+	}
+	if w := postJSON(h, "/generate/questions", gin.H{"resumeId": "x", "jdId": "y"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("未登录访问旧接口返回%d", w.Code)
+	}
+	if code := doJSON(t, h, http.MethodGet, "/", nil, nil); code != http.StatusFound {
+		t.Fatalf("未登录访问首页返回%d，期望跳转到登录页", code)
+	}
+
+	alice := withToken(h, registerUser(t, h, "alice@example.com", "", ""))
+	bob := withToken(h, registerUser(t, h, "bob@example.com", "", ""))
+
+	var invite createdInvite
+	if code := doJSON(t, alice, http.MethodPost, "/api/v1/team/invites", gin.H{"role": "recruiter"}, &invite); code != http.StatusCreated || invite.Code == "" {
+		t.Fatalf("创建邀请码返回%d", code)
+	}
+	carol := withToken(h, registerUser(t, h, "carol@example.com", invite.Code, ""))
+
+	resumeID := upload(t, s, alice, "/api/v1/resumes", "file", "resume.txt", "张三\nGo语言开发")
+	if resumeID == "" {
+		t.FailNow()
+	}
+
+	if code := doJSON(t, alice, http.MethodGet, "/api/v1/resumes/"+resumeID, nil, nil); code != http.StatusOK {
+		t.Fatalf("上传者获取简历返回%d", code)
+	}
+	if code := doJSON(t, carol, http.MethodGet, "/api/v1/resumes/"+resumeID, nil, nil); code != http.StatusOK {
+		t.Fatalf("同团队成员获取简历返回%d", code)
+	}
+	if code := doJSON(t, bob, http.MethodGet, "/api/v1/resumes/"+resumeID, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("其他团队获取简历返回%d，期望404", code)
+	}
+	if code := doJSON(t, bob, http.MethodGet, "/api/v1/jobs/"+resumeID, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("其他团队查询解析任务返回%d，期望404", code)
+	}
+	if code := doJSON(t, bob, http.MethodDelete, "/api/v1/resumes/"+resumeID, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("其他团队删除简历返回%d，期望404", code)
+	}
+
+	var list page[models.Resume]
+	if code := doJSON(t, bob, http.MethodGet, "/api/v1/resumes", nil, &list); code != http.StatusOK || list.Total != 0 {
+		t.Fatalf("其他团队的简历列表返回%d，共%d条", code, list.Total)
+	}
+	if code := doJSON(t, carol, http.MethodGet, "/api/v1/resumes", nil, &list); code != http.StatusOK || list.Total != 1 {
+		t.Fatalf("同团队的简历列表返回%d，共%d条", code, list.Total)
+	}
+}
+
+// TestRolesRestrictAccess 检查面试官只能看到分配给自己的会话，候选人看不到参考答案和评分要点
+func TestRolesRestrictAccess(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *config.Config) {
+		cfg.AuthEnabled = true
+		cfg.AllowSignup = true
+	})
+
+	recruiter := withToken(h, registerUser(t, h, "alice@example.com", "", ""))
+	inviteInterviewer := func(email string) (http.Handler, string) {
+		var invite createdInvite
+		if code := doJSON(t, recruiter, http.MethodPost, "/api/v1/team/invites", gin.H{"role": "interviewer"}, &invite); code != http.StatusCreated {
+			t.Fatalf("创建邀请码返回%d", code)
+		}
+		interviewer := withToken(h, registerUser(t, h, email, invite.Code, ""))
+		var me auth.User
+		if code := doJSON(t, interviewer, http.MethodGet, "/api/v1/auth/me", nil, &me); code != http.StatusOK || me.Role != auth.RoleInterviewer {
+			t.Fatalf("受邀用户的角色不正确: %d %+v", code, me)
+		}
+		return interviewer, me.ID
+	}
+	bob, bobID := inviteInterviewer("bob@example.com")
+	erin, _ := inviteInterviewer("erin@example.com")
+
+	resumeID := upload(t, s, recruiter, "/api/v1/resumes", "file", "resume.txt", "张三\nGo语言开发")
+	jdID := upload(t, s, recruiter, "/api/v1/jds", "file", "jd.txt", "Go工程师\n要求：Go语言")
+	if resumeID == "" || jdID == "" {
+		t.FailNow()
+	}
+	var qs models.QuestionSet
+	if code := doJSON(t, recruiter, http.MethodPost, "/api/v1/question-sets", gin.H{"resumeId": resumeID, "jdId": jdID}, &qs); code != http.StatusCreated {
+		t.Fatalf("生成问题集返回%d", code)
+	}
+
+	var resp errorResponse
+	if code := doJSON(t, bob, http.MethodPost, "/api/v1/question-sets", gin.H{"resumeId": resumeID, "jdId": jdID}, &resp); code != http.StatusForbidden || resp.Error.Code != codeForbidden {
+		t.Fatalf("面试官生成问题集返回%d %+v，期望403", code, resp)
+	}
+	if code := doJSON(t, bob, http.MethodPost, "/api/v1/team/invites", gin.H{"role": "recruiter"}, nil); code != http.StatusForbidden {
+		t.Fatalf("面试官创建邀请码返回%d，期望403", code)
+	}
+
+	var assigned, unassigned models.Session
+	if code := doJSON(t, recruiter, http.MethodPost, "/api/v1/sessions", gin.H{"questionSetId": qs.ID, "interviewerId": bobID}, &assigned); code != http.StatusCreated || assigned.InterviewerID != bobID {
+		t.Fatalf("创建分配给面试官的会话返回%d %+v", code, assigned)
+	}
+	if code := doJSON(t, recruiter, http.MethodPost, "/api/v1/sessions", gin.H{"questionSetId": qs.ID}, &unassigned); code != http.StatusCreated {
+		t.Fatalf("创建会话返回%d", code)
+	}
+
+	var sessions page[models.Session]
+	if code := doJSON(t, bob, http.MethodGet, "/api/v1/sessions", nil, &sessions); code != http.StatusOK || sessions.Total != 1 || sessions.Items[0].ID != assigned.ID {
+		t.Fatalf("面试官的会话列表返回%d %+v", code, sessions)
+	}
+	if code := doJSON(t, erin, http.MethodGet, "/api/v1/sessions/"+assigned.ID, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("未分配的面试官获取会话返回%d，期望404", code)
+	}
+	if code := doJSON(t, bob, http.MethodGet, "/api/v1/sessions/"+unassigned.ID, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("面试官获取未分配的会话返回%d，期望404", code)
+	}
+
+	var evaluation models.Evaluation
+	if code := doJSON(t, bob, http.MethodPost, "/api/v1/evaluations", gin.H{"sessionId": assigned.ID, "questionId": 1, "answer": "我熟悉Go"}, &evaluation); code != http.StatusCreated {
+		t.Fatalf("面试官记录评估返回%d", code)
+	}
+	if code := doJSON(t, recruiter, http.MethodGet, "/api/v1/evaluations/"+evaluation.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("招聘者获取评估返回%d", code)
+	}
+	if code := doJSON(t, erin, http.MethodGet, "/api/v1/evaluations/"+evaluation.ID, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("未分配的面试官获取评估返回%d，期望404", code)
+	}
+	if code := doJSON(t, bob, http.MethodPatch, "/api/v1/sessions/"+assigned.ID, gin.H{"status": "completed"}, nil); code != http.StatusOK {
+		t.Fatalf("面试官结束会话返回%d", code)
+	}
+	if code := doJSON(t, bob, http.MethodPatch, "/api/v1/sessions/"+assigned.ID, gin.H{"interviewerId": ""}, nil); code != http.StatusForbidden {
+		t.Fatalf("面试官修改分配返回%d，期望403", code)
+	}
+
+	var full models.QuestionSet
+	if code := doJSON(t, bob, http.MethodGet, "/api/v1/question-sets/"+qs.ID, nil, &full); code != http.StatusOK || full.Questions[0].ReferenceAnswer == "" {
+		t.Fatalf("面试官应能看到参考答案: %d %+v", code, full.Questions[0])
+	}
+
+	// 候选人练习模式
+	candidate := withToken(h, registerUser(t, h, "dave@example.com", "", "candidate"))
+	ownResume := upload(t, s, candidate, "/api/v1/resumes", "file", "resume.txt", "李四\nGo语言开发")
+	ownJD := upload(t, s, candidate, "/api/v1/jds", "file", "jd.txt", "Go工程师\n要求：Go语言")
+	if ownResume == "" || ownJD == "" {
+		t.FailNow()
+	}
+	var practice models.QuestionSet
+	if code := doJSON(t, candidate, http.MethodPost, "/api/v1/question-sets", gin.H{"resumeId": ownResume, "jdId": ownJD}, &practice); code != http.StatusCreated {
+		t.Fatalf("候选人生成问题集返回%d", code)
+	}
+	if code := doJSON(t, candidate, http.MethodGet, "/api/v1/question-sets/"+practice.ID, nil, &practice); code != http.StatusOK {
+		t.Fatalf("候选人获取问题集返回%d", code)
+	}
+	for _, q := range practice.Questions {
+		if q.ReferenceAnswer != "" || len(q.Rubric) > 0 {
+			t.Fatalf("候选人不应看到参考答案和评分要点: %+v", q)
+		}
+	}
+	if stored, _ := s.questions.Get(practice.ID); stored.Questions[0].ReferenceAnswer == "" {
+		t.Fatal("隐藏参考答案不应修改存储中的问题集")
+	}
+	if code := doJSON(t, candidate, http.MethodPatch, "/api/v1/sessions/"+assigned.ID, gin.H{"status": "active"}, nil); code != http.StatusNotFound {
+		t.Fatalf("候选人访问其他团队的会话返回%d，期望404", code)
+	}
+}
+
+// readAudit 读取审计日志中的所有记录
+func readAudit(t *testing.T, s *Server) []audit.Entry {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(s.cfg.DataDir, "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("读取审计日志失败: %v", err)
+	}
+	var entries []audit.Entry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry audit.Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("审计记录不是JSON: %s", line)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// TestForgetCandidate 删除候选人时一并删除同一邮箱的简历、文件、问题集、会话和评估，并记录审计日志
+func TestForgetCandidate(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *config.Config) { cfg.PersistData = true })
+
+	first := upload(t, s, h, "/api/v1/resumes", "file", "a.txt", "王五\nGo语言开发")
+	second := upload(t, s, h, "/api/v1/resumes", "file", "b.txt", "王五\nJava开发")
+	other := upload(t, s, h, "/api/v1/resumes", "file", "c.txt", "赵六\nPython开发")
+	jdID := upload(t, s, h, "/api/v1/jds", "file", "jd.txt", "Go工程师")
+	if first == "" || second == "" || other == "" || jdID == "" {
+		t.FailNow()
+	}
+	for _, id := range []string{first, second} {
+		if code := doJSON(t, h, http.MethodPut, "/api/v1/resumes/"+id, gin.H{"name": "王五", "email": "wangwu@example.com"}, nil); code != http.StatusOK {
+			t.Fatalf("修改简历返回%d", code)
+		}
+	}
+	firstResume, _ := s.resumes.Get(first)
+
+	var questionSet models.QuestionSet
+	doJSON(t, h, http.MethodPost, "/api/v1/question-sets", gin.H{"resumeId": first, "jdId": jdID}, &questionSet)
+	var session models.Session
+	doJSON(t, h, http.MethodPost, "/api/v1/sessions", gin.H{"questionSetId": questionSet.ID}, &session)
+	if code := doJSON(t, h, http.MethodPost, "/api/v1/evaluations", gin.H{"sessionId": session.ID, "questionId": 1, "answer": "回答"}, nil); code != http.StatusCreated {
+		t.Fatalf("评估回答返回%d", code)
+	}
+
+	var result forgetResult
+	if code := doJSON(t, h, http.MethodDelete, "/api/v1/candidates/"+first, nil, &result); code != http.StatusOK {
+		t.Fatalf("删除候选人返回%d", code)
+	}
+	if len(result.ResumeIDs) != 2 || len(result.QuestionSetIDs) != 1 || len(result.SessionIDs) != 1 || len(result.EvaluationIDs) != 1 || result.FilesDeleted != 2 {
+		t.Fatalf("删除结果不正确: %+v", result)
+	}
+	if _, err := os.Stat(firstResume.FilePath); !os.IsNotExist(err) {
+		t.Fatalf("上传的文件未被删除")
+	}
+	if _, ok := s.jobQueue.Get(second); ok {
+		t.Fatalf("解析任务未被删除")
+	}
+	if _, ok := s.resumes.Get(other); !ok {
+		t.Fatalf("其他候选人的简历被删除")
+	}
+	if len(s.sessions.Values()) != 0 || len(s.evaluations.Values()) != 0 || len(s.questions.Values()) != 0 {
+		t.Fatalf("候选人的面试数据未被删除")
+	}
+
+	forgotten := 0
+	for _, entry := range readAudit(t, s) {
+		if entry.Action == audit.ActionForget && entry.ActorID == auth.LocalUser.ID {
+			forgotten++
+		}
+	}
+	if forgotten != 5 {
+		t.Fatalf("审计日志记录了%d条删除，期望5条", forgotten)
+	}
+}
+
+// TestForgetCandidateDuringParse 解析任务处理期间删除候选人，任务结束后简历不会被重新保存
+func TestForgetCandidateDuringParse(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant",
+				"content": `{"name":"王五","email":"","phone":"","education":[],"experience":[],"skills":["Go"]}`}}},
+		})
+	}))
+	defer fake.Close()
+	t.Setenv("GROK3_API_URL", fake.URL)
+
+	s, h := newTestServer(t, func(cfg *config.Config) { cfg.GrokAPIKey = "test" })
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "resume.txt")
+	fw.Write([]byte("王五\nGo语言开发"))
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/resumes", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var job jobs.Job
+	if w.Code != http.StatusAccepted || json.Unmarshal(w.Body.Bytes(), &job) != nil {
+		t.Fatalf("上传简历返回%d: %s", w.Code, w.Body.String())
+	}
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("解析任务未调用AI服务")
+	}
+
+	// 与删除候选人相同的步骤：先删除上传文件和解析任务，再删除简历
+	s.deleteUpload(job.ID, job.FilePath)
+	s.resumes.Delete(job.ID)
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("等待解析任务结束失败: %v", err)
+	}
+	if _, ok := s.resumes.Get(job.ID); ok {
+		t.Fatalf("已删除候选人的简历在解析完成后被重新保存")
+	}
+	if _, ok := s.jobQueue.Get(job.ID); ok {
+		t.Fatalf("解析任务未被删除")
+	}
+}
+
+// TestRetentionPurgesExpiredUploads 超过保留期限的上传文件被删除，解析结果保留
+func TestRetentionPurgesExpiredUploads(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *config.Config) {
+		cfg.PersistData = true
+		cfg.RetentionDays = 7
+	})
+
+	expired := upload(t, s, h, "/api/v1/resumes", "file", "old.txt", "孙七\nGo语言开发")
+	fresh := upload(t, s, h, "/api/v1/resumes", "file", "new.txt", "周八\nGo语言开发")
+	if expired == "" || fresh == "" {
+		t.FailNow()
+	}
+	expiredResume, _ := s.resumes.Get(expired)
+	old := time.Now().AddDate(0, 0, -8)
+	if err := os.Chtimes(expiredResume.FilePath, old, old); err != nil {
+		t.Fatalf("设置修改时间失败: %v", err)
+	}
+
+	s.purgeExpiredUploads(time.Now())
+
+	if _, err := os.Stat(expiredResume.FilePath); !os.IsNotExist(err) {
+		t.Fatalf("过期文件未被删除")
+	}
+	if resume, ok := s.resumes.Get(expired); !ok || resume.FilePath != "" || resume.RawText == "" {
+		t.Fatalf("应保留解析结果并清除文件路径: %+v", resume)
+	}
+	if resume, _ := s.resumes.Get(fresh); existingFile(resume.FilePath) == "" {
+		t.Fatalf("未过期的文件被删除")
+	}
+
+	entries := readAudit(t, s)
+	last := entries[len(entries)-1]
+	if last.Action != audit.ActionPurge || last.ResourceType != resourceResume || last.ResourceID != expired || last.ActorID != audit.SystemActor {
+		t.Fatalf("审计记录不正确: %+v", last)
+	}
+}
+
+// TestEncryptedUploads 配置密钥后上传文件和任务记录加密保存，解析流程不受影响
+func TestEncryptedUploads(t *testing.T) {
+	key := "k1:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	s, h := newTestServer(t, func(cfg *config.Config) {
+		cfg.PersistData = true
+		cfg.EncryptionKeys = key
+	})
+
+	resumeID := upload(t, s, h, "/api/v1/resumes", "file", "resume.txt", "吴九\nGo语言开发")
+	if resumeID == "" {
+		t.FailNow()
+	}
+	resume, _ := s.resumes.Get(resumeID)
+	if !strings.Contains(resume.RawText, "Go语言开发") {
+		t.Fatalf("加密的文件未能正常解析: %q", resume.RawText)
+	}
+
+	for _, path := range []string{resume.FilePath, filepath.Join(s.cfg.DataDir, "jobs", resumeID+".json")} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("读取%s失败: %v", path, err)
+		}
+		if !encryption.IsEncrypted(data) || bytes.Contains(data, []byte("Go语言开发")) {
+			t.Fatalf("%s未加密保存", path)
+		}
+	}
+
+	// 超过MaxFileSize的文件拒绝保存，加密和明文保存都不留下文件
+	s.cfg.MaxFileSize = 4
+	plain, _ := newTestServer(t, func(cfg *config.Config) { cfg.MaxFileSize = 4 })
+	for _, server := range []*Server{s, plain} {
+		dir := t.TempDir()
+		if _, err := server.saveFile(dir, "big.txt", strings.NewReader("超过大小上限的内容")); err == nil {
+			t.Fatalf("超过MaxFileSize的文件应拒绝保存，加密: %v", server.keyring.Enabled())
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Fatalf("拒绝保存的文件应被删除，加密: %v", server.keyring.Enabled())
+		}
+		if _, err := server.saveFile(dir, "ok.txt", strings.NewReader("abcd")); err != nil {
+			t.Fatalf("不超过MaxFileSize的文件应正常保存: %v", err)
+		}
+	}
+}
+
+// TestBatchScreenUploads 同名文件和压缩包互不覆盖，不支持的文件记为失败，上传的文件筛选后删除
+func TestBatchScreenUploads(t *testing.T) {
+	key := "k1:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	s, h := newTestServer(t, func(cfg *config.Config) {
+		cfg.EncryptionKeys = key
+		cfg.BatchMaxFiles = 5
+	})
+	jdID := upload(t, s, h, "/upload/jd", "jd", "jd.txt", "Go后端工程师\n要求：熟悉Go语言和MySQL")
+	if jdID == "" {
+		t.FailNow()
+	}
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, content := range map[string]string{"a.txt": "孙七\nGo语言开发", "notes.docx": "不支持的格式"} {
+		entry, _ := zw.Create(name)
+		entry.Write([]byte(content))
+	}
+	zw.Close()
+
+	screen := func(files map[string][]string, archives ...[]byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("jdId", jdID)
+		for name, contents := range files {
+			for _, content := range contents {
+				fw, _ := mw.CreateFormFile("files", name)
+				fw.Write([]byte(content))
+			}
+		}
+		for _, data := range archives {
+			fw, _ := mw.CreateFormFile("archive", "a.txt.zip")
+			fw.Write(data)
+		}
+		mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/batch/screen", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := screen(map[string][]string{"a.txt": {"张三\nGo语言开发", "李四\nMySQL运维"}}, archive.Bytes())
+	if w.Code != http.StatusOK {
+		t.Fatalf("批量筛选返回%d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Shortlist struct {
+			Total   int `json:"total"`
+			Failed  int `json:"failed"`
+			Results []struct {
+				ResumeID string `json:"resumeId"`
+			} `json:"results"`
+		} `json:"shortlist"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	texts := make(map[string]bool)
+	for _, r := range resp.Shortlist.Results {
+		if resume, ok := s.resumes.Get(r.ResumeID); ok {
+			texts[strings.SplitN(resume.RawText, "\n", 2)[0]] = true
+		}
+	}
+	if resp.Shortlist.Total != 4 || resp.Shortlist.Failed != 1 || !texts["张三"] || !texts["李四"] || !texts["孙七"] {
+		t.Fatalf("同名文件不应互相覆盖，压缩包中不支持的文件应记为失败: %s", w.Body.String())
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(s.cfg.UploadDir, "batch"))
+	if len(entries) != 0 {
+		t.Fatalf("筛选结束后应删除上传的文件，仍有%d个批次目录", len(entries))
+	}
+
+	// 文件数量在写入磁盘前检查，压缩包按剩余名额解压
+	if w := screen(map[string][]string{"b.txt": {"1", "2", "3", "4", "5", "6"}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("超过文件数量上限应返回400，实际为%d", w.Code)
+	}
+	if w := screen(map[string][]string{"b.txt": {"1", "2", "3", "4"}}, archive.Bytes()); w.Code != http.StatusBadRequest {
+		t.Fatalf("压缩包超过剩余名额应返回400，实际为%d", w.Code)
+	}
+}
+
+// TestAuditLogQuery 每个请求都记录执行者、操作、资源和调用的模型，并可按条件查询
+func TestAuditLogQuery(t *testing.T) {
+	s, h := newTestServer(t)
+
+	resumeID := upload(t, s, h, "/api/v1/resumes", "file", "resume.txt", "张三\nGo语言开发")
+	jdID := upload(t, s, h, "/api/v1/jds", "file", "jd.txt", "Go工程师")
+	if resumeID == "" || jdID == "" {
+		t.FailNow()
+	}
+	doJSON(t, h, http.MethodGet, "/api/v1/resumes/"+resumeID, nil, nil)
+	var questionSet models.QuestionSet
+	if code := doJSON(t, h, http.MethodPost, "/api/v1/question-sets", gin.H{"resumeId": resumeID, "jdId": jdID}, &questionSet); code != http.StatusCreated {
+		t.Fatalf("生成问题返回%d", code)
+	}
+
+	var accessed page[audit.Entry]
+	if code := doJSON(t, h, http.MethodGet, "/api/v1/audit?resourceId="+resumeID, nil, &accessed); code != http.StatusOK {
+		t.Fatalf("查询审计日志返回%d", code)
+	}
+	actions := map[string]bool{}
+	for _, entry := range accessed.Items {
+		if entry.ActorID != auth.LocalUser.ID || entry.ResourceType != resourceResume {
+			t.Fatalf("审计记录的执行者或资源类型不正确: %+v", entry)
+		}
+		actions[entry.Action] = true
+	}
+	if !actions["createResume"] || !actions["getResume"] || !actions[audit.ActionParse] {
+		t.Fatalf("简历的审计记录不完整: %v", actions)
+	}
+
+	var generated page[audit.Entry]
+	doJSON(t, h, http.MethodGet, "/api/v1/audit?action=createQuestionSet", nil, &generated)
+	if generated.Total != 1 {
+		t.Fatalf("生成问题的审计记录有%d条，期望1条", generated.Total)
+	}
+	entry := generated.Items[0]
+	if entry.ResourceID != questionSet.ID || entry.Provider != ai.ProviderMock || entry.Status != http.StatusCreated {
+		t.Fatalf("生成问题的审计记录不正确: %+v", entry)
+	}
+
+	doJSON(t, h, http.MethodGet, "/api/v1/resumes", nil, nil)
+	var listed page[audit.Entry]
+	doJSON(t, h, http.MethodGet, "/api/v1/audit?action=listResumes", nil, &listed)
+	if listed.Total != 1 || listed.Items[0].Detail != "ids="+resumeID {
+		t.Fatalf("列出简历的审计记录应包含返回的简历ID: %+v", listed.Items)
+	}
+
+	if code := doJSON(t, h, http.MethodGet, "/api/v1/audit?since=yesterday", nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("无效的时间返回%d，期望400", code)
+	}
+	var none page[audit.Entry]
+	doJSON(t, h, http.MethodGet, "/api/v1/audit?since="+time.Now().Add(time.Hour).Format(time.RFC3339), nil, &none)
+	if none.Total != 0 {
+		t.Fatalf("起始时间之后不应有记录，得到%d条", none.Total)
+	}
+}
+
+// TestMetricsEndpoint 按路由模板统计请求，并导出解析和大模型等阶段的指标
+func TestMetricsEndpoint(t *testing.T) {
+	_, h := newTestServer(t)
+
+	doJSON(t, h, http.MethodGet, "/api/v1/resumes/missing", nil, nil)
+	route := "/api/v1/resumes/:id"
+	before := metrics.HTTPRequests.Value(http.MethodGet, route, "404")
+	doJSON(t, h, http.MethodGet, "/api/v1/resumes/missing", nil, nil)
+	if got := metrics.HTTPRequests.Value(http.MethodGet, route, "404"); got != before+1 {
+		t.Fatalf("请求计数为%v，期望%v", got, before+1)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("/metrics返回%d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`resume_ai_http_requests_total{method="GET",route="/api/v1/resumes/:id",status="404"}`,
+		"# TYPE resume_ai_llm_request_duration_seconds histogram",
+		"# TYPE resume_ai_json_repairs_total counter",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("指标中缺少 %s", want)
+		}
+	}
+}
+
+// TestUsageBudget 超出每日预算时按配置降级为模拟模式或拒绝请求，并按用户汇总用量
+func TestUsageBudget(t *testing.T) {
+	for _, action := range []string{budgetDegrade, budgetBlock} {
+		t.Run(action, func(t *testing.T) {
+			s, h := newTestServer(t, func(cfg *config.Config) {
+				cfg.UserDailyBudget = 1
+				cfg.BudgetAction = action
+			})
+			resumeID := upload(t, s, h, "/api/v1/resumes", "file", "resume.txt", "张三\nGo语言开发")
+			jdID := upload(t, s, h, "/api/v1/jds", "file", "jd.txt", "Go工程师")
+			if resumeID == "" || jdID == "" {
+				t.FailNow()
+			}
+
+			// 模拟生成器不返回用量，直接记一次超出预算的调用
+			s.usage.Record(usage.Record{UserID: auth.LocalUser.ID, TeamID: auth.LocalUser.TeamID, Operation: "createQuestionSet",
+				Provider: ai.ProviderGrok, Model: "grok-3", CompletionTokens: 1_000_000})
+
+			fallbacks := metrics.Fallbacks.Value(metrics.FallbackBudget)
+			w := postJSON(h, "/api/v1/question-sets", gin.H{"resumeId": resumeID, "jdId": jdID})
+			if action == budgetBlock {
+				if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), codeBudgetExceeded) {
+					t.Fatalf("超出预算时应拒绝请求，得到%d: %s", w.Code, w.Body.String())
+				}
+			} else {
+				if w.Code != http.StatusCreated {
+					t.Fatalf("超出预算时应降级为模拟模式，得到%d: %s", w.Code, w.Body.String())
+				}
+				if got := metrics.Fallbacks.Value(metrics.FallbackBudget); got <= fallbacks {
+					t.Fatalf("降级次数未增加: %v", got)
+				}
+			}
+
+			var summaries page[usage.Summary]
+			if code := doJSON(t, h, http.MethodGet, "/api/v1/usage?groupBy=user", nil, &summaries); code != http.StatusOK {
+				t.Fatalf("查询用量返回%d", code)
+			}
+			if summaries.Total != 1 || summaries.Items[0].Key != auth.LocalUser.ID || summaries.Items[0].Cost != 15 {
+				t.Fatalf("按用户汇总的用量不正确: %+v", summaries.Items)
+			}
+			if code := doJSON(t, h, http.MethodGet, "/api/v1/usage?groupBy=team", nil, nil); code != http.StatusBadRequest {
+				t.Fatalf("不支持的汇总维度返回%d，期望400", code)
+			}
+			if code := doJSON(t, h, http.MethodGet, "/api/v1/usage?until=tomorrow", nil, nil); code != http.StatusBadRequest {
+				t.Fatalf("无效的截止时间返回%d，期望400", code)
+			}
+		})
+	}
+}
+
+// syncBuffer 是并发安全的缓冲区，用于收集请求和后台任务写出的日志
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestRequestIDPropagation 上传请求的ID返回给客户端，并出现在请求日志和后台解析任务的日志中
+func TestRequestIDPropagation(t *testing.T) {
+	var logs syncBuffer
+	logger, err := logging.New(&logs, logging.FormatJSON, "info")
+	if err != nil {
+		t.Fatalf("创建日志记录器失败: %v", err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	s, h := newTestServer(t)
+	traced := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set(requestIDHeader, "trace-123")
+		h.ServeHTTP(w, r)
+	})
+	resumeID := upload(t, s, traced, "/api/v1/resumes", "file", "resume.txt", "张三\nGo语言开发")
+	if resumeID == "" {
+		t.FailNow()
+	}
+	if job, _ := s.jobQueue.Get(resumeID); job.RequestID != "trace-123" {
+		t.Fatalf("任务的请求ID为%q，期望trace-123", job.RequestID)
+	}
+
+	messages := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]any
+		if json.Unmarshal([]byte(line), &entry) == nil && entry[logging.RequestIDKey] == "trace-123" {
+			messages[entry["msg"].(string)] = true
+		}
+	}
+	if !messages["请求完成"] || !messages["开始处理任务"] || !messages["任务处理完成"] {
+		t.Fatalf("请求和任务日志中缺少请求ID: %v", messages)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/resumes/"+resumeID, nil)
+	req.Header.Set(requestIDHeader, "bad id\n")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if id := w.Header().Get(requestIDHeader); id == "" || id == "bad id\n" {
+		t.Fatalf("非法的请求ID应被替换为新生成的ID，得到%q", id)
+	}
+}
+
+func TestTracingSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	s, h := newTestServer(t)
+	resumeID := upload(t, s, h, "/api/v1/resumes", "file", "resume.txt", "张三\nGo语言开发")
+	if resumeID == "" {
+		t.FailNow()
+	}
+
+	// 任务span在任务结果写入之后才结束，这里按已开始的span检查父子关系
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Started() {
+		spans[span.Name()] = span
+	}
+	request, job, parse := spans["POST /api/v1/resumes"], spans["job.resume"], spans["parser.ParseFile"]
+	if request == nil || job == nil || parse == nil {
+		t.Fatalf("缺少请求、任务或解析span: %v", spans)
+	}
+	if job.Parent().SpanID() != request.SpanContext().SpanID() || job.SpanContext().TraceID() != request.SpanContext().TraceID() {
+		t.Fatal("任务span应挂在提交上传的请求span下")
+	}
+	if parse.Parent().SpanID() != job.SpanContext().SpanID() {
+		t.Fatal("解析span应挂在任务span下")
+	}
+	if job, _ := s.jobQueue.Get(resumeID); job.TraceParent == "" {
+		t.Fatal("任务应记录提交请求的追踪上下文")
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	_, h := newTestServer(t, func(cfg *config.Config) {
+		cfg.OCRAPIKey = "ocr-secret-key-5678"
+	})
+
+	var live map[string]string
+	if code := doJSON(t, h, http.MethodGet, "/healthz", nil, &live); code != http.StatusOK || live["status"] != "ok" {
+		t.Fatalf("存活探针返回%d %v", code, live)
+	}
+
+	var ready health.Report
+	if code := doJSON(t, h, http.MethodGet, "/readyz", nil, &ready); code != http.StatusOK || ready.Status != health.StatusOK {
+		t.Fatalf("就绪探针返回%d %+v", code, ready)
+	}
+	for _, check := range ready.Checks {
+		if check.Details != nil {
+			t.Fatalf("就绪探针不应返回检查详情: %+v", check)
+		}
+	}
+
+	var diag diagnostics
+	if code := doJSON(t, h, http.MethodGet, "/api/v1/diagnostics", nil, &diag); code != http.StatusOK {
+		t.Fatalf("诊断接口返回%d", code)
+	}
+	statuses := map[string]health.Status{}
+	for _, check := range diag.Checks {
+		statuses[check.Name] = check.Status
+	}
+	// 测试配置未启用OCR、未配置大模型密钥，对应检查应跳过
+	if statuses["storage"] != health.StatusOK || statuses["tesseract"] != health.StatusSkipped || statuses["ai_provider"] != health.StatusSkipped {
+		t.Fatalf("诊断检查结果不正确: %v", statuses)
+	}
+	if key := diag.Config["OCR_SPACE_API_KEY"]; key != "****5678" {
+		t.Fatalf("诊断信息中的密钥应被遮蔽，得到%q", key)
+	}
+
+	// 上传目录不可写时服务未就绪
+	file := filepath.Join(t.TempDir(), "not-a-dir")
+	os.WriteFile(file, nil, 0644)
+	_, h = newTestServer(t, func(cfg *config.Config) { cfg.UploadDir = file })
+	if code := doJSON(t, h, http.MethodGet, "/readyz", nil, &ready); code != http.StatusServiceUnavailable || ready.Status != health.StatusFail {
+		t.Fatalf("存储不可用时就绪探针应返回503，得到%d %+v", code, ready)
+	}
+}
+
+func TestShutdownStopsReadiness(t *testing.T) {
+	s, h := newTestServer(t)
+	resumeID := upload(t, s, h, "/api/v1/resumes", "file", "resume.txt", "张三\nGo语言开发")
+
+	// 摘除期间就绪探针返回503，请求照常处理
+	s.Drain()
+	var draining health.Report
+	if code := doJSON(t, h, http.MethodGet, "/readyz", nil, &draining); code != http.StatusServiceUnavailable {
+		t.Fatalf("摘除期间就绪探针应返回503，得到%d", code)
+	}
+	if code := doJSON(t, h, http.MethodGet, "/api/v1/resumes/"+resumeID, nil, nil); code != http.StatusOK {
+		t.Fatalf("摘除期间查询简历返回%d", code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("没有进行中的任务时停止不应出错: %v", err)
+	}
+	var ready health.Report
+	if code := doJSON(t, h, http.MethodGet, "/readyz", nil, &ready); code != http.StatusServiceUnavailable {
+		t.Fatalf("停止后就绪探针应返回503，得到%d", code)
+	}
+	// 停止过程中已完成的结果仍可查询
+	if code := doJSON(t, h, http.MethodGet, "/api/v1/resumes/"+resumeID, nil, nil); code != http.StatusOK {
+		t.Fatalf("停止过程中查询简历返回%d", code)
+	}
+}
+
+// unavailableGenerator 模拟不可用的AI服务
+type unavailableGenerator struct{}
+
+func (unavailableGenerator) Provider() string { return ai.ProviderGrok }
+func (unavailableGenerator) Model() string    { return ai.Grok3Model }
+
+func (unavailableGenerator) GenerateQuestions(ctx context.Context, resume *models.Resume, jd *models.JobDescription) (*models.QuestionSet, error) {
+	return nil, errors.New("服务不可用")
+}
+
+func TestProviderFailover(t *testing.T) {
+	s, h := newTestServer(t)
+	s.generator = ai.NewFailoverQuestionGenerator(time.Second, unavailableGenerator{}, ai.NewMockQuestionGenerator())
+
+	resumeID := upload(t, s, h, "/api/v1/resumes", "file", "resume.txt", "张三\nGo语言开发")
+	jdID := upload(t, s, h, "/api/v1/jds", "file", "jd.txt", "Go工程师")
+	var questionSet models.QuestionSet
+	if code := doJSON(t, h, http.MethodPost, "/api/v1/question-sets", gin.H{"resumeId": resumeID, "jdId": jdID}, &questionSet); code != http.StatusCreated {
+		t.Fatalf("首选服务不可用时应改用下一个服务，返回%d", code)
+	}
+	if questionSet.Provider != ai.ProviderMock {
+		t.Fatalf("问题集应记录实际提供服务的服务，得到%q", questionSet.Provider)
+	}
+
+	var generated page[audit.Entry]
+	doJSON(t, h, http.MethodGet, "/api/v1/audit?action=createQuestionSet", nil, &generated)
+	if generated.Total != 1 || generated.Items[0].Provider != ai.ProviderMock {
+		t.Fatalf("审计记录应记录实际提供服务的服务: %+v", generated.Items)
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/10yihang/resume-ai-interview/internal/ai"
+	"github.com/10yihang/resume-ai-interview/internal/interview"
+	"github.com/10yihang/resume-ai-interview/models"
+	"github.com/gin-gonic/gin"
+)
+
+// startSSE 设置Server-Sent Events响应头
+func startSSE(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+}
+
+// sendEvent 发送一个SSE事件并立即刷新到客户端
+func sendEvent(c *gin.Context, event string, data any) {
+	c.SSEvent(event, data)
+	c.Writer.Flush()
+}
+
+// GenerateQuestionsStreamHandler 以SSE流式生成面试问题
+// 事件：question（单个问题）、done（完整问题集）、error（错误信息）
+func (s *Server) GenerateQuestionsStreamHandler(c *gin.Context) {
+	var request questionRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	// 获取简历和JD
+	resume, jd, ok := s.lookupResumeAndJD(c, request)
+	if !ok {
+		return
+	}
+
+	// 超出预算时按配置降级或拒绝，拒绝时还未开始推送，返回普通的错误响应
+	generator, err := s.questionGenerator(c, "")
+	if err != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+
+	startSSE(c)
+
+	onQuestion := func(q models.Question) {
+		sendEvent(c, "question", questionView(c, q))
+	}
+
+	// 生成问题，不支持流式的生成器一次性生成后逐个推送
+	var questionSet *models.QuestionSet
+	auditModel(c, generator)
+	if streamer, ok := generator.(ai.StreamingQuestionGenerator); ok {
+		questionSet, err = streamer.GenerateQuestionsStream(c.Request.Context(), resume, jd, onQuestion)
+	} else {
+		questionSet, err = generator.GenerateQuestions(c.Request.Context(), resume, jd)
+		if err == nil {
+			for _, q := range questionSet.Questions {
+				onQuestion(q)
+			}
+		}
+	}
+
+	if err != nil {
+		sendEvent(c, "error", gin.H{"error": "生成问题失败: " + err.Error()})
+		return
+	}
+	auditServedBy(c, questionSet.Provider, questionSet.Model)
+	s.recordUsage(currentUser(c), "", "generateQuestionsStream", questionSet.Usage)
+
+	// 保存生成的问题
+	questionID := s.saveQuestionSet(c, request, questionSet)
+
+	sendEvent(c, "done", gin.H{
+		"message":       "问题生成成功",
+		"questionSetId": questionID,
+		"questions":     questionSetView(c, questionSet),
+	})
+}
+
+// EvaluateAnswerStreamHandler 以SSE流式评估面试回答
+// 事件：delta（feedback/suggestions的增量文本）、done（完整评估）、error（错误信息）
+func (s *Server) EvaluateAnswerStreamHandler(c *gin.Context) {
+	var request answerRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	question, jd, ok := s.lookupQuestion(c, request)
+	if !ok {
+		return
+	}
+
+	// 超出预算时按配置降级或拒绝，拒绝时还未开始推送，返回普通的错误响应
+	evaluator, err := s.answerEvaluator(c, "")
+	if err != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+
+	startSSE(c)
+
+	onDelta := func(field, text string) {
+		sendEvent(c, "delta", gin.H{"field": field, "text": text})
+	}
+
+	// 评估回答，不支持流式的评估器一次性评估后整段推送
+	var evaluation *models.Evaluation
+	auditResource(c, request.QuestionSetID)
+	auditModel(c, evaluator)
+	if streamer, ok := evaluator.(interview.StreamingAnswerEvaluator); ok {
+		evaluation, err = streamer.EvaluateAnswerStream(c.Request.Context(), question, request.Answer, jd, onDelta)
+	} else {
+		evaluation, err = evaluator.EvaluateAnswer(c.Request.Context(), question, request.Answer, jd)
+		if err == nil {
+			onDelta("feedback", evaluation.Feedback)
+			onDelta("suggestions", evaluation.Suggestions)
+		}
+	}
+
+	if err != nil {
+		sendEvent(c, "error", gin.H{"error": "评估回答失败: " + err.Error()})
+		return
+	}
+	auditServedBy(c, evaluation.Provider, evaluation.Model)
+	s.recordUsage(currentUser(c), "", "evaluateAnswerStream", evaluation.Usage)
+
+	sendEvent(c, "done", gin.H{
+		"message":    "回答评估成功",
+		"evaluation": evaluation,
+	})
+}
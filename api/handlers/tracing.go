@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/10yihang/resume-ai-interview/internal/logging"
+	"github.com/10yihang/resume-ai-interview/internal/tracing"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// traceRequests 为每个请求创建一个服务端span，解析、OCR和大模型调用的span都挂在其下
+// span名称使用路由模板而不是实际路径，避免名称中带有ID
+func traceRequests(c *gin.Context) {
+	route := c.FullPath()
+	if route == "" {
+		route = unmatchedRoute
+	}
+	ctx, span := tracing.StartRequest(c.Request.Context(), c.Request.Header, c.Request.Method+" "+route,
+		attribute.String("http.request.method", c.Request.Method),
+		attribute.String("http.route", route),
+		attribute.String(logging.RequestIDKey, logging.RequestID(c.Request.Context())),
+	)
+	defer span.End()
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+
+	status := c.Writer.Status()
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/ai"
+	"github.com/10yihang/resume-ai-interview/internal/auth"
+	"github.com/10yihang/resume-ai-interview/internal/interview"
+	"github.com/10yihang/resume-ai-interview/internal/metrics"
+	"github.com/10yihang/resume-ai-interview/internal/usage"
+	"github.com/10yihang/resume-ai-interview/models"
+	"github.com/gin-gonic/gin"
+)
+
+// 超出预算时的处理方式
+const (
+	budgetDegrade = "degrade" // 改用模拟生成器和评估器，解析时不调用AI
+	budgetBlock   = "block"   // 拒绝请求
+)
+
+// overBudget 检查用户当天和会话的费用，超出预算时返回是否需要拒绝请求
+// 不拒绝时调用方应降级处理，降级会记录日志和指标
+func (s *Server) overBudget(ctx context.Context, userID, sessionID string) (exceeded bool, err error) {
+	err = s.usage.CheckBudget(userID, sessionID, time.Now())
+	if err == nil {
+		return false, nil
+	}
+	if s.cfg.BudgetAction == budgetBlock {
+		return true, err
+	}
+	slog.WarnContext(ctx, "超出大模型费用预算，改用模拟模式", "user_id", userID, "session_id", sessionID, "error", err)
+	metrics.Fallbacks.Inc(metrics.FallbackBudget)
+	return true, nil
+}
+
+// questionGenerator 返回本次请求使用的问题生成器，超出预算时按配置降级为模拟生成器或返回错误
+func (s *Server) questionGenerator(c *gin.Context, sessionID string) (ai.QuestionGeneratorInterface, error) {
+	exceeded, err := s.overBudget(c.Request.Context(), currentUser(c).ID, sessionID)
+	switch {
+	case err != nil:
+		return nil, err
+	case exceeded:
+		return ai.NewMockQuestionGenerator(), nil
+	}
+	return s.generator, nil
+}
+
+// answerEvaluator 返回本次请求使用的回答评估器，超出预算时按配置降级为模拟评估器或返回错误
+func (s *Server) answerEvaluator(c *gin.Context, sessionID string) (interview.AnswerEvaluatorInterface, error) {
+	exceeded, err := s.overBudget(c.Request.Context(), currentUser(c).ID, sessionID)
+	switch {
+	case err != nil:
+		return nil, err
+	case exceeded:
+		return interview.NewMockAnswerEvaluator(), nil
+	}
+	return s.evaluator, nil
+}
+
+// parseWithAI 返回解析文件时是否调用AI，超出预算时按配置返回false（只保留原文）或错误
+func (s *Server) parseWithAI(ctx context.Context, userID string) (bool, error) {
+	exceeded, err := s.overBudget(ctx, userID, "")
+	if err != nil {
+		return false, err
+	}
+	return !exceeded, nil
+}
+
+// recordUsage 记录一次大模型调用的用量，模拟模式或接口没有返回用量时不记录
+func (s *Server) recordUsage(user *auth.User, sessionID, operation string, u *models.TokenUsage) {
+	if u == nil {
+		return
+	}
+	s.usage.Record(usage.Record{
+		UserID:           user.ID,
+		TeamID:           user.TeamID,
+		SessionID:        sessionID,
+		Operation:        operation,
+		Provider:         u.Provider,
+		Model:            u.Model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+	})
+}
+
+// ListUsageHandler 按用户、会话、日期或模型汇总大模型的用量和费用
+// 招聘者可以查看整个团队，其他角色只能查看自己的用量
+func (s *Server) ListUsageHandler(c *gin.Context) {
+	user := currentUser(c)
+	filter := usage.Filter{
+		TeamID:    user.TeamID,
+		UserID:    c.Query("userId"),
+		SessionID: c.Query("sessionId"),
+	}
+	if user.Role != auth.RoleRecruiter {
+		filter.UserID = user.ID
+	}
+	var ok bool
+	if filter.Since, filter.Until, ok = timeRange(c); !ok {
+		return
+	}
+
+	summaries, err := s.usage.Summarize(filter, c.DefaultQuery("groupBy", usage.GroupByDay))
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	result, ok := paginate(c, summaries)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/audit"
+	"github.com/10yihang/resume-ai-interview/internal/auth"
+	"github.com/10yihang/resume-ai-interview/internal/jobs"
+	"github.com/10yihang/resume-ai-interview/internal/openapi"
+	"github.com/10yihang/resume-ai-interview/internal/usage"
+	"github.com/10yihang/resume-ai-interview/models"
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersion 是REST API的版本号
+const APIVersion = "1.0.0"
+
+// apiBasePath 是版本化REST API的路径前缀
+const apiBasePath = "/api/v1"
+
+// 分页参数的默认值和上限
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// 错误码，客户端应根据错误码而不是错误信息判断错误类型
+const (
+	codeInvalidRequest = "invalid_request"
+	codeUnauthorized   = "unauthorized"
+	codeForbidden      = "forbidden"
+	codeNotFound       = "not_found"
+	codeConflict       = "conflict"
+	codeQueueFull      = "queue_full"
+	codeUpstream       = "upstream_error"
+	codeBudgetExceeded = "budget_exceeded"
+	codeInternal       = "internal_error"
+)
+
+// apiError 是REST API的错误信息
+type apiError struct {
+	Code    string `json:"code" binding:"required" doc:"机器可读的错误码" enum:"invalid_request,unauthorized,forbidden,not_found,conflict,queue_full,upstream_error,budget_exceeded,internal_error"`
+	Message string `json:"message" binding:"required" doc:"错误描述"`
+}
+
+// errorResponse 是REST API的错误响应体
+type errorResponse struct {
+	Error apiError `json:"error" binding:"required"`
+}
+
+// page 是分页列表的响应体
+type page[T any] struct {
+	Items    []T `json:"items"`
+	Page     int `json:"page"`
+	PageSize int `json:"pageSize"`
+	Total    int `json:"total"`
+}
+
+// abortWithError 写入错误响应并中止后续处理
+func abortWithError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, errorResponse{Error: apiError{Code: code, Message: message}})
+}
+
+// newResourceID 生成随机资源ID
+func newResourceID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// paginate 按查询参数page和pageSize截取列表，参数无效时写入400响应
+func paginate[T any](c *gin.Context, items []T) (page[T], bool) {
+	pageNum, pageSize := 1, defaultPageSize
+	if v := c.Query("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "page必须是正整数")
+			return page[T]{}, false
+		}
+		pageNum = n
+	}
+	if v := c.Query("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "pageSize必须是1到"+strconv.Itoa(maxPageSize)+"之间的整数")
+			return page[T]{}, false
+		}
+		pageSize = n
+	}
+
+	start := (pageNum - 1) * pageSize
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + pageSize
+	if end > len(items) {
+		end = len(items)
+	}
+	return page[T]{
+		Items:    append([]T{}, items[start:end]...),
+		Page:     pageNum,
+		PageSize: pageSize,
+		Total:    len(items),
+	}, true
+}
+
+// timeRange 解析查询参数since和until（RFC3339格式），未指定时为零值，参数无效时写入400响应
+func timeRange(c *gin.Context) (since, until time.Time, ok bool) {
+	for _, param := range []struct {
+		name   string
+		target *time.Time
+	}{{"since", &since}, {"until", &until}} {
+		if v := c.Query(param.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				abortWithError(c, http.StatusBadRequest, codeInvalidRequest, param.name+"必须是RFC3339格式的时间")
+				return time.Time{}, time.Time{}, false
+			}
+			*param.target = t
+		}
+	}
+	return since, until, true
+}
+
+// sortNewestFirst 按创建时间倒序排列，创建时间相同时按ID排序保证顺序稳定
+func sortNewestFirst[T any](items []T, key func(T) (time.Time, string)) {
+	sort.SliceStable(items, func(i, j int) bool {
+		ti, idi := key(items[i])
+		tj, idj := key(items[j])
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return idi < idj
+	})
+}
+
+// apiRoute 是REST API的一个路由及其文档描述
+type apiRoute struct {
+	openapi.Route
+	handler gin.HandlerFunc
+}
+
+// 路由中常用的错误状态码组合
+var (
+	errsRead   = []int{http.StatusNotFound}
+	errsWrite  = []int{http.StatusBadRequest, http.StatusNotFound}
+	errsCreate = []int{http.StatusBadRequest, http.StatusNotFound, http.StatusTooManyRequests, http.StatusBadGateway}
+	errsUpload = []int{http.StatusBadRequest, http.StatusServiceUnavailable, http.StatusInternalServerError}
+	errsList   = []int{http.StatusBadRequest}
+)
+
+// 路由允许的角色，未指定的路由所有角色都可以访问
+var (
+	recruiterOnly = roleNames(auth.RoleRecruiter)
+	// materialRoles 可以上传和管理材料，候选人练习时只能管理自己的材料
+	materialRoles = roleNames(auth.RoleRecruiter, auth.RoleCandidate)
+)
+
+// roleNames 将角色转换为路由表中使用的字符串
+func roleNames(roles ...auth.Role) []string {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+	return names
+}
+
+// 分页查询参数
+var pageParams = []openapi.Param{
+	{Name: "page", Type: "integer", Description: "页码，从1开始"},
+	{Name: "pageSize", Type: "integer", Description: "每页条数，默认20，最大100"},
+}
+
+// withPageParams 在分页参数之后追加过滤参数
+func withPageParams(filters ...openapi.Param) []openapi.Param {
+	return append(append([]openapi.Param{}, pageParams...), filters...)
+}
+
+// apiRoutes 返回REST API的路由表，路由注册和OpenAPI文档都由它生成
+func (s *Server) apiRoutes() []apiRoute {
+	return []apiRoute{
+		// 用户认证
+		{openapi.Route{Method: http.MethodPost, Path: "/auth/register", OperationID: "register", Tag: "auth",
+			Summary: "注册新用户并登录，不带邀请码时创建新团队", Request: registerRequest{}, Response: auth.User{},
+			Status: http.StatusCreated, Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusConflict}, Public: true}, s.RegisterHandler},
+		{openapi.Route{Method: http.MethodPost, Path: "/auth/login", OperationID: "login", Tag: "auth",
+			Summary: "使用邮箱和密码登录，登录会话保存在Cookie中", Request: loginRequest{}, Response: auth.User{},
+			Errors: []int{http.StatusBadRequest, http.StatusUnauthorized}, Public: true}, s.LoginHandler},
+		{openapi.Route{Method: http.MethodPost, Path: "/auth/logout", OperationID: "logout", Tag: "auth",
+			Summary: "退出登录", Status: http.StatusNoContent, Public: true}, s.LogoutHandler},
+		{openapi.Route{Method: http.MethodGet, Path: "/auth/me", OperationID: "getCurrentUser", Tag: "auth",
+			Summary: "获取当前用户", Response: auth.User{}}, s.MeHandler},
+		{openapi.Route{Method: http.MethodGet, Path: "/auth/tokens", OperationID: "listTokens", Tag: "auth",
+			Summary: "列出当前用户的API令牌", Query: withPageParams(), Response: auth.Token{}, List: true, Errors: errsList}, s.ListTokensHandler},
+		{openapi.Route{Method: http.MethodPost, Path: "/auth/tokens", OperationID: "createToken", Tag: "auth",
+			Summary: "创建API令牌，令牌原文只返回这一次", Request: tokenRequest{}, Response: createdToken{},
+			Status: http.StatusCreated, Errors: []int{http.StatusBadRequest}}, s.CreateTokenHandler},
+		{openapi.Route{Method: http.MethodDelete, Path: "/auth/tokens/:id", OperationID: "deleteToken", Tag: "auth",
+			Summary: "撤销API令牌", Status: http.StatusNoContent, Errors: errsRead}, s.DeleteTokenHandler},
+
+		// 团队
+		{openapi.Route{Method: http.MethodPost, Path: "/team/invites", OperationID: "createInvite", Tag: "team",
+			Summary: "创建以指定角色加入当前团队的一次性邀请码", Request: inviteRequest{}, Response: createdInvite{},
+			Status: http.StatusCreated, Errors: []int{http.StatusBadRequest}, Roles: recruiterOnly}, s.CreateInviteHandler},
+		{openapi.Route{Method: http.MethodGet, Path: "/team/members", OperationID: "listTeamMembers", Tag: "team",
+			Summary: "列出团队成员", Query: withPageParams(), Response: auth.User{}, List: true, Errors: errsList, Roles: recruiterOnly}, s.ListTeamMembersHandler},
+		{openapi.Route{Method: http.MethodPatch, Path: "/team/members/:id", OperationID: "updateTeamMember", Tag: "team",
+			Summary: "修改团队成员的角色", Request: memberUpdate{}, Response: auth.User{}, Errors: errsWrite, Roles: recruiterOnly}, s.UpdateTeamMemberHandler},
+
+		// 审计日志
+		{openapi.Route{Method: http.MethodGet, Path: "/audit", OperationID: "listAuditEntries", Tag: "audit",
+			Summary: "查询团队的审计记录，最新的在前",
+			Query: withPageParams(
+				openapi.Param{Name: "actorId", Description: "按执行者的用户ID过滤"},
+				openapi.Param{Name: "action", Description: "按操作过滤，如getResume、forget"},
+				openapi.Param{Name: "resourceType", Description: "按资源类型过滤，如resumes"},
+				openapi.Param{Name: "resourceId", Description: "按资源ID过滤"},
+				openapi.Param{Name: "since", Description: "起始时间（含），RFC3339格式"},
+				openapi.Param{Name: "until", Description: "截止时间（不含），RFC3339格式"}),
+			Response: audit.Entry{}, List: true, Errors: errsList, Roles: recruiterOnly}, s.ListAuditEntriesHandler},
+
+		// 大模型用量
+		{openapi.Route{Method: http.MethodGet, Path: "/usage", OperationID: "listUsage", Tag: "usage",
+			Summary: "汇总大模型的token用量和费用；招聘者可查看整个团队，其他角色只能查看自己的用量",
+			Query: withPageParams(
+				openapi.Param{Name: "groupBy", Description: "汇总维度：user、session、day（默认）或model"},
+				openapi.Param{Name: "userId", Description: "按用户过滤"},
+				openapi.Param{Name: "sessionId", Description: "按面试会话过滤"},
+				openapi.Param{Name: "since", Description: "起始时间（含），RFC3339格式"},
+				openapi.Param{Name: "until", Description: "截止时间（不含），RFC3339格式"}),
+			Response: usage.Summary{}, List: true, Errors: errsList}, s.ListUsageHandler},
+
+		// 运行诊断
+		{openapi.Route{Method: http.MethodGet, Path: "/diagnostics", OperationID: "getDiagnostics", Tag: "system",
+			Summary:  "检查Tesseract、pdftoppm、大模型服务和存储等依赖，并返回遮蔽了密钥的当前配置",
+			Response: diagnostics{}, Roles: recruiterOnly}, s.DiagnosticsHandler},
+
+		// 简历
+		{openapi.Route{Method: http.MethodPost, Path: "/resumes", OperationID: "createResume", Tag: "resumes",
+			Summary:  "上传简历，返回解析任务；简历ID与任务ID相同",
+			Form:     []openapi.FormField{{Name: "file", File: true, Required: true, Description: "PDF、TXT或图片文件"}},
+			Response: jobs.Job{}, Status: http.StatusAccepted, Errors: errsUpload, Roles: materialRoles}, s.CreateResumeHandler},
+		{openapi.Route{Method: http.MethodGet, Path: "/resumes", OperationID: "listResumes", Tag: "resumes",
+			Summary: "列出简历", Query: withPageParams(), Response: models.Resume{}, List: true, Errors: errsList}, s.ListResumesHandler},
+		{openapi.Route{Method: http.MethodGet, Path: "/resumes/:id", OperationID: "getResume", Tag: "resumes",
+			Summary: "获取简历", Response: models.Resume{}, Errors: errsRead}, s.GetResumeHandler},
+		{openapi.Route{Method: http.MethodPut, Path: "/resumes/:id", OperationID: "updateResume", Tag: "resumes",
+			Summary: "修改简历的解析结果", Request: models.Resume{}, Response: models.Resume{}, Errors: errsWrite, Roles: materialRoles}, s.UpdateResumeHandler},
+		{openapi.Route{Method: http.MethodDelete, Path: "/resumes/:id", OperationID: "deleteResume", Tag: "resumes",
+			Summary: "删除简历及上传的文件", Status: http.StatusNoContent, Errors: errsRead, Roles: materialRoles}, s.DeleteResumeHandler},
+		{openapi.Route{Method: http.MethodDelete, Path: "/candidates/:id", OperationID: "forgetCandidate", Tag: "resumes",
+			Summary:  "删除候选人的所有数据：id对应的简历及同一邮箱的其他简历、上传的文件、问题集、面试会话和评估",
+			Response: forgetResult{}, Errors: errsRead, Roles: materialRoles}, s.ForgetCandidateHandler},
+
+		// JD
+		{openapi.Route{Method: http.MethodPost, Path: "/jds", OperationID: "createJD", Tag: "jds",
+			Summary:  "上传JD，返回解析任务；JD ID与任务ID相同",
+			Form:     []openapi.FormField{{Name: "file", File: true, Required: true, Description: "PDF、TXT或图片文件"}},
+			Response: jobs.Job{}, Status: http.StatusAccepted, Errors: errsUpload, Roles: materialRoles}, s.CreateJDHandler},
+		{openapi.Route{Method: http.MethodGet, Path: "/jds", OperationID: "listJDs", Tag: "jds",
+			Summary: "列出JD", Query: withPageParams(), Response: models.JobDescription{}, List: true, Errors: errsList}, s.ListJDsHandler},
+		{openapi.Route{Method: http.MethodGet, Path: "/jds/:id", OperationID: "getJD", Tag: "jds",
+			Summary: "获取JD", Response: models.JobDescription{}, Errors: errsRead}, s.GetJDHandler},
+		{openapi.Route{Method: http.MethodPut, Path: "/jds/:id", OperationID: "updateJD", Tag: "jds",
+			Summary: "修改JD的解析结果", Request: models.JobDescription{}, Response: models.JobDescription{}, Errors: errsWrite, Roles: materialRoles}, s.UpdateJDHandler},
+		{openapi.Route{Method: http.MethodDelete, Path: "/jds/:id", OperationID: "deleteJD", Tag: "jds",
+			Summary: "删除JD及上传的文件", Status: http.StatusNoContent, Errors: errsRead, Roles: materialRoles}, s.DeleteJDHandler},
+
+		// 解析任务
+		{openapi.Route{Method: http.MethodGet, Path: "/jobs/:id", OperationID: "getJob", Tag: "jobs",
+			Summary: "查询解析任务状态", Response: jobs.Job{}, Errors: errsRead}, s.GetJobV1Handler},
+
+		// 问题集
+		{openapi.Route{Method: http.MethodPost, Path: "/question-sets", OperationID: "createQuestionSet", Tag: "question-sets",
+			Summary: "根据简历和JD生成问题集", Request: questionRequest{}, Response: models.QuestionSet{},
+			Status: http.StatusCreated, Errors: errsCreate, Roles: materialRoles}, s.CreateQuestionSetHandler},
+		{openapi.Route{Method: http.MethodGet, Path: "/question-sets", OperationID: "listQuestionSets", Tag: "question-sets",
+			Summary: "列出问题集",
+			Query: withPageParams(
+				openapi.Param{Name: "resumeId", Description: "按简历过滤"},
+				openapi.Param{Name: "jdId", Description: "按JD过滤"}),
+			Response: models.QuestionSet{}, List: true, Errors: errsList}, s.ListQuestionSetsHandler},
+		{openapi.Route{Method: http.MethodGet, Path: "/question-sets/:id", OperationID: "getQuestionSet", Tag: "question-sets",
+			Summary: "获取问题集", Response: models.QuestionSet{}, Errors: errsRead}, s.GetQuestionSetHandler},
+		{openapi.Route{Method: http.MethodPut, Path: "/question-sets/:id", OperationID: "updateQuestionSet", Tag: "question-sets",
+			Summary: "替换问题集中的问题", Request: questionSetUpdate{}, Response: models.QuestionSet{}, Errors: errsWrite, Roles: recruiterOnly}, s.UpdateQuestionSetHandler},
+		{openapi.Route{Method: http.MethodDelete, Path: "/question-sets/:id", OperationID: "deleteQuestionSet", Tag: "question-sets",
+			Summary: "删除问题集", Status: http.StatusNoContent, Errors: errsRead, Roles: materialRoles}, s.DeleteQuestionSetHandler},
+
+		// 面试会话
+		{openapi.Route{Method: http.MethodPost, Path: "/sessions", OperationID: "createSession", Tag: "sessions",
+			Summary: "基于问题集开始面试会话", Request: sessionRequest{}, Response: models.Session{},
+			Status: http.StatusCreated, Errors: errsWrite, Roles: materialRoles}, s.CreateSessionHandler},
+		{openapi.Route{Method: http.MethodGet, Path: "/sessions", OperationID: "listSessions", Tag: "sessions",
+			Summary: "列出面试会话",
+			Query: withPageParams(
+				openapi.Param{Name: "questionSetId", Description: "按问题集过滤"},
+				openapi.Param{Name: "status", Description: "按状态过滤：active或completed"}),
+			Response: models.Session{}, List: true, Errors: errsList}, s.ListSessionsHandler},
+		{openapi.Route{Method: http.MethodGet, Path: "/sessions/:id", OperationID: "getSession", Tag: "sessions",
+			Summary: "获取面试会话", Response: models.Session{}, Errors: errsRead}, s.GetSessionHandler},
+		{openapi.Route{Method: http.MethodPatch, Path: "/sessions/:id", OperationID: "updateSession", Tag: "sessions",
+			Summary: "修改面试会话状态", Request: sessionUpdate{}, Response: models.Session{}, Errors: errsWrite}, s.UpdateSessionHandler},
+		{openapi.Route{Method: http.MethodDelete, Path: "/sessions/:id", OperationID: "deleteSession", Tag: "sessions",
+			Summary: "删除面试会话及其评估", Status: http.StatusNoContent, Errors: errsRead, Roles: materialRoles}, s.DeleteSessionHandler},
+
+		// 回答评估
+		{openapi.Route{Method: http.MethodPost, Path: "/evaluations", OperationID: "createEvaluation", Tag: "evaluations",
+			Summary: "评估一个回答，指定sessionId时评估记入该会话", Request: evaluationRequest{}, Response: models.Evaluation{},
+			Status: http.StatusCreated, Errors: errsCreate}, s.CreateEvaluationHandler},
+		{openapi.Route{Method: http.MethodGet, Path: "/evaluations", OperationID: "listEvaluations", Tag: "evaluations",
+			Summary: "列出评估",
+			Query: withPageParams(
+				openapi.Param{Name: "sessionId", Description: "按会话过滤"},
+				openapi.Param{Name: "questionSetId", Description: "按问题集过滤"}),
+			Response: models.Evaluation{}, List: true, Errors: errsList}, s.ListEvaluationsHandler},
+		{openapi.Route{Method: http.MethodGet, Path: "/evaluations/:id", OperationID: "getEvaluation", Tag: "evaluations",
+			Summary: "获取评估", Response: models.Evaluation{}, Errors: errsRead}, s.GetEvaluationHandler},
+		{openapi.Route{Method: http.MethodDelete, Path: "/evaluations/:id", OperationID: "deleteEvaluation", Tag: "evaluations",
+			Summary: "删除评估", Status: http.StatusNoContent, Errors: errsRead, Roles: materialRoles}, s.DeleteEvaluationHandler},
+	}
+}
+
+// registerAPIRoutes 注册 /api/v1 下的路由和OpenAPI文档，非公开路由需要登录，指定了角色的路由还要检查角色
+// 每个请求都以operationId为操作记录审计日志
+func (s *Server) registerAPIRoutes(r gin.IRouter) {
+	group := r.Group(apiBasePath)
+	for _, route := range s.apiRoutes() {
+		audited := s.audited(route.OperationID, route.Tag)
+		switch {
+		case route.Public:
+			group.Handle(route.Method, route.Path, audited, route.handler)
+		case len(route.Roles) > 0:
+			roles := make([]auth.Role, len(route.Roles))
+			for i, name := range route.Roles {
+				roles[i] = auth.Role(name)
+			}
+			group.Handle(route.Method, route.Path, audited, s.authenticate, requireRole(roles...), route.handler)
+		default:
+			group.Handle(route.Method, route.Path, audited, s.authenticate, route.handler)
+		}
+	}
+	group.GET("/openapi.json", s.OpenAPIHandler)
+}
+
+// openAPIDocument 由路由表生成OpenAPI文档，只生成一次
+var openAPIDocument = sync.OnceValue(func() *openapi.Document {
+	b := openapi.NewBuilder("AI简历面试助手 API", APIVersion,
+		"简历与JD的上传解析、面试问题生成、面试会话和回答评估。错误响应统一为 {\"error\": {\"code\", \"message\"}}。",
+		errorResponse{})
+	b.SetSecurity(map[string]openapi.SecurityScheme{
+		"bearerToken":   {Type: "http", Scheme: "bearer", Description: "通过 /auth/tokens 创建的API令牌"},
+		"sessionCookie": {Type: "apiKey", In: "cookie", Name: sessionCookie, Description: "网页登录后的会话Cookie"},
+	})
+	for _, route := range (&Server{}).apiRoutes() {
+		b.Add(apiBasePath, route.Route)
+	}
+	return b.Document()
+})
+
+// OpenAPIHandler 返回OpenAPI 3文档
+func (s *Server) OpenAPIHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, openAPIDocument())
+}
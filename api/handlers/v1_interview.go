@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/audit"
+	"github.com/10yihang/resume-ai-interview/internal/auth"
+	"github.com/10yihang/resume-ai-interview/models"
+	"github.com/gin-gonic/gin"
+)
+
+// questionSetUpdate 替换问题集中问题的请求参数
+type questionSetUpdate struct {
+	Questions []models.Question `json:"questions" binding:"required"`
+}
+
+// sessionRequest 创建面试会话的请求参数
+type sessionRequest struct {
+	QuestionSetID string `json:"questionSetId" binding:"required"`
+	InterviewerID string `json:"interviewerId" doc:"分配的面试官用户ID，只有招聘者可以指定"`
+}
+
+// sessionUpdate 修改面试会话的请求参数，至少指定一个字段
+type sessionUpdate struct {
+	Status        models.SessionStatus `json:"status" enum:"active,completed"`
+	InterviewerID *string              `json:"interviewerId" doc:"重新分配面试官，空字符串表示取消分配，只有招聘者可以修改"`
+}
+
+// evaluationRequest 评估回答的请求参数
+// 指定sessionId时问题集取自会话，否则必须指定questionSetId
+type evaluationRequest struct {
+	SessionID     string `json:"sessionId"`
+	QuestionSetID string `json:"questionSetId"`
+	QuestionID    int    `json:"questionId" binding:"required"`
+	Answer        string `json:"answer" binding:"required"`
+}
+
+// CreateQuestionSetHandler 根据简历和JD生成问题集
+func (s *Server) CreateQuestionSetHandler(c *gin.Context) {
+	var request questionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "无效的请求参数: "+err.Error())
+		return
+	}
+
+	resume, ok := s.resumeFor(c, request.ResumeID)
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "简历不存在")
+		return
+	}
+	jd, ok := s.jdFor(c, request.JDID)
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "JD不存在")
+		return
+	}
+
+	generator, err := s.questionGenerator(c, "")
+	if err != nil {
+		abortWithError(c, http.StatusTooManyRequests, codeBudgetExceeded, err.Error())
+		return
+	}
+	auditModel(c, generator)
+	auditDetail(c, "resumeId=%s jdId=%s", request.ResumeID, request.JDID)
+	questionSet, err := generator.GenerateQuestions(c.Request.Context(), resume, jd)
+	if err != nil {
+		abortWithError(c, http.StatusBadGateway, codeUpstream, "生成问题失败: "+err.Error())
+		return
+	}
+
+	auditServedBy(c, questionSet.Provider, questionSet.Model)
+	s.recordUsage(currentUser(c), "", "createQuestionSet", questionSet.Usage)
+	id := s.saveQuestionSet(c, request, questionSet)
+
+	c.Header("Location", apiBasePath+"/question-sets/"+id)
+	c.JSON(http.StatusCreated, questionSetView(c, questionSet))
+}
+
+// ListQuestionSetsHandler 分页列出问题集，可按简历或JD过滤
+func (s *Server) ListQuestionSetsHandler(c *gin.Context) {
+	resumeID, jdID := c.Query("resumeId"), c.Query("jdId")
+
+	var items []*models.QuestionSet
+	all := visibleTo(c, s.questions.Values(), func(qs *models.QuestionSet) (string, string) { return qs.OwnerID, qs.TeamID })
+	for _, qs := range all {
+		if (resumeID == "" || qs.ResumeID == resumeID) && (jdID == "" || qs.JDID == jdID) {
+			items = append(items, questionSetView(c, qs))
+		}
+	}
+	sortNewestFirst(items, func(qs *models.QuestionSet) (time.Time, string) { return qs.CreatedAt, qs.ID })
+
+	result, ok := paginate(c, items)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetQuestionSetHandler 获取问题集
+func (s *Server) GetQuestionSetHandler(c *gin.Context) {
+	questionSet, ok := s.questionSetFor(c, c.Param("id"))
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "问题集不存在")
+		return
+	}
+	c.JSON(http.StatusOK, questionSetView(c, questionSet))
+}
+
+// UpdateQuestionSetHandler 替换问题集中的问题，问题ID必须为正数且不能重复
+func (s *Server) UpdateQuestionSetHandler(c *gin.Context) {
+	var update questionSetUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "无效的请求参数: "+err.Error())
+		return
+	}
+
+	seen := make(map[int]bool, len(update.Questions))
+	for _, q := range update.Questions {
+		if q.ID <= 0 || seen[q.ID] {
+			abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "问题ID必须为正数且不能重复")
+			return
+		}
+		if q.Content == "" {
+			abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "问题内容不能为空")
+			return
+		}
+		seen[q.ID] = true
+	}
+
+	if _, ok := s.questionSetFor(c, c.Param("id")); !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "问题集不存在")
+		return
+	}
+	questionSet, ok := s.questions.Update(c.Param("id"), func(old *models.QuestionSet) *models.QuestionSet {
+		updated := *old
+		updated.Questions = update.Questions
+		return &updated
+	})
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "问题集不存在")
+		return
+	}
+	c.JSON(http.StatusOK, questionSet)
+}
+
+// DeleteQuestionSetHandler 删除问题集
+func (s *Server) DeleteQuestionSetHandler(c *gin.Context) {
+	if _, ok := s.questionSetFor(c, c.Param("id")); !ok || !s.questions.Delete(c.Param("id")) {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "问题集不存在")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateSessionHandler 基于问题集开始面试会话
+func (s *Server) CreateSessionHandler(c *gin.Context) {
+	var request sessionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "无效的请求参数: "+err.Error())
+		return
+	}
+
+	questionSet, ok := s.questionSetFor(c, request.QuestionSetID)
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "问题集不存在")
+		return
+	}
+
+	user := currentUser(c)
+	if request.InterviewerID != "" && !s.assignInterviewer(c, request.InterviewerID) {
+		return
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:            newResourceID(),
+		QuestionSetID: questionSet.ID,
+		ResumeID:      questionSet.ResumeID,
+		JDID:          questionSet.JDID,
+		InterviewerID: request.InterviewerID,
+		Status:        models.SessionActive,
+		EvaluationIDs: []string{},
+		OwnerID:       user.ID,
+		TeamID:        user.TeamID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	s.sessions.Put(session.ID, session)
+
+	c.Header("Location", apiBasePath+"/sessions/"+session.ID)
+	c.JSON(http.StatusCreated, session)
+}
+
+// ListSessionsHandler 分页列出面试会话，可按问题集或状态过滤
+func (s *Server) ListSessionsHandler(c *gin.Context) {
+	questionSetID, status := c.Query("questionSetId"), models.SessionStatus(c.Query("status"))
+
+	user := currentUser(c)
+	var items []*models.Session
+	for _, session := range s.sessions.Values() {
+		if !sessionVisible(user, session) {
+			continue
+		}
+		if (questionSetID == "" || session.QuestionSetID == questionSetID) && (status == "" || session.Status == status) {
+			items = append(items, session)
+		}
+	}
+	sortNewestFirst(items, func(session *models.Session) (time.Time, string) { return session.CreatedAt, session.ID })
+
+	result, ok := paginate(c, items)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetSessionHandler 获取面试会话
+func (s *Server) GetSessionHandler(c *gin.Context) {
+	session, ok := s.sessionFor(c, c.Param("id"))
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "面试会话不存在")
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// UpdateSessionHandler 修改面试会话状态
+func (s *Server) UpdateSessionHandler(c *gin.Context) {
+	var update sessionUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "无效的请求参数: "+err.Error())
+		return
+	}
+	if update.Status == "" && update.InterviewerID == nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "status和interviewerId至少指定一个")
+		return
+	}
+	if update.Status != "" && update.Status != models.SessionActive && update.Status != models.SessionCompleted {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "status必须是active或completed")
+		return
+	}
+
+	if _, ok := s.sessionFor(c, c.Param("id")); !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "面试会话不存在")
+		return
+	}
+	if update.InterviewerID != nil && !s.assignInterviewer(c, *update.InterviewerID) {
+		return
+	}
+
+	session, ok := s.sessions.Update(c.Param("id"), func(old *models.Session) *models.Session {
+		updated := *old
+		if update.Status != "" {
+			updated.Status = update.Status
+		}
+		if update.InterviewerID != nil {
+			updated.InterviewerID = *update.InterviewerID
+		}
+		updated.UpdatedAt = time.Now()
+		return &updated
+	})
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "面试会话不存在")
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// DeleteSessionHandler 删除面试会话及其评估
+func (s *Server) DeleteSessionHandler(c *gin.Context) {
+	session, ok := s.sessionFor(c, c.Param("id"))
+	if !ok || !s.sessions.Delete(session.ID) {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "面试会话不存在")
+		return
+	}
+	for _, evaluation := range s.evaluations.Values() {
+		if evaluation.SessionID == session.ID && s.evaluations.Delete(evaluation.ID) {
+			s.recordDeletion(c, audit.ActionDelete, resourceEvaluation, evaluation.ID)
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// assignInterviewer 检查当前用户能否把会话分配给interviewerID，不能时写入错误响应
+// 只有招聘者可以分配，面试官必须是同一团队的招聘者或面试官；interviewerID为空表示取消分配
+func (s *Server) assignInterviewer(c *gin.Context, interviewerID string) bool {
+	user := currentUser(c)
+	if !user.HasRole(auth.RoleRecruiter) {
+		abortWithError(c, http.StatusForbidden, codeForbidden, "只有招聘者可以分配面试官")
+		return false
+	}
+	if interviewerID == "" || interviewerID == user.ID {
+		return true
+	}
+	interviewer, ok := s.auth.User(interviewerID)
+	if !ok || interviewer.TeamID != user.TeamID || !interviewer.HasRole(auth.RoleRecruiter, auth.RoleInterviewer) {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "面试官必须是团队中的招聘者或面试官")
+		return false
+	}
+	return true
+}
+
+// CreateEvaluationHandler 评估一个回答，指定会话时评估记入该会话
+func (s *Server) CreateEvaluationHandler(c *gin.Context) {
+	var request evaluationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "无效的请求参数: "+err.Error())
+		return
+	}
+
+	if request.SessionID != "" {
+		session, ok := s.sessionFor(c, request.SessionID)
+		if !ok {
+			abortWithError(c, http.StatusNotFound, codeNotFound, "面试会话不存在")
+			return
+		}
+		if request.QuestionSetID != "" && request.QuestionSetID != session.QuestionSetID {
+			abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "questionSetId与会话的问题集不一致")
+			return
+		}
+		if session.Status != models.SessionActive {
+			abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "面试会话已结束")
+			return
+		}
+		request.QuestionSetID = session.QuestionSetID
+	}
+	if request.QuestionSetID == "" {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "必须指定sessionId或questionSetId")
+		return
+	}
+
+	questionSet, ok := s.questionSetFor(c, request.QuestionSetID)
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "问题集不存在")
+		return
+	}
+	index := slices.IndexFunc(questionSet.Questions, func(q models.Question) bool { return q.ID == request.QuestionID })
+	if index < 0 {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "问题不存在")
+		return
+	}
+	jd, ok := s.jds.Get(questionSet.JDID)
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "JD不存在")
+		return
+	}
+
+	answer := models.Answer{QuestionID: request.QuestionID, Content: request.Answer}
+	evaluator, err := s.answerEvaluator(c, request.SessionID)
+	if err != nil {
+		abortWithError(c, http.StatusTooManyRequests, codeBudgetExceeded, err.Error())
+		return
+	}
+	auditModel(c, evaluator)
+	evaluation, err := evaluator.EvaluateAnswer(c.Request.Context(), questionSet.Questions[index], answer, jd)
+	if err != nil {
+		abortWithError(c, http.StatusBadGateway, codeUpstream, "评估回答失败: "+err.Error())
+		return
+	}
+
+	auditServedBy(c, evaluation.Provider, evaluation.Model)
+	user := currentUser(c)
+	s.recordUsage(user, request.SessionID, "createEvaluation", evaluation.Usage)
+	evaluation.ID = newResourceID()
+	evaluation.OwnerID = user.ID
+	evaluation.TeamID = user.TeamID
+	evaluation.SessionID = request.SessionID
+	evaluation.QuestionSetID = request.QuestionSetID
+	evaluation.Answer = request.Answer
+	evaluation.CreatedAt = time.Now()
+	s.evaluations.Put(evaluation.ID, evaluation)
+
+	// 评估期间会话可能已被删除，此时丢弃评估
+	if request.SessionID != "" {
+		_, ok := s.sessions.Update(request.SessionID, func(old *models.Session) *models.Session {
+			updated := *old
+			updated.EvaluationIDs = append(slices.Clone(old.EvaluationIDs), evaluation.ID)
+			updated.UpdatedAt = evaluation.CreatedAt
+			return &updated
+		})
+		if !ok {
+			s.evaluations.Delete(evaluation.ID)
+			abortWithError(c, http.StatusNotFound, codeNotFound, "面试会话不存在")
+			return
+		}
+	}
+
+	c.Header("Location", apiBasePath+"/evaluations/"+evaluation.ID)
+	c.JSON(http.StatusCreated, evaluation)
+}
+
+// ListEvaluationsHandler 分页列出评估，可按会话或问题集过滤
+func (s *Server) ListEvaluationsHandler(c *gin.Context) {
+	sessionID, questionSetID := c.Query("sessionId"), c.Query("questionSetId")
+
+	var items []*models.Evaluation
+	user := currentUser(c)
+	for _, evaluation := range s.evaluations.Values() {
+		if !s.evaluationVisible(user, evaluation) {
+			continue
+		}
+		if (sessionID == "" || evaluation.SessionID == sessionID) && (questionSetID == "" || evaluation.QuestionSetID == questionSetID) {
+			items = append(items, evaluation)
+		}
+	}
+	sortNewestFirst(items, func(e *models.Evaluation) (time.Time, string) { return e.CreatedAt, e.ID })
+
+	result, ok := paginate(c, items)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetEvaluationHandler 获取评估
+func (s *Server) GetEvaluationHandler(c *gin.Context) {
+	evaluation, ok := s.evaluationFor(c, c.Param("id"))
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "评估不存在")
+		return
+	}
+	c.JSON(http.StatusOK, evaluation)
+}
+
+// DeleteEvaluationHandler 删除评估，并从所属会话中移除
+func (s *Server) DeleteEvaluationHandler(c *gin.Context) {
+	evaluation, ok := s.evaluationFor(c, c.Param("id"))
+	if !ok || !s.evaluations.Delete(evaluation.ID) {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "评估不存在")
+		return
+	}
+
+	if evaluation.SessionID != "" {
+		s.sessions.Update(evaluation.SessionID, func(old *models.Session) *models.Session {
+			updated := *old
+			updated.EvaluationIDs = slices.DeleteFunc(slices.Clone(old.EvaluationIDs), func(id string) bool { return id == evaluation.ID })
+			updated.UpdatedAt = time.Now()
+			return &updated
+		})
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/jobs"
+	"github.com/10yihang/resume-ai-interview/models"
+	"github.com/gin-gonic/gin"
+)
+
+// createUpload 保存上传的文件并提交解析任务，返回202和任务状态
+// 解析完成后生成的资源ID与任务ID相同
+func (s *Server) createUpload(c *gin.Context, subDir, jobKind string) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "无法获取上传文件: "+err.Error())
+		return
+	}
+
+	filename, err := s.storeUpload(header, subDir)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+
+	job, err := s.submitUpload(c, jobKind, header.Filename, filename)
+	if err != nil {
+		os.Remove(filename)
+		if errors.Is(err, jobs.ErrQueueFull) {
+			abortWithError(c, http.StatusServiceUnavailable, codeQueueFull, "解析任务队列已满，请稍后重试")
+			return
+		}
+		abortWithError(c, http.StatusInternalServerError, codeInternal, "提交解析任务失败: "+err.Error())
+		return
+	}
+
+	c.Header("Location", apiBasePath+"/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// deleteUpload 删除资源对应的上传文件和解析任务
+// 需在从存储中删除资源之前调用，保证正在处理的任务不会在删除后重新保存结果
+func (s *Server) deleteUpload(id, filePath string) {
+	if filePath != "" {
+		os.Remove(filePath)
+	}
+	s.jobQueue.Remove(id)
+}
+
+// CreateResumeHandler 上传简历
+func (s *Server) CreateResumeHandler(c *gin.Context) {
+	s.createUpload(c, "resumes", jobKindResume)
+}
+
+// ListResumesHandler 分页列出简历，最新的在前
+func (s *Server) ListResumesHandler(c *gin.Context) {
+	items := visibleTo(c, s.resumes.Values(), func(r *models.Resume) (string, string) { return r.OwnerID, r.TeamID })
+	sortNewestFirst(items, func(r *models.Resume) (time.Time, string) { return r.CreatedAt, r.ID })
+
+	result, ok := paginate(c, items)
+	if !ok {
+		return
+	}
+	ids := make([]string, 0, len(result.Items))
+	for _, r := range result.Items {
+		ids = append(ids, r.ID)
+	}
+	auditReturned(c, ids)
+	c.JSON(http.StatusOK, result)
+}
+
+// GetResumeHandler 获取简历
+func (s *Server) GetResumeHandler(c *gin.Context) {
+	resume, ok := s.resumeFor(c, c.Param("id"))
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "简历不存在")
+		return
+	}
+	c.JSON(http.StatusOK, resume)
+}
+
+// UpdateResumeHandler 修改简历的解析结果，ID、文件路径和创建时间保持不变
+func (s *Server) UpdateResumeHandler(c *gin.Context) {
+	var update models.Resume
+	if err := c.ShouldBindJSON(&update); err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "无效的请求参数: "+err.Error())
+		return
+	}
+
+	if _, ok := s.resumeFor(c, c.Param("id")); !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "简历不存在")
+		return
+	}
+	resume, ok := s.resumes.Update(c.Param("id"), func(old *models.Resume) *models.Resume {
+		update.ID = old.ID
+		update.OwnerID = old.OwnerID
+		update.TeamID = old.TeamID
+		update.FilePath = old.FilePath
+		update.CreatedAt = old.CreatedAt
+		return &update
+	})
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "简历不存在")
+		return
+	}
+	c.JSON(http.StatusOK, resume)
+}
+
+// DeleteResumeHandler 删除简历及上传的文件
+func (s *Server) DeleteResumeHandler(c *gin.Context) {
+	id := c.Param("id")
+	resume, ok := s.resumeFor(c, id)
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "简历不存在")
+		return
+	}
+	s.deleteUpload(id, resume.FilePath)
+	if !s.resumes.Delete(id) {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "简历不存在")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateJDHandler 上传JD
+func (s *Server) CreateJDHandler(c *gin.Context) {
+	s.createUpload(c, "jds", jobKindJD)
+}
+
+// ListJDsHandler 分页列出JD，最新的在前
+func (s *Server) ListJDsHandler(c *gin.Context) {
+	items := visibleTo(c, s.jds.Values(), func(jd *models.JobDescription) (string, string) { return jd.OwnerID, jd.TeamID })
+	sortNewestFirst(items, func(jd *models.JobDescription) (time.Time, string) { return jd.CreatedAt, jd.ID })
+
+	result, ok := paginate(c, items)
+	if !ok {
+		return
+	}
+	ids := make([]string, 0, len(result.Items))
+	for _, jd := range result.Items {
+		ids = append(ids, jd.ID)
+	}
+	auditReturned(c, ids)
+	c.JSON(http.StatusOK, result)
+}
+
+// GetJDHandler 获取JD
+func (s *Server) GetJDHandler(c *gin.Context) {
+	jd, ok := s.jdFor(c, c.Param("id"))
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "JD不存在")
+		return
+	}
+	c.JSON(http.StatusOK, jd)
+}
+
+// UpdateJDHandler 修改JD的解析结果，ID、文件路径和创建时间保持不变
+func (s *Server) UpdateJDHandler(c *gin.Context) {
+	var update models.JobDescription
+	if err := c.ShouldBindJSON(&update); err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidRequest, "无效的请求参数: "+err.Error())
+		return
+	}
+
+	if _, ok := s.jdFor(c, c.Param("id")); !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "JD不存在")
+		return
+	}
+	jd, ok := s.jds.Update(c.Param("id"), func(old *models.JobDescription) *models.JobDescription {
+		update.ID = old.ID
+		update.OwnerID = old.OwnerID
+		update.TeamID = old.TeamID
+		update.FilePath = old.FilePath
+		update.CreatedAt = old.CreatedAt
+		return &update
+	})
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "JD不存在")
+		return
+	}
+	c.JSON(http.StatusOK, jd)
+}
+
+// DeleteJDHandler 删除JD及上传的文件
+func (s *Server) DeleteJDHandler(c *gin.Context) {
+	id := c.Param("id")
+	jd, ok := s.jdFor(c, id)
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "JD不存在")
+		return
+	}
+	s.deleteUpload(id, jd.FilePath)
+	if !s.jds.Delete(id) {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "JD不存在")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetJobV1Handler 查询解析任务状态
+func (s *Server) GetJobV1Handler(c *gin.Context) {
+	job, ok := s.jobFor(c, c.Param("id"))
+	if !ok {
+		abortWithError(c, http.StatusNotFound, codeNotFound, "任务不存在")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,157 @@
+// screen 命令行批量筛选工具：解析多份简历并与一个JD匹配，输出排名
+//
+// 用法：
+//
+//	go run ./cmd/screen -jd jd.pdf [-parallel 4] [-json] resume1.pdf resume2.pdf resumes.zip
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/config"
+	"github.com/10yihang/resume-ai-interview/internal/logging"
+	"github.com/10yihang/resume-ai-interview/internal/ocr"
+	"github.com/10yihang/resume-ai-interview/internal/parser"
+	"github.com/10yihang/resume-ai-interview/internal/redact"
+	"github.com/10yihang/resume-ai-interview/internal/screening"
+	"github.com/10yihang/resume-ai-interview/models"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	jdPath := flag.String("jd", "", "职位描述文件路径（PDF/TXT）")
+	parallel := flag.Int("parallel", 0, "同时解析的简历数，默认使用BATCH_PARALLELISM")
+	asJSON := flag.Bool("json", false, "以JSON格式输出结果")
+	flag.Parse()
+
+	if *jdPath == "" || flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "用法: screen -jd <JD文件> [-parallel N] [-json] <简历文件或zip>...")
+		os.Exit(2)
+	}
+
+	// 加载环境变量
+	envErr := godotenv.Load()
+	// 配置文件通过环境变量CONFIG_FILE指定，命令行参数已用于筛选选项
+	cfg, err := config.Load(nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// 初始化日志，日志输出到标准错误，不影响标准输出中的结果
+	if err := logging.Setup(cfg.LogFormat, cfg.LogLevel); err != nil {
+		fmt.Fprintln(os.Stderr, "初始化日志失败:", err)
+		os.Exit(1)
+	}
+	if envErr != nil {
+		slog.Warn("未找到.env文件")
+	}
+
+	parallelism := cfg.BatchParallelism
+	if *parallel > 0 {
+		parallelism = *parallel
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// 出错时在run中删除临时目录后再退出
+	if err := run(ctx, cfg, *jdPath, flag.Args(), parallelism, *asJSON); err != nil {
+		slog.Error("批量筛选失败", "error", err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+// run 解析JD和各份简历并输出筛选结果，zip压缩包解压到临时目录，返回前删除
+func run(ctx context.Context, cfg *config.Config, jdPath string, paths []string, parallelism int, asJSON bool) error {
+	redaction := redact.NewPolicy(cfg.RedactProviders)
+	aiParser := newAIParser(cfg, redaction)
+	jd, err := aiParser.ParseJDFile(ctx, jdPath)
+	if err != nil {
+		return fmt.Errorf("JD解析失败: %w", err)
+	}
+
+	// zip压缩包解压到临时目录
+	tempDir, err := os.MkdirTemp("", "resume-screen-")
+	if err != nil {
+		return fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	var candidates []screening.Candidate
+	for i, path := range paths {
+		if strings.ToLower(filepath.Ext(path)) == ".zip" {
+			extracted, err := screening.ExtractZip(path, filepath.Join(tempDir, fmt.Sprintf("%d", i)), cfg.BatchMaxFiles, cfg.MaxFileSize)
+			if err != nil {
+				return fmt.Errorf("解压%s失败: %w", path, err)
+			}
+			candidates = append(candidates, extracted...)
+			continue
+		}
+		candidates = append(candidates, screening.Candidate{FileName: filepath.Base(path), FilePath: path})
+	}
+
+	parse := func(ctx context.Context, candidate screening.Candidate) (*models.Resume, error) {
+		if candidate.FilePath == "" {
+			return nil, fmt.Errorf("不支持的文件格式: %s", filepath.Ext(candidate.FileName))
+		}
+		// 每个并发任务使用独立的解析器
+		return newAIParser(cfg, redaction).ParseResumeFile(ctx, candidate.FilePath)
+	}
+	shortlist := screening.Screen(ctx, candidates, jd, parse, screening.NewKeywordMatcher(), parallelism)
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(shortlist); err != nil {
+			return fmt.Errorf("输出结果失败: %w", err)
+		}
+		return nil
+	}
+	printShortlist(jd, shortlist)
+	return nil
+}
+
+// newFileParser 根据配置创建文件解析器
+func newFileParser(cfg *config.Config) parser.FileParser {
+	var ocrProcessor ocr.OCRProcessor
+	if cfg.UseOCR {
+		ocrProcessor = parser.NewOCRChain(cfg)
+	}
+	return parser.NewResumeFileParser(ocrProcessor, cfg.UseOCR)
+}
+
+// newAIParser 创建按配置的AI服务优先级解析简历和JD的解析器，每个并发任务使用独立的解析器
+func newAIParser(cfg *config.Config, redaction *redact.Policy) *parser.AITextParser {
+	return parser.NewAITextParser("", false, newFileParser(cfg)).
+		WithProviders(cfg.Providers(), time.Duration(cfg.AITimeout)*time.Second).
+		WithRedaction(redaction).
+		WithChunkTokens(cfg.ParseChunkTokens)
+}
+
+// printShortlist 以表格形式输出筛选结果
+func printShortlist(jd *models.JobDescription, shortlist *screening.Shortlist) {
+	fmt.Printf("\n职位: %s  共%d份简历，成功%d份，失败%d份\n\n", jd.Title, shortlist.Total, shortlist.Succeeded, shortlist.Failed)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "排名\t文件\t姓名\t分数\t未满足的要求/失败原因")
+	for _, r := range shortlist.Results {
+		if r.Error != "" {
+			fmt.Fprintf(w, "-\t%s\t\t\t%s\n", r.FileName, r.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\n", r.Rank, r.FileName, r.Name, r.Score, strings.Join(r.Missing, "；"))
+	}
+	w.Flush()
+}
@@ -7,12 +7,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/10yihang/resume-ai-interview/api/handlers"
 	"github.com/10yihang/resume-ai-interview/config"
+	"github.com/10yihang/resume-ai-interview/internal/ai"
+	"github.com/10yihang/resume-ai-interview/internal/interview"
+	"github.com/10yihang/resume-ai-interview/internal/logging"
+	"github.com/10yihang/resume-ai-interview/internal/redact"
+	"github.com/10yihang/resume-ai-interview/internal/tracing"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
@@ -23,47 +33,181 @@ const (
 
 func main() {
 	// 加载环境变量
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: No .env file found")
+	envErr := godotenv.Load()
+
+	// 按 配置文件 < 环境变量 < 命令行参数 加载配置，存在无效配置时列出全部问题后退出
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		if config.IsHelp(err) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// 初始化日志
+	if err := logging.Setup(cfg.LogFormat, cfg.LogLevel); err != nil {
+		fmt.Fprintln(os.Stderr, "初始化日志失败:", err)
+		os.Exit(1)
+	}
+	if envErr != nil {
+		slog.Warn("未找到.env文件")
 	}
 
-	// 初始化配置
-	cfg := config.NewConfig()
+	// 出错时在run中的清理（临时目录、链路追踪）都执行完后再退出
+	if err := run(cfg); err != nil {
+		slog.Error("服务异常退出", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run 启动服务直到收到停止信号，退出前删除临时目录并导出剩余的span
+func run(cfg *config.Config) error {
 
 	// 打印版本和AI提供商信息
-	fmt.Printf("AI简历面试助手 v%s\n", Version)
-	if cfg.UseGrok {
-		fmt.Println("AI提供商: Grok 3")
-	} else if cfg.APIKey != "" {
-		fmt.Println("AI提供商: OpenAI")
-	} else {
-		fmt.Println("AI提供商: 模拟模式 (未配置API密钥)")
+	var providers []string
+	for _, provider := range cfg.Providers() {
+		providers = append(providers, provider.Name+"/"+provider.Model)
+	}
+	if len(providers) == 0 {
+		providers = []string{"mock"}
+		slog.Warn("未配置API密钥，将使用模拟模式")
 	}
-	// 创建Gin引擎
-	r := gin.Default()
+	slog.Info("AI简历面试助手启动", "version", Version, "providers", providers)
+	if !cfg.AuthEnabled {
+		slog.Warn("未启用用户认证，任何能访问服务的人都能查看全部简历，只应在本机使用")
+	}
+	if cfg.OCRAPIKey != "" && cfg.OCRSpaceKey() == "" {
+		slog.Warn("已配置OCR.space的API密钥，但REDACT_PROVIDERS包含ocrspace，原始文件无法遮蔽，不会使用OCR.space识别")
+	}
+
+	// 初始化链路追踪，退出时导出剩余的span
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, "resume-ai-interview", cfg.TracingSample)
+	if err != nil {
+		return fmt.Errorf("初始化链路追踪失败: %w", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// OCR生成的图像和解密的上传文件写在本进程专用的临时目录，退出时整体删除
+	cleanupTemp, err := useProcessTempDir()
+	if err != nil {
+		return fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer cleanupTemp()
+
+	// 创建Gin引擎，请求日志由RegisterRoutes中的中间件以结构化格式输出
+	r := gin.New()
+	r.Use(gin.Recovery())
 
 	// 加载静态文件
 	r.Static("/static", "./static")
 	r.LoadHTMLGlob("templates/*")
 
-	// 初始化handlers，传入配置
-	handlers.InitHandlers(cfg)
+	// 创建问题生成器和回答评估器
+	generator, evaluator := newAIComponents(cfg)
+
+	// 创建处理器服务，注入问题生成器和回答评估器
+	server, err := handlers.NewServer(cfg, generator, evaluator)
+	if err != nil {
+		return fmt.Errorf("创建服务失败: %w", err)
+	}
+	if err := server.Start(context.Background()); err != nil {
+		return fmt.Errorf("启动任务队列失败: %w", err)
+	}
 
 	// 设置路由
-	r.GET("/", handlers.IndexHandler)
-	r.POST("/upload/resume", handlers.UploadResumeHandler)
-	r.POST("/upload/jd", handlers.UploadJDHandler)
-	r.POST("/generate/questions", handlers.GenerateQuestionsHandler)
-	r.POST("/evaluate/answer", handlers.EvaluateAnswerHandler)
+	server.RegisterRoutes(r)
 
 	// 启动服务器
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	port := cfg.Port
+	srv := &http.Server{Addr: ":" + port, Handler: r, ReadHeaderTimeout: 10 * time.Second}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+	slog.Info("服务已启动", "addr", "http://localhost:"+port)
+
+	// 收到SIGINT或SIGTERM后停止服务，停止过程中再次收到信号时立即退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-serveErr:
+		server.Close()
+		return fmt.Errorf("服务运行失败: %w", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// 先让就绪探针返回503，等编排系统摘除本实例后再停止接受连接
+	server.Drain()
+	slog.Info("收到停止信号，开始停止服务", "drain_delay_s", cfg.DrainDelay, "timeout_s", cfg.ShutdownTimeout)
+	time.Sleep(time.Duration(cfg.DrainDelay) * time.Second)
+	shutdown(srv, server, time.Duration(cfg.ShutdownTimeout)*time.Second)
+	slog.Info("服务已停止")
+	return nil
+}
+
+// shutdown 在timeout内停止服务：不再接受新连接，同时等待进行中的请求和解析任务完成，
+// 超时后强制关闭连接并取消任务，最后关闭审计日志等资源
+func shutdown(srv *http.Server, server *handlers.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// 任务的状态推送要等任务结束才返回，请求和任务需要同时等待
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Warn("等待解析任务超时，未完成的任务已取消", "error", err)
+		}
+	}()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("等待进行中的请求超时，强制关闭连接", "error", err)
+		srv.Close()
+	}
+	<-jobsDone
+	server.Close()
+}
+
+// useProcessTempDir 为本进程创建专用的临时目录并设为系统临时目录，返回删除该目录的函数
+// tesseract、pdftoppm等子进程也继承该目录，任务被中途取消时遗留的文件随目录一起删除
+func useProcessTempDir() (func(), error) {
+	dir, err := os.MkdirTemp("", "resume-ai-interview-")
+	if err != nil {
+		return nil, err
+	}
+	// TMPDIR用于类Unix系统，TMP和TEMP用于Windows
+	for _, key := range []string{"TMPDIR", "TMP", "TEMP"} {
+		os.Setenv(key, dir)
+	}
+	return func() {
+		if err := os.RemoveAll(dir); err != nil {
+			slog.Warn("删除临时目录失败", "dir", dir, "error", err)
+		}
+	}, nil
+}
+
+// newAIComponents 按优先级为每个配置了密钥的AI服务创建问题生成器和回答评估器，前一个失败或超时时改用下一个
+// 对启用了遮蔽的服务，发送前遮蔽个人信息；没有配置任何密钥时使用模拟生成器和评估器
+func newAIComponents(cfg *config.Config) (ai.QuestionGeneratorInterface, interview.AnswerEvaluatorInterface) {
+	providers := cfg.Providers()
+	if len(providers) == 0 {
+		return ai.NewMockQuestionGenerator(), interview.NewMockAnswerEvaluator()
 	}
 
-	fmt.Printf("Server running on http://localhost:%s\n", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	redaction := redact.NewPolicy(cfg.RedactProviders)
+	var generators []ai.QuestionGeneratorInterface
+	var evaluators []interview.AnswerEvaluatorInterface
+	for _, provider := range providers {
+		useGrok := provider.Name == ai.ProviderGrok
+		generator := ai.GetQuestionGenerator(provider.APIKey, useGrok, provider.Model)
+		evaluator := interview.GetAnswerEvaluator(provider.APIKey, useGrok, provider.Model, cfg.Rubric)
+		if redaction.Enabled(provider.Name) {
+			generator = ai.NewRedactingQuestionGenerator(generator)
+			evaluator = interview.NewRedactingAnswerEvaluator(evaluator)
+		}
+		generators = append(generators, generator)
+		evaluators = append(evaluators, evaluator)
 	}
+
+	timeout := time.Duration(cfg.AITimeout) * time.Second
+	return ai.NewFailoverQuestionGenerator(timeout, generators...), interview.NewFailoverAnswerEvaluator(timeout, evaluators...)
 }
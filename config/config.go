@@ -1,92 +1,387 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 )
 
 // Config 保存应用程序配置信息
+// 每个字段的配置文件路径、环境变量和命令行参数见settings
 type Config struct {
-	APIKey        string
-	UseGrok       bool
-	MaxFileSize   int64
-	DataDir       string
-	OCRAPIKey     string
-	TesseractPath string
-	UseOCR        bool
+	APIKey           string   // 首选AI服务的API密钥，见Providers
+	UseGrok          bool     // 首选AI服务是否为Grok
+	AIProviders      []string // AI服务的优先级顺序，前一个调用失败或超时时改用下一个，为空时依次为grok、openai
+	AITimeout        int      // 每次调用AI服务的超时时间（秒），超时后改用下一个服务，0表示不限
+	ParseChunkTokens int      // 分块解析时每块简历文本的token数上限，超过上限的简历分块解析后合并
+	GrokAPIKey       string
+	GrokModel        string // Grok生成问题和评估回答使用的模型
+	GrokParseModel   string // Grok解析简历和JD使用的模型
+	OpenAIAPIKey     string
+	OpenAIModel      string // OpenAI生成问题和评估回答使用的模型
+	OpenAIParseModel string // OpenAI解析简历和JD使用的模型
+	Port             string // HTTP服务监听的端口
+	MaxFileSize      int64
+	DataDir          string
+	UploadDir        string // 上传文件保存目录
+	OCRAPIKey        string
+	TesseractPath    string
+	UseOCR           bool
+	OCREngines       []string // OCR引擎的顺序，前一个失败或某页识别质量低时改用下一个，为空时依次为pdftext、tesseract、ocrspace
+	OCRMinQuality    float64  // 页识别质量的阈值，0到1之间，低于该值的页改用下一个引擎识别
+	OCRDPI           int      // PDF转换为图像的分辨率
+	OCRWorkers       int      // 每个文件同时识别的页数上限
+	OCRPreprocess    bool     // Tesseract识别前是否预处理图像
+	TesseractLangs   string   // Tesseract使用的语言包，多个用+连接，如chi_sim+eng
+	TesseractPSM     int      // Tesseract的页面分割模式（--psm）
+	TesseractOEM     int      // Tesseract的识别引擎模式（--oem）
+	TesseractOutput  string   // Tesseract的输出格式：text、tsv或hocr，tsv和hocr按版面重建多栏文本的阅读顺序
+	PersistData      bool     // 是否将任务等数据持久化到DataDir
+	JobWorkers       int      // 后台任务并发数
+	JobQueueSize     int      // 等待中任务的上限
+	BatchParallelism int      // 批量筛选时同时解析的简历数上限
+	BatchMaxFiles    int      // 单次批量筛选的简历数上限
+	AuthEnabled      bool     // 是否启用用户认证，默认启用，关闭时所有请求视为同一个本地用户
+	AllowSignup      bool     // 是否允许不带邀请码的自助注册
+	SessionTTLHours  int      // 网页登录会话的有效期（小时）
+	RedactProviders  []string // 发送数据前需要遮蔽个人信息的外部服务
+	RetentionDays    int      // 上传的原始文件保留天数，超过后自动删除只保留解析结果，0表示永久保留
+	EncryptionKeys   string   // 加密上传文件和持久化记录的主密钥，格式为 id:base64密钥，多个用逗号分隔，第一个用于加密
+	UsagePrices      string   // 覆盖或补充内置的模型价格表，格式为 模型=输入价格:输出价格（美元/百万token），多个用逗号分隔
+	UserDailyBudget  float64  // 每个用户每天的大模型费用上限（美元），0表示不限
+	SessionBudget    float64  // 每场面试会话的大模型费用上限（美元），0表示不限
+	BudgetAction     string   // 超出预算时的处理方式：degrade改用模拟生成器和评估器，block拒绝请求
+	LogFormat        string   // 日志格式：json或text
+	LogLevel         string   // 日志级别：debug、info、warn或error
+	TracingExporter  string   // 链路追踪导出方式：none、stdout或otlp
+	TracingSample    float64  // 链路追踪采样比例，0到1之间
+	ShutdownTimeout  int      // 停止服务时等待进行中的请求和解析任务的最长时间（秒）
+	DrainDelay       int      // 收到停止信号后就绪探针先返回503，等待该秒数后才停止接受连接
+	Rubric           []string // 评估回答时的评分标准，每项一个分数段，为空时使用内置标准
 }
 
-// NewConfig 创建一个新的配置实例
-func NewConfig() *Config {
-	// 优先尝试使用Grok 3 API密钥
-	apiKey := getEnvOrDefault("GROK3_API_KEY", "")
-	useGrok := true
+// 配置值的来源，优先级从低到高
+const (
+	SourceDefault = "默认值"
+	SourceFile    = "配置文件"
+	SourceEnv     = "环境变量"
+	SourceFlag    = "命令行参数"
+)
+
+// Issue 是一项无效的配置
+type Issue struct {
+	Key    string // 配置文件中的路径，如limits.maxFileSize
+	Env    string // 对应的环境变量名
+	Source string // 无效值的来源
+	Value  string // 无效的值，密钥已遮蔽
+	Reason string
+}
 
-	// 如果没有配置Grok 3 API密钥，尝试使用OpenAI API密钥
-	if apiKey == "" {
-		apiKey = getEnvOrDefault("OPENAI_API_KEY", "")
-		useGrok = false
+// String 返回配置项、来源、取值和原因，如 limits.maxFileSize（环境变量 MAX_FILE_SIZE）= "10MB": 不是有效的整数
+func (i Issue) String() string {
+	where := i.Source
+	switch {
+	case i.Env == "":
+	case i.Source == SourceEnv:
+		where += " " + i.Env
+	case i.Source == SourceFlag:
+		where += " -" + setting{env: i.Env}.flagName()
 	}
+	if i.Value != "" {
+		return fmt.Sprintf("%s（%s）= %q: %s", i.Key, where, i.Value, i.Reason)
+	}
+	return fmt.Sprintf("%s（%s）: %s", i.Key, where, i.Reason)
+}
 
-	// OCR配置
-	ocrAPIKey := getEnvOrDefault("OCR_SPACE_API_KEY", "")
-	tesseractPath := getEnvOrDefault("TESSERACT_PATH", "tesseract")
-	useOCR := getEnvOrDefault("USE_OCR", "true") == "true"
+// ValidationError 汇总加载配置时发现的所有无效配置项
+type ValidationError struct {
+	Issues []Issue
+}
 
-	config := &Config{
-		APIKey:        apiKey,
-		UseGrok:       useGrok,
-		MaxFileSize:   getEnvAsInt64OrDefault("MAX_FILE_SIZE", 10*1024*1024), // 默认10MB
-		DataDir:       getEnvOrDefault("DATA_DIR", "./data"),
-		OCRAPIKey:     ocrAPIKey,
-		TesseractPath: tesseractPath,
-		UseOCR:        useOCR,
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "配置无效（共%d项）:", len(e.Issues))
+	for _, issue := range e.Issues {
+		b.WriteString("\n  - ")
+		b.WriteString(issue.String())
 	}
+	return b.String()
+}
 
-	// 打印配置信息
-	if config.UseGrok {
-		fmt.Printf("使用Grok 3 API，API密钥长度: %d\n", len(config.APIKey))
-	} else if config.APIKey != "" {
-		fmt.Printf("使用OpenAI API，API密钥长度: %d\n", len(config.APIKey))
-	} else {
-		fmt.Println("警告: 未配置API密钥，将使用模拟模式")
+// defaults 返回所有配置项为默认值的配置
+func defaults() *Config {
+	return &Config{
+		GrokModel:        "grok-3",
+		GrokParseModel:   "grok-3-latest",
+		OpenAIModel:      "gpt-4-turbo-preview",
+		OpenAIParseModel: "gpt-4o",
+		AITimeout:        120,
+		ParseChunkTokens: 3000,
+		Port:             "8080",
+		MaxFileSize:      10 * 1024 * 1024, // 默认10MB
+		DataDir:          "./data",
+		UploadDir:        "./uploads",
+		TesseractPath:    "tesseract",
+		UseOCR:           true,
+		OCRMinQuality:    0.6,
+		OCRDPI:           300,
+		OCRWorkers:       2,
+		OCRPreprocess:    true,
+		TesseractLangs:   "chi_sim+eng",
+		TesseractPSM:     3,
+		TesseractOEM:     3,
+		TesseractOutput:  "tsv",
+		JobWorkers:       2,
+		JobQueueSize:     100,
+		BatchParallelism: 4,
+		BatchMaxFiles:    100,
+		AuthEnabled:      true,
+		AllowSignup:      true,
+		SessionTTLHours:  7 * 24,
+		RedactProviders:  []string{"openai", "grok", "ocrspace"},
+		BudgetAction:     "degrade",
+		LogFormat:        "json",
+		LogLevel:         "info",
+		TracingExporter:  "none",
+		TracingSample:    1,
+		ShutdownTimeout:  30,
+		DrainDelay:       5,
 	}
+}
 
+// NewConfig 创建一个新的配置实例，使用默认值和环境变量
+// 无效的环境变量会被忽略并保留默认值；服务启动时应使用Load，以便发现并报告无效配置
+func NewConfig() *Config {
+	config := defaults()
+	for _, s := range config.settings() {
+		if raw, ok := os.LookupEnv(s.env); ok {
+			s.value.Set(raw)
+		}
+	}
+	config.resolveProvider()
 	return config
 }
 
-// Load 从环境变量加载配置
-func Load() (*Config, error) {
-	config := NewConfig()
+// Load 按 默认值 < 配置文件 < 环境变量 < 命令行参数 的优先级加载配置，并校验所有配置项
+// args是命令行参数（不含程序名），配置文件通过-config参数或环境变量CONFIG_FILE指定，支持YAML和TOML；
+// 存在无效配置时返回*ValidationError，列出每一项的来源和原因，而不是只报告第一项
+func Load(args []string) (*Config, error) {
+	config := defaults()
+	settings := config.settings()
+	sources := make(map[string]string, len(settings))
+	var issues []Issue
+
+	// 命令行参数最后应用，这里先只记录原始值
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "配置文件路径（.yaml、.yml或.toml）")
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		if s.secret {
+			continue // 密钥不通过命令行传入，避免出现在进程列表中
+		}
+		s := s
+		fs.Func(s.flagName(), s.doc, func(raw string) error {
+			flagValues[s.key] = raw
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	apply := func(s setting, source, raw string) {
+		sources[s.key] = source
+		if err := s.value.Set(raw); err != nil {
+			issues = append(issues, s.issue(source, raw, err.Error()))
+		}
+	}
 
-	if config.APIKey == "" {
-		return config, fmt.Errorf("未找到API密钥配置，请设置GROK3_API_KEY或OPENAI_API_KEY环境变量")
+	if *configFile != "" {
+		values, fileIssues, err := readFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, fileIssues...)
+		known := make(map[string]bool, len(settings))
+		for _, s := range settings {
+			known[s.key] = true
+			raw, ok := values[s.key]
+			if !ok {
+				continue
+			}
+			sources[s.key] = SourceFile
+			if list, isList := raw.([]string); isList {
+				if setter, ok := s.value.(listSetter); ok {
+					setter.SetList(list)
+					continue
+				}
+				issues = append(issues, s.issue(SourceFile, strings.Join(list, ","), "不能是列表"))
+				continue
+			}
+			apply(s, SourceFile, raw.(string))
+		}
+		var unknown []string
+		for key := range values {
+			if !known[key] {
+				unknown = append(unknown, key)
+			}
+		}
+		sort.Strings(unknown)
+		for _, key := range unknown {
+			issues = append(issues, Issue{Key: key, Source: SourceFile, Reason: "未知的配置项"})
+		}
+	}
+	for _, s := range settings {
+		if raw, ok := os.LookupEnv(s.env); ok {
+			apply(s, SourceEnv, raw)
+		}
+	}
+	for _, s := range settings {
+		if raw, ok := flagValues[s.key]; ok {
+			apply(s, SourceFlag, raw)
+		}
+	}
+
+	// 解析失败的配置项不再做范围校验，避免同一项报告两次
+	invalid := make(map[string]bool, len(issues))
+	for _, issue := range issues {
+		invalid[issue.Key] = true
+	}
+	for _, s := range settings {
+		if s.check == nil || invalid[s.key] {
+			continue
+		}
+		if reason := s.check(); reason != "" {
+			source := sources[s.key]
+			if source == "" {
+				source = SourceDefault
+			}
+			issues = append(issues, s.issue(source, s.display(), reason))
+		}
 	}
 
+	config.resolveProvider()
+	if len(issues) > 0 {
+		return nil, &ValidationError{Issues: issues}
+	}
 	return config, nil
 }
 
-// getEnvOrDefault 获取环境变量或返回默认值
-func getEnvOrDefault(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+// Provider 是一个配置了API密钥的AI服务
+type Provider struct {
+	Name       string // grok或openai
+	APIKey     string
+	Model      string // 生成问题和评估回答使用的模型
+	ParseModel string // 解析简历和JD使用的模型
+}
+
+// Providers 按优先级返回配置了API密钥的AI服务，没有配置任何密钥时返回空列表
+// 未配置AIProviders时先Grok后OpenAI，与只配置一个密钥时的行为一致
+func (c *Config) Providers() []Provider {
+	order := c.AIProviders
+	if len(order) == 0 {
+		order = []string{"grok", "openai"}
+	}
+	var providers []Provider
+	for _, name := range order {
+		if provider, ok := c.provider(name); ok {
+			providers = append(providers, provider)
+		}
+	}
+	return providers
+}
+
+// provider 返回指定名称的AI服务，未配置API密钥时返回false
+func (c *Config) provider(name string) (Provider, bool) {
+	var provider Provider
+	switch strings.ToLower(name) {
+	case "grok":
+		provider = Provider{Name: "grok", APIKey: c.GrokAPIKey, Model: c.GrokModel, ParseModel: c.GrokParseModel}
+	case "openai":
+		provider = Provider{Name: "openai", APIKey: c.OpenAIAPIKey, Model: c.OpenAIModel, ParseModel: c.OpenAIParseModel}
+	}
+	return provider, provider.APIKey != ""
+}
+
+// resolveProvider 把优先级最高的AI服务设为首选服务
+func (c *Config) resolveProvider() {
+	c.APIKey, c.UseGrok = "", false
+	if providers := c.Providers(); len(providers) > 0 {
+		c.APIKey, c.UseGrok = providers[0].APIKey, providers[0].Name == "grok"
+	}
+}
+
+// AIProvider 返回首选AI服务的名称，未配置API密钥时返回空字符串
+func (c *Config) AIProvider() string {
+	switch {
+	case c.APIKey == "":
+		return ""
+	case c.UseGrok:
+		return "grok"
+	default:
+		return "openai"
+	}
+}
+
+// ChatModel 返回首选AI服务生成问题和评估回答使用的模型
+func (c *Config) ChatModel() string {
+	if c.UseGrok {
+		return c.GrokModel
 	}
-	return defaultValue
+	return c.OpenAIModel
 }
 
-// getEnvAsInt64OrDefault 获取环境变量并转换为int64或返回默认值
-func getEnvAsInt64OrDefault(key string, defaultValue int64) int64 {
-	if value, exists := os.LookupEnv(key); exists {
-		if parsed, err := parseInt64(value); err == nil {
-			return parsed
+// ParseModel 返回首选AI服务解析简历和JD使用的模型
+func (c *Config) ParseModel() string {
+	if c.UseGrok {
+		return c.GrokParseModel
+	}
+	return c.OpenAIParseModel
+}
+
+// OCRSpaceKey 返回OCR.space的API密钥
+// 上传到OCR.space的原始文件无法遮蔽个人信息，配置了对ocrspace遮蔽时返回空字符串，改用本地Tesseract识别
+func (c *Config) OCRSpaceKey() string {
+	for _, provider := range c.RedactProviders {
+		if strings.EqualFold(provider, "ocrspace") {
+			return ""
 		}
 	}
-	return defaultValue
+	return c.OCRAPIKey
+}
+
+// OCREngineOrder 按顺序返回可用的OCR引擎名称，未配置或不允许使用OCR.space时去掉ocrspace
+func (c *Config) OCREngineOrder() []string {
+	order := c.OCREngines
+	if len(order) == 0 {
+		order = []string{"pdftext", "tesseract", "ocrspace"}
+	}
+	var engines []string
+	for _, name := range order {
+		name = strings.ToLower(name)
+		if name == "ocrspace" && c.OCRSpaceKey() == "" {
+			continue
+		}
+		engines = append(engines, name)
+	}
+	return engines
+}
+
+// Masked 返回以环境变量名为键的当前配置，用于诊断
+// API密钥只保留末尾4位，加密密钥只保留密钥ID
+func (c *Config) Masked() map[string]string {
+	settings := c.settings()
+	masked := make(map[string]string, len(settings))
+	for _, s := range settings {
+		masked[s.env] = s.display()
+	}
+	return masked
 }
 
-// parseInt64 将字符串解析为int64
-func parseInt64(value string) (int64, error) {
-	var result int64
-	_, err := fmt.Sscanf(value, "%d", &result)
-	return result, err
+// IsHelp 返回错误是否因为命令行参数中有-h或-help
+func IsHelp(err error) bool {
+	return errors.Is(err, flag.ErrHelp)
 }
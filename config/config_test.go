@@ -0,0 +1,234 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  port: 9090
+providers:
+  openai:
+    apiKey: sk-file-000000001234
+    model: gpt-4o-mini
+limits:
+  maxFileSize: 2048
+  jobWorkers: 3
+privacy:
+  redactProviders: [grok]
+evaluation:
+  rubric:
+    - "1-5分：不合格"
+    - "6-10分：合格"
+`)
+	t.Setenv("JOB_WORKERS", "5")
+
+	cfg, err := Load([]string{"-config", path, "-job-workers", "7", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if cfg.Port != "9090" || cfg.MaxFileSize != 2048 || cfg.LogLevel != "debug" {
+		t.Fatalf("配置文件和命令行参数应覆盖默认值: %+v", cfg)
+	}
+	if cfg.JobWorkers != 7 {
+		t.Fatalf("命令行参数应覆盖环境变量和配置文件，得到%d", cfg.JobWorkers)
+	}
+	if cfg.UseGrok || cfg.APIKey != "sk-file-000000001234" || cfg.ChatModel() != "gpt-4o-mini" || cfg.ParseModel() != "gpt-4o" {
+		t.Fatalf("只配置OpenAI密钥时应使用OpenAI和配置的模型: %+v", cfg)
+	}
+	if strings.Join(cfg.RedactProviders, ",") != "grok" || len(cfg.Rubric) != 2 {
+		t.Fatalf("配置文件中的列表应原样读取: %v %v", cfg.RedactProviders, cfg.Rubric)
+	}
+	if cfg.Masked()["OPENAI_API_KEY"] != "****1234" {
+		t.Fatalf("诊断信息中的密钥应遮蔽: %s", cfg.Masked()["OPENAI_API_KEY"])
+	}
+
+	t.Setenv("JOB_WORKERS", "4")
+	cfg, err = Load([]string{"-config", path})
+	if err != nil || cfg.JobWorkers != 4 {
+		t.Fatalf("环境变量应覆盖配置文件: %v %+v", err, cfg)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[providers.grok]
+apiKey = "xai-000000005678"
+model = "grok-3-mini"
+
+[ocr]
+enabled = false
+
+[usage]
+sessionBudget = 0.5
+`)
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("加载TOML配置失败: %v", err)
+	}
+	if !cfg.UseGrok || cfg.ChatModel() != "grok-3-mini" || cfg.UseOCR || cfg.SessionBudget != 0.5 {
+		t.Fatalf("应读取环境变量CONFIG_FILE指定的TOML配置: %+v", cfg)
+	}
+}
+
+func TestLoadReportsAllIssues(t *testing.T) {
+	path := writeFile(t, "config.yml", `
+limits:
+  maxFileSize: 10MB
+  jobWorker: 2
+logging:
+  format: xml
+`)
+	t.Setenv("USE_OCR", "yes")
+	t.Setenv("OCR_SPACE_API_KEY", "secret-key-000009999")
+
+	_, err := Load([]string{"-config", path, "-tracing-sample-ratio", "2"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("存在无效配置时应返回ValidationError，得到%v", err)
+	}
+
+	found := map[string]Issue{}
+	for _, issue := range verr.Issues {
+		found[issue.Key] = issue
+	}
+	for key, source := range map[string]string{
+		"limits.maxFileSize":  SourceFile,
+		"limits.jobWorker":    SourceFile,
+		"logging.format":      SourceFile,
+		"ocr.enabled":         SourceEnv,
+		"tracing.sampleRatio": SourceFlag,
+	} {
+		issue, ok := found[key]
+		if !ok {
+			t.Fatalf("应报告%s无效: %v", key, err)
+		}
+		if issue.Source != source {
+			t.Fatalf("%s的来源应为%s，得到%s", key, source, issue.Source)
+		}
+	}
+	if len(verr.Issues) != 5 {
+		t.Fatalf("每项无效配置只应报告一次: %v", err)
+	}
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Fatalf("错误信息中不应出现密钥: %v", err)
+	}
+}
+
+func TestLoadRejectsUnknownFormat(t *testing.T) {
+	path := writeFile(t, "config.json", `{}`)
+	if _, err := Load([]string{"-config", path}); err == nil {
+		t.Fatalf("不支持的配置文件格式应返回错误")
+	}
+	if _, err := Load([]string{"-h"}); !IsHelp(err) {
+		t.Fatalf("-h应返回帮助错误，得到%v", err)
+	}
+}
+
+func TestNewConfigIgnoresInvalidEnv(t *testing.T) {
+	t.Setenv("MAX_FILE_SIZE", "abc")
+	t.Setenv("REDACT_PROVIDERS", "none")
+	t.Setenv("EVALUATION_RUBRIC", "0-5分：差|6-10分：好")
+
+	cfg := NewConfig()
+	if cfg.MaxFileSize != 10*1024*1024 {
+		t.Fatalf("无效的环境变量应保留默认值，得到%d", cfg.MaxFileSize)
+	}
+	if !cfg.AuthEnabled {
+		t.Fatalf("默认应启用用户认证")
+	}
+	if len(cfg.RedactProviders) != 0 || len(cfg.Rubric) != 2 {
+		t.Fatalf("列表应按分隔符拆分，none表示空列表: %v %v", cfg.RedactProviders, cfg.Rubric)
+	}
+}
+
+func TestProvidersOrder(t *testing.T) {
+	t.Setenv("GROK3_API_KEY", "xai-000000001111")
+	t.Setenv("OPENAI_API_KEY", "sk-000000002222")
+	t.Setenv("OPENAI_MODEL", "gpt-4o")
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if providers := cfg.Providers(); len(providers) != 2 || providers[0].Name != "grok" || !cfg.UseGrok {
+		t.Fatalf("未配置顺序时应先Grok后OpenAI: %+v", providers)
+	}
+
+	cfg, err = Load([]string{"-ai-providers", "openai,grok", "-ai-timeout", "30"})
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	providers := cfg.Providers()
+	if len(providers) != 2 || providers[0].Name != "openai" || providers[0].Model != "gpt-4o" || providers[1].Name != "grok" {
+		t.Fatalf("应按配置的顺序使用AI服务: %+v", providers)
+	}
+	if cfg.UseGrok || cfg.APIKey != "sk-000000002222" || cfg.AITimeout != 30 {
+		t.Fatalf("首选服务应为OpenAI: %+v", cfg)
+	}
+
+	t.Setenv("GROK3_API_KEY", "")
+	_, err = Load([]string{"-ai-providers", "grok,openai,openai"})
+	if err == nil || !strings.Contains(err.Error(), "providers.order") {
+		t.Fatalf("重复或未配置密钥的服务应报告无效，得到%v", err)
+	}
+}
+
+func TestOCREngineOrder(t *testing.T) {
+	t.Setenv("OCR_SPACE_API_KEY", "ocr-000000003333")
+	t.Setenv("REDACT_PROVIDERS", "openai,grok,ocrspace")
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if order := strings.Join(cfg.OCREngineOrder(), ","); order != "pdftext,tesseract" {
+		t.Fatalf("未配置顺序时应先读取文本层再用Tesseract，遮蔽ocrspace时不使用OCR.space，得到%s", order)
+	}
+
+	cfg, err = Load([]string{"-ocr-engines", "ocrspace,tesseract", "-redact-providers", "none", "-ocr-min-quality", "0.8"})
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if order := strings.Join(cfg.OCREngineOrder(), ","); order != "ocrspace,tesseract" || cfg.OCRMinQuality != 0.8 {
+		t.Fatalf("应按配置的顺序使用OCR引擎: %s %v", order, cfg.OCRMinQuality)
+	}
+
+	_, err = Load([]string{"-ocr-engines", "tesseract,easyocr,tesseract", "-ocr-min-quality", "1.5"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Issues) != 2 {
+		t.Fatalf("未知的引擎和超出范围的阈值应报告无效，得到%v", err)
+	}
+}
+
+func TestTesseractSettings(t *testing.T) {
+	cfg, err := Load([]string{"-tesseract-languages", "chi_tra+eng", "-tesseract-psm", "6", "-tesseract-output", "hocr", "-ocr-dpi", "400"})
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if cfg.TesseractLangs != "chi_tra+eng" || cfg.TesseractPSM != 6 || cfg.TesseractOEM != 3 || cfg.TesseractOutput != "hocr" || cfg.OCRDPI != 400 || cfg.OCRWorkers != 2 || !cfg.OCRPreprocess {
+		t.Fatalf("应读取Tesseract的识别参数: %+v", cfg)
+	}
+
+	_, err = Load([]string{"-tesseract-languages", "chi sim", "-tesseract-psm", "0", "-tesseract-output", "pdf", "-ocr-dpi", "30", "-ocr-workers", "0"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Issues) != 5 {
+		t.Fatalf("无效的语言包、分割模式、输出格式、分辨率和并发数应报告无效，得到%v", err)
+	}
+}
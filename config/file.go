@@ -0,0 +1,108 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile 读取YAML或TOML配置文件，按扩展名决定格式
+// 返回以点分隔路径为键的取值，标量转为字符串，列表转为[]string；无法作为配置值的条目记为无效配置
+func readFile(path string) (map[string]any, []Issue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		if err := decoder.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("解析配置文件失败: %w", err)
+		}
+	case ".toml":
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return nil, nil, fmt.Errorf("解析配置文件失败: %w", err)
+		}
+	default:
+		return nil, nil, fmt.Errorf("不支持的配置文件格式: %s，只支持.yaml、.yml和.toml", ext)
+	}
+
+	values := map[string]any{}
+	var issues []Issue
+	flatten("", doc, values, &issues)
+	return values, issues, nil
+}
+
+// flatten 把嵌套的配置展开为以点分隔路径为键的取值
+func flatten(prefix string, node map[string]any, values map[string]any, issues *[]Issue) {
+	names := make([]string, 0, len(node))
+	for name := range node {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v := node[name]
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			flatten(key, v, values, issues)
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				s, ok := scalar(item)
+				if !ok {
+					*issues = append(*issues, Issue{Key: key, Source: SourceFile, Reason: "列表中只能是字符串或数字"})
+					items = nil
+					break
+				}
+				items = append(items, s)
+			}
+			if items != nil {
+				values[key] = items
+			}
+		default:
+			s, ok := scalar(v)
+			if !ok {
+				*issues = append(*issues, Issue{Key: key, Source: SourceFile, Reason: "不是有效的配置值"})
+				continue
+			}
+			values[key] = s
+		}
+	}
+}
+
+// scalar 把配置文件中的标量转为字符串，交给对应配置项按其类型严格解析
+func scalar(v any) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,399 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// setting 是一个配置项，同一个配置项可以来自配置文件、环境变量或命令行参数
+type setting struct {
+	key    string              // 配置文件中的路径，如limits.maxFileSize
+	env    string              // 环境变量名
+	doc    string              // 说明，用作命令行参数的帮助信息
+	secret bool                // 密钥，展示时遮蔽且不接受命令行参数
+	value  value               // 指向Config中的字段
+	check  func() string       // 校验取值，返回无效的原因，有效时返回空字符串
+	mask   func(string) string // 展示时遮蔽取值，为nil时使用maskSecret
+}
+
+// value 是配置项的取值，Set解析字符串形式的值
+type value interface {
+	Set(raw string) error
+	String() string
+}
+
+// listSetter 是列表类型的取值，配置文件中的列表直接设置，不需要拆分字符串
+type listSetter interface {
+	SetList(items []string)
+}
+
+// flagName 返回配置项的命令行参数名，由环境变量名转为小写并用-连接，如MAX_FILE_SIZE对应-max-file-size
+func (s setting) flagName() string {
+	return strings.ToLower(strings.ReplaceAll(s.env, "_", "-"))
+}
+
+// display 返回展示用的取值，密钥已遮蔽
+func (s setting) display() string {
+	raw := s.value.String()
+	if !s.secret {
+		return raw
+	}
+	if s.mask != nil {
+		return s.mask(raw)
+	}
+	return maskSecret(raw)
+}
+
+// issue 创建该配置项的一条无效记录
+func (s setting) issue(source, raw, reason string) Issue {
+	if s.secret {
+		raw = maskSecret(raw)
+	}
+	return Issue{Key: s.key, Env: s.env, Source: source, Value: raw, Reason: reason}
+}
+
+// settings 返回绑定到c各字段的配置项表，配置文件、环境变量、命令行参数、校验和诊断展示都由该表驱动
+func (c *Config) settings() []setting {
+	return []setting{
+		// 服务
+		{key: "server.port", env: "PORT", doc: "HTTP服务监听的端口", value: (*stringValue)(&c.Port), check: portCheck(&c.Port)},
+		{key: "server.drainDelay", env: "DRAIN_DELAY", doc: "收到停止信号后就绪探针先返回503，等待该秒数后才停止接受连接", value: (*intValue)(&c.DrainDelay), check: atLeast(&c.DrainDelay, 0)},
+		{key: "server.shutdownTimeout", env: "SHUTDOWN_TIMEOUT", doc: "停止服务时等待进行中的请求和任务的最长时间（秒）", value: (*intValue)(&c.ShutdownTimeout), check: atLeast(&c.ShutdownTimeout, 0)},
+
+		// AI服务和模型
+		{key: "providers.order", env: "AI_PROVIDERS", doc: "AI服务的优先级顺序，逗号分隔，调用失败或超时时改用下一个", value: &listValue{p: &c.AIProviders, sep: ","}, check: c.providersCheck},
+		{key: "providers.timeout", env: "AI_TIMEOUT", doc: "每次调用AI服务的超时时间（秒），超时后改用下一个服务，0表示不限", value: (*intValue)(&c.AITimeout), check: atLeast(&c.AITimeout, 0)},
+		{key: "providers.parseChunkTokens", env: "PARSE_CHUNK_TOKENS", doc: "分块解析时每块简历文本的token数上限，超过上限的简历分块解析后合并", value: (*intValue)(&c.ParseChunkTokens), check: atLeast(&c.ParseChunkTokens, 500)},
+		{key: "providers.grok.apiKey", env: "GROK3_API_KEY", secret: true, value: (*stringValue)(&c.GrokAPIKey)},
+		{key: "providers.grok.model", env: "GROK3_MODEL", doc: "Grok生成问题和评估回答使用的模型", value: (*stringValue)(&c.GrokModel), check: notEmpty(&c.GrokModel)},
+		{key: "providers.grok.parseModel", env: "GROK3_PARSE_MODEL", doc: "Grok解析简历和JD使用的模型", value: (*stringValue)(&c.GrokParseModel), check: notEmpty(&c.GrokParseModel)},
+		{key: "providers.openai.apiKey", env: "OPENAI_API_KEY", secret: true, value: (*stringValue)(&c.OpenAIAPIKey)},
+		{key: "providers.openai.model", env: "OPENAI_MODEL", doc: "OpenAI生成问题和评估回答使用的模型", value: (*stringValue)(&c.OpenAIModel), check: notEmpty(&c.OpenAIModel)},
+		{key: "providers.openai.parseModel", env: "OPENAI_PARSE_MODEL", doc: "OpenAI解析简历和JD使用的模型", value: (*stringValue)(&c.OpenAIParseModel), check: notEmpty(&c.OpenAIParseModel)},
+
+		// OCR
+		{key: "ocr.enabled", env: "USE_OCR", doc: "是否使用OCR识别PDF和图片", value: (*boolValue)(&c.UseOCR)},
+		{key: "ocr.tesseractPath", env: "TESSERACT_PATH", doc: "Tesseract可执行文件路径", value: (*stringValue)(&c.TesseractPath), check: notEmpty(&c.TesseractPath)},
+		{key: "ocr.workers", env: "OCR_WORKERS", doc: "每个文件同时识别的页数上限", value: (*intValue)(&c.OCRWorkers), check: atLeast(&c.OCRWorkers, 1)},
+		{key: "ocr.preprocess", env: "OCR_PREPROCESS", doc: "Tesseract识别前是否预处理图像：转正、灰度化、缩放、二值化和纠偏", value: (*boolValue)(&c.OCRPreprocess)},
+		{key: "ocr.dpi", env: "OCR_DPI", doc: "PDF转换为图像的分辨率", value: (*intValue)(&c.OCRDPI), check: intBetween(&c.OCRDPI, 72, 1200)},
+		{key: "ocr.tesseractLanguages", env: "TESSERACT_LANGUAGES", doc: "Tesseract使用的语言包，多个用+连接，如chi_sim+eng", value: (*stringValue)(&c.TesseractLangs), check: languagesCheck(&c.TesseractLangs)},
+		{key: "ocr.tesseractPsm", env: "TESSERACT_PSM", doc: "Tesseract的页面分割模式（--psm），1到13", value: (*intValue)(&c.TesseractPSM), check: intBetween(&c.TesseractPSM, 1, 13)},
+		{key: "ocr.tesseractOem", env: "TESSERACT_OEM", doc: "Tesseract的识别引擎模式（--oem），0到3", value: (*intValue)(&c.TesseractOEM), check: intBetween(&c.TesseractOEM, 0, 3)},
+		{key: "ocr.tesseractOutput", env: "TESSERACT_OUTPUT", doc: "Tesseract的输出格式：text、tsv或hocr，tsv和hocr按版面重建阅读顺序", value: (*stringValue)(&c.TesseractOutput), check: oneOf(&c.TesseractOutput, "text", "tsv", "hocr")},
+		{key: "ocr.ocrSpaceApiKey", env: "OCR_SPACE_API_KEY", secret: true, value: (*stringValue)(&c.OCRAPIKey)},
+		{key: "ocr.engines", env: "OCR_ENGINES", doc: "OCR引擎的顺序，逗号分隔，前一个失败或某页识别质量低时改用下一个", value: &listValue{p: &c.OCREngines, sep: ","}, check: c.ocrEnginesCheck},
+		{key: "ocr.minQuality", env: "OCR_MIN_QUALITY", doc: "页识别质量的阈值，0到1之间，低于该值的页改用下一个引擎识别", value: (*floatValue)(&c.OCRMinQuality), check: between(&c.OCRMinQuality, 0, 1)},
+
+		// 存储
+		{key: "storage.dataDir", env: "DATA_DIR", doc: "任务、账号等数据的保存目录", value: (*stringValue)(&c.DataDir), check: notEmpty(&c.DataDir)},
+		{key: "storage.uploadDir", env: "UPLOAD_DIR", doc: "上传文件的保存目录", value: (*stringValue)(&c.UploadDir), check: notEmpty(&c.UploadDir)},
+		{key: "storage.persist", env: "PERSIST_DATA", doc: "是否把数据持久化到数据目录", value: (*boolValue)(&c.PersistData)},
+		{key: "storage.retentionDays", env: "RETENTION_DAYS", doc: "上传的原始文件保留天数，0表示永久保留", value: (*intValue)(&c.RetentionDays), check: atLeast(&c.RetentionDays, 0)},
+		{key: "storage.encryptionKeys", env: "ENCRYPTION_KEYS", secret: true, value: (*stringValue)(&c.EncryptionKeys), check: keyringCheck(&c.EncryptionKeys), mask: maskKeyring},
+
+		// 限制
+		{key: "limits.maxFileSize", env: "MAX_FILE_SIZE", doc: "上传文件的大小上限（字节）", value: (*int64Value)(&c.MaxFileSize), check: positive64(&c.MaxFileSize)},
+		{key: "limits.jobWorkers", env: "JOB_WORKERS", doc: "后台解析任务的并发数", value: (*intValue)(&c.JobWorkers), check: atLeast(&c.JobWorkers, 1)},
+		{key: "limits.jobQueueSize", env: "JOB_QUEUE_SIZE", doc: "等待中解析任务的上限", value: (*intValue)(&c.JobQueueSize), check: atLeast(&c.JobQueueSize, 1)},
+		{key: "limits.batchParallelism", env: "BATCH_PARALLELISM", doc: "批量筛选时同时解析的简历数上限", value: (*intValue)(&c.BatchParallelism), check: atLeast(&c.BatchParallelism, 1)},
+		{key: "limits.batchMaxFiles", env: "BATCH_MAX_FILES", doc: "单次批量筛选的简历数上限", value: (*intValue)(&c.BatchMaxFiles), check: atLeast(&c.BatchMaxFiles, 1)},
+
+		// 认证
+		{key: "auth.enabled", env: "AUTH_ENABLED", doc: "是否启用用户认证", value: (*boolValue)(&c.AuthEnabled)},
+		{key: "auth.allowSignup", env: "ALLOW_SIGNUP", doc: "是否允许不带邀请码的自助注册", value: (*boolValue)(&c.AllowSignup)},
+		{key: "auth.sessionTTLHours", env: "SESSION_TTL_HOURS", doc: "网页登录会话的有效期（小时）", value: (*intValue)(&c.SessionTTLHours), check: atLeast(&c.SessionTTLHours, 1)},
+
+		// 隐私
+		{key: "privacy.redactProviders", env: "REDACT_PROVIDERS", doc: "发送数据前需要遮蔽个人信息的外部服务，逗号分隔，none表示不遮蔽", value: &listValue{p: &c.RedactProviders, sep: ","}, check: oneOfEach(&c.RedactProviders, "openai", "grok", "ocrspace")},
+
+		// 用量与预算
+		{key: "usage.prices", env: "USAGE_PRICES", doc: "模型价格，格式为 模型=输入价格:输出价格", value: (*stringValue)(&c.UsagePrices)},
+		{key: "usage.userDailyBudget", env: "USER_DAILY_BUDGET", doc: "每个用户每天的大模型费用上限（美元），0表示不限", value: (*floatValue)(&c.UserDailyBudget), check: between(&c.UserDailyBudget, 0, -1)},
+		{key: "usage.sessionBudget", env: "SESSION_BUDGET", doc: "每场面试会话的大模型费用上限（美元），0表示不限", value: (*floatValue)(&c.SessionBudget), check: between(&c.SessionBudget, 0, -1)},
+		{key: "usage.budgetAction", env: "BUDGET_ACTION", doc: "超出预算时的处理方式：degrade或block", value: (*stringValue)(&c.BudgetAction), check: oneOf(&c.BudgetAction, "degrade", "block")},
+
+		// 日志与追踪
+		{key: "logging.format", env: "LOG_FORMAT", doc: "日志格式：json或text", value: (*stringValue)(&c.LogFormat), check: oneOf(&c.LogFormat, "json", "text")},
+		{key: "logging.level", env: "LOG_LEVEL", doc: "日志级别：debug、info、warn或error", value: (*stringValue)(&c.LogLevel), check: logLevelCheck(&c.LogLevel)},
+		{key: "tracing.exporter", env: "TRACING_EXPORTER", doc: "链路追踪导出方式：none、stdout或otlp", value: (*stringValue)(&c.TracingExporter), check: oneOf(&c.TracingExporter, "none", "stdout", "otlp")},
+		{key: "tracing.sampleRatio", env: "TRACING_SAMPLE_RATIO", doc: "链路追踪采样比例，0到1之间", value: (*floatValue)(&c.TracingSample), check: between(&c.TracingSample, 0, 1)},
+
+		// 评估
+		{key: "evaluation.rubric", env: "EVALUATION_RUBRIC", doc: "评估回答时的评分标准，每项一个分数段，用|分隔", value: &listValue{p: &c.Rubric, sep: "|"}},
+	}
+}
+
+// stringValue 是字符串配置项
+type stringValue string
+
+func (v *stringValue) Set(raw string) error { *v = stringValue(strings.TrimSpace(raw)); return nil }
+func (v *stringValue) String() string       { return string(*v) }
+
+// intValue 是整数配置项
+type intValue int
+
+func (v *intValue) Set(raw string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("不是有效的整数")
+	}
+	*v = intValue(n)
+	return nil
+}
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+// int64Value 是64位整数配置项
+type int64Value int64
+
+func (v *int64Value) Set(raw string) error {
+	n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	if err != nil {
+		return fmt.Errorf("不是有效的整数")
+	}
+	*v = int64Value(n)
+	return nil
+}
+func (v *int64Value) String() string { return strconv.FormatInt(int64(*v), 10) }
+
+// floatValue 是小数配置项
+type floatValue float64
+
+func (v *floatValue) Set(raw string) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil {
+		return fmt.Errorf("不是有效的数字")
+	}
+	*v = floatValue(f)
+	return nil
+}
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'f', -1, 64) }
+
+// boolValue 是布尔配置项，接受true、false、1、0等
+type boolValue bool
+
+func (v *boolValue) Set(raw string) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("只能是true或false")
+	}
+	*v = boolValue(b)
+	return nil
+}
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+// listValue 是列表配置项，环境变量和命令行参数中用sep分隔，值为none时表示空列表
+type listValue struct {
+	p   *[]string
+	sep string
+}
+
+func (v *listValue) Set(raw string) error {
+	var items []string
+	for _, item := range strings.Split(raw, v.sep) {
+		if item = strings.TrimSpace(item); item != "" && item != "none" {
+			items = append(items, item)
+		}
+	}
+	*v.p = items
+	return nil
+}
+
+func (v *listValue) SetList(items []string) {
+	*v.p = nil
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			*v.p = append(*v.p, item)
+		}
+	}
+}
+
+func (v *listValue) String() string { return strings.Join(*v.p, v.sep) }
+
+// notEmpty 要求字符串不为空
+func notEmpty(p *string) func() string {
+	return func() string {
+		if *p == "" {
+			return "不能为空"
+		}
+		return ""
+	}
+}
+
+// atLeast 要求整数不小于min
+func atLeast(p *int, min int) func() string {
+	return func() string {
+		if *p < min {
+			return fmt.Sprintf("不能小于%d", min)
+		}
+		return ""
+	}
+}
+
+// intBetween 要求整数在min和max之间
+func intBetween(p *int, min, max int) func() string {
+	return func() string {
+		if *p < min || *p > max {
+			return fmt.Sprintf("必须是%d到%d之间的整数", min, max)
+		}
+		return ""
+	}
+}
+
+// positive64 要求64位整数大于0
+func positive64(p *int64) func() string {
+	return func() string {
+		if *p <= 0 {
+			return "必须大于0"
+		}
+		return ""
+	}
+}
+
+// between 要求小数在min和max之间，max为负数时不限上限
+func between(p *float64, min, max float64) func() string {
+	return func() string {
+		switch {
+		case *p < min:
+			return fmt.Sprintf("不能小于%g", min)
+		case max >= 0 && *p > max:
+			return fmt.Sprintf("不能大于%g", max)
+		}
+		return ""
+	}
+}
+
+// oneOf 要求字符串是allowed中的一个
+func oneOf(p *string, allowed ...string) func() string {
+	return func() string {
+		for _, a := range allowed {
+			if strings.EqualFold(*p, a) {
+				return ""
+			}
+		}
+		return "只能是" + strings.Join(allowed, "、")
+	}
+}
+
+// oneOfEach 要求列表中的每一项都是allowed中的一个
+func oneOfEach(p *[]string, allowed ...string) func() string {
+	return func() string {
+		for _, item := range *p {
+			item := item
+			if reason := oneOf(&item, allowed...)(); reason != "" {
+				return fmt.Sprintf("%s无效，%s", item, reason)
+			}
+		}
+		return ""
+	}
+}
+
+// providersCheck 要求AI服务的优先级顺序中每个服务只出现一次，且都配置了API密钥
+func (c *Config) providersCheck() string {
+	if reason := oneOfEach(&c.AIProviders, "grok", "openai")(); reason != "" {
+		return reason
+	}
+	seen := map[string]bool{}
+	for _, name := range c.AIProviders {
+		name = strings.ToLower(name)
+		if seen[name] {
+			return name + "重复"
+		}
+		seen[name] = true
+		if _, ok := c.provider(name); !ok {
+			return name + "未配置API密钥"
+		}
+	}
+	return ""
+}
+
+// ocrEnginesCheck 要求OCR引擎的顺序中每个引擎只出现一次
+func (c *Config) ocrEnginesCheck() string {
+	if reason := oneOfEach(&c.OCREngines, "pdftext", "tesseract", "ocrspace")(); reason != "" {
+		return reason
+	}
+	seen := map[string]bool{}
+	for _, name := range c.OCREngines {
+		name = strings.ToLower(name)
+		if seen[name] {
+			return name + "重复"
+		}
+		seen[name] = true
+	}
+	return ""
+}
+
+// languagesCheck 要求Tesseract语言包是用+连接的语言名，如chi_sim+eng
+func languagesCheck(p *string) func() string {
+	return func() string {
+		for _, language := range strings.Split(*p, "+") {
+			if language == "" || strings.Trim(language, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_") != "" {
+				return "必须是用+连接的语言包名，如chi_sim+eng"
+			}
+		}
+		return ""
+	}
+}
+
+// portCheck 要求端口是1到65535之间的整数
+func portCheck(p *string) func() string {
+	return func() string {
+		if n, err := strconv.Atoi(*p); err != nil || n < 1 || n > 65535 {
+			return "必须是1到65535之间的端口号"
+		}
+		return ""
+	}
+}
+
+// logLevelCheck 要求日志级别是slog可以识别的级别
+func logLevelCheck(p *string) func() string {
+	return func() string {
+		var level slog.Level
+		if level.UnmarshalText([]byte(*p)) != nil {
+			return "只能是debug、info、warn或error"
+		}
+		return ""
+	}
+}
+
+// keyringCheck 要求加密密钥为 id:base64密钥 格式，密钥本身的长度在加载密钥时校验
+func keyringCheck(p *string) func() string {
+	return func() string {
+		for _, entry := range strings.Split(*p, ",") {
+			if entry = strings.TrimSpace(entry); entry == "" {
+				continue
+			}
+			if id, key, ok := strings.Cut(entry, ":"); !ok || id == "" || key == "" {
+				return "格式应为 id:base64密钥，多个用逗号分隔"
+			}
+		}
+		return ""
+	}
+}
+
+// maskSecret 遮蔽密钥，较长的密钥保留末尾4位便于核对，未设置时返回空字符串
+func maskSecret(secret string) string {
+	switch {
+	case secret == "":
+		return ""
+	case len(secret) < 12:
+		return "****"
+	default:
+		return "****" + secret[len(secret)-4:]
+	}
+}
+
+// maskKeyring 遮蔽 id:base64密钥 格式的加密密钥列表，只保留密钥ID
+func maskKeyring(keys string) string {
+	var masked []string
+	for _, entry := range strings.Split(keys, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		id, _, _ := strings.Cut(entry, ":")
+		masked = append(masked, id+":****")
+	}
+	return strings.Join(masked, ",")
+}
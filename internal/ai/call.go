@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/metrics"
+	"github.com/10yihang/resume-ai-interview/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Call 是一次进行中的大模型调用，结束时记录追踪span、耗时指标、token用量和日志
+type Call struct {
+	ctx      context.Context
+	span     trace.Span
+	provider string
+	model    string
+	stream   bool
+	start    time.Time
+}
+
+// StartCall 开始一次大模型调用，返回带有调用span的context，调用结束后必须调用End
+func StartCall(ctx context.Context, provider, model string, stream bool) (context.Context, *Call) {
+	ctx, span := tracing.Start(ctx, "chat "+model,
+		attribute.String("gen_ai.system", provider),
+		attribute.String("gen_ai.request.model", model),
+		attribute.Bool("gen_ai.stream", stream),
+	)
+	return ctx, &Call{ctx: ctx, span: span, provider: provider, model: model, stream: stream, start: time.Now()}
+}
+
+// End 结束调用，记录接口返回的token用量，日志带有ctx中的请求ID
+func (c *Call) End(promptTokens, completionTokens int, err error) {
+	metrics.ObserveLLM(c.provider, c.model, c.stream, c.start, err)
+	metrics.RecordUsage(c.provider, c.model, promptTokens, completionTokens)
+	c.span.SetAttributes(
+		attribute.Int("gen_ai.usage.input_tokens", promptTokens),
+		attribute.Int("gen_ai.usage.output_tokens", completionTokens),
+	)
+	tracing.End(c.span, err)
+
+	attrs := []any{"provider", c.provider, "model", c.model, "stream", c.stream, "duration_ms", time.Since(c.start).Milliseconds(),
+		"prompt_tokens", promptTokens, "completion_tokens", completionTokens}
+	if err != nil {
+		slog.WarnContext(c.ctx, "大模型调用失败", append(attrs, "error", err)...)
+		return
+	}
+	slog.InfoContext(c.ctx, "大模型调用完成", attrs...)
+}
@@ -0,0 +1,135 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/metrics"
+	"github.com/10yihang/resume-ai-interview/models"
+)
+
+// Attempt 是按优先级尝试的一个AI服务
+type Attempt[R any] struct {
+	Provider string
+	Model    string
+	Call     func(ctx context.Context) (R, error)
+}
+
+// Failover 按顺序尝试attempts，前一个服务返回错误或超时时改用下一个，返回结果和实际提供服务的序号
+// timeout是每次尝试的超时时间，0表示不限；canRetry不为nil且返回false时不再尝试，例如流式输出已经发送了部分内容；
+// 调用方取消ctx时立即返回。全部失败时返回每个服务的错误
+func Failover[R any](ctx context.Context, timeout time.Duration, attempts []Attempt[R], canRetry func() bool) (R, int, error) {
+	var zero R
+	if len(attempts) == 0 {
+		return zero, -1, errors.New("未配置AI服务")
+	}
+	var errs []error
+	for i, attempt := range attempts {
+		result, err := callWithTimeout(ctx, timeout, attempt.Call)
+		if err == nil {
+			return result, i, nil
+		}
+		if len(attempts) == 1 {
+			return zero, i, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", attempt.Provider, err))
+
+		last := i == len(attempts)-1
+		if last || ctx.Err() != nil || (canRetry != nil && !canRetry()) {
+			break
+		}
+		next := attempts[i+1]
+		metrics.LLMFailovers.Inc(attempt.Provider, next.Provider)
+		slog.WarnContext(ctx, "AI服务调用失败，改用下一个服务",
+			"provider", attempt.Provider, "model", attempt.Model, "next_provider", next.Provider, "error", err)
+	}
+	return zero, len(errs) - 1, errors.Join(errs...)
+}
+
+// callWithTimeout 在超时时间内调用一次AI服务
+func callWithTimeout[R any](ctx context.Context, timeout time.Duration, call func(ctx context.Context) (R, error)) (R, error) {
+	if timeout <= 0 {
+		return call(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return call(ctx)
+}
+
+// FailoverQuestionGenerator 按优先级使用多个问题生成器，前一个失败或超时时改用下一个
+// 生成的问题集记录实际提供服务的AI服务和模型
+type FailoverQuestionGenerator struct {
+	generators []QuestionGeneratorInterface
+	timeout    time.Duration
+}
+
+// NewFailoverQuestionGenerator 创建按优先级切换的问题生成器，timeout是每次尝试的超时时间，0表示不限
+func NewFailoverQuestionGenerator(timeout time.Duration, generators ...QuestionGeneratorInterface) *FailoverQuestionGenerator {
+	return &FailoverQuestionGenerator{generators: generators, timeout: timeout}
+}
+
+// Provider 返回首选的AI服务
+func (g *FailoverQuestionGenerator) Provider() string {
+	provider, _ := DescribeModel(g.generators[0])
+	return provider
+}
+
+// Model 返回首选的模型
+func (g *FailoverQuestionGenerator) Model() string {
+	_, model := DescribeModel(g.generators[0])
+	return model
+}
+
+// GenerateQuestions 依次尝试各个生成器生成面试问题
+func (g *FailoverQuestionGenerator) GenerateQuestions(ctx context.Context, resume *models.Resume, jd *models.JobDescription) (*models.QuestionSet, error) {
+	return g.generate(ctx, nil, func(ctx context.Context, generator QuestionGeneratorInterface) (*models.QuestionSet, error) {
+		return generator.GenerateQuestions(ctx, resume, jd)
+	})
+}
+
+// GenerateQuestionsStream 依次尝试各个生成器流式生成面试问题
+// 已经输出问题后再失败时不再切换，避免客户端收到两个服务生成的重复问题
+func (g *FailoverQuestionGenerator) GenerateQuestionsStream(ctx context.Context, resume *models.Resume, jd *models.JobDescription, onQuestion func(models.Question)) (*models.QuestionSet, error) {
+	emitted := false
+	emit := func(q models.Question) {
+		emitted = true
+		if onQuestion != nil {
+			onQuestion(q)
+		}
+	}
+	canRetry := func() bool { return !emitted }
+	return g.generate(ctx, canRetry, func(ctx context.Context, generator QuestionGeneratorInterface) (*models.QuestionSet, error) {
+		streamer, ok := generator.(StreamingQuestionGenerator)
+		if !ok {
+			questionSet, err := generator.GenerateQuestions(ctx, resume, jd)
+			if err != nil {
+				return nil, err
+			}
+			for _, q := range questionSet.Questions {
+				emit(q)
+			}
+			return questionSet, nil
+		}
+		return streamer.GenerateQuestionsStream(ctx, resume, jd, emit)
+	})
+}
+
+// generate 依次尝试各个生成器，在结果中记录实际提供服务的AI服务和模型
+func (g *FailoverQuestionGenerator) generate(ctx context.Context, canRetry func() bool, call func(context.Context, QuestionGeneratorInterface) (*models.QuestionSet, error)) (*models.QuestionSet, error) {
+	attempts := make([]Attempt[*models.QuestionSet], len(g.generators))
+	for i, generator := range g.generators {
+		provider, model := DescribeModel(generator)
+		attempts[i] = Attempt[*models.QuestionSet]{Provider: provider, Model: model, Call: func(ctx context.Context) (*models.QuestionSet, error) {
+			return call(ctx, generator)
+		}}
+	}
+	questionSet, served, err := Failover(ctx, g.timeout, attempts, canRetry)
+	if err != nil {
+		return nil, err
+	}
+	questionSet.Provider, questionSet.Model = attempts[served].Provider, attempts[served].Model
+	return questionSet, nil
+}
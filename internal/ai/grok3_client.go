@@ -2,9 +2,13 @@ package ai
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"log/slog"
+	"strings"
 
+	"github.com/10yihang/resume-ai-interview/models"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -38,21 +42,24 @@ type Grok3ChatResponse struct {
 		Message      Grok3Message `json:"message"`
 		FinishReason string       `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage Grok3Usage `json:"usage"`
+}
+
+// Grok3Usage 表示Grok 3 接口返回的token用量
+type Grok3Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// TokenUsage 转换为计费使用的用量，接口没有返回用量时返回nil
+func (u Grok3Usage) TokenUsage(model string) *models.TokenUsage {
+	return NewTokenUsage(ProviderGrok, model, u.PromptTokens, u.CompletionTokens)
 }
 
 // NewGrok3Client 创建一个新的Grok 3 客户端
 func NewGrok3Client(apiKey string) *Grok3Client {
-	// 从环境变量获取API基础URL，如果没有设置则使用默认值
-	baseURL := os.Getenv("GROK3_API_URL")
-	if baseURL == "" {
-		// X.AI的API与OpenAI兼容，但URL不同
-		baseURL = "https://api.x.ai/v1"
-	}
+	baseURL := grokBaseURL()
 
 	// 配置OpenAI客户端
 	config := openai.DefaultConfig(apiKey)
@@ -60,8 +67,7 @@ func NewGrok3Client(apiKey string) *Grok3Client {
 
 	client := openai.NewClientWithConfig(config)
 
-	// 打印API信息
-	fmt.Printf("初始化Grok API客户端，基础URL: %s\n", baseURL)
+	slog.Debug("初始化Grok API客户端", "base_url", baseURL)
 
 	return &Grok3Client{
 		client: client,
@@ -69,8 +75,9 @@ func NewGrok3Client(apiKey string) *Grok3Client {
 }
 
 // CreateChatCompletion 发送聊天请求到Grok 3 API
-func (c *Grok3Client) CreateChatCompletion(ctx context.Context, request Grok3ChatRequest) (Grok3ChatResponse, error) {
-	var response Grok3ChatResponse
+func (c *Grok3Client) CreateChatCompletion(ctx context.Context, request Grok3ChatRequest) (response Grok3ChatResponse, err error) {
+	ctx, call := StartCall(ctx, ProviderGrok, request.Model, false)
+	defer func() { call.End(response.Usage.PromptTokens, response.Usage.CompletionTokens, err) }()
 
 	// 转换Grok3的请求格式为OpenAI的请求格式
 	messages := make([]openai.ChatCompletionMessage, len(request.Messages))
@@ -88,7 +95,6 @@ func (c *Grok3Client) CreateChatCompletion(ctx context.Context, request Grok3Cha
 		MaxTokens:   request.MaxTokens,
 		Temperature: request.Temperature,
 	}
-	// fmt.Printf("Grok3 API请求：%v\n", openaiRequest)
 
 	// 发送请求
 	openaiResp, err := c.client.CreateChatCompletion(ctx, openaiRequest)
@@ -131,3 +137,58 @@ func (c *Grok3Client) CreateChatCompletion(ctx context.Context, request Grok3Cha
 
 	return response, nil
 }
+
+// CreateChatCompletionStream 以流式方式发送聊天请求到Grok 3 API
+// 每收到一段增量内容就调用onDelta，返回完整的回复内容和接口在最后返回的token用量
+func (c *Grok3Client) CreateChatCompletionStream(ctx context.Context, request Grok3ChatRequest, onDelta func(string)) (_ string, usage Grok3Usage, err error) {
+	ctx, call := StartCall(ctx, ProviderGrok, request.Model, true)
+	defer func() { call.End(usage.PromptTokens, usage.CompletionTokens, err) }()
+
+	messages := make([]openai.ChatCompletionMessage, len(request.Messages))
+	for i, msg := range request.Messages {
+		messages[i] = openai.ChatCompletionMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+	}
+
+	stream, err := c.client.CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{
+		Model:       request.Model,
+		Messages:    messages,
+		MaxTokens:   request.MaxTokens,
+		Temperature: request.Temperature,
+	})
+	if err != nil {
+		return "", usage, fmt.Errorf("错误：发送流式请求到Grok API失败：%w", err)
+	}
+	defer stream.Close()
+
+	var builder strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return builder.String(), usage, fmt.Errorf("错误：读取Grok API流式响应失败：%w", err)
+		}
+		if chunk.Usage != nil {
+			usage = Grok3Usage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+			}
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		builder.WriteString(delta)
+		if onDelta != nil {
+			onDelta(delta)
+		}
+	}
+
+	return builder.String(), usage, nil
+}
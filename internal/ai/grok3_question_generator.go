@@ -11,6 +11,7 @@ import (
 // Grok3QuestionGenerator 用于使用Grok 3生成面试问题
 type Grok3QuestionGenerator struct {
 	client *Grok3Client
+	model  string
 }
 
 // NewGrok3QuestionGenerator 创建使用Grok 3的问题生成器
@@ -22,33 +23,28 @@ func NewGrok3QuestionGenerator(apiKey string) *Grok3QuestionGenerator {
 	client := NewGrok3Client(apiKey)
 	return &Grok3QuestionGenerator{
 		client: client,
+		model:  Grok3Model,
 	}
 }
 
-// GenerateQuestions 根据简历和JD生成面试问题
-func (g *Grok3QuestionGenerator) GenerateQuestions(resume *models.Resume, jd *models.JobDescription) (*models.QuestionSet, error) {
-	// 构建提示词
-	prompt := buildQuestionPrompt(resume, jd)
+// WithModel 设置使用的模型，model为空时保留默认模型
+func (g *Grok3QuestionGenerator) WithModel(model string) *Grok3QuestionGenerator {
+	if model != "" {
+		g.model = model
+	}
+	return g
+}
+
+// Provider 返回使用的AI服务
+func (g *Grok3QuestionGenerator) Provider() string { return ProviderGrok }
 
+// Model 返回使用的模型
+func (g *Grok3QuestionGenerator) Model() string { return g.model }
+
+// GenerateQuestions 根据简历和JD生成面试问题
+func (g *Grok3QuestionGenerator) GenerateQuestions(ctx context.Context, resume *models.Resume, jd *models.JobDescription) (*models.QuestionSet, error) {
 	// 调用Grok 3 API
-	resp, err := g.client.CreateChatCompletion(
-		context.Background(),
-		Grok3ChatRequest{
-			Model: "grok-3", // 使用Grok 3模型
-			Messages: []Grok3Message{
-				{
-					Role:    "system",
-					Content: "你是一位经验丰富的HR面试官，需要根据简历和职位描述生成有针对性的面试问题。请生成10个问题，包括技术能力、项目经验、职业规划、团队协作等方面。问题要有针对性，能够考察候选人是否符合岗位需求。",
-				},
-				{
-					Role:    "user",
-					Content: prompt,
-				},
-			},
-			MaxTokens:   2048,
-			Temperature: 0.7,
-		},
-	)
+	resp, err := g.client.CreateChatCompletion(ctx, g.buildRequest(resume, jd))
 
 	if err != nil {
 		return nil, fmt.Errorf("调用Grok 3接口生成问题失败: %w", err)
@@ -59,6 +55,44 @@ func (g *Grok3QuestionGenerator) GenerateQuestions(resume *models.Resume, jd *mo
 	}
 
 	// 解析问题
-	questionSet := parseQuestions(resume, jd, resp.Choices[0].Message.Content)
+	questionSet := parseQuestions(ctx, resume, jd, resp.Choices[0].Message.Content)
+	questionSet.Usage = resp.Usage.TokenUsage(g.model)
 	return questionSet, nil
 }
+
+// GenerateQuestionsStream 流式生成面试问题，每生成一个问题就回调一次
+func (g *Grok3QuestionGenerator) GenerateQuestionsStream(ctx context.Context, resume *models.Resume, jd *models.JobDescription, onQuestion func(models.Question)) (*models.QuestionSet, error) {
+	streamParser := newQuestionStreamParser(onQuestion)
+
+	content, usage, err := g.client.CreateChatCompletionStream(ctx, g.buildRequest(resume, jd), streamParser.Write)
+	if err != nil {
+		return nil, fmt.Errorf("调用Grok 3接口生成问题失败: %w", err)
+	}
+
+	if content == "" {
+		return nil, fmt.Errorf("Grok 3返回了空的回复")
+	}
+
+	questionSet := parseQuestions(ctx, resume, jd, content)
+	questionSet.Usage = usage.TokenUsage(g.model)
+	return questionSet, nil
+}
+
+// buildRequest 构建生成问题的Grok 3请求
+func (g *Grok3QuestionGenerator) buildRequest(resume *models.Resume, jd *models.JobDescription) Grok3ChatRequest {
+	return Grok3ChatRequest{
+		Model: g.model,
+		Messages: []Grok3Message{
+			{
+				Role:    "system",
+				Content: questionSystemPrompt,
+			},
+			{
+				Role:    "user",
+				Content: buildQuestionPrompt(resume, jd),
+			},
+		},
+		MaxTokens:   2048,
+		Temperature: 0.7,
+	}
+}
@@ -1,16 +1,57 @@
 package ai
 
 import (
+	"context"
+
 	"github.com/10yihang/resume-ai-interview/models"
 )
 
 // QuestionGeneratorInterface 定义了问题生成器的接口
 type QuestionGeneratorInterface interface {
-	GenerateQuestions(resume *models.Resume, jd *models.JobDescription) (*models.QuestionSet, error)
+	GenerateQuestions(ctx context.Context, resume *models.Resume, jd *models.JobDescription) (*models.QuestionSet, error)
+}
+
+// StreamingQuestionGenerator 定义了支持流式输出的问题生成器
+// 每解析出一个完整的问题就调用onQuestion，结束后返回完整的问题集
+type StreamingQuestionGenerator interface {
+	QuestionGeneratorInterface
+	GenerateQuestionsStream(ctx context.Context, resume *models.Resume, jd *models.JobDescription, onQuestion func(models.Question)) (*models.QuestionSet, error)
+}
+
+// AI服务名称
+const (
+	ProviderGrok   = "grok"
+	ProviderOpenAI = "openai"
+	ProviderMock   = "mock"
+)
+
+// Grok3Model 是调用Grok 3时默认使用的模型
+const Grok3Model = "grok-3"
+
+// ModelInfo 由调用AI服务的组件实现，返回使用的服务和模型名称，用于审计
+type ModelInfo interface {
+	Provider() string
+	Model() string
+}
+
+// DescribeModel 返回组件使用的AI服务和模型，未实现ModelInfo时返回空字符串
+func DescribeModel(v any) (provider, model string) {
+	if info, ok := v.(ModelInfo); ok {
+		return info.Provider(), info.Model()
+	}
+	return "", ""
+}
+
+// NewTokenUsage 创建一次调用的token用量，接口没有返回用量时返回nil
+func NewTokenUsage(provider, model string, promptTokens, completionTokens int) *models.TokenUsage {
+	if promptTokens == 0 && completionTokens == 0 {
+		return nil
+	}
+	return &models.TokenUsage{Provider: provider, Model: model, PromptTokens: promptTokens, CompletionTokens: completionTokens}
 }
 
-// GetQuestionGenerator 根据配置返回适当的问题生成器
-func GetQuestionGenerator(apiKey string, useGrok bool) QuestionGeneratorInterface {
+// GetQuestionGenerator 根据配置返回适当的问题生成器，model为空时使用默认模型
+func GetQuestionGenerator(apiKey string, useGrok bool, model string) QuestionGeneratorInterface {
 	if apiKey == "" {
 		// 如果没有API密钥，使用模拟生成器
 		return NewMockQuestionGenerator()
@@ -18,9 +59,9 @@ func GetQuestionGenerator(apiKey string, useGrok bool) QuestionGeneratorInterfac
 
 	if useGrok {
 		// 使用Grok 3
-		return NewGrok3QuestionGenerator(apiKey)
+		return NewGrok3QuestionGenerator(apiKey).WithModel(model)
 	}
 
 	// 默认使用OpenAI
-	return NewQuestionGenerator(apiKey)
+	return NewQuestionGenerator(apiKey).WithModel(model)
 }
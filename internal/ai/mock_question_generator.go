@@ -1,8 +1,9 @@
 package ai
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"log/slog"
 
 	"github.com/10yihang/resume-ai-interview/models"
 )
@@ -15,18 +16,28 @@ func NewMockQuestionGenerator() *MockQuestionGenerator {
 	return &MockQuestionGenerator{}
 }
 
+// Provider 返回使用的AI服务
+func (g *MockQuestionGenerator) Provider() string { return ProviderMock }
+
+// Model 返回使用的模型
+func (g *MockQuestionGenerator) Model() string { return ProviderMock }
+
 // GenerateQuestions 生成模拟面试问题
-func (g *MockQuestionGenerator) GenerateQuestions(resume *models.Resume, jd *models.JobDescription) (*models.QuestionSet, error) {
+func (g *MockQuestionGenerator) GenerateQuestions(ctx context.Context, resume *models.Resume, jd *models.JobDescription) (*models.QuestionSet, error) {
 	// 创建一些模拟问题
 	questions := []models.Question{
-		{ID: 1, Content: "请介绍一下你的技术背景和专长？", Category: "专业技能"},
+		{ID: 1, Content: "请介绍一下你的技术背景和专长？", Category: "专业技能",
+			ReferenceAnswer: "结合岗位要求介绍主要技术栈、代表项目和个人贡献",
+			Rubric:          []string{"技术栈与岗位相关", "有具体项目支撑", "说明个人贡献"}},
 		{ID: 2, Content: "你对这个职位的理解是什么？", Category: "职业规划"},
 		{ID: 3, Content: "请描述一个你曾经解决的技术难题及解决方案？", Category: "专业技能"},
 		{ID: 4, Content: "你是如何处理团队合作中的冲突的？", Category: "团队协作"},
 		{ID: 5, Content: "你最近学习了哪些新技术？为什么选择学习它们？", Category: "专业技能"},
 		{ID: 6, Content: "请分享一个你在工作中犯过的错误，以及从中学到了什么？", Category: "工作经验"},
 		{ID: 7, Content: "你期望的职业发展路径是什么？", Category: "职业规划"},
-		{ID: 8, Content: "你如何确保你的代码质量？", Category: "专业技能"},
+		{ID: 8, Content: "你如何确保你的代码质量？", Category: "专业技能",
+			ReferenceAnswer: "代码评审、单元测试、静态检查和持续集成相结合",
+			Rubric:          []string{"提到测试", "提到代码评审", "提到自动化工具"}},
 		{ID: 9, Content: "你如何应对工作中的压力和截止日期？", Category: "工作经验"},
 		{ID: 10, Content: "你如何保持自己的技术更新？", Category: "专业技能"},
 	}
@@ -38,6 +49,25 @@ func (g *MockQuestionGenerator) GenerateQuestions(resume *models.Resume, jd *mod
 	}, nil
 }
 
+// GenerateQuestionsStream 逐个输出模拟面试问题
+func (g *MockQuestionGenerator) GenerateQuestionsStream(ctx context.Context, resume *models.Resume, jd *models.JobDescription, onQuestion func(models.Question)) (*models.QuestionSet, error) {
+	questionSet, err := g.GenerateQuestions(ctx, resume, jd)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, q := range questionSet.Questions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if onQuestion != nil {
+			onQuestion(q)
+		}
+	}
+
+	return questionSet, nil
+}
+
 // 解析模拟问题响应
 func (g *MockQuestionGenerator) parseMockQuestions(resume *models.Resume, jd *models.JobDescription, content string) *models.QuestionSet {
 	// 解析JSON内容
@@ -47,7 +77,7 @@ func (g *MockQuestionGenerator) parseMockQuestions(resume *models.Resume, jd *mo
 
 	err := json.Unmarshal([]byte(content), &result)
 	if err != nil {
-		fmt.Printf("解析JSON失败：%v\n", err)
+		slog.Warn("解析模拟问题JSON失败", "error", err)
 		// 返回一些默认问题
 		questions := []models.Question{
 			{ID: 1, Content: "请介绍一下你的技术背景和专长？", Category: "专业技能"},
@@ -8,6 +8,8 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/10yihang/resume-ai-interview/models"
 )
 
 // OpenAIClient 是OpenAI API的客户端
@@ -42,18 +44,26 @@ type OpenAIChatResponse struct {
 		Message      OpenAIMessage `json:"message"`
 		FinishReason string        `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage OpenAIUsage `json:"usage"`
+}
+
+// OpenAIUsage 表示OpenAI接口返回的token用量
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// TokenUsage 转换为计费使用的用量，接口没有返回用量时返回nil
+func (u OpenAIUsage) TokenUsage(model string) *models.TokenUsage {
+	return NewTokenUsage(ProviderOpenAI, model, u.PromptTokens, u.CompletionTokens)
 }
 
 // NewOpenAIClient 创建一个新的OpenAI客户端
 func NewOpenAIClient(apiKey string) *OpenAIClient {
 	return &OpenAIClient{
 		apiKey:  apiKey,
-		baseURL: "https://api.openai.com/v1",
+		baseURL: openAIBaseURL,
 		httpClient: &http.Client{
 			Timeout: time.Second * 60,
 		},
@@ -61,8 +71,9 @@ func NewOpenAIClient(apiKey string) *OpenAIClient {
 }
 
 // CreateChatCompletion 发送聊天请求到OpenAI API
-func (c *OpenAIClient) CreateChatCompletion(ctx context.Context, request OpenAIChatRequest) (OpenAIChatResponse, error) {
-	var response OpenAIChatResponse
+func (c *OpenAIClient) CreateChatCompletion(ctx context.Context, request OpenAIChatRequest) (response OpenAIChatResponse, err error) {
+	ctx, call := StartCall(ctx, ProviderOpenAI, request.Model, false)
+	defer func() { call.End(response.Usage.PromptTokens, response.Usage.CompletionTokens, err) }()
 
 	jsonReq, err := json.Marshal(request)
 	if err != nil {
@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// openAIBaseURL 是OpenAI API的地址
+const openAIBaseURL = "https://api.openai.com/v1"
+
+// grokBaseURL 返回Grok API的地址，可通过环境变量GROK3_API_URL覆盖
+func grokBaseURL() string {
+	if baseURL := os.Getenv("GROK3_API_URL"); baseURL != "" {
+		return baseURL
+	}
+	// X.AI的API与OpenAI兼容，但URL不同
+	return "https://api.x.ai/v1"
+}
+
+// ProviderBaseURL 返回AI服务的接口地址，未知的服务返回空字符串
+func ProviderBaseURL(provider string) string {
+	switch provider {
+	case ProviderGrok:
+		return grokBaseURL()
+	case ProviderOpenAI:
+		return openAIBaseURL
+	default:
+		return ""
+	}
+}
+
+// Ping 请求AI服务的模型列表接口，检查服务是否可达以及API密钥是否有效
+// 列出模型不消耗token
+func Ping(ctx context.Context, provider, apiKey string) error {
+	baseURL := ProviderBaseURL(provider)
+	if baseURL == "" {
+		return fmt.Errorf("未知的AI服务: %s", provider)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("无法连接AI服务: %w", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("AI服务拒绝了API密钥，状态码%d", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("AI服务返回状态码%d", resp.StatusCode)
+	}
+	return nil
+}
@@ -3,11 +3,15 @@ package ai
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"path/filepath"
 	"strings"
 
+	"github.com/10yihang/resume-ai-interview/internal/metrics"
 	"github.com/10yihang/resume-ai-interview/models"
 	"github.com/sashabaranov/go-openai"
 )
@@ -15,6 +19,7 @@ import (
 // QuestionGenerator 用于生成面试问题
 type QuestionGenerator struct {
 	client *openai.Client
+	model  string
 }
 
 // NewQuestionGenerator 创建问题生成器
@@ -26,42 +31,99 @@ func NewQuestionGenerator(apiKey string) *QuestionGenerator {
 	client := openai.NewClient(apiKey)
 	return &QuestionGenerator{
 		client: client,
+		model:  openai.GPT4TurboPreview,
 	}
 }
 
-// GenerateQuestions 根据简历和JD生成面试问题
-func (g *QuestionGenerator) GenerateQuestions(resume *models.Resume, jd *models.JobDescription) (*models.QuestionSet, error) {
-	// 构建提示词
-	prompt := buildQuestionPrompt(resume, jd)
+// WithModel 设置使用的模型，model为空时保留默认模型
+func (g *QuestionGenerator) WithModel(model string) *QuestionGenerator {
+	if model != "" {
+		g.model = model
+	}
+	return g
+}
+
+// questionSystemPrompt 生成面试问题时使用的系统提示词
+const questionSystemPrompt = "你是一位经验丰富的HR面试官，需要根据简历和职位描述生成有针对性的面试问题。请生成10个问题，包括技术能力、项目经验、职业规划、团队协作等方面。问题要有针对性，能够考察候选人是否符合岗位需求。"
 
+// Provider 返回使用的AI服务
+func (g *QuestionGenerator) Provider() string { return ProviderOpenAI }
+
+// Model 返回使用的模型
+func (g *QuestionGenerator) Model() string { return g.model }
+
+// GenerateQuestions 根据简历和JD生成面试问题
+func (g *QuestionGenerator) GenerateQuestions(ctx context.Context, resume *models.Resume, jd *models.JobDescription) (*models.QuestionSet, error) {
 	// 调用OpenAI API
-	resp, err := g.client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4TurboPreview,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "你是一位经验丰富的HR面试官，需要根据简历和职位描述生成有针对性的面试问题。请生成10个问题，包括技术能力、项目经验、职业规划、团队协作等方面。问题要有针对性，能够考察候选人是否符合岗位需求。",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-			MaxTokens: 2048,
-		},
-	)
+	callCtx, call := StartCall(ctx, ProviderOpenAI, g.Model(), false)
+	resp, err := g.client.CreateChatCompletion(callCtx, g.buildRequest(resume, jd))
+	call.End(resp.Usage.PromptTokens, resp.Usage.CompletionTokens, err)
 
 	if err != nil {
 		return nil, fmt.Errorf("调用AI接口生成问题失败: %w", err)
 	}
 
 	// 解析问题
-	questionSet := parseQuestions(resume, jd, resp.Choices[0].Message.Content)
+	questionSet := parseQuestions(ctx, resume, jd, resp.Choices[0].Message.Content)
+	questionSet.Usage = NewTokenUsage(ProviderOpenAI, g.Model(), resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	return questionSet, nil
+}
+
+// GenerateQuestionsStream 流式生成面试问题，每生成一个问题就回调一次
+func (g *QuestionGenerator) GenerateQuestionsStream(ctx context.Context, resume *models.Resume, jd *models.JobDescription, onQuestion func(models.Question)) (_ *models.QuestionSet, err error) {
+	var usage openai.Usage
+	callCtx, call := StartCall(ctx, ProviderOpenAI, g.Model(), true)
+	defer func() { call.End(usage.PromptTokens, usage.CompletionTokens, err) }()
+
+	// 要求接口在最后一个数据块中返回token用量
+	request := g.buildRequest(resume, jd)
+	request.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := g.client.CreateChatCompletionStream(callCtx, request)
+	if err != nil {
+		return nil, fmt.Errorf("调用AI接口生成问题失败: %w", err)
+	}
+	defer stream.Close()
+
+	streamParser := newQuestionStreamParser(onQuestion)
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取AI流式响应失败: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		if len(chunk.Choices) > 0 {
+			streamParser.Write(chunk.Choices[0].Delta.Content)
+		}
+	}
+
+	questionSet := parseQuestions(ctx, resume, jd, streamParser.Content())
+	questionSet.Usage = NewTokenUsage(ProviderOpenAI, g.Model(), usage.PromptTokens, usage.CompletionTokens)
 	return questionSet, nil
 }
 
+// buildRequest 构建生成问题的OpenAI请求
+func (g *QuestionGenerator) buildRequest(resume *models.Resume, jd *models.JobDescription) openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Model: g.model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: questionSystemPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: buildQuestionPrompt(resume, jd),
+			},
+		},
+		MaxTokens: 2048,
+	}
+}
+
 // 构建问题生成的提示词
 func buildQuestionPrompt(resume *models.Resume, jd *models.JobDescription) string {
 	return fmt.Sprintf(`
@@ -92,7 +154,9 @@ func buildQuestionPrompt(resume *models.Resume, jd *models.JobDescription) strin
     {
       "id": 1,
       "content": "问题内容",
-      "category": "问题类别"
+      "category": "问题类别",
+      "referenceAnswer": "参考答案要点",
+      "rubric": ["评分要点1", "评分要点2"]
     }
   ]
 }
@@ -110,23 +174,26 @@ func buildQuestionPrompt(resume *models.Resume, jd *models.JobDescription) strin
 }
 
 // 解析AI返回的问题
-func parseQuestions(resume *models.Resume, jd *models.JobDescription, content string) *models.QuestionSet {
+func parseQuestions(ctx context.Context, resume *models.Resume, jd *models.JobDescription, content string) *models.QuestionSet {
 	// 提取JSON部分
 	jsonStr := extractJSONFromContent(content)
 
 	// 解析JSON
 	var result struct {
 		Questions []struct {
-			ID       int    `json:"id"`
-			Content  string `json:"content"`
-			Category string `json:"category"`
+			ID              int      `json:"id"`
+			Content         string   `json:"content"`
+			Category        string   `json:"category"`
+			ReferenceAnswer string   `json:"referenceAnswer"`
+			Rubric          []string `json:"rubric"`
 		} `json:"questions"`
 	}
 
 	err := json.Unmarshal([]byte(jsonStr), &result)
 	if err != nil {
-		fmt.Printf("解析问题JSON失败: %v\n", err)
+		slog.WarnContext(ctx, "解析问题JSON失败，使用默认问题集", "error", err)
 		// 解析失败时返回默认问题集
+		metrics.Fallbacks.Inc(metrics.FallbackDefaultQuestions)
 		return getDefaultQuestions(resume, jd)
 	}
 
@@ -134,15 +201,18 @@ func parseQuestions(resume *models.Resume, jd *models.JobDescription, content st
 	questions := make([]models.Question, 0, len(result.Questions))
 	for _, q := range result.Questions {
 		question := models.Question{
-			ID:       q.ID,
-			Content:  q.Content,
-			Category: q.Category,
+			ID:              q.ID,
+			Content:         q.Content,
+			Category:        q.Category,
+			ReferenceAnswer: q.ReferenceAnswer,
+			Rubric:          q.Rubric,
 		}
 		questions = append(questions, question)
 	}
 
 	// 如果没有解析到问题，返回默认问题集
 	if len(questions) == 0 {
+		metrics.Fallbacks.Inc(metrics.FallbackDefaultQuestions)
 		return getDefaultQuestions(resume, jd)
 	}
 
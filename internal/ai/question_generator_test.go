@@ -1,11 +1,16 @@
 package ai
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/10yihang/resume-ai-interview/config"
+	"github.com/10yihang/resume-ai-interview/internal/metrics"
 	"github.com/10yihang/resume-ai-interview/models"
 )
 
@@ -27,7 +32,7 @@ func TestQuestionGeneration(t *testing.T) {
 
 	// 测试Grok问题生成器
 	grokGenerator := NewGrok3QuestionGenerator(apiKey)
-	grokQuestions, err := grokGenerator.GenerateQuestions(resume, jd)
+	grokQuestions, err := grokGenerator.GenerateQuestions(context.Background(), resume, jd)
 	if err != nil {
 		t.Logf("Grok问题生成失败: %v", err)
 	} else {
@@ -40,7 +45,7 @@ func TestQuestionGeneration(t *testing.T) {
 
 	// 测试模拟问题生成器
 	mockGenerator := NewMockQuestionGenerator()
-	mockQuestions, err := mockGenerator.GenerateQuestions(resume, jd)
+	mockQuestions, err := mockGenerator.GenerateQuestions(context.Background(), resume, jd)
 	if err != nil {
 		t.Errorf("模拟问题生成失败: %v", err)
 	} else {
@@ -75,3 +80,144 @@ func createTestResumeAndJD() (*models.Resume, *models.JobDescription) {
 
 	return resume, jd
 }
+
+func TestQuestionStreamParser(t *testing.T) {
+	var got []models.Question
+	streamParser := newQuestionStreamParser(func(q models.Question) {
+		got = append(got, q)
+	})
+
+	// 模拟模型逐段返回的内容，问题对象被切分在多个片段中
+	chunks := []string{
+		"```json\n{\n  \"questions\": [\n    {\"id\": 1, \"content\": \"请介绍",
+		"一下你的{项目}经验\", \"category\": \"工作经验\"},\n    {\"id\": 2, ",
+		"\"content\": \"如何理解\\\"微服务\\\"?\", \"category\": \"专业技能\"}",
+		"\n  ]\n}\n```",
+	}
+	for i, chunk := range chunks {
+		streamParser.Write(chunk)
+		if i == 0 && len(got) != 0 {
+			t.Fatalf("问题未完整时不应输出，实际输出%d个", len(got))
+		}
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("期望解析出2个问题，实际为%d个", len(got))
+	}
+	if got[0].Content != "请介绍一下你的{项目}经验" || got[1].Content != `如何理解"微服务"?` {
+		t.Errorf("解析的问题内容不正确: %+v", got)
+	}
+
+	resume, jd := createTestResumeAndJD()
+	questionSet := parseQuestions(context.Background(), resume, jd, streamParser.Content())
+	if len(questionSet.Questions) != 2 {
+		t.Errorf("完整内容应解析出2个问题，实际为%d个", len(questionSet.Questions))
+	}
+}
+
+// stubGenerator 按配置返回错误、等待超时或在输出部分问题后失败的生成器
+type stubGenerator struct {
+	provider string
+	err      error
+	hang     bool // 一直等到ctx取消
+	partial  bool // 流式输出一个问题后返回err
+}
+
+func (g *stubGenerator) Provider() string { return g.provider }
+func (g *stubGenerator) Model() string    { return g.provider + "-model" }
+
+func (g *stubGenerator) GenerateQuestions(ctx context.Context, resume *models.Resume, jd *models.JobDescription) (*models.QuestionSet, error) {
+	if g.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if g.err != nil {
+		return nil, g.err
+	}
+	return &models.QuestionSet{Questions: []models.Question{{ID: 1, Content: g.provider}}}, nil
+}
+
+func (g *stubGenerator) GenerateQuestionsStream(ctx context.Context, resume *models.Resume, jd *models.JobDescription, onQuestion func(models.Question)) (*models.QuestionSet, error) {
+	if g.partial {
+		onQuestion(models.Question{ID: 1, Content: g.provider})
+		return nil, g.err
+	}
+	questionSet, err := g.GenerateQuestions(ctx, resume, jd)
+	if err == nil {
+		onQuestion(questionSet.Questions[0])
+	}
+	return questionSet, err
+}
+
+func TestFailoverQuestionGenerator(t *testing.T) {
+	resume, jd := createTestResumeAndJD()
+	down := errors.New("服务不可用")
+	before := metrics.LLMFailovers.Value(ProviderGrok, ProviderOpenAI)
+
+	generator := NewFailoverQuestionGenerator(50*time.Millisecond,
+		&stubGenerator{provider: ProviderGrok, err: down},
+		&stubGenerator{provider: ProviderOpenAI},
+	)
+	questionSet, err := generator.GenerateQuestions(context.Background(), resume, jd)
+	if err != nil {
+		t.Fatalf("首选服务失败时应改用下一个服务: %v", err)
+	}
+	if questionSet.Provider != ProviderOpenAI || questionSet.Model != "openai-model" || questionSet.Questions[0].Content != ProviderOpenAI {
+		t.Fatalf("问题集应记录实际提供服务的服务和模型: %+v", questionSet)
+	}
+	if generator.Provider() != ProviderGrok {
+		t.Fatalf("Provider应返回首选服务，得到%s", generator.Provider())
+	}
+	if got := metrics.LLMFailovers.Value(ProviderGrok, ProviderOpenAI) - before; got != 1 {
+		t.Fatalf("切换服务应记录指标，得到%v", got)
+	}
+
+	// 超时后改用下一个服务
+	generator = NewFailoverQuestionGenerator(20*time.Millisecond,
+		&stubGenerator{provider: ProviderGrok, hang: true},
+		&stubGenerator{provider: ProviderOpenAI},
+	)
+	if questionSet, err = generator.GenerateQuestions(context.Background(), resume, jd); err != nil || questionSet.Provider != ProviderOpenAI {
+		t.Fatalf("首选服务超时时应改用下一个服务: %v %+v", err, questionSet)
+	}
+
+	// 全部失败时返回每个服务的错误
+	generator = NewFailoverQuestionGenerator(0,
+		&stubGenerator{provider: ProviderGrok, err: down},
+		&stubGenerator{provider: ProviderOpenAI, err: errors.New("密钥无效")},
+	)
+	if _, err = generator.GenerateQuestions(context.Background(), resume, jd); err == nil ||
+		!errors.Is(err, down) || !strings.Contains(err.Error(), "openai: 密钥无效") {
+		t.Fatalf("全部失败时应返回每个服务的错误，得到%v", err)
+	}
+}
+
+func TestFailoverQuestionGeneratorStream(t *testing.T) {
+	resume, jd := createTestResumeAndJD()
+	down := errors.New("连接中断")
+
+	var received []string
+	onQuestion := func(q models.Question) { received = append(received, q.Content) }
+
+	generator := NewFailoverQuestionGenerator(0,
+		&stubGenerator{provider: ProviderGrok, err: down},
+		&stubGenerator{provider: ProviderOpenAI},
+	)
+	questionSet, err := generator.GenerateQuestionsStream(context.Background(), resume, jd, onQuestion)
+	if err != nil || questionSet.Provider != ProviderOpenAI || len(received) != 1 {
+		t.Fatalf("还没有输出问题时应改用下一个服务: %v %v", err, received)
+	}
+
+	// 已经输出问题后失败时不再切换，避免重复输出
+	received = nil
+	generator = NewFailoverQuestionGenerator(0,
+		&stubGenerator{provider: ProviderGrok, err: down, partial: true},
+		&stubGenerator{provider: ProviderOpenAI},
+	)
+	if _, err = generator.GenerateQuestionsStream(context.Background(), resume, jd, onQuestion); !errors.Is(err, down) {
+		t.Fatalf("输出部分问题后失败应返回错误，得到%v", err)
+	}
+	if len(received) != 1 || received[0] != ProviderGrok {
+		t.Fatalf("输出部分问题后不应切换服务: %v", received)
+	}
+}
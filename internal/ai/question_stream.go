@@ -0,0 +1,90 @@
+package ai
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/10yihang/resume-ai-interview/models"
+)
+
+// questionStreamParser 从流式返回的JSON片段中增量解析出完整的问题对象
+type questionStreamParser struct {
+	buf        strings.Builder
+	pos        int  // 下一个待扫描的位置
+	inArray    bool // 是否已进入questions数组
+	depth      int  // 当前对象嵌套深度
+	objStart   int  // 当前对象的起始位置
+	inString   bool
+	escaping   bool
+	onQuestion func(models.Question)
+}
+
+// newQuestionStreamParser 创建问题流解析器
+func newQuestionStreamParser(onQuestion func(models.Question)) *questionStreamParser {
+	return &questionStreamParser{onQuestion: onQuestion}
+}
+
+// Write 追加一段增量内容，并回调其中已完整的问题
+func (p *questionStreamParser) Write(delta string) {
+	p.buf.WriteString(delta)
+	content := p.buf.String()
+
+	if !p.inArray {
+		keyIdx := strings.Index(content, `"questions"`)
+		if keyIdx < 0 {
+			return
+		}
+		arrIdx := strings.Index(content[keyIdx:], "[")
+		if arrIdx < 0 {
+			return
+		}
+		p.inArray = true
+		p.pos = keyIdx + arrIdx + 1
+	}
+
+	for ; p.pos < len(content); p.pos++ {
+		ch := content[p.pos]
+
+		if p.inString {
+			if p.escaping {
+				p.escaping = false
+			} else if ch == '\\' {
+				p.escaping = true
+			} else if ch == '"' {
+				p.inString = false
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			p.inString = true
+		case '{':
+			if p.depth == 0 {
+				p.objStart = p.pos
+			}
+			p.depth++
+		case '}':
+			p.depth--
+			if p.depth == 0 {
+				p.emit(content[p.objStart : p.pos+1])
+			}
+		}
+	}
+}
+
+// Content 返回目前为止接收到的完整内容
+func (p *questionStreamParser) Content() string {
+	return p.buf.String()
+}
+
+// emit 解析单个问题对象并回调
+func (p *questionStreamParser) emit(raw string) {
+	var q models.Question
+	if err := json.Unmarshal([]byte(raw), &q); err != nil || q.Content == "" {
+		return
+	}
+	if p.onQuestion != nil {
+		p.onQuestion(q)
+	}
+}
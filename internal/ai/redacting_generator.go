@@ -0,0 +1,105 @@
+package ai
+
+import (
+	"context"
+
+	"github.com/10yihang/resume-ai-interview/internal/redact"
+	"github.com/10yihang/resume-ai-interview/models"
+)
+
+// RedactingQuestionGenerator 在调用外部大模型生成问题前遮蔽简历中的个人信息
+// 生成的问题、参考答案和评分要点中的占位符会还原为原文
+type RedactingQuestionGenerator struct {
+	inner QuestionGeneratorInterface
+}
+
+// NewRedactingQuestionGenerator 创建遮蔽个人信息的问题生成器
+func NewRedactingQuestionGenerator(inner QuestionGeneratorInterface) *RedactingQuestionGenerator {
+	return &RedactingQuestionGenerator{inner: inner}
+}
+
+// Provider 返回内部生成器使用的AI服务
+func (g *RedactingQuestionGenerator) Provider() string {
+	provider, _ := DescribeModel(g.inner)
+	return provider
+}
+
+// Model 返回内部生成器使用的模型
+func (g *RedactingQuestionGenerator) Model() string {
+	_, model := DescribeModel(g.inner)
+	return model
+}
+
+// GenerateQuestions 遮蔽简历后生成面试问题
+func (g *RedactingQuestionGenerator) GenerateQuestions(ctx context.Context, resume *models.Resume, jd *models.JobDescription) (*models.QuestionSet, error) {
+	redactor := redact.New()
+	questionSet, err := g.inner.GenerateQuestions(ctx, maskResume(redactor, resume), jd)
+	if err != nil {
+		return nil, err
+	}
+	return restoreQuestionSet(redactor, questionSet), nil
+}
+
+// GenerateQuestionsStream 遮蔽简历后流式生成面试问题，内部生成器不支持流式时一次性生成后逐个回调
+func (g *RedactingQuestionGenerator) GenerateQuestionsStream(ctx context.Context, resume *models.Resume, jd *models.JobDescription, onQuestion func(models.Question)) (*models.QuestionSet, error) {
+	streamer, ok := g.inner.(StreamingQuestionGenerator)
+	if !ok {
+		questionSet, err := g.GenerateQuestions(ctx, resume, jd)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range questionSet.Questions {
+			if onQuestion != nil {
+				onQuestion(q)
+			}
+		}
+		return questionSet, nil
+	}
+
+	redactor := redact.New()
+	questionSet, err := streamer.GenerateQuestionsStream(ctx, maskResume(redactor, resume), jd, func(q models.Question) {
+		if onQuestion != nil {
+			onQuestion(restoreQuestion(redactor, q))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restoreQuestionSet(redactor, questionSet), nil
+}
+
+// maskResume 返回遮蔽了个人信息的简历副本，不修改原简历
+func maskResume(redactor *redact.Redactor, resume *models.Resume) *models.Resume {
+	if resume == nil {
+		return nil
+	}
+	masked := *resume
+	redactor.AddNames(resume.Name)
+	masked.Name = redactor.Mask(resume.Name)
+	masked.Email = redactor.Mask(resume.Email)
+	masked.Phone = redactor.Mask(resume.Phone)
+	masked.RawText = redactor.MaskDocument(resume.RawText)
+	masked.Education = redactor.MaskAll(resume.Education)
+	masked.Experience = redactor.MaskAll(resume.Experience)
+	masked.Skills = redactor.MaskAll(resume.Skills)
+	return &masked
+}
+
+// restoreQuestion 还原问题中的占位符
+func restoreQuestion(redactor *redact.Redactor, q models.Question) models.Question {
+	q.Content = redactor.Restore(q.Content)
+	q.ReferenceAnswer = redactor.Restore(q.ReferenceAnswer)
+	q.Rubric = redactor.RestoreAll(q.Rubric)
+	return q
+}
+
+// restoreQuestionSet 还原问题集中所有问题的占位符
+func restoreQuestionSet(redactor *redact.Redactor, questionSet *models.QuestionSet) *models.QuestionSet {
+	if questionSet == nil {
+		return nil
+	}
+	for i, q := range questionSet.Questions {
+		questionSet.Questions[i] = restoreQuestion(redactor, q)
+	}
+	return questionSet
+}
@@ -0,0 +1,181 @@
+// Package audit 记录只追加的审计日志，每条记录是JSON文件中的一行
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 审计动作
+const (
+	ActionDelete = "delete" // 用户删除数据
+	ActionForget = "forget" // 删除候选人的所有数据
+	ActionPurge  = "purge"  // 超过保留期限后自动删除上传的原始文件
+	ActionParse  = "parse"  // 后台任务调用AI解析上传的文件
+)
+
+// SystemActor 是系统自动执行的操作的执行者
+const SystemActor = "system"
+
+// memoryLimit 是不写文件时内存中保留的最近记录数
+const memoryLimit = 10000
+
+// Entry 是一条审计记录
+type Entry struct {
+	Time         time.Time `json:"time" binding:"required"`
+	ActorID      string    `json:"actorId" binding:"required" doc:"执行者的用户ID，系统自动执行时为system，未登录时为空"`
+	TeamID       string    `json:"teamId,omitempty"`
+	Action       string    `json:"action" binding:"required" doc:"操作，REST API为接口的operationId"`
+	ResourceType string    `json:"resourceType" binding:"required"`
+	ResourceID   string    `json:"resourceId"`
+	Provider     string    `json:"provider,omitempty" doc:"调用的AI服务"`
+	Model        string    `json:"model,omitempty" doc:"调用的模型"`
+	Status       int       `json:"status,omitempty" doc:"HTTP响应状态码"`
+	Detail       string    `json:"detail,omitempty"`
+}
+
+// Filter 是查询审计记录的条件，空字段表示不限
+type Filter struct {
+	TeamID       string
+	ActorID      string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Since        time.Time
+	Until        time.Time
+}
+
+// Match 判断记录是否满足查询条件
+func (f Filter) Match(e Entry) bool {
+	switch {
+	case f.TeamID != "" && e.TeamID != f.TeamID:
+		return false
+	case f.ActorID != "" && e.ActorID != f.ActorID:
+		return false
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case f.ResourceType != "" && e.ResourceType != f.ResourceType:
+		return false
+	case f.ResourceID != "" && e.ResourceID != f.ResourceID:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// Log 是只追加的审计日志，并发安全
+// 写文件时查询读取整个文件，不写文件时在内存中保留最近的记录
+type Log struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	recent []Entry
+}
+
+// NewLog 打开审计日志，path为空时不写文件，只输出到标准日志并在内存中保留最近的记录
+func NewLog(path string) (*Log, error) {
+	l := &Log{path: path}
+	if path == "" {
+		return l, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建审计日志目录失败: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志失败: %w", err)
+	}
+	l.file = file
+	return l, nil
+}
+
+// Record 追加一条审计记录，未设置时间时使用当前时间
+// 写入失败不影响业务操作，只打印日志
+func (l *Log) Record(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		slog.Error("序列化审计记录失败", "error", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.path == "" {
+		slog.Info("审计", "entry", json.RawMessage(data))
+		if len(l.recent) >= memoryLimit {
+			l.recent = append(l.recent[:0], l.recent[len(l.recent)-memoryLimit/2:]...)
+		}
+		l.recent = append(l.recent, entry)
+		return
+	}
+	if l.file == nil {
+		slog.Warn("审计日志已关闭，丢弃记录", "entry", json.RawMessage(data))
+		return
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		slog.Error("写入审计日志失败", "error", err)
+	}
+}
+
+// Query 按时间顺序返回满足条件的记录
+// 写文件时另外以只读方式打开文件扫描，不持有写入的锁，查询大文件时不会阻塞Record
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	var entries []Entry
+	if l.path == "" {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for _, e := range l.recent {
+			if filter.Match(e) {
+				entries = append(entries, e)
+			}
+		}
+		return entries, nil
+	}
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("读取审计日志失败: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// 跳过写到一半的记录
+			continue
+		}
+		if filter.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取审计日志失败: %w", err)
+	}
+	return entries, nil
+}
+
+// Close 关闭审计日志文件
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
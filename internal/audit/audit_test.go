@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLogQuery(t *testing.T) {
+	for name, path := range map[string]string{
+		"文件": filepath.Join(t.TempDir(), "audit.jsonl"),
+		"内存": "",
+	} {
+		t.Run(name, func(t *testing.T) {
+			l, err := NewLog(path)
+			if err != nil {
+				t.Fatalf("打开审计日志失败: %v", err)
+			}
+			defer l.Close()
+
+			start := time.Now().Add(-time.Minute)
+			l.Record(Entry{Time: start, ActorID: "u1", TeamID: "t1", Action: "getResume", ResourceType: "resumes", ResourceID: "r1"})
+			l.Record(Entry{ActorID: "u2", TeamID: "t1", Action: "createQuestionSet", ResourceType: "question-sets", ResourceID: "q1", Provider: "mock", Model: "mock"})
+			l.Record(Entry{ActorID: "u3", TeamID: "t2", Action: "getResume", ResourceType: "resumes", ResourceID: "r2"})
+
+			entries, err := l.Query(Filter{TeamID: "t1"})
+			if err != nil {
+				t.Fatalf("查询失败: %v", err)
+			}
+			if len(entries) != 2 || entries[0].ActorID != "u1" || entries[1].Provider != "mock" {
+				t.Fatalf("按团队查询结果不正确: %+v", entries)
+			}
+			if entries[1].Time.IsZero() {
+				t.Fatalf("未设置时间的记录应使用当前时间")
+			}
+
+			entries, _ = l.Query(Filter{Action: "getResume", Since: start.Add(time.Second)})
+			if len(entries) != 1 || entries[0].ResourceID != "r2" {
+				t.Fatalf("按操作和时间查询结果不正确: %+v", entries)
+			}
+			entries, _ = l.Query(Filter{Until: start})
+			if len(entries) != 0 {
+				t.Fatalf("截止时间不应包含当时的记录: %+v", entries)
+			}
+		})
+	}
+}
+
+// TestQueryWhileRecording 查询时另外打开文件读取，与写入并发时只读到完整的记录
+func TestQueryWhileRecording(t *testing.T) {
+	l, err := NewLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("打开审计日志失败: %v", err)
+	}
+	defer l.Close()
+
+	const n = 200
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			l.Record(Entry{ActorID: "u1", Action: "getResume", ResourceType: "resumes", ResourceID: fmt.Sprint(i)})
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		entries, err := l.Query(Filter{ActorID: "u1"})
+		if err != nil {
+			t.Fatalf("查询失败: %v", err)
+		}
+		for j, e := range entries {
+			if e.ResourceID != fmt.Sprint(j) {
+				t.Fatalf("查询结果应按写入顺序且不含写到一半的记录: %+v", e)
+			}
+		}
+	}
+	wg.Wait()
+
+	if entries, _ := l.Query(Filter{}); len(entries) != n {
+		t.Fatalf("应查到全部%d条记录，实际%d条", n, len(entries))
+	}
+}
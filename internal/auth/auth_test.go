@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	// 测试中使用最低加密强度，避免bcrypt拖慢测试
+	passwordCost = bcrypt.MinCost
+}
+
+// newTestService 创建保存到临时目录的认证服务
+func newTestService(t *testing.T) (*Service, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "auth.json")
+	s, err := NewService(path, time.Hour)
+	if err != nil {
+		t.Fatalf("创建认证服务失败: %v", err)
+	}
+	return s, path
+}
+
+func TestRegisterAndAuthenticate(t *testing.T) {
+	s, _ := newTestService(t)
+
+	user, err := s.Register(" Alice@Example.com ", "Alice", "password123", "", "")
+	if err != nil {
+		t.Fatalf("注册失败: %v", err)
+	}
+	if user.Email != "alice@example.com" || user.TeamID == "" || user.PasswordHash == "" {
+		t.Fatalf("注册结果不正确: %+v", user)
+	}
+
+	got, err := s.Authenticate("ALICE@example.com", "password123")
+	if err != nil || got.ID != user.ID {
+		t.Fatalf("登录失败: %v %+v", err, got)
+	}
+	if _, err := s.Authenticate("alice@example.com", "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("密码错误应返回ErrInvalidCredentials，实际为%v", err)
+	}
+	if _, err := s.Authenticate("nobody@example.com", "password123"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("用户不存在应返回ErrInvalidCredentials，实际为%v", err)
+	}
+
+	if _, err := s.Register("alice@example.com", "", "password123", "", ""); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("重复邮箱应返回ErrEmailTaken，实际为%v", err)
+	}
+	if _, err := s.Register("bob@example.com", "", "short", "", ""); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("密码过短应返回ErrWeakPassword，实际为%v", err)
+	}
+	if _, err := s.Register("not-an-email", "", "password123", "", ""); !errors.Is(err, ErrInvalidEmail) {
+		t.Fatalf("邮箱无效应返回ErrInvalidEmail，实际为%v", err)
+	}
+}
+
+func TestSessionsAndTokens(t *testing.T) {
+	s, _ := newTestService(t)
+	user, err := s.Register("alice@example.com", "", "password123", "", "")
+	if err != nil {
+		t.Fatalf("注册失败: %v", err)
+	}
+
+	secret, _ := s.CreateSession(user.ID)
+	if got, ok := s.SessionUser(secret); !ok || got.ID != user.ID {
+		t.Fatalf("登录会话无效: %v %+v", ok, got)
+	}
+	s.EndSession(secret)
+	if _, ok := s.SessionUser(secret); ok {
+		t.Fatal("退出登录后会话仍然有效")
+	}
+
+	raw, token, err := s.CreateToken(user.ID, "ci")
+	if err != nil {
+		t.Fatalf("创建令牌失败: %v", err)
+	}
+	if got, ok := s.TokenUser(raw); !ok || got.ID != user.ID {
+		t.Fatalf("令牌无效: %v %+v", ok, got)
+	}
+	if _, ok := s.TokenUser(raw + "x"); ok {
+		t.Fatal("错误的令牌不应通过校验")
+	}
+	if tokens := s.ListTokens(user.ID); len(tokens) != 1 || tokens[0].ID != token.ID || tokens[0].LastUsedAt == nil {
+		t.Fatalf("令牌列表不正确: %+v", tokens)
+	}
+
+	if err := s.RevokeToken("someone-else", token.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("不能撤销其他用户的令牌，实际为%v", err)
+	}
+	if err := s.RevokeToken(user.ID, token.ID); err != nil {
+		t.Fatalf("撤销令牌失败: %v", err)
+	}
+	if _, ok := s.TokenUser(raw); ok {
+		t.Fatal("撤销后令牌仍然有效")
+	}
+}
+
+func TestInviteJoinsTeamOnce(t *testing.T) {
+	s, _ := newTestService(t)
+	alice, err := s.Register("alice@example.com", "", "password123", "", "")
+	if err != nil {
+		t.Fatalf("注册失败: %v", err)
+	}
+
+	code, _, err := s.CreateInvite(alice, RoleInterviewer, time.Hour)
+	if err != nil {
+		t.Fatalf("创建邀请码失败: %v", err)
+	}
+	bob, err := s.Register("bob@example.com", "", "password123", code, "")
+	if err != nil {
+		t.Fatalf("使用邀请码注册失败: %v", err)
+	}
+	if bob.TeamID != alice.TeamID {
+		t.Fatalf("受邀用户应加入邀请人的团队: %s != %s", bob.TeamID, alice.TeamID)
+	}
+	if _, err := s.Register("carol@example.com", "", "password123", code, ""); !errors.Is(err, ErrInvalidInvite) {
+		t.Fatalf("邀请码只能使用一次，实际为%v", err)
+	}
+
+	expired, _, err := s.CreateInvite(alice, RoleInterviewer, -time.Minute)
+	if err != nil {
+		t.Fatalf("创建邀请码失败: %v", err)
+	}
+	if _, err := s.Register("carol@example.com", "", "password123", expired, ""); !errors.Is(err, ErrInvalidInvite) {
+		t.Fatalf("过期的邀请码应返回ErrInvalidInvite，实际为%v", err)
+	}
+
+	carol, err := s.Register("carol@example.com", "", "password123", "", "")
+	if err != nil {
+		t.Fatalf("注册失败: %v", err)
+	}
+	if !bob.CanAccess(alice.ID, alice.TeamID) {
+		t.Fatal("同一团队的用户应能访问彼此的资源")
+	}
+	if carol.CanAccess(alice.ID, alice.TeamID) {
+		t.Fatal("不同团队的用户不应能访问彼此的资源")
+	}
+	if !carol.CanAccess(carol.ID, "") {
+		t.Fatal("用户应能访问自己创建的资源")
+	}
+}
+
+func TestServicePersistence(t *testing.T) {
+	s, path := newTestService(t)
+	user, err := s.Register("alice@example.com", "", "password123", "", "")
+	if err != nil {
+		t.Fatalf("注册失败: %v", err)
+	}
+	raw, _, err := s.CreateToken(user.ID, "ci")
+	if err != nil {
+		t.Fatalf("创建令牌失败: %v", err)
+	}
+
+	reloaded, err := NewService(path, time.Hour)
+	if err != nil {
+		t.Fatalf("重新加载认证服务失败: %v", err)
+	}
+	if _, err := reloaded.Authenticate("alice@example.com", "password123"); err != nil {
+		t.Fatalf("重新加载后登录失败: %v", err)
+	}
+	if got, ok := reloaded.TokenUser(raw); !ok || got.ID != user.ID {
+		t.Fatalf("重新加载后令牌无效: %v %+v", ok, got)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	s, path := newTestService(t)
+
+	recruiter, err := s.Register("alice@example.com", "", "password123", "", "")
+	if err != nil || recruiter.Role != RoleRecruiter {
+		t.Fatalf("不带邀请码注册默认应为招聘者: %v %+v", err, recruiter)
+	}
+	candidate, err := s.Register("dave@example.com", "", "password123", "", RoleCandidate)
+	if err != nil || candidate.Role != RoleCandidate || candidate.TeamID == recruiter.TeamID {
+		t.Fatalf("候选人应注册到自己的团队: %v %+v", err, candidate)
+	}
+	if _, err := s.Register("erin@example.com", "", "password123", "", RoleInterviewer); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("面试官只能通过邀请码加入，实际为%v", err)
+	}
+
+	code, _, err := s.CreateInvite(recruiter, RoleInterviewer, time.Hour)
+	if err != nil {
+		t.Fatalf("创建邀请码失败: %v", err)
+	}
+	// 邀请码决定角色，忽略请求中的角色
+	interviewer, err := s.Register("bob@example.com", "", "password123", code, RoleRecruiter)
+	if err != nil || interviewer.Role != RoleInterviewer {
+		t.Fatalf("使用邀请码注册的角色应为面试官: %v %+v", err, interviewer)
+	}
+	if _, _, err := s.CreateInvite(recruiter, "admin", time.Hour); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("无效角色应返回ErrInvalidRole，实际为%v", err)
+	}
+
+	if members := s.TeamMembers(recruiter.TeamID); len(members) != 2 || members[0].ID != recruiter.ID {
+		t.Fatalf("团队成员不正确: %+v", members)
+	}
+	if _, err := s.SetRole(candidate.TeamID, interviewer.ID, RoleRecruiter); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("不能修改其他团队成员的角色，实际为%v", err)
+	}
+	if _, err := s.SetRole(recruiter.TeamID, interviewer.ID, RoleRecruiter); err != nil {
+		t.Fatalf("修改角色失败: %v", err)
+	}
+
+	reloaded, err := NewService(path, time.Hour)
+	if err != nil {
+		t.Fatalf("重新加载认证服务失败: %v", err)
+	}
+	if user, ok := reloaded.User(interviewer.ID); !ok || user.Role != RoleRecruiter {
+		t.Fatalf("重新加载后角色不正确: %+v", user)
+	}
+
+	if candidate.CanAccess(recruiter.ID, candidate.TeamID) {
+		t.Fatal("候选人不应能访问团队中其他人的资源")
+	}
+	if !candidate.HasRole(RoleRecruiter, RoleCandidate) || candidate.HasRole(RoleRecruiter) {
+		t.Fatal("HasRole结果不正确")
+	}
+}
@@ -0,0 +1,41 @@
+package auth
+
+import "errors"
+
+// Role 表示用户在团队中的角色
+type Role string
+
+// 用户角色
+const (
+	// RoleRecruiter 招聘者，管理简历、JD和面试，可以查看完整的评估
+	RoleRecruiter Role = "recruiter"
+	// RoleInterviewer 面试官，只能查看和记录分配给自己的面试会话
+	RoleInterviewer Role = "interviewer"
+	// RoleCandidate 候选人，在练习模式下只能访问自己的数据，看不到参考答案和评分要点
+	RoleCandidate Role = "candidate"
+)
+
+// ErrInvalidRole 表示角色不存在或不允许使用
+var ErrInvalidRole = errors.New("角色无效")
+
+// Valid 判断角色是否有效
+func (r Role) Valid() bool {
+	switch r {
+	case RoleRecruiter, RoleInterviewer, RoleCandidate:
+		return true
+	}
+	return false
+}
+
+// HasRole 判断用户是否具有roles中的任意一个角色
+func (u *User) HasRole(roles ...Role) bool {
+	if u == nil {
+		return false
+	}
+	for _, role := range roles {
+		if u.Role == role {
+			return true
+		}
+	}
+	return false
+}
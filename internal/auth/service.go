@@ -0,0 +1,381 @@
+package auth
+
+import (
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// tokenPrefix 是API令牌的前缀，便于在日志或代码中识别泄露的令牌
+const tokenPrefix = "rai_"
+
+// minPasswordLength 是密码的最小长度
+const minPasswordLength = 8
+
+// passwordCost 是bcrypt的计算成本，测试中可以调低
+var passwordCost = bcrypt.DefaultCost
+
+// dummyHash 用于邮箱不存在时仍执行一次bcrypt比较，避免通过响应时间判断邮箱是否注册
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// session 是一次网页登录会话
+type session struct {
+	userID    string
+	expiresAt time.Time
+}
+
+// Service 管理用户、API令牌、登录会话和团队邀请
+// 用户、令牌和邀请码保存到path指定的文件，登录会话只保存在内存中
+type Service struct {
+	mu         sync.Mutex
+	path       string
+	sessionTTL time.Duration
+
+	users    map[string]*User    // 按用户ID索引
+	byEmail  map[string]string   // 邮箱到用户ID
+	tokens   map[string]*Token   // 按令牌哈希索引
+	invites  map[string]*Invite  // 按邀请码哈希索引
+	sessions map[string]*session // 按会话哈希索引
+}
+
+// NewService 创建认证服务，path为空时不持久化
+func NewService(path string, sessionTTL time.Duration) (*Service, error) {
+	if sessionTTL <= 0 {
+		sessionTTL = 7 * 24 * time.Hour
+	}
+
+	s := &Service{
+		path:       path,
+		sessionTTL: sessionTTL,
+		users:      make(map[string]*User),
+		byEmail:    make(map[string]string),
+		tokens:     make(map[string]*Token),
+		invites:    make(map[string]*Invite),
+		sessions:   make(map[string]*session),
+	}
+	if path != "" {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// normalizeEmail 统一邮箱的大小写和空白
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Register 注册新用户
+// inviteCode为空时用户创建自己的团队，角色只能是招聘者（默认）或练习模式的候选人；
+// 否则加入邀请码对应的团队，角色由邀请码决定，忽略role参数
+func (s *Service) Register(email, name, password, inviteCode string, role Role) (*User, error) {
+	email = normalizeEmail(email)
+	if _, err := mail.ParseAddress(email); err != nil {
+		return nil, ErrInvalidEmail
+	}
+	if len(password) < minPasswordLength {
+		return nil, ErrWeakPassword
+	}
+	if role == "" {
+		role = RoleRecruiter
+	}
+	if inviteCode == "" && role != RoleRecruiter && role != RoleCandidate {
+		return nil, ErrInvalidRole
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return nil, fmt.Errorf("密码加密失败: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byEmail[email]; ok {
+		return nil, ErrEmailTaken
+	}
+
+	teamID := randomHex(12)
+	var invite *Invite
+	if inviteCode != "" {
+		invite = s.invites[hashSecret(inviteCode)]
+		if invite == nil || time.Now().After(invite.ExpiresAt) {
+			return nil, ErrInvalidInvite
+		}
+		teamID = invite.TeamID
+		role = invite.Role
+	}
+
+	if name == "" {
+		name = email
+	}
+	user := &User{
+		ID:           randomHex(12),
+		Email:        email,
+		Name:         strings.TrimSpace(name),
+		TeamID:       teamID,
+		Role:         role,
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now(),
+	}
+	s.users[user.ID] = user
+	s.byEmail[email] = user.ID
+	if invite != nil {
+		// 邀请码只能使用一次
+		delete(s.invites, invite.Hash)
+	}
+
+	if err := s.save(); err != nil {
+		delete(s.users, user.ID)
+		delete(s.byEmail, email)
+		if invite != nil {
+			s.invites[invite.Hash] = invite
+		}
+		return nil, err
+	}
+	copied := *user
+	return &copied, nil
+}
+
+// Authenticate 校验邮箱和密码
+func (s *Service) Authenticate(email, password string) (*User, error) {
+	s.mu.Lock()
+	var user *User
+	if id, ok := s.byEmail[normalizeEmail(email)]; ok {
+		copied := *s.users[id]
+		user = &copied
+	}
+	s.mu.Unlock()
+
+	if user == nil {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// User 按ID获取用户
+func (s *Service) User(id string) (*User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, false
+	}
+	copied := *user
+	return &copied, true
+}
+
+// CreateSession 为用户创建登录会话，返回会话密钥和过期时间
+func (s *Service) CreateSession(userID string) (string, time.Time) {
+	secret := randomHex(32)
+	expiresAt := time.Now().Add(s.sessionTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 顺便清理已过期的会话
+	now := time.Now()
+	for hash, sess := range s.sessions {
+		if now.After(sess.expiresAt) {
+			delete(s.sessions, hash)
+		}
+	}
+	s.sessions[hashSecret(secret)] = &session{userID: userID, expiresAt: expiresAt}
+	return secret, expiresAt
+}
+
+// SessionUser 返回登录会话对应的用户，会话不存在或已过期时返回false
+func (s *Service) SessionUser(secret string) (*User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := hashSecret(secret)
+	sess, ok := s.sessions[hash]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(sess.expiresAt) {
+		delete(s.sessions, hash)
+		return nil, false
+	}
+	user, ok := s.users[sess.userID]
+	if !ok {
+		return nil, false
+	}
+	copied := *user
+	return &copied, true
+}
+
+// EndSession 结束登录会话
+func (s *Service) EndSession(secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, hashSecret(secret))
+}
+
+// CreateToken 为用户创建API令牌，令牌原文只在创建时返回一次
+func (s *Service) CreateToken(userID, name string) (string, *Token, error) {
+	secret := tokenPrefix + randomHex(24)
+	token := &Token{
+		ID:        randomHex(8),
+		UserID:    userID,
+		Name:      name,
+		Hash:      hashSecret(secret),
+		CreatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return "", nil, ErrNotFound
+	}
+	s.tokens[token.Hash] = token
+	if err := s.save(); err != nil {
+		delete(s.tokens, token.Hash)
+		return "", nil, err
+	}
+	copied := *token
+	return secret, &copied, nil
+}
+
+// TokenUser 返回API令牌对应的用户
+func (s *Service) TokenUser(secret string) (*User, bool) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[hashSecret(secret)]
+	if !ok {
+		return nil, false
+	}
+	user, ok := s.users[token.UserID]
+	if !ok {
+		return nil, false
+	}
+	// 最近使用时间只在内存中更新，不为每次请求写文件
+	now := time.Now()
+	token.LastUsedAt = &now
+
+	copied := *user
+	return &copied, true
+}
+
+// ListTokens 列出用户的所有API令牌，按创建时间排序
+func (s *Service) ListTokens(userID string) []Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tokens []Token
+	for _, token := range s.tokens {
+		if token.UserID == userID {
+			tokens = append(tokens, *token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+	return tokens
+}
+
+// RevokeToken 撤销用户的API令牌
+func (s *Service) RevokeToken(userID, tokenID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.tokens {
+		if token.ID == tokenID && token.UserID == userID {
+			delete(s.tokens, hash)
+			return s.save()
+		}
+	}
+	return ErrNotFound
+}
+
+// CreateInvite 创建以role角色加入用户所在团队的一次性邀请码，邀请码原文只在创建时返回一次
+func (s *Service) CreateInvite(user *User, role Role, ttl time.Duration) (string, *Invite, error) {
+	if !role.Valid() {
+		return "", nil, ErrInvalidRole
+	}
+
+	code := randomHex(12)
+	invite := &Invite{
+		Hash:      hashSecret(code),
+		TeamID:    user.TeamID,
+		Role:      role,
+		CreatedBy: user.ID,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 顺便清理已过期的邀请码
+	now := time.Now()
+	for hash, old := range s.invites {
+		if now.After(old.ExpiresAt) {
+			delete(s.invites, hash)
+		}
+	}
+	s.invites[invite.Hash] = invite
+	if err := s.save(); err != nil {
+		delete(s.invites, invite.Hash)
+		return "", nil, err
+	}
+	copied := *invite
+	return code, &copied, nil
+}
+
+// TeamMembers 列出团队的所有成员，按注册时间排序
+func (s *Service) TeamMembers(teamID string) []User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var members []User
+	for _, user := range s.users {
+		if user.TeamID == teamID {
+			members = append(members, *user)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].CreatedAt.Before(members[j].CreatedAt)
+	})
+	return members
+}
+
+// SetRole 修改团队成员的角色
+func (s *Service) SetRole(teamID, userID string, role Role) (*User, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok || user.TeamID != teamID {
+		return nil, ErrNotFound
+	}
+	old := user.Role
+	user.Role = role
+	if err := s.save(); err != nil {
+		user.Role = old
+		return nil, err
+	}
+	copied := *user
+	return &copied, nil
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// userRecord 是持久化的用户，包含不对外输出的密码哈希
+type userRecord struct {
+	User
+	PasswordHash string `json:"passwordHash"`
+}
+
+// tokenRecord 是持久化的API令牌，包含令牌哈希
+type tokenRecord struct {
+	Token
+	Hash string `json:"hash"`
+}
+
+// inviteRecord 是持久化的邀请码，包含邀请码哈希
+type inviteRecord struct {
+	Invite
+	Hash string `json:"hash"`
+}
+
+// snapshot 是认证数据文件的内容
+type snapshot struct {
+	Users   []userRecord   `json:"users"`
+	Tokens  []tokenRecord  `json:"tokens"`
+	Invites []inviteRecord `json:"invites"`
+}
+
+// load 从文件加载认证数据，文件不存在时视为空
+func (s *Service) load() error {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取认证数据失败: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("解析认证数据失败: %w", err)
+	}
+
+	for _, r := range snap.Users {
+		user := r.User
+		user.PasswordHash = r.PasswordHash
+		if user.Role == "" {
+			// 引入角色之前注册的用户都是招聘者
+			user.Role = RoleRecruiter
+		}
+		s.users[user.ID] = &user
+		s.byEmail[user.Email] = user.ID
+	}
+	for _, r := range snap.Tokens {
+		token := r.Token
+		token.Hash = r.Hash
+		s.tokens[token.Hash] = &token
+	}
+	for _, r := range snap.Invites {
+		invite := r.Invite
+		invite.Hash = r.Hash
+		if invite.Role == "" {
+			invite.Role = RoleRecruiter
+		}
+		s.invites[invite.Hash] = &invite
+	}
+	return nil
+}
+
+// save 将认证数据写入文件，调用方需持有锁
+// 先写临时文件再重命名，避免写到一半的文件
+func (s *Service) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	var snap snapshot
+	for _, user := range s.users {
+		snap.Users = append(snap.Users, userRecord{User: *user, PasswordHash: user.PasswordHash})
+	}
+	for _, token := range s.tokens {
+		snap.Tokens = append(snap.Tokens, tokenRecord{Token: *token, Hash: token.Hash})
+	}
+	for _, invite := range s.invites {
+		snap.Invites = append(snap.Invites, inviteRecord{Invite: *invite, Hash: invite.Hash})
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化认证数据失败: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("创建认证数据目录失败: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入认证数据失败: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("保存认证数据失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Package auth 提供用户账号、API令牌、登录会话和团队邀请
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrEmailTaken 表示邮箱已被注册
+	ErrEmailTaken = errors.New("邮箱已被注册")
+	// ErrInvalidCredentials 表示邮箱或密码错误
+	ErrInvalidCredentials = errors.New("邮箱或密码错误")
+	// ErrWeakPassword 表示密码不满足要求
+	ErrWeakPassword = errors.New("密码至少需要8个字符")
+	// ErrInvalidEmail 表示邮箱格式不正确
+	ErrInvalidEmail = errors.New("邮箱格式不正确")
+	// ErrInvalidInvite 表示邀请码无效、已使用或已过期
+	ErrInvalidInvite = errors.New("邀请码无效或已过期")
+	// ErrNotFound 表示令牌或用户不存在
+	ErrNotFound = errors.New("记录不存在")
+)
+
+// LocalUser 是未启用认证时所有请求使用的本地用户
+var LocalUser = &User{ID: "local", Email: "local@localhost", Name: "本地用户", TeamID: "local", Role: RoleRecruiter}
+
+// User 表示一个用户账号
+type User struct {
+	ID           string    `json:"id"`
+	Email        string    `json:"email"`
+	Name         string    `json:"name"`
+	TeamID       string    `json:"teamId"`
+	Role         Role      `json:"role" enum:"recruiter,interviewer,candidate"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// CanAccess 判断用户能否访问属于ownerID或teamID的资源
+// 用户可以访问自己创建的资源和同一团队的资源，候选人只能访问自己创建的资源
+func (u *User) CanAccess(ownerID, teamID string) bool {
+	if u == nil {
+		return false
+	}
+	if ownerID != "" && ownerID == u.ID {
+		return true
+	}
+	return u.Role != RoleCandidate && teamID != "" && teamID == u.TeamID
+}
+
+// Token 表示一个API令牌，只保存令牌的哈希
+type Token struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"userId"`
+	Name       string     `json:"name"`
+	Hash       string     `json:"-"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// Invite 表示加入团队的一次性邀请码，只保存邀请码的哈希
+type Invite struct {
+	Hash      string    `json:"-"`
+	TeamID    string    `json:"teamId"`
+	Role      Role      `json:"role"`
+	CreatedBy string    `json:"createdBy"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// randomHex 生成n字节的随机数并编码为十六进制
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// hashSecret 计算令牌、会话或邀请码的哈希，泄露存储文件也无法还原原文
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,383 @@
+// Package encryption 使用AES-GCM信封加密保存到磁盘的文件和记录
+// 每个文件使用随机生成的数据密钥加密，数据密钥再用配置的主密钥加密后保存在文件头中
+// 更换主密钥时只需用新主密钥重新加密文件头中的数据密钥，不需要重新加密文件内容
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// magic 是加密文件的文件头标识，没有该标识的数据视为明文
+var magic = []byte("RAIENC1\x00")
+
+// 密钥和随机数长度
+const (
+	keySize   = 32 // AES-256
+	nonceSize = 12
+)
+
+var (
+	// ErrNoKey 表示数据已加密，但没有配置对应的主密钥
+	ErrNoKey = errors.New("缺少解密所需的密钥")
+	// ErrCorrupted 表示加密数据格式错误或已被篡改
+	ErrCorrupted = errors.New("加密数据已损坏")
+)
+
+// Keyring 保存主密钥，第一个密钥用于加密，其余密钥只用于解密轮换前加密的数据
+// nil的Keyring表示未启用加密，写入明文，读取时明文原样返回
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// ParseKeyring 解析逗号分隔的主密钥列表，格式为 id:base64密钥，第一个为当前使用的密钥
+// 密钥必须是32字节，spec为空时返回nil，表示不加密
+func ParseKeyring(spec string) (*Keyring, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	k := &Keyring{keys: make(map[string][]byte)}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(item, ":")
+		if !ok || id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("密钥格式错误，应为 id:base64密钥")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("密钥%s必须是base64编码的%d字节", id, keySize)
+		}
+		if _, exists := k.keys[id]; exists {
+			return nil, fmt.Errorf("密钥ID重复: %s", id)
+		}
+		if k.primary == "" {
+			k.primary = id
+		}
+		k.keys[id] = key
+	}
+	if k.primary == "" {
+		return nil, nil
+	}
+	return k, nil
+}
+
+// Enabled 判断是否启用了加密
+func (k *Keyring) Enabled() bool {
+	return k != nil
+}
+
+// Primary 返回当前用于加密的密钥ID
+func (k *Keyring) Primary() string {
+	if k == nil {
+		return ""
+	}
+	return k.primary
+}
+
+// IsEncrypted 判断数据是否是加密格式
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// header 是加密数据的文件头
+type header struct {
+	keyID      string
+	wrappedKey []byte // 随机数+加密后的数据密钥
+	body       []byte // 随机数+加密后的内容
+}
+
+// parseHeader 解析加密数据
+// 格式：magic | 密钥ID长度(1) | 密钥ID | 数据密钥长度(1) | 加密的数据密钥 | 加密的内容
+func parseHeader(data []byte) (*header, error) {
+	rest := data[len(magic):]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return nil, ErrCorrupted
+	}
+	h := &header{keyID: string(rest[1 : 1+rest[0]])}
+	rest = rest[1+rest[0]:]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return nil, ErrCorrupted
+	}
+	h.wrappedKey = rest[1 : 1+rest[0]]
+	h.body = rest[1+rest[0]:]
+	if len(h.body) < nonceSize {
+		return nil, ErrCorrupted
+	}
+	return h, nil
+}
+
+// encode 序列化加密数据
+func (h *header) encode() []byte {
+	out := make([]byte, 0, len(magic)+2+len(h.keyID)+len(h.wrappedKey)+len(h.body))
+	out = append(out, magic...)
+	out = append(out, byte(len(h.keyID)))
+	out = append(out, h.keyID...)
+	out = append(out, byte(len(h.wrappedKey)))
+	out = append(out, h.wrappedKey...)
+	return append(out, h.body...)
+}
+
+// seal 使用AES-GCM加密，返回随机数+密文
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize, nonceSize+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成随机数失败: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open 解密seal的结果
+func open(key, data, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < nonceSize {
+		return nil, ErrCorrupted
+	}
+	plaintext, err := gcm.Open(nil, data[:nonceSize], data[nonceSize:], aad)
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	return plaintext, nil
+}
+
+// Seal 加密数据，未启用加密时原样返回
+func (k *Keyring) Seal(plaintext []byte) ([]byte, error) {
+	if k == nil {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("生成数据密钥失败: %w", err)
+	}
+	body, err := seal(dataKey, plaintext, magic)
+	if err != nil {
+		return nil, fmt.Errorf("加密数据失败: %w", err)
+	}
+	wrapped, err := seal(k.keys[k.primary], dataKey, []byte(k.primary))
+	if err != nil {
+		return nil, fmt.Errorf("加密数据密钥失败: %w", err)
+	}
+	return (&header{keyID: k.primary, wrappedKey: wrapped, body: body}).encode(), nil
+}
+
+// Open 解密数据，明文原样返回，因此启用加密前写入的文件仍然可以读取
+func (k *Keyring) Open(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	h, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := k.unwrap(h)
+	if err != nil {
+		return nil, err
+	}
+	return open(dataKey, h.body, magic)
+}
+
+// unwrap 用主密钥解密文件头中的数据密钥
+func (k *Keyring) unwrap(h *header) ([]byte, error) {
+	if k == nil {
+		return nil, ErrNoKey
+	}
+	key, ok := k.keys[h.keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoKey, h.keyID)
+	}
+	return open(key, h.wrappedKey, []byte(h.keyID))
+}
+
+// Rewrap 用当前主密钥重新加密数据密钥，内容不变；返回数据是否被修改
+// 明文和已经使用当前主密钥的数据原样返回
+func (k *Keyring) Rewrap(data []byte) ([]byte, bool, error) {
+	if k == nil || !IsEncrypted(data) {
+		return data, false, nil
+	}
+	h, err := parseHeader(data)
+	if err != nil {
+		return nil, false, err
+	}
+	if h.keyID == k.primary {
+		return data, false, nil
+	}
+	dataKey, err := k.unwrap(h)
+	if err != nil {
+		return nil, false, err
+	}
+	wrapped, err := seal(k.keys[k.primary], dataKey, []byte(k.primary))
+	if err != nil {
+		return nil, false, fmt.Errorf("加密数据密钥失败: %w", err)
+	}
+	h.keyID, h.wrappedKey = k.primary, wrapped
+	return h.encode(), true, nil
+}
+
+// WriteFile 加密后写入文件，先写临时文件再重命名，避免写到一半的文件
+func (k *Keyring) WriteFile(path string, data []byte, perm os.FileMode) error {
+	sealed, err := k.Seal(data)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, sealed, perm)
+}
+
+// ReadFile 读取文件并解密
+func (k *Keyring) ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.Open(data)
+}
+
+// SealFile 原地加密明文文件，未启用加密或文件已加密时不做处理
+func (k *Keyring) SealFile(path string) error {
+	if k == nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取文件失败: %w", err)
+	}
+	if IsEncrypted(data) {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("读取文件信息失败: %w", err)
+	}
+	if err := k.WriteFile(path, data, info.Mode().Perm()); err != nil {
+		return err
+	}
+	// 保留原来的修改时间，保留期限仍按上传时间计算
+	return os.Chtimes(path, info.ModTime(), info.ModTime())
+}
+
+// OpenToTemp 返回可以直接读取的明文文件路径，供只接受文件路径的解析器和OCR工具使用
+// 文件已加密时解密到只有当前用户可读的临时目录，调用cleanup删除；未加密时返回原路径
+func (k *Keyring) OpenToTemp(path string) (plainPath string, cleanup func(), err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	if !IsEncrypted(data) {
+		return path, func() {}, nil
+	}
+	plaintext, err := k.Open(data)
+	if err != nil {
+		return "", nil, fmt.Errorf("解密文件失败: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "decrypted-")
+	if err != nil {
+		return "", nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	// 保留文件名和扩展名，解析器根据扩展名选择解析方式
+	plainPath = filepath.Join(dir, filepath.Base(path))
+	if err := os.WriteFile(plainPath, plaintext, 0600); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	return plainPath, cleanup, nil
+}
+
+// RewrapDir 把目录下用旧主密钥加密的文件改用当前主密钥，返回处理的文件数
+// 用于更换主密钥后迁移已有文件，完成后即可从配置中删除旧密钥
+func (k *Keyring) RewrapDir(dir string) (int, error) {
+	if k == nil || len(k.keys) < 2 {
+		return 0, nil
+	}
+
+	count := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rewrapped, changed, err := k.Rewrap(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if !changed {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := writeAtomic(path, rewrapped, info.Mode().Perm()); err != nil {
+			return err
+		}
+		os.Chtimes(path, info.ModTime(), info.ModTime())
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("轮换密钥失败: %w", err)
+	}
+	return count, nil
+}
+
+// writeAtomic 先写临时文件再重命名
+func writeAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	return nil
+}
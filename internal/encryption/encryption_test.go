@@ -0,0 +1,131 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testKey 生成测试用的密钥配置
+func testKey(id string, b byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, keySize))
+}
+
+func TestSealAndOpen(t *testing.T) {
+	k, err := ParseKeyring(testKey("k1", 1))
+	if err != nil {
+		t.Fatalf("解析密钥失败: %v", err)
+	}
+
+	plaintext := []byte("张三 13812345678")
+	sealed, err := k.Seal(plaintext)
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	if !IsEncrypted(sealed) || bytes.Contains(sealed, plaintext) {
+		t.Fatalf("加密结果中包含明文")
+	}
+	opened, err := k.Open(sealed)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("解密结果错误: %q %v", opened, err)
+	}
+
+	// 明文原样返回
+	if opened, err := k.Open(plaintext); err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("明文应原样返回: %q %v", opened, err)
+	}
+
+	// 篡改的数据无法解密
+	sealed[len(sealed)-1] ^= 0xff
+	if _, err := k.Open(sealed); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("篡改的数据应返回ErrCorrupted，实际: %v", err)
+	}
+
+	// 未配置密钥时无法解密
+	var none *Keyring
+	sealed, _ = k.Seal(plaintext)
+	if _, err := none.Open(sealed); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("未配置密钥时应返回ErrNoKey，实际: %v", err)
+	}
+	if out, _ := none.Seal(plaintext); !bytes.Equal(out, plaintext) {
+		t.Fatalf("未启用加密时应写入明文")
+	}
+}
+
+func TestParseKeyringErrors(t *testing.T) {
+	for _, spec := range []string{"k1", "k1:short", testKey("k1", 1) + "," + testKey("k1", 2)} {
+		if _, err := ParseKeyring(spec); err == nil {
+			t.Fatalf("密钥配置%q应报错", spec)
+		}
+	}
+	if k, err := ParseKeyring(" "); k != nil || err != nil {
+		t.Fatalf("空配置应返回nil")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, _ := ParseKeyring(testKey("old", 1))
+	rotated, _ := ParseKeyring(testKey("new", 2) + "," + testKey("old", 1))
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "resumes", "a.txt")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := old.WriteFile(path, []byte("简历内容"), 0600); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	// 新密钥列表仍能读取旧密钥加密的文件
+	if data, err := rotated.ReadFile(path); err != nil || string(data) != "简历内容" {
+		t.Fatalf("读取旧密钥加密的文件失败: %q %v", data, err)
+	}
+
+	count, err := rotated.RewrapDir(dir)
+	if err != nil || count != 1 {
+		t.Fatalf("轮换密钥处理了%d个文件: %v", count, err)
+	}
+	if _, err := old.ReadFile(path); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("轮换后不应再使用旧密钥: %v", err)
+	}
+	onlyNew, _ := ParseKeyring(testKey("new", 2))
+	if data, err := onlyNew.ReadFile(path); err != nil || string(data) != "简历内容" {
+		t.Fatalf("轮换后去掉旧密钥仍应能读取: %q %v", data, err)
+	}
+
+	// 已使用当前密钥的文件不再处理
+	if count, _ := rotated.RewrapDir(dir); count != 0 {
+		t.Fatalf("重复轮换处理了%d个文件", count)
+	}
+}
+
+func TestSealFileAndOpenToTemp(t *testing.T) {
+	k, _ := ParseKeyring(testKey("k1", 1))
+	path := filepath.Join(t.TempDir(), "resume.txt")
+	os.WriteFile(path, []byte("明文简历"), 0600)
+
+	if err := k.SealFile(path); err != nil {
+		t.Fatalf("加密文件失败: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !IsEncrypted(data) {
+		t.Fatalf("文件未被加密")
+	}
+
+	plainPath, cleanup, err := k.OpenToTemp(path)
+	if err != nil {
+		t.Fatalf("解密到临时文件失败: %v", err)
+	}
+	if !strings.HasSuffix(plainPath, "resume.txt") || plainPath == path {
+		t.Fatalf("临时文件应保留文件名: %s", plainPath)
+	}
+	if data, _ := os.ReadFile(plainPath); string(data) != "明文简历" {
+		t.Fatalf("临时文件内容错误: %q", data)
+	}
+	cleanup()
+	if _, err := os.Stat(plainPath); !os.IsNotExist(err) {
+		t.Fatalf("临时文件未被删除")
+	}
+}
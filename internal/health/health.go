@@ -0,0 +1,115 @@
+// Package health 检查服务依赖的OCR工具、AI服务和存储是否可用
+// 检查并发执行，每项有独立的超时，单项检查卡住不会拖慢整个探针
+package health
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Status 是检查结果的状态
+type Status string
+
+const (
+	StatusOK       Status = "ok"       // 检查通过
+	StatusFail     Status = "fail"     // 检查失败
+	StatusSkipped  Status = "skipped"  // 当前配置不需要该依赖
+	StatusDegraded Status = "degraded" // 只有非必需的检查失败，服务可用但部分功能受限
+)
+
+// Check 是一项依赖检查
+type Check struct {
+	Name     string
+	Required bool // 必需的检查失败时服务不能正常工作
+	// Run 执行检查，返回展示在诊断信息中的详情；Run为nil表示当前配置不需要该依赖
+	Run func(ctx context.Context) (map[string]any, error)
+}
+
+// Result 是一项检查的结果
+type Result struct {
+	Name       string         `json:"name" binding:"required"`
+	Status     Status         `json:"status" binding:"required" enum:"ok,fail,skipped"`
+	Required   bool           `json:"required"`
+	Error      string         `json:"error,omitempty"`
+	Details    map[string]any `json:"details,omitempty"`
+	DurationMs int64          `json:"durationMs"`
+}
+
+// Report 是一组检查的汇总结果
+type Report struct {
+	Status Status   `json:"status" binding:"required" doc:"必需的检查都通过时为ok，只有非必需的检查失败时为degraded，否则为fail" enum:"ok,degraded,fail"`
+	Checks []Result `json:"checks"`
+}
+
+// Ready 返回必需的检查是否都通过
+func (r Report) Ready() bool {
+	return r.Status != StatusFail
+}
+
+// Run 并发执行检查，每项检查最多等待timeout，结果按传入的顺序返回
+func Run(ctx context.Context, timeout time.Duration, checks ...Check) Report {
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		if check.Run == nil {
+			results[i] = Result{Name: check.Name, Status: StatusSkipped, Required: check.Required}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runOne(ctx, timeout, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, result := range results {
+		if result.Status != StatusFail {
+			continue
+		}
+		if result.Required {
+			report.Status = StatusFail
+			break
+		}
+		report.Status = StatusDegraded
+	}
+	return report
+}
+
+// runOne 在超时时间内执行一项检查
+func runOne(ctx context.Context, timeout time.Duration, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := check.Run(ctx)
+	result := Result{
+		Name:       check.Name,
+		Status:     StatusOK,
+		Required:   check.Required,
+		Details:    details,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// CheckWritable 检查目录可以创建并写入文件，写入的临时文件随即删除
+func CheckWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	file, err := os.CreateTemp(dir, ".healthcheck-*")
+	if err != nil {
+		return fmt.Errorf("目录不可写: %w", err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
@@ -0,0 +1,71 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func ok(context.Context) (map[string]any, error) {
+	return map[string]any{"version": "1.0"}, nil
+}
+
+func fail(context.Context) (map[string]any, error) {
+	return nil, errors.New("未安装")
+}
+
+func TestRunAggregatesStatus(t *testing.T) {
+	report := Run(context.Background(), time.Second,
+		Check{Name: "storage", Required: true, Run: ok},
+		Check{Name: "pdftoppm", Run: fail},
+		Check{Name: "ocrspace"},
+	)
+	if report.Status != StatusDegraded || !report.Ready() {
+		t.Fatalf("只有非必需的检查失败时应为degraded，得到%s", report.Status)
+	}
+	if len(report.Checks) != 3 || report.Checks[0].Name != "storage" || report.Checks[0].Details["version"] != "1.0" {
+		t.Fatalf("检查结果应按传入顺序返回并带有详情: %+v", report.Checks)
+	}
+	if report.Checks[1].Status != StatusFail || report.Checks[1].Error != "未安装" {
+		t.Fatalf("失败的检查应记录错误: %+v", report.Checks[1])
+	}
+	if report.Checks[2].Status != StatusSkipped {
+		t.Fatalf("没有Run的检查应跳过: %+v", report.Checks[2])
+	}
+
+	report = Run(context.Background(), time.Second, Check{Name: "tesseract", Required: true, Run: fail})
+	if report.Status != StatusFail || report.Ready() {
+		t.Fatalf("必需的检查失败时应为fail，得到%s", report.Status)
+	}
+}
+
+func TestRunTimesOut(t *testing.T) {
+	slow := func(ctx context.Context) (map[string]any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	start := time.Now()
+	report := Run(context.Background(), 50*time.Millisecond, Check{Name: "ai_provider", Required: true, Run: slow})
+	if time.Since(start) > time.Second || report.Checks[0].Status != StatusFail {
+		t.Fatalf("超时的检查应及时返回失败: %+v", report.Checks[0])
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+	if err := CheckWritable(dir); err != nil {
+		t.Fatalf("可写目录检查失败: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("检查后应删除临时文件，剩余%d个", len(entries))
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0644)
+	if err := CheckWritable(file); err == nil {
+		t.Fatal("普通文件不能作为目录")
+	}
+}
@@ -3,10 +3,15 @@ package interview
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"strings"
 
+	"github.com/10yihang/resume-ai-interview/internal/ai"
+	"github.com/10yihang/resume-ai-interview/internal/metrics"
 	"github.com/10yihang/resume-ai-interview/models"
 	"github.com/sashabaranov/go-openai"
 )
@@ -14,6 +19,8 @@ import (
 // AnswerEvaluator 用于评估面试答案
 type AnswerEvaluator struct {
 	client *openai.Client
+	model  string
+	rubric []string
 }
 
 // NewAnswerEvaluator 创建答案评估器
@@ -25,51 +32,126 @@ func NewAnswerEvaluator(apiKey string) *AnswerEvaluator {
 	client := openai.NewClient(apiKey)
 	return &AnswerEvaluator{
 		client: client,
+		model:  openai.GPT4TurboPreview,
 	}
 }
 
-// EvaluateAnswer 评估面试回答
-func (e *AnswerEvaluator) EvaluateAnswer(question models.Question, answer models.Answer, jd *models.JobDescription) (*models.Evaluation, error) {
-	// 构建提示词
-	prompt := buildEvaluationPrompt(question, answer, jd)
+// WithModel 设置使用的模型，model为空时保留默认模型
+func (e *AnswerEvaluator) WithModel(model string) *AnswerEvaluator {
+	if model != "" {
+		e.model = model
+	}
+	return e
+}
+
+// WithRubric 设置评分标准，每项一个分数段，为空时使用默认标准
+func (e *AnswerEvaluator) WithRubric(rubric []string) *AnswerEvaluator {
+	e.rubric = rubric
+	return e
+}
+
+// evaluationSystemPrompt 评估面试回答时使用的系统提示词
+const evaluationSystemPrompt = "你是一位专业的HR面试官，需要评估候选人的面试回答。请基于面试问题、候选人的回答以及职位要求，评估回答质量，给出分数（1-10）、反馈和改进建议。"
 
+// Provider 返回使用的AI服务
+func (e *AnswerEvaluator) Provider() string { return ai.ProviderOpenAI }
+
+// Model 返回使用的模型
+func (e *AnswerEvaluator) Model() string { return e.model }
+
+// EvaluateAnswer 评估面试回答
+func (e *AnswerEvaluator) EvaluateAnswer(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription) (*models.Evaluation, error) {
 	// 调用OpenAI API
-	resp, err := e.client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4TurboPreview,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "你是一位专业的HR面试官，需要评估候选人的面试回答。请基于面试问题、候选人的回答以及职位要求，评估回答质量，给出分数（1-10）、反馈和改进建议。",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-			MaxTokens: 1024,
-		},
-	)
+	callCtx, call := ai.StartCall(ctx, ai.ProviderOpenAI, e.Model(), false)
+	resp, err := e.client.CreateChatCompletion(callCtx, e.buildRequest(question, answer, jd))
+	call.End(resp.Usage.PromptTokens, resp.Usage.CompletionTokens, err)
 
 	if err != nil {
 		return nil, fmt.Errorf("调用AI接口评估答案失败: %w", err)
 	}
 
 	// 解析评估结果
-	evaluation := parseEvaluation(answer, resp.Choices[0].Message.Content)
+	evaluation := parseEvaluation(ctx, answer, resp.Choices[0].Message.Content)
+	evaluation.Usage = ai.NewTokenUsage(ai.ProviderOpenAI, e.Model(), resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	return evaluation, nil
+}
+
+// EvaluateAnswerStream 流式评估面试回答，逐段推送反馈和建议
+func (e *AnswerEvaluator) EvaluateAnswerStream(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription, onDelta func(field, text string)) (_ *models.Evaluation, err error) {
+	var usage openai.Usage
+	callCtx, call := ai.StartCall(ctx, ai.ProviderOpenAI, e.Model(), true)
+	defer func() { call.End(usage.PromptTokens, usage.CompletionTokens, err) }()
+
+	// 要求接口在最后一个数据块中返回token用量
+	request := e.buildRequest(question, answer, jd)
+	request.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := e.client.CreateChatCompletionStream(callCtx, request)
+	if err != nil {
+		return nil, fmt.Errorf("调用AI接口评估答案失败: %w", err)
+	}
+	defer stream.Close()
+
+	extractor := newFeedbackStreamExtractor(onDelta)
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取AI流式响应失败: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		if len(chunk.Choices) > 0 {
+			extractor.Write(chunk.Choices[0].Delta.Content)
+		}
+	}
+
+	evaluation := parseEvaluation(ctx, answer, extractor.Content())
+	evaluation.Usage = ai.NewTokenUsage(ai.ProviderOpenAI, e.Model(), usage.PromptTokens, usage.CompletionTokens)
 	return evaluation, nil
 }
 
-// 构建评估提示词
-func buildEvaluationPrompt(question models.Question, answer models.Answer, jd *models.JobDescription) string {
+// buildRequest 构建评估回答的OpenAI请求
+func (e *AnswerEvaluator) buildRequest(question models.Question, answer models.Answer, jd *models.JobDescription) openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Model: e.model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: evaluationSystemPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: buildEvaluationPrompt(question, answer, jd, e.rubric),
+			},
+		},
+		MaxTokens: 1024,
+	}
+}
+
+// defaultRubric 未配置评分标准时使用的默认标准
+var defaultRubric = []string{
+	"1-3分：不满足基本要求，回答模糊或错误",
+	"4-6分：基本符合要求，但缺乏深度或细节",
+	"7-8分：良好的回答，体现了专业知识和经验",
+	"9-10分：优秀的回答，全面、深入且有洞察力",
+}
+
+// 构建评估提示词，rubric为空时使用默认评分标准
+func buildEvaluationPrompt(question models.Question, answer models.Answer, jd *models.JobDescription, rubric []string) string {
+	if len(rubric) == 0 {
+		rubric = defaultRubric
+	}
+
 	return fmt.Sprintf(`
 请评估以下面试回答：
 
 ==== 面试问题 ====
 问题：%s
 问题类别：%s
-
+%s
 ==== 候选人回答 ====
 %s
 
@@ -86,22 +168,33 @@ func buildEvaluationPrompt(question models.Question, answer models.Answer, jd *m
 }
 
 评分标准：
-1-3分：不满足基本要求，回答模糊或错误
-4-6分：基本符合要求，但缺乏深度或细节
-7-8分：良好的回答，体现了专业知识和经验
-9-10分：优秀的回答，全面、深入且有洞察力
+%s
 `,
 		question.Content,
 		question.Category,
+		answerKey(question),
 		answer.Content,
 		jd.Title,
 		jd.Company,
 		strings.Join(jd.Requirements, ", "),
+		strings.Join(rubric, "\n"),
 	)
 }
 
+// answerKey 返回问题的参考答案和评分要点，没有时返回空字符串
+func answerKey(question models.Question) string {
+	var b strings.Builder
+	if question.ReferenceAnswer != "" {
+		fmt.Fprintf(&b, "参考答案：%s\n", question.ReferenceAnswer)
+	}
+	if len(question.Rubric) > 0 {
+		fmt.Fprintf(&b, "评分要点：%s\n", strings.Join(question.Rubric, "；"))
+	}
+	return b.String()
+}
+
 // 解析AI返回的评估结果
-func parseEvaluation(answer models.Answer, content string) *models.Evaluation {
+func parseEvaluation(ctx context.Context, answer models.Answer, content string) *models.Evaluation {
 	// 提取JSON部分
 	jsonStr := extractEvaluationJSON(content)
 
@@ -113,13 +206,15 @@ func parseEvaluation(answer models.Answer, content string) *models.Evaluation {
 
 	err := json.Unmarshal([]byte(jsonStr), &result)
 	if err != nil {
-		fmt.Printf("解析评估JSON失败: %v\n", err)
+		slog.WarnContext(ctx, "解析评估JSON失败，尝试修复", "error", err)
 		// 尝试修复JSON格式
 		fixedJSON := tryFixJSON(jsonStr)
 		err = json.Unmarshal([]byte(fixedJSON), &result)
+		metrics.RecordRepair("evaluator", err)
 
 		if err != nil {
 			// 如果仍然失败，返回默认评估
+			metrics.Fallbacks.Inc(metrics.FallbackDefaultEvaluation)
 			return &models.Evaluation{
 				AnswerID:    answer.QuestionID,
 				Score:       7,
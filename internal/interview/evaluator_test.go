@@ -7,11 +7,15 @@
 package interview
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/10yihang/resume-ai-interview/config"
+	"github.com/10yihang/resume-ai-interview/internal/ai"
 	"github.com/10yihang/resume-ai-interview/models"
 )
 
@@ -33,7 +37,7 @@ func TestAnswerEvaluation(t *testing.T) {
 
 	// 测试Grok答案评估器
 	grokEvaluator := NewGrok3AnswerEvaluator(apiKey)
-	grokEvaluation, err := grokEvaluator.EvaluateAnswer(question, answer, jd)
+	grokEvaluation, err := grokEvaluator.EvaluateAnswer(context.Background(), question, answer, jd)
 	if err != nil {
 		t.Logf("Grok评估失败: %v", err)
 	} else {
@@ -45,7 +49,7 @@ func TestAnswerEvaluation(t *testing.T) {
 
 	// 测试模拟答案评估器
 	mockEvaluator := NewMockAnswerEvaluator()
-	mockEvaluation, err := mockEvaluator.EvaluateAnswer(question, answer, jd)
+	mockEvaluation, err := mockEvaluator.EvaluateAnswer(context.Background(), question, answer, jd)
 	if err != nil {
 		t.Errorf("模拟评估失败: %v", err)
 	} else {
@@ -82,3 +86,105 @@ func createTestData() (models.Question, models.Answer, *models.JobDescription) {
 
 	return question, answer, jd
 }
+
+func TestFeedbackStreamExtractor(t *testing.T) {
+	got := make(map[string]string)
+	extractor := newFeedbackStreamExtractor(func(field, text string) {
+		got[field] += text
+	})
+
+	// 模拟模型逐段返回的评估JSON，转义序列和中文字符可能被切断
+	content := "{\n  \"score\": 8,\n  \"feedback\": \"回答结构清晰，\\\"STAR\\\"方法运用得当\\n\",\n  \"suggestions\": \"可以补充量化结果\"\n}"
+	raw := []byte(content)
+	for i := 0; i < len(raw); i += 5 {
+		end := min(i+5, len(raw))
+		extractor.Write(string(raw[i:end]))
+	}
+
+	if got["feedback"] != "回答结构清晰，\"STAR\"方法运用得当\n" {
+		t.Errorf("feedback提取不正确: %q", got["feedback"])
+	}
+	if got["suggestions"] != "可以补充量化结果" {
+		t.Errorf("suggestions提取不正确: %q", got["suggestions"])
+	}
+
+	evaluation := parseEvaluation(context.Background(), models.Answer{QuestionID: 1}, extractor.Content())
+	if evaluation.Score != 8 {
+		t.Errorf("期望分数为8，实际为%d", evaluation.Score)
+	}
+}
+
+// echoEvaluator 把收到的回答原样写入反馈，用于检查遮蔽和还原
+type echoEvaluator struct {
+	received string
+}
+
+func (e *echoEvaluator) EvaluateAnswer(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription) (*models.Evaluation, error) {
+	e.received = answer.Content
+	return &models.Evaluation{Score: 6, Feedback: answer.Content, Suggestions: "请补充" + answer.Content}, nil
+}
+
+func (e *echoEvaluator) EvaluateAnswerStream(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription, onDelta func(field, text string)) (*models.Evaluation, error) {
+	evaluation, _ := e.EvaluateAnswer(ctx, question, answer, jd)
+	for _, f := range []struct{ name, text string }{{"feedback", evaluation.Feedback}, {"suggestions", evaluation.Suggestions}} {
+		for i := 0; i < len(f.text); i += 3 {
+			onDelta(f.name, f.text[i:min(i+3, len(f.text))])
+		}
+	}
+	return evaluation, nil
+}
+
+func TestRedactingAnswerEvaluator(t *testing.T) {
+	inner := &echoEvaluator{}
+	evaluator := NewRedactingAnswerEvaluator(inner)
+	answer := models.Answer{QuestionID: 1, Content: "可以发邮件到dev@example.com"}
+
+	streamed := map[string]string{}
+	evaluation, err := evaluator.EvaluateAnswerStream(context.Background(), models.Question{ID: 1}, answer, nil, func(field, text string) {
+		streamed[field] += text
+	})
+	if err != nil {
+		t.Fatalf("评估失败: %v", err)
+	}
+	if inner.received != "可以发邮件到[EMAIL_1]" {
+		t.Fatalf("发送给评估器的回答未遮蔽: %s", inner.received)
+	}
+	if evaluation.Feedback != answer.Content || streamed["feedback"] != answer.Content {
+		t.Fatalf("反馈未还原: %q / %q", evaluation.Feedback, streamed["feedback"])
+	}
+	if streamed["suggestions"] != "请补充"+answer.Content {
+		t.Fatalf("建议未还原: %q", streamed["suggestions"])
+	}
+}
+
+// failingEvaluator 总是返回错误的评估器
+type failingEvaluator struct{}
+
+func (failingEvaluator) Provider() string { return ai.ProviderGrok }
+func (failingEvaluator) Model() string    { return ai.Grok3Model }
+
+func (failingEvaluator) EvaluateAnswer(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription) (*models.Evaluation, error) {
+	return nil, errors.New("服务不可用")
+}
+
+func TestFailoverAnswerEvaluator(t *testing.T) {
+	evaluator := NewFailoverAnswerEvaluator(time.Second, failingEvaluator{}, NewMockAnswerEvaluator())
+	question, answer, jd := createTestData()
+
+	streamed := map[string]string{}
+	evaluation, err := evaluator.EvaluateAnswerStream(context.Background(), question, answer, jd, func(field, text string) {
+		streamed[field] += text
+	})
+	if err != nil {
+		t.Fatalf("首选服务失败时应改用下一个服务: %v", err)
+	}
+	if evaluation.Provider != ai.ProviderMock || evaluation.Model != ai.ProviderMock {
+		t.Fatalf("评估结果应记录实际提供服务的服务和模型: %s/%s", evaluation.Provider, evaluation.Model)
+	}
+	if streamed["feedback"] != evaluation.Feedback {
+		t.Fatalf("应输出实际提供服务的评估器的反馈: %q", streamed["feedback"])
+	}
+	if evaluator.Provider() != ai.ProviderGrok {
+		t.Fatalf("Provider应返回首选服务，得到%s", evaluator.Provider())
+	}
+}
@@ -0,0 +1,82 @@
+package interview
+
+import (
+	"context"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/ai"
+	"github.com/10yihang/resume-ai-interview/models"
+)
+
+// FailoverAnswerEvaluator 按优先级使用多个答案评估器，前一个失败或超时时改用下一个
+// 评估结果记录实际提供服务的AI服务和模型
+type FailoverAnswerEvaluator struct {
+	evaluators []AnswerEvaluatorInterface
+	timeout    time.Duration
+}
+
+// NewFailoverAnswerEvaluator 创建按优先级切换的答案评估器，timeout是每次尝试的超时时间，0表示不限
+func NewFailoverAnswerEvaluator(timeout time.Duration, evaluators ...AnswerEvaluatorInterface) *FailoverAnswerEvaluator {
+	return &FailoverAnswerEvaluator{evaluators: evaluators, timeout: timeout}
+}
+
+// Provider 返回首选的AI服务
+func (e *FailoverAnswerEvaluator) Provider() string {
+	provider, _ := ai.DescribeModel(e.evaluators[0])
+	return provider
+}
+
+// Model 返回首选的模型
+func (e *FailoverAnswerEvaluator) Model() string {
+	_, model := ai.DescribeModel(e.evaluators[0])
+	return model
+}
+
+// EvaluateAnswer 依次尝试各个评估器评估面试回答
+func (e *FailoverAnswerEvaluator) EvaluateAnswer(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription) (*models.Evaluation, error) {
+	return e.evaluate(ctx, nil, func(ctx context.Context, evaluator AnswerEvaluatorInterface) (*models.Evaluation, error) {
+		return evaluator.EvaluateAnswer(ctx, question, answer, jd)
+	})
+}
+
+// EvaluateAnswerStream 依次尝试各个评估器流式评估面试回答
+// 已经输出反馈后再失败时不再切换，避免客户端收到两个服务拼接的反馈
+func (e *FailoverAnswerEvaluator) EvaluateAnswerStream(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription, onDelta func(field, text string)) (*models.Evaluation, error) {
+	emitted := false
+	emit := func(field, text string) {
+		emitted = true
+		if onDelta != nil {
+			onDelta(field, text)
+		}
+	}
+	canRetry := func() bool { return !emitted }
+	return e.evaluate(ctx, canRetry, func(ctx context.Context, evaluator AnswerEvaluatorInterface) (*models.Evaluation, error) {
+		if streamer, ok := evaluator.(StreamingAnswerEvaluator); ok {
+			return streamer.EvaluateAnswerStream(ctx, question, answer, jd, emit)
+		}
+		evaluation, err := evaluator.EvaluateAnswer(ctx, question, answer, jd)
+		if err != nil {
+			return nil, err
+		}
+		emit("feedback", evaluation.Feedback)
+		emit("suggestions", evaluation.Suggestions)
+		return evaluation, nil
+	})
+}
+
+// evaluate 依次尝试各个评估器，在结果中记录实际提供服务的AI服务和模型
+func (e *FailoverAnswerEvaluator) evaluate(ctx context.Context, canRetry func() bool, call func(context.Context, AnswerEvaluatorInterface) (*models.Evaluation, error)) (*models.Evaluation, error) {
+	attempts := make([]ai.Attempt[*models.Evaluation], len(e.evaluators))
+	for i, evaluator := range e.evaluators {
+		provider, model := ai.DescribeModel(evaluator)
+		attempts[i] = ai.Attempt[*models.Evaluation]{Provider: provider, Model: model, Call: func(ctx context.Context) (*models.Evaluation, error) {
+			return call(ctx, evaluator)
+		}}
+	}
+	evaluation, served, err := ai.Failover(ctx, e.timeout, attempts, canRetry)
+	if err != nil {
+		return nil, err
+	}
+	evaluation.Provider, evaluation.Model = attempts[served].Provider, attempts[served].Model
+	return evaluation, nil
+}
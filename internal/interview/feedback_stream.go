@@ -0,0 +1,120 @@
+package interview
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// streamedFields 流式输出时需要逐字推送的评估字段
+var streamedFields = []string{"feedback", "suggestions"}
+
+// feedbackStreamExtractor 从流式返回的评估JSON中增量提取文本字段
+type feedbackStreamExtractor struct {
+	buf     strings.Builder
+	emitted map[string]int // 每个字段已推送的解码文本长度
+	onDelta func(field, text string)
+}
+
+// newFeedbackStreamExtractor 创建评估字段流提取器
+func newFeedbackStreamExtractor(onDelta func(field, text string)) *feedbackStreamExtractor {
+	return &feedbackStreamExtractor{
+		emitted: make(map[string]int),
+		onDelta: onDelta,
+	}
+}
+
+// Write 追加一段增量内容，并推送各字段新增的文本
+func (e *feedbackStreamExtractor) Write(delta string) {
+	e.buf.WriteString(delta)
+	content := e.buf.String()
+
+	for _, field := range streamedFields {
+		value, ok := partialStringValue(content, field)
+		if !ok || len(value) <= e.emitted[field] {
+			continue
+		}
+		if e.onDelta != nil {
+			e.onDelta(field, value[e.emitted[field]:])
+		}
+		e.emitted[field] = len(value)
+	}
+}
+
+// Content 返回目前为止接收到的完整内容
+func (e *feedbackStreamExtractor) Content() string {
+	return e.buf.String()
+}
+
+// partialStringValue 解码JSON中某个字符串字段目前已到达的部分
+// 不完整的转义序列和UTF-8字符会留到下一次再解码
+func partialStringValue(content, field string) (string, bool) {
+	keyIdx := strings.Index(content, `"`+field+`"`)
+	if keyIdx < 0 {
+		return "", false
+	}
+
+	i := keyIdx + len(field) + 2
+	for i < len(content) && (content[i] == ' ' || content[i] == ':' || content[i] == '\n' || content[i] == '\t' || content[i] == '\r') {
+		i++
+	}
+	if i >= len(content) || content[i] != '"' {
+		return "", false
+	}
+	i++
+
+	var value strings.Builder
+	for i < len(content) {
+		ch := content[i]
+		if ch == '"' {
+			break
+		}
+		if ch != '\\' {
+			value.WriteByte(ch)
+			i++
+			continue
+		}
+
+		// 处理转义序列
+		if i+1 >= len(content) {
+			break
+		}
+		switch content[i+1] {
+		case 'n':
+			value.WriteByte('\n')
+		case 't':
+			value.WriteByte('\t')
+		case 'r':
+			value.WriteByte('\r')
+		case 'u':
+			if i+6 > len(content) {
+				return trimIncompleteRune(value.String()), true
+			}
+			code, err := strconv.ParseUint(content[i+2:i+6], 16, 32)
+			if err == nil {
+				value.WriteRune(rune(code))
+			}
+			i += 6
+			continue
+		default:
+			value.WriteByte(content[i+1])
+		}
+		i += 2
+	}
+
+	return trimIncompleteRune(value.String()), true
+}
+
+// trimIncompleteRune 去掉末尾未接收完整的UTF-8字符
+func trimIncompleteRune(s string) string {
+	for i := len(s) - 1; i >= 0 && i >= len(s)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(s[i]) {
+			continue
+		}
+		if !utf8.FullRuneInString(s[i:]) {
+			return s[:i]
+		}
+		break
+	}
+	return s
+}
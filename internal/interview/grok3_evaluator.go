@@ -11,15 +11,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"strings"
 
 	"github.com/10yihang/resume-ai-interview/internal/ai"
+	"github.com/10yihang/resume-ai-interview/internal/metrics"
 	"github.com/10yihang/resume-ai-interview/models"
 )
 
 // Grok3AnswerEvaluator 用于使用Grok 3评估面试答案
 type Grok3AnswerEvaluator struct {
 	client *ai.Grok3Client
+	model  string
+	rubric []string
 }
 
 // NewGrok3AnswerEvaluator 创建使用Grok 3的答案评估器
@@ -31,33 +35,34 @@ func NewGrok3AnswerEvaluator(apiKey string) *Grok3AnswerEvaluator {
 	client := ai.NewGrok3Client(apiKey)
 	return &Grok3AnswerEvaluator{
 		client: client,
+		model:  ai.Grok3Model,
 	}
 }
 
-// EvaluateAnswer 评估面试回答
-func (e *Grok3AnswerEvaluator) EvaluateAnswer(question models.Question, answer models.Answer, jd *models.JobDescription) (*models.Evaluation, error) {
-	// 构建提示词
-	prompt := buildEvaluationPrompt(question, answer, jd)
+// WithModel 设置使用的模型，model为空时保留默认模型
+func (e *Grok3AnswerEvaluator) WithModel(model string) *Grok3AnswerEvaluator {
+	if model != "" {
+		e.model = model
+	}
+	return e
+}
+
+// WithRubric 设置评分标准，每项一个分数段，为空时使用默认标准
+func (e *Grok3AnswerEvaluator) WithRubric(rubric []string) *Grok3AnswerEvaluator {
+	e.rubric = rubric
+	return e
+}
+
+// Provider 返回使用的AI服务
+func (e *Grok3AnswerEvaluator) Provider() string { return ai.ProviderGrok }
+
+// Model 返回使用的模型
+func (e *Grok3AnswerEvaluator) Model() string { return e.model }
 
+// EvaluateAnswer 评估面试回答
+func (e *Grok3AnswerEvaluator) EvaluateAnswer(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription) (*models.Evaluation, error) {
 	// 调用Grok 3 API
-	resp, err := e.client.CreateChatCompletion(
-		context.Background(),
-		ai.Grok3ChatRequest{
-			Model: "grok-3", // 使用Grok 3模型
-			Messages: []ai.Grok3Message{
-				{
-					Role:    "system",
-					Content: "你是一位专业的HR面试官，需要评估候选人的面试回答。请基于面试问题、候选人的回答以及职位要求，评估回答质量，给出分数（1-10）、反馈和改进建议。",
-				},
-				{
-					Role:    "user",
-					Content: prompt,
-				},
-			},
-			MaxTokens:   1024,
-			Temperature: 0.5,
-		},
-	)
+	resp, err := e.client.CreateChatCompletion(ctx, e.buildRequest(question, answer, jd))
 
 	if err != nil {
 		return nil, fmt.Errorf("调用Grok 3接口评估回答失败: %w", err)
@@ -67,12 +72,50 @@ func (e *Grok3AnswerEvaluator) EvaluateAnswer(question models.Question, answer m
 		return nil, fmt.Errorf("Grok 3返回了空的回复")
 	}
 	// 解析评估
-	evaluation := parseGrokEvaluation(answer, resp.Choices[0].Message.Content)
+	evaluation := parseGrokEvaluation(ctx, answer, resp.Choices[0].Message.Content)
+	evaluation.Usage = resp.Usage.TokenUsage(e.model)
 	return evaluation, nil
 }
 
+// EvaluateAnswerStream 流式评估面试回答，逐段推送反馈和建议
+func (e *Grok3AnswerEvaluator) EvaluateAnswerStream(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription, onDelta func(field, text string)) (*models.Evaluation, error) {
+	extractor := newFeedbackStreamExtractor(onDelta)
+
+	content, usage, err := e.client.CreateChatCompletionStream(ctx, e.buildRequest(question, answer, jd), extractor.Write)
+	if err != nil {
+		return nil, fmt.Errorf("调用Grok 3接口评估回答失败: %w", err)
+	}
+
+	if content == "" {
+		return nil, fmt.Errorf("Grok 3返回了空的回复")
+	}
+
+	evaluation := parseGrokEvaluation(ctx, answer, content)
+	evaluation.Usage = usage.TokenUsage(e.model)
+	return evaluation, nil
+}
+
+// buildRequest 构建评估回答的Grok 3请求
+func (e *Grok3AnswerEvaluator) buildRequest(question models.Question, answer models.Answer, jd *models.JobDescription) ai.Grok3ChatRequest {
+	return ai.Grok3ChatRequest{
+		Model: e.model,
+		Messages: []ai.Grok3Message{
+			{
+				Role:    "system",
+				Content: evaluationSystemPrompt,
+			},
+			{
+				Role:    "user",
+				Content: buildEvaluationPrompt(question, answer, jd, e.rubric),
+			},
+		},
+		MaxTokens:   1024,
+		Temperature: 0.5,
+	}
+}
+
 // parseGrokEvaluation 解析Grok 3返回的评估结果
-func parseGrokEvaluation(answer models.Answer, content string) *models.Evaluation {
+func parseGrokEvaluation(ctx context.Context, answer models.Answer, content string) *models.Evaluation {
 	// 提取JSON部分
 	jsonContent := extractJSONFromEvalContent(content)
 
@@ -84,13 +127,15 @@ func parseGrokEvaluation(answer models.Answer, content string) *models.Evaluatio
 
 	err := json.Unmarshal([]byte(jsonContent), &result)
 	if err != nil {
-		fmt.Printf("解析Grok 3返回的JSON失败：%v\n", err)
+		slog.WarnContext(ctx, "解析Grok 3返回的评估JSON失败，尝试修复", "error", err)
 		// 尝试修复JSON
 		fixedJson := tryFixEvaluationJSON(jsonContent)
 		err = json.Unmarshal([]byte(fixedJson), &result)
+		metrics.RecordRepair("evaluator", err)
 
 		if err != nil {
 			// 如果仍然失败，通过文本分析提取评估信息
+			metrics.Fallbacks.Inc(metrics.FallbackTextEvaluation)
 			return extractEvaluationFromText(answer, content)
 		}
 	}
@@ -7,16 +7,25 @@
 package interview
 
 import (
+	"context"
+
 	"github.com/10yihang/resume-ai-interview/models"
 )
 
 // AnswerEvaluatorInterface 定义了面试答案评估器的接口
 type AnswerEvaluatorInterface interface {
-	EvaluateAnswer(question models.Question, answer models.Answer, jd *models.JobDescription) (*models.Evaluation, error)
+	EvaluateAnswer(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription) (*models.Evaluation, error)
+}
+
+// StreamingAnswerEvaluator 定义了支持流式输出的答案评估器
+// 评估过程中feedback和suggestions字段的增量文本通过onDelta回调
+type StreamingAnswerEvaluator interface {
+	AnswerEvaluatorInterface
+	EvaluateAnswerStream(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription, onDelta func(field, text string)) (*models.Evaluation, error)
 }
 
-// GetAnswerEvaluator 根据配置返回适当的答案评估器
-func GetAnswerEvaluator(apiKey string, useGrok bool) AnswerEvaluatorInterface {
+// GetAnswerEvaluator 根据配置返回适当的答案评估器，model为空时使用默认模型，rubric为空时使用默认评分标准
+func GetAnswerEvaluator(apiKey string, useGrok bool, model string, rubric []string) AnswerEvaluatorInterface {
 	if apiKey == "" {
 		// 如果没有API密钥，使用模拟评估器
 		return NewMockAnswerEvaluator()
@@ -24,8 +33,8 @@ func GetAnswerEvaluator(apiKey string, useGrok bool) AnswerEvaluatorInterface {
 
 	if useGrok {
 		// 使用Grok 3
-		return NewGrok3AnswerEvaluator(apiKey)
+		return NewGrok3AnswerEvaluator(apiKey).WithModel(model).WithRubric(rubric)
 	}
 	// 默认使用OpenAI
-	return NewAnswerEvaluator(apiKey)
+	return NewAnswerEvaluator(apiKey).WithModel(model).WithRubric(rubric)
 }
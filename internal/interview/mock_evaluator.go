@@ -1,12 +1,14 @@
 package interview
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"log/slog"
 	"math/rand"
 	"strings"
 	"time"
 
+	"github.com/10yihang/resume-ai-interview/internal/ai"
 	"github.com/10yihang/resume-ai-interview/models"
 )
 
@@ -18,8 +20,14 @@ func NewMockAnswerEvaluator() *MockAnswerEvaluator {
 	return &MockAnswerEvaluator{}
 }
 
+// Provider 返回使用的AI服务
+func (e *MockAnswerEvaluator) Provider() string { return ai.ProviderMock }
+
+// Model 返回使用的模型
+func (e *MockAnswerEvaluator) Model() string { return ai.ProviderMock }
+
 // EvaluateAnswer 评估面试回答
-func (e *MockAnswerEvaluator) EvaluateAnswer(question models.Question, answer models.Answer, jd *models.JobDescription) (*models.Evaluation, error) {
+func (e *MockAnswerEvaluator) EvaluateAnswer(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription) (*models.Evaluation, error) {
 	// 初始化随机数生成器
 	rand.Seed(time.Now().UnixNano())
 
@@ -66,6 +74,36 @@ func (e *MockAnswerEvaluator) EvaluateAnswer(question models.Question, answer mo
 	}, nil
 }
 
+// EvaluateAnswerStream 生成模拟评估，并将反馈和建议分段推送
+func (e *MockAnswerEvaluator) EvaluateAnswerStream(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription, onDelta func(field, text string)) (*models.Evaluation, error) {
+	evaluation, err := e.EvaluateAnswer(ctx, question, answer, jd)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []struct {
+		name string
+		text string
+	}{
+		{"feedback", evaluation.Feedback},
+		{"suggestions", evaluation.Suggestions},
+	}
+	for _, f := range fields {
+		runes := []rune(f.text)
+		for i := 0; i < len(runes); i += 4 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			end := min(i+4, len(runes))
+			if onDelta != nil {
+				onDelta(f.name, string(runes[i:end]))
+			}
+		}
+	}
+
+	return evaluation, nil
+}
+
 // 根据问题类别获取关键词
 func getKeywords(category string) []string {
 	switch category {
@@ -151,7 +189,7 @@ func (e *MockAnswerEvaluator) parseMockEvaluation(answer models.Answer, content
 
 	err := json.Unmarshal([]byte(content), &result)
 	if err != nil {
-		fmt.Printf("解析JSON失败：%v\n", err)
+		slog.Warn("解析模拟评估JSON失败", "error", err)
 		// 返回一个默认评估
 		return &models.Evaluation{
 			AnswerID:    answer.QuestionID,
@@ -0,0 +1,103 @@
+package interview
+
+import (
+	"context"
+
+	"github.com/10yihang/resume-ai-interview/internal/ai"
+	"github.com/10yihang/resume-ai-interview/internal/redact"
+	"github.com/10yihang/resume-ai-interview/models"
+)
+
+// RedactingAnswerEvaluator 在调用外部大模型评估前遮蔽问题和回答中的个人信息
+// 评估反馈和建议中的占位符会还原为原文
+type RedactingAnswerEvaluator struct {
+	inner AnswerEvaluatorInterface
+}
+
+// NewRedactingAnswerEvaluator 创建遮蔽个人信息的答案评估器
+func NewRedactingAnswerEvaluator(inner AnswerEvaluatorInterface) *RedactingAnswerEvaluator {
+	return &RedactingAnswerEvaluator{inner: inner}
+}
+
+// Provider 返回内部评估器使用的AI服务
+func (e *RedactingAnswerEvaluator) Provider() string {
+	provider, _ := ai.DescribeModel(e.inner)
+	return provider
+}
+
+// Model 返回内部评估器使用的模型
+func (e *RedactingAnswerEvaluator) Model() string {
+	_, model := ai.DescribeModel(e.inner)
+	return model
+}
+
+// EvaluateAnswer 遮蔽问题和回答后评估
+func (e *RedactingAnswerEvaluator) EvaluateAnswer(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription) (*models.Evaluation, error) {
+	redactor := redact.New()
+	question, answer = maskAnswer(redactor, question, answer)
+	evaluation, err := e.inner.EvaluateAnswer(ctx, question, answer, jd)
+	if err != nil {
+		return nil, err
+	}
+	return restoreEvaluation(redactor, evaluation), nil
+}
+
+// EvaluateAnswerStream 遮蔽问题和回答后流式评估，内部评估器不支持流式时一次性评估后整段回调
+func (e *RedactingAnswerEvaluator) EvaluateAnswerStream(ctx context.Context, question models.Question, answer models.Answer, jd *models.JobDescription, onDelta func(field, text string)) (*models.Evaluation, error) {
+	streamer, ok := e.inner.(StreamingAnswerEvaluator)
+	if !ok {
+		evaluation, err := e.EvaluateAnswer(ctx, question, answer, jd)
+		if err != nil {
+			return nil, err
+		}
+		if onDelta != nil {
+			onDelta("feedback", evaluation.Feedback)
+			onDelta("suggestions", evaluation.Suggestions)
+		}
+		return evaluation, nil
+	}
+
+	redactor := redact.New()
+	question, answer = maskAnswer(redactor, question, answer)
+
+	// 占位符被拆到两个片段时等待后续片段，切换到下一个字段时输出上一个字段剩余的文本
+	var field string
+	restorer := redactor.NewStreamRestorer()
+	emit := func(field, text string) {
+		if onDelta != nil && text != "" {
+			onDelta(field, text)
+		}
+	}
+	evaluation, err := streamer.EvaluateAnswerStream(ctx, question, answer, jd, func(next, text string) {
+		if next != field {
+			emit(field, restorer.Flush())
+			field = next
+		}
+		emit(field, restorer.Write(text))
+	})
+	emit(field, restorer.Flush())
+	if err != nil {
+		return nil, err
+	}
+	return restoreEvaluation(redactor, evaluation), nil
+}
+
+// maskAnswer 遮蔽问题和回答中的个人信息
+func maskAnswer(redactor *redact.Redactor, question models.Question, answer models.Answer) (models.Question, models.Answer) {
+	question.Content = redactor.Mask(question.Content)
+	question.ReferenceAnswer = redactor.Mask(question.ReferenceAnswer)
+	question.Rubric = redactor.MaskAll(question.Rubric)
+	answer.Content = redactor.Mask(answer.Content)
+	return question, answer
+}
+
+// restoreEvaluation 还原评估中的占位符
+func restoreEvaluation(redactor *redact.Redactor, evaluation *models.Evaluation) *models.Evaluation {
+	if evaluation == nil {
+		return nil
+	}
+	evaluation.Answer = redactor.Restore(evaluation.Answer)
+	evaluation.Feedback = redactor.Restore(evaluation.Feedback)
+	evaluation.Suggestions = redactor.Restore(evaluation.Suggestions)
+	return evaluation
+}
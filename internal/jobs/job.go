@@ -0,0 +1,58 @@
+// Package jobs 提供后台任务队列，用于异步处理文件上传后的OCR和AI解析
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Status 表示任务所处的阶段
+type Status string
+
+const (
+	StatusQueued  Status = "queued"  // 等待处理
+	StatusOCR     Status = "ocr"     // 文件解析/OCR中
+	StatusParsing Status = "parsing" // AI解析中
+	StatusDone    Status = "done"    // 处理完成
+	StatusFailed  Status = "failed"  // 处理失败
+)
+
+// Terminal 判断任务是否已经结束
+func (s Status) Terminal() bool {
+	return s == StatusDone || s == StatusFailed
+}
+
+// Job 表示一个后台处理任务
+type Job struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`                  // 任务类型，如resume、jd
+	FileName    string          `json:"fileName"`              // 上传时的原始文件名
+	FilePath    string          `json:"filePath"`              // 已保存文件的路径
+	OwnerID     string          `json:"ownerId,omitempty"`     // 提交任务的用户
+	TeamID      string          `json:"teamId,omitempty"`      // 提交用户所在的团队
+	RequestID   string          `json:"requestId,omitempty"`   // 提交任务的请求ID，处理任务的日志带有同一ID
+	TraceParent string          `json:"traceParent,omitempty"` // 提交任务的请求的追踪上下文，处理任务的span挂在其下
+	Status      Status          `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	ResultID    string          `json:"resultId,omitempty"` // 处理结果的ID，如resumeId
+	Result      json.RawMessage `json:"result,omitempty"`   // 处理结果
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// Handler 处理一种类型的任务
+// report用于上报处理阶段，返回结果ID和可序列化为JSON的结果
+type Handler func(ctx context.Context, job Job, report func(Status)) (resultID string, result any, err error)
+
+// newID 生成随机任务ID
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand不可用时退化为时间戳
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,429 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/logging"
+	"github.com/10yihang/resume-ai-interview/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	// ErrQueueFull 表示等待处理的任务已达到上限
+	ErrQueueFull = errors.New("任务队列已满")
+	// ErrUnknownKind 表示没有注册对应类型的任务处理器
+	ErrUnknownKind = errors.New("未知的任务类型")
+)
+
+// Queue 是带有固定数量工作协程的任务队列
+type Queue struct {
+	mu          sync.Mutex
+	jobs        map[string]*Job
+	handlers    map[string]Handler
+	subscribers map[string][]chan Job
+	running     map[string]context.CancelFunc // 正在处理的任务，删除任务时取消其处理
+	onDone      func(job Job, result any)     // 任务成功完成时在锁内调用
+	pending     chan string
+	store       Store
+	workers     int
+	capacity    int
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	stopping    chan struct{} // 关闭后工作协程不再取出新任务
+	stopOnce    sync.Once
+}
+
+// NewQueue 创建任务队列
+// workers为并发处理的任务数，capacity为等待中任务的上限，store为nil时不持久化
+func NewQueue(workers, capacity int, store Store) *Queue {
+	if workers <= 0 {
+		workers = 1
+	}
+	if capacity <= 0 {
+		capacity = 100
+	}
+
+	return &Queue{
+		jobs:        make(map[string]*Job),
+		handlers:    make(map[string]Handler),
+		subscribers: make(map[string][]chan Job),
+		running:     make(map[string]context.CancelFunc),
+		store:       store,
+		workers:     workers,
+		capacity:    capacity,
+		stopping:    make(chan struct{}),
+	}
+}
+
+// Register 注册某种类型任务的处理器，需在Start之前调用
+func (q *Queue) Register(kind string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = handler
+}
+
+// OnDone 设置任务成功完成时的回调，需在Start之前调用
+// 回调在队列的锁内执行，只对仍未被删除的任务调用，处理期间被Remove删除的任务的结果不会交给回调
+func (q *Queue) OnDone(fn func(job Job, result any)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onDone = fn
+}
+
+// Start 恢复持久化的任务并启动工作协程
+// 重启前未完成的任务会重新排队处理
+func (q *Queue) Start(ctx context.Context) error {
+	var restored []Job
+	if q.store != nil {
+		jobs, err := q.store.LoadAll()
+		if err != nil {
+			return fmt.Errorf("加载持久化任务失败: %w", err)
+		}
+		restored = jobs
+	}
+
+	// 按创建时间排序，保证重新排队的顺序与提交顺序一致
+	sort.Slice(restored, func(i, j int) bool {
+		return restored[i].CreatedAt.Before(restored[j].CreatedAt)
+	})
+
+	q.mu.Lock()
+	q.pending = make(chan string, q.capacity+len(restored))
+	for i := range restored {
+		job := restored[i]
+		if !job.Status.Terminal() {
+			job.Status = StatusQueued
+			job.UpdatedAt = time.Now()
+			q.pending <- job.ID
+		}
+		q.jobs[job.ID] = &job
+	}
+	q.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	q.cancel = cancel
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.worker(ctx)
+	}
+	return nil
+}
+
+// Stop 停止工作协程并等待正在处理的任务返回
+func (q *Queue) Stop() {
+	if q.cancel != nil {
+		q.cancel()
+	}
+	q.wg.Wait()
+}
+
+// Shutdown 停止取出新任务并等待正在处理的任务完成，ctx结束时取消仍在处理的任务
+// 排队中和被取消的任务保持排队状态，持久化时重启后继续处理；等待超时时返回ctx的错误
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.stopOnce.Do(func() { close(q.stopping) })
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	q.Stop()
+	return err
+}
+
+// Submit 提交一个新任务，返回任务的当前状态，需在Start之后调用
+// spec中由调用方设置Kind、FileName、FilePath、归属信息、请求ID和追踪上下文，其余字段由队列填写
+func (q *Queue) Submit(spec Job) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.handlers[spec.Kind]; !ok {
+		return Job{}, fmt.Errorf("%w: %s", ErrUnknownKind, spec.Kind)
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:          newID(),
+		Kind:        spec.Kind,
+		FileName:    spec.FileName,
+		FilePath:    spec.FilePath,
+		OwnerID:     spec.OwnerID,
+		TeamID:      spec.TeamID,
+		RequestID:   spec.RequestID,
+		TraceParent: spec.TraceParent,
+		Status:      StatusQueued,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	select {
+	case q.pending <- job.ID:
+	default:
+		return Job{}, ErrQueueFull
+	}
+
+	q.jobs[job.ID] = job
+	q.persist(*job)
+	return *job, nil
+}
+
+// Get 获取任务的当前状态
+func (q *Queue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List 返回所有任务，按创建时间排序
+func (q *Queue) List() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Remove 删除任务及其持久化记录，返回任务是否存在
+// 排队中的任务不会再被处理，正在处理的任务被取消，其结果会被丢弃
+func (q *Queue) Remove(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.jobs[id]; !ok {
+		return false
+	}
+	delete(q.jobs, id)
+	if cancel, ok := q.running[id]; ok {
+		cancel()
+	}
+	for _, ch := range q.subscribers[id] {
+		close(ch)
+	}
+	delete(q.subscribers, id)
+
+	if q.store != nil {
+		if err := q.store.Delete(id); err != nil {
+			slog.Error("删除任务失败", "job_id", id, "error", err)
+		}
+	}
+	return true
+}
+
+// Subscribe 订阅任务状态变化
+// 返回的通道会先收到任务的当前状态，任务结束后通道关闭；调用cancel可提前取消订阅
+func (q *Queue) Subscribe(id string) (<-chan Job, func(), bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, nil, false
+	}
+
+	ch := make(chan Job, 8)
+	ch <- *job
+	if job.Status.Terminal() {
+		close(ch)
+		return ch, func() {}, true
+	}
+
+	q.subscribers[id] = append(q.subscribers[id], ch)
+	cancel := func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		subs := q.subscribers[id]
+		for i, sub := range subs {
+			if sub == ch {
+				q.subscribers[id] = append(subs[:i], subs[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+	}
+	return ch, cancel, true
+}
+
+// worker 从队列中取出任务并处理
+func (q *Queue) worker(ctx context.Context) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.stopping:
+			return
+		case id := <-q.pending:
+			// 停止信号与新任务同时就绪时不再处理，任务保持排队状态
+			select {
+			case <-q.stopping:
+				return
+			default:
+			}
+			q.process(ctx, id)
+		}
+	}
+}
+
+// process 处理单个任务
+func (q *Queue) process(ctx context.Context, id string) {
+	// 任务被删除时取消处理
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	var handler Handler
+	if ok {
+		handler = q.handlers[job.Kind]
+		q.running[id] = cancel
+	}
+	q.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, id)
+		q.mu.Unlock()
+	}()
+	if handler == nil {
+		q.finish(id, "", nil, fmt.Errorf("%w: %s", ErrUnknownKind, job.Kind))
+		return
+	}
+
+	snapshot, _ := q.Get(id)
+	ctx = logging.WithRequestID(ctx, snapshot.RequestID)
+	ctx = tracing.WithTraceParent(ctx, snapshot.TraceParent)
+	ctx, span := tracing.Start(ctx, "job."+snapshot.Kind,
+		attribute.String("job.id", id), attribute.String("job.kind", snapshot.Kind))
+	defer span.End()
+	report := func(status Status) {
+		q.update(id, func(job *Job) {
+			job.Status = status
+		})
+	}
+
+	start := time.Now()
+	slog.InfoContext(ctx, "开始处理任务", "job_id", id, "kind", snapshot.Kind)
+	resultID, result, err := handler(ctx, snapshot, report)
+	if err != nil && ctx.Err() != nil {
+		if _, ok := q.Get(id); !ok {
+			slog.InfoContext(ctx, "任务已删除，停止处理", "job_id", id)
+			return
+		}
+		// 队列停止导致的中断不算失败，重新标记为排队，重启后继续处理
+		q.update(id, func(job *Job) {
+			job.Status = StatusQueued
+		})
+		slog.InfoContext(ctx, "任务被中断，重启后继续处理", "job_id", id)
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.WarnContext(ctx, "任务处理失败", "job_id", id, "kind", snapshot.Kind, "duration_ms", time.Since(start).Milliseconds(), "error", err)
+	} else {
+		slog.InfoContext(ctx, "任务处理完成", "job_id", id, "kind", snapshot.Kind, "duration_ms", time.Since(start).Milliseconds())
+	}
+	q.finish(id, resultID, result, err)
+}
+
+// finish 记录任务的最终结果，任务已被删除时丢弃
+func (q *Queue) finish(id, resultID string, result any, err error) {
+	var raw json.RawMessage
+	if err == nil && result != nil {
+		data, marshalErr := json.Marshal(result)
+		if marshalErr != nil {
+			err = fmt.Errorf("序列化任务结果失败: %w", marshalErr)
+		} else {
+			raw = data
+		}
+	}
+
+	q.update(id, func(job *Job) {
+		if err != nil {
+			job.Status = StatusFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = StatusDone
+		job.ResultID = resultID
+		job.Result = raw
+		if q.onDone != nil {
+			q.onDone(*job, result)
+		}
+	})
+}
+
+// update 修改任务状态，持久化并通知订阅者
+func (q *Queue) update(id string, mutate func(job *Job)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return
+	}
+	mutate(job)
+	job.UpdatedAt = time.Now()
+	q.persist(*job)
+
+	for _, ch := range q.subscribers[id] {
+		select {
+		case ch <- *job:
+		default:
+			// 订阅者处理过慢时丢弃中间状态，但要保证终态送达：腾出一个最旧的位置
+			if job.Status.Terminal() {
+				select {
+				case <-ch:
+				default:
+				}
+				ch <- *job
+			}
+		}
+		if job.Status.Terminal() {
+			close(ch)
+		}
+	}
+	if job.Status.Terminal() {
+		delete(q.subscribers, id)
+	}
+}
+
+// persist 保存任务状态，调用方需持有锁
+func (q *Queue) persist(job Job) {
+	if q.store == nil {
+		return
+	}
+	if err := q.store.Save(job); err != nil {
+		slog.Error("保存任务失败", "job_id", job.ID, "error", err)
+	}
+}
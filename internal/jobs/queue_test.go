@@ -0,0 +1,214 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/encryption"
+)
+
+// waitForJob 订阅任务直到结束，返回收到的所有状态
+func waitForJob(t *testing.T, q *Queue, id string) []Job {
+	t.Helper()
+
+	updates, cancel, ok := q.Subscribe(id)
+	if !ok {
+		t.Fatalf("任务%s不存在", id)
+	}
+	defer cancel()
+
+	var got []Job
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case job, ok := <-updates:
+			if !ok {
+				return got
+			}
+			got = append(got, job)
+		case <-timeout:
+			t.Fatalf("等待任务%s结束超时", id)
+		}
+	}
+}
+
+func TestQueueProcessesJobs(t *testing.T) {
+	q := NewQueue(2, 10, nil)
+	q.Register("resume", func(ctx context.Context, job Job, report func(Status)) (string, any, error) {
+		report(StatusOCR)
+		report(StatusParsing)
+		if job.FileName == "bad.pdf" {
+			return "", nil, errors.New("解析失败")
+		}
+		return job.FileName, map[string]string{"name": "张三"}, nil
+	})
+	if err := q.Start(context.Background()); err != nil {
+		t.Fatalf("启动队列失败: %v", err)
+	}
+	defer q.Stop()
+
+	good, err := q.Submit(Job{Kind: "resume", FileName: "good.pdf", FilePath: "/tmp/good.pdf"})
+	if err != nil {
+		t.Fatalf("提交任务失败: %v", err)
+	}
+	bad, err := q.Submit(Job{Kind: "resume", FileName: "bad.pdf", FilePath: "/tmp/bad.pdf"})
+	if err != nil {
+		t.Fatalf("提交任务失败: %v", err)
+	}
+
+	updates := waitForJob(t, q, good.ID)
+	final := updates[len(updates)-1]
+	if final.Status != StatusDone || final.ResultID != "good.pdf" || string(final.Result) != `{"name":"张三"}` {
+		t.Errorf("成功任务的最终状态不正确: %+v", final)
+	}
+
+	updates = waitForJob(t, q, bad.ID)
+	final = updates[len(updates)-1]
+	if final.Status != StatusFailed || final.Error != "解析失败" {
+		t.Errorf("失败任务的最终状态不正确: %+v", final)
+	}
+
+	if _, err := q.Submit(Job{Kind: "unknown", FileName: "a.txt", FilePath: "/tmp/a.txt"}); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("未注册的任务类型应返回ErrUnknownKind，实际为%v", err)
+	}
+}
+
+func TestQueueRestoresPersistedJobs(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建任务存储失败: %v", err)
+	}
+
+	// 模拟重启前处理到一半的任务和已完成的任务
+	interrupted := Job{ID: "interrupted", Kind: "resume", FileName: "a.pdf", Status: StatusOCR, CreatedAt: time.Now()}
+	finished := Job{ID: "finished", Kind: "resume", FileName: "b.pdf", Status: StatusDone, ResultID: "b.pdf", CreatedAt: time.Now()}
+	for _, job := range []Job{interrupted, finished} {
+		if err := store.Save(job); err != nil {
+			t.Fatalf("保存任务失败: %v", err)
+		}
+	}
+
+	processed := make(chan string, 2)
+	q := NewQueue(1, 10, store)
+	q.Register("resume", func(ctx context.Context, job Job, report func(Status)) (string, any, error) {
+		processed <- job.ID
+		return job.FileName, nil, nil
+	})
+	if err := q.Start(context.Background()); err != nil {
+		t.Fatalf("启动队列失败: %v", err)
+	}
+	defer q.Stop()
+
+	updates := waitForJob(t, q, interrupted.ID)
+	if final := updates[len(updates)-1]; final.Status != StatusDone {
+		t.Errorf("中断的任务应在重启后完成，实际状态为%s", final.Status)
+	}
+	if id := <-processed; id != interrupted.ID {
+		t.Errorf("只应重新处理未完成的任务，实际处理了%s", id)
+	}
+
+	if job, ok := q.Get(finished.ID); !ok || job.Status != StatusDone {
+		t.Errorf("已完成的任务应被恢复: %+v", job)
+	}
+
+	// 状态变化应已写回存储
+	jobs, err := store.LoadAll()
+	if err != nil {
+		t.Fatalf("加载任务失败: %v", err)
+	}
+	for _, job := range jobs {
+		if job.Status != StatusDone {
+			t.Errorf("任务%s的持久化状态应为done，实际为%s", job.ID, job.Status)
+		}
+	}
+}
+
+func TestQueueShutdown(t *testing.T) {
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	q := NewQueue(1, 10, nil)
+	q.Register("resume", func(ctx context.Context, job Job, report func(Status)) (string, any, error) {
+		started <- job.FileName
+		select {
+		case <-release:
+			return job.FileName, nil, nil
+		case <-ctx.Done():
+			return "", nil, ctx.Err()
+		}
+	})
+	if err := q.Start(context.Background()); err != nil {
+		t.Fatalf("启动队列失败: %v", err)
+	}
+
+	running, _ := q.Submit(Job{Kind: "resume", FileName: "a.pdf"})
+	<-started
+	waiting, _ := q.Submit(Job{Kind: "resume", FileName: "b.pdf"})
+
+	// 正在处理的任务在期限内完成，排队的任务不再处理
+	done := make(chan error)
+	go func() { done <- q.Shutdown(context.Background()) }()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("任务在期限内完成时不应返回错误: %v", err)
+	}
+	if job, _ := q.Get(running.ID); job.Status != StatusDone {
+		t.Errorf("正在处理的任务应完成，实际状态为%s", job.Status)
+	}
+	if job, _ := q.Get(waiting.ID); job.Status != StatusQueued {
+		t.Errorf("停止后排队的任务应保持排队，实际状态为%s", job.Status)
+	}
+
+	// 超过期限时取消正在处理的任务
+	q = NewQueue(1, 10, nil)
+	q.Register("resume", func(ctx context.Context, job Job, report func(Status)) (string, any, error) {
+		started <- job.FileName
+		<-ctx.Done()
+		return "", nil, ctx.Err()
+	})
+	q.Start(context.Background())
+	stuck, _ := q.Submit(Job{Kind: "resume", FileName: "c.pdf"})
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("超过期限时应返回DeadlineExceeded，实际为%v", err)
+	}
+	if job, _ := q.Get(stuck.ID); job.Status != StatusQueued {
+		t.Errorf("被取消的任务应重新标记为排队，实际状态为%s", job.Status)
+	}
+}
+
+func TestFileStoreSkipsUndecryptableJobs(t *testing.T) {
+	dir := t.TempDir()
+	keyring := func(b byte) *encryption.Keyring {
+		k, err := encryption.ParseKeyring("k1:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32)))
+		if err != nil {
+			t.Fatalf("解析密钥失败: %v", err)
+		}
+		return k
+	}
+
+	// 用已删除的密钥加密的记录无法解密，不应影响其他任务的加载
+	old, _ := NewFileStore(dir)
+	if err := old.WithKeyring(keyring(1)).Save(Job{ID: "old", Kind: "resume", Status: StatusDone}); err != nil {
+		t.Fatalf("保存任务失败: %v", err)
+	}
+	store, _ := NewFileStore(dir)
+	store.WithKeyring(keyring(2))
+	if err := store.Save(Job{ID: "new", Kind: "resume", Status: StatusDone}); err != nil {
+		t.Fatalf("保存任务失败: %v", err)
+	}
+
+	jobs, err := store.LoadAll()
+	if err != nil {
+		t.Fatalf("无法解密的记录应跳过而不是报错: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != "new" {
+		t.Fatalf("应只加载能解密的任务: %+v", jobs)
+	}
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/10yihang/resume-ai-interview/internal/encryption"
+)
+
+// Store 定义了任务的持久化存储
+type Store interface {
+	// Save 保存任务的最新状态
+	Save(job Job) error
+	// LoadAll 加载所有已保存的任务
+	LoadAll() ([]Job, error)
+	// Delete 删除任务，任务不存在时不报错
+	Delete(id string) error
+}
+
+// FileStore 将每个任务保存为目录下的一个JSON文件
+type FileStore struct {
+	dir     string
+	keyring *encryption.Keyring // 配置了密钥时任务文件加密保存
+}
+
+// NewFileStore 创建基于文件的任务存储
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建任务目录失败: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// WithKeyring 设置加密任务文件使用的密钥，任务结果中包含解析出的简历内容
+func (s *FileStore) WithKeyring(keyring *encryption.Keyring) *FileStore {
+	s.keyring = keyring
+	return s
+}
+
+// Save 保存任务，先写临时文件再重命名以避免写到一半的文件
+func (s *FileStore) Save(job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("序列化任务失败: %w", err)
+	}
+	if data, err = s.keyring.Seal(data); err != nil {
+		return fmt.Errorf("加密任务失败: %w", err)
+	}
+
+	path := filepath.Join(s.dir, job.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入任务文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("保存任务文件失败: %w", err)
+	}
+	return nil
+}
+
+// LoadAll 加载目录下所有任务，无法解密或解析的文件会被跳过
+func (s *FileStore) LoadAll() ([]Job, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取任务目录失败: %w", err)
+	}
+
+	var jobs []Job
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("读取任务文件失败: %w", err)
+		}
+		// 无法解密的记录（如对应的主密钥已被删除）跳过，不影响其他任务的恢复
+		if data, err = s.keyring.Open(data); err != nil {
+			slog.Error("解密任务文件失败，已跳过", "file", entry.Name(), "error", err)
+			continue
+		}
+
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil || job.ID == "" {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Delete 删除任务文件
+func (s *FileStore) Delete(id string) error {
+	err := os.Remove(filepath.Join(s.dir, id+".json"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除任务文件失败: %w", err)
+	}
+	return nil
+}
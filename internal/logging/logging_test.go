@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestLoggerAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, "info")
+	if err != nil {
+		t.Fatalf("创建日志记录器失败: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.With("component", "ocr").InfoContext(ctx, "OCR处理完成", "tokens", 10)
+	logger.DebugContext(ctx, "低于日志级别的记录不输出")
+	logger.Info("没有请求ID")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("期望输出2条日志，得到%d条: %s", len(lines), buf.String())
+	}
+	var first, second map[string]any
+	if err := json.Unmarshal(lines[0], &first); err != nil {
+		t.Fatalf("日志不是JSON格式: %v", err)
+	}
+	json.Unmarshal(lines[1], &second)
+	if first[RequestIDKey] != "req-1" || first["component"] != "ocr" || first["msg"] != "OCR处理完成" {
+		t.Fatalf("日志缺少请求ID或属性: %v", first)
+	}
+	if _, ok := second[RequestIDKey]; ok {
+		t.Fatalf("没有请求ID的context不应输出request_id: %v", second)
+	}
+}
+
+func TestNewRejectsInvalidOptions(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Fatalf("应拒绝不支持的日志格式")
+	}
+	if _, err := New(&bytes.Buffer{}, FormatText, "verbose"); err == nil {
+		t.Fatalf("应拒绝无效的日志级别")
+	}
+	if RequestID(context.Background()) != "" || WithRequestID(context.Background(), "") != context.Background() {
+		t.Fatalf("空请求ID不应写入context")
+	}
+}
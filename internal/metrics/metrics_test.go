@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("test_requests_total", "请求数", "route", "status")
+	duration := r.NewHistogramVec("test_duration_seconds", "耗时", []float64{1, 0.1}, "route")
+
+	requests.Inc("/a", "200")
+	requests.Add(2, "/a", "200")
+	requests.Inc(`/b"c`, "500")
+	duration.Observe(0.05, "/a")
+	duration.Observe(0.5, "/a")
+	duration.Observe(3, "/a")
+
+	if got := requests.Value("/a", "200"); got != 3 {
+		t.Fatalf("计数为%v，期望3", got)
+	}
+	if got := duration.Count("/a"); got != 3 {
+		t.Fatalf("观测次数为%d，期望3", got)
+	}
+
+	var out strings.Builder
+	if _, err := r.WriteTo(&out); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	text := out.String()
+	for _, want := range []string{
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{route="/a",le="0.1"} 1` + "\n",
+		`test_duration_seconds_bucket{route="/a",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{route="/a",le="+Inf"} 3` + "\n",
+		`test_duration_seconds_sum{route="/a"} 3.55` + "\n",
+		`test_duration_seconds_count{route="/a"} 3` + "\n",
+		"# HELP test_requests_total 请求数\n# TYPE test_requests_total counter\n",
+		`test_requests_total{route="/a",status="200"} 3` + "\n",
+		`test_requests_total{route="/b\"c",status="500"} 1` + "\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("导出内容缺少 %q:\n%s", want, text)
+		}
+	}
+	if strings.Index(text, "test_duration_seconds") > strings.Index(text, "test_requests_total") {
+		t.Fatalf("指标没有按名称排序:\n%s", text)
+	}
+}
+
+func TestRegistryRejectsDuplicates(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("dup_total", "重复")
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("重复注册指标应当panic")
+		}
+	}()
+	r.NewCounterVec("dup_total", "重复")
+}
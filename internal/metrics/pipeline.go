@@ -0,0 +1,88 @@
+package metrics
+
+import "time"
+
+// Default 是服务使用的指标注册表，由 /metrics 导出
+var Default = NewRegistry()
+
+// 结果标签的取值
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// 降级处理的类型，用作Fallbacks的kind标签
+const (
+	FallbackPDFText           = "pdf_text"           // OCR失败后改用PDF文本层
+	FallbackDefaultQuestions  = "default_questions"  // 无法解析模型输出，使用默认问题集
+	FallbackDefaultEvaluation = "default_evaluation" // 无法解析模型输出，使用默认评估
+	FallbackTextEvaluation    = "text_evaluation"    // 无法解析模型输出，从文本中提取评估
+	FallbackRawText           = "raw_text"           // 无法解析模型输出，只保留简历或JD原文
+	FallbackBudget            = "budget"             // 超出费用预算，改用模拟模式
+)
+
+// 服务的运行指标
+var (
+	// HTTPRequests 按方法、路由模板和状态码统计请求数
+	HTTPRequests = Default.NewCounterVec("resume_ai_http_requests_total",
+		"HTTP请求数", "method", "route", "status")
+	// HTTPDuration 按方法和路由模板统计请求耗时
+	HTTPDuration = Default.NewHistogramVec("resume_ai_http_request_duration_seconds",
+		"HTTP请求耗时（秒）", nil, "method", "route")
+	// FileParseDuration 按文件格式统计从上传文件中提取文本的耗时，包括OCR
+	FileParseDuration = Default.NewHistogramVec("resume_ai_file_parse_duration_seconds",
+		"提取文件文本的耗时（秒）", nil, "format", "result")
+	// OCRDuration 按OCR引擎统计识别耗时
+	OCRDuration = Default.NewHistogramVec("resume_ai_ocr_duration_seconds",
+		"OCR识别耗时（秒）", nil, "engine", "result")
+	// OCRPageRetries 按引擎统计识别质量低的页交给该引擎重新识别的页数
+	OCRPageRetries = Default.NewCounterVec("resume_ai_ocr_page_retries_total",
+		"识别质量低、改用下一个OCR引擎重新识别的页数", "engine")
+	// LLMDuration 按服务、模型和是否流式统计大模型调用耗时
+	LLMDuration = Default.NewHistogramVec("resume_ai_llm_request_duration_seconds",
+		"大模型调用耗时（秒）", nil, "provider", "model", "stream", "result")
+	// LLMTokens 按服务、模型统计接口返回的token用量，type为prompt或completion
+	LLMTokens = Default.NewCounterVec("resume_ai_llm_tokens_total",
+		"大模型接口返回的token用量", "provider", "model", "type")
+	// Fallbacks 按类型统计降级处理的次数
+	Fallbacks = Default.NewCounterVec("resume_ai_fallbacks_total",
+		"降级处理次数", "kind")
+	// LLMFailovers 统计AI服务调用失败或超时后改用下一个服务的次数
+	LLMFailovers = Default.NewCounterVec("resume_ai_llm_failovers_total",
+		"AI服务失败后改用下一个服务的次数", "from", "to")
+	// JSONRepairs 统计模型返回的JSON无法直接解析、需要修复的次数，result表示修复后能否解析
+	JSONRepairs = Default.NewCounterVec("resume_ai_json_repairs_total",
+		"修复模型返回的JSON的次数", "component", "result")
+)
+
+// Result 把错误转换为结果标签
+func Result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultOK
+}
+
+// ObserveLLM 记录一次大模型调用的耗时
+func ObserveLLM(provider, model string, stream bool, start time.Time, err error) {
+	streamLabel := "false"
+	if stream {
+		streamLabel = "true"
+	}
+	LLMDuration.ObserveSince(start, provider, model, streamLabel, Result(err))
+}
+
+// RecordUsage 记录接口返回的token用量
+func RecordUsage(provider, model string, promptTokens, completionTokens int) {
+	if promptTokens > 0 {
+		LLMTokens.Add(float64(promptTokens), provider, model, "prompt")
+	}
+	if completionTokens > 0 {
+		LLMTokens.Add(float64(completionTokens), provider, model, "completion")
+	}
+}
+
+// RecordRepair 记录一次JSON修复及修复后能否解析
+func RecordRepair(component string, err error) {
+	JSONRepairs.Inc(component, Result(err))
+}
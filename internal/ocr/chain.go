@@ -0,0 +1,176 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/metrics"
+)
+
+// DefaultEngines 是默认的引擎顺序：先读取PDF文本层，再用本地Tesseract，最后用OCR.space
+var DefaultEngines = []string{EnginePDFText, EngineTesseract, EngineOCRSpace}
+
+// DefaultMinQuality 是默认的页质量阈值，低于该值的页改用下一个引擎识别
+const DefaultMinQuality = 0.6
+
+// Chain 按顺序使用多个OCR引擎识别文件
+// 前一个引擎失败时由下一个引擎识别全部页；识别质量低于阈值的页交给下一个引擎重新识别，每页保留质量最高的结果
+type Chain struct {
+	engines    []Engine
+	minQuality float64
+}
+
+// NewChain 创建按顺序使用engines的引擎链，minQuality是页质量阈值，0表示第一个成功的引擎的结果都接受
+func NewChain(minQuality float64, engines ...Engine) *Chain {
+	return &Chain{engines: engines, minQuality: minQuality}
+}
+
+// Options 是创建引擎链的配置
+type Options struct {
+	Engines       []string         // 按顺序使用的引擎名称，如DefaultEngines
+	MinQuality    float64          // 页质量阈值，低于该值的页改用下一个引擎识别
+	TesseractPath string           // Tesseract可执行文件路径
+	Tesseract     TesseractOptions // Tesseract的语言、分割模式和输出格式等识别参数
+	OCRSpaceKey   string           // OCR.space的API密钥，为空时跳过ocrspace
+}
+
+// GetOCRChain 根据配置创建引擎链，未知的引擎名称被忽略
+func GetOCRChain(opts Options) *Chain {
+	var engines []Engine
+	for _, name := range opts.Engines {
+		switch name {
+		case EnginePDFText:
+			engines = append(engines, NewPDFText())
+		case EngineTesseract:
+			engines = append(engines, NewTesseractOCR(opts.TesseractPath).WithOptions(opts.Tesseract))
+		case EngineOCRSpace:
+			if opts.OCRSpaceKey != "" {
+				engines = append(engines, NewOCRSpaceAPI(opts.OCRSpaceKey))
+			}
+		}
+	}
+	return NewChain(opts.MinQuality, engines...)
+}
+
+// textLayerFirst 返回先读取PDF文本层的引擎链，只有没有文本层或文本层乱码的页交给OCR引擎
+// 引擎链中已有pdftext时移到最前面，没有时加在最前面
+func (c *Chain) textLayerFirst() *Chain {
+	engines := []Engine{NewPDFText()}
+	for _, engine := range c.engines {
+		if engine.Name() == EnginePDFText {
+			engines[0] = engine
+			continue
+		}
+		engines = append(engines, engine)
+	}
+	return NewChain(c.minQuality, engines...)
+}
+
+// Engines 返回引擎链中各引擎的名称
+func (c *Chain) Engines() []string {
+	names := make([]string, len(c.engines))
+	for i, engine := range c.engines {
+		names[i] = engine.Name()
+	}
+	return names
+}
+
+// ExtractTextFromPDF 用引擎链识别PDF文件
+func (c *Chain) ExtractTextFromPDF(ctx context.Context, pdfPath string) (string, error) {
+	pages, err := c.Recognize(ctx, pdfPath)
+	return joinPages(pages), err
+}
+
+// ExtractTextFromImage 用引擎链识别图像文件
+func (c *Chain) ExtractTextFromImage(ctx context.Context, imagePath string) (string, error) {
+	pages, err := c.Recognize(ctx, imagePath)
+	return joinPages(pages), err
+}
+
+// Recognize 依次使用各引擎识别文件，返回按页号排序的各页结果
+// 失败时返回*Error；所有引擎都不支持该文件格式时其中包含ErrUnsupportedFormat，没有识别出任何文本时包含ErrEmptyText
+func (c *Chain) Recognize(ctx context.Context, filePath string) ([]Page, error) {
+	if len(c.engines) == 0 {
+		return nil, &Error{Errs: []error{fmt.Errorf("%w: 未配置OCR引擎", ErrUnavailable)}}
+	}
+	best := map[int]Page{}
+	var pending []int // 需要下一个引擎识别的页号，nil表示全部页
+	var errs []error
+	supported := false
+	for i, engine := range c.engines {
+		if i > 0 && len(best) > 0 && len(pending) == 0 {
+			break
+		}
+		// 所有页都需要重新识别时不指定页号，引擎可以一次处理整个文件
+		request := pending
+		if len(pending) == len(best) {
+			request = nil
+		}
+		start := time.Now()
+		pages, err := engine.RecognizePages(ctx, filePath, request)
+		if errors.Is(err, ErrUnsupportedFormat) {
+			continue
+		}
+		supported = true
+		metrics.OCRDuration.ObserveSince(start, engine.Name(), metrics.Result(err))
+		if len(pending) > 0 {
+			metrics.OCRPageRetries.Add(float64(len(pending)), engine.Name())
+		}
+		if err != nil {
+			errs = append(errs, &EngineError{Engine: engine.Name(), Err: err})
+			if ctx.Err() != nil {
+				break
+			}
+			slog.WarnContext(ctx, "OCR引擎识别失败，改用下一个引擎",
+				"engine", engine.Name(), "file", filepath.Base(filePath), "error", err)
+			continue
+		}
+		for _, page := range pages {
+			if prev, ok := best[page.Number]; !ok || page.Quality > prev.Quality {
+				best[page.Number] = page
+			}
+		}
+		pending = c.lowQuality(best)
+		if len(pending) > 0 && i < len(c.engines)-1 {
+			slog.InfoContext(ctx, "部分页识别质量低，改用下一个引擎识别",
+				"engine", engine.Name(), "file", filepath.Base(filePath), "pages", pending)
+		}
+	}
+
+	if !supported {
+		return nil, &Error{Errs: []error{fmt.Errorf("%w: %s", ErrUnsupportedFormat, filepath.Ext(filePath))}}
+	}
+	result := make([]Page, 0, len(best))
+	empty := true
+	for _, page := range best {
+		result = append(result, page)
+		if page.Quality > 0 {
+			empty = false
+		}
+	}
+	if empty {
+		return nil, &Error{Errs: append(errs, ErrEmptyText)}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Number < result[j].Number })
+	if low := c.lowQuality(best); len(low) > 0 {
+		slog.WarnContext(ctx, "部分页的识别质量低于阈值", "file", filepath.Base(filePath), "pages", low)
+	}
+	return result, nil
+}
+
+// lowQuality 返回识别质量低于阈值的页号，按页号排序
+func (c *Chain) lowQuality(pages map[int]Page) []int {
+	var low []int
+	for number, page := range pages {
+		if page.Quality < c.minQuality {
+			low = append(low, number)
+		}
+	}
+	sort.Ints(low)
+	return low
+}
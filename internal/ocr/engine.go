@@ -0,0 +1,155 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// 引擎名称，用于配置引擎链的顺序、日志和指标
+const (
+	EnginePDFText   = "pdftext"
+	EngineTesseract = "tesseract"
+	EngineOCRSpace  = "ocrspace"
+)
+
+// 可以用errors.Is判断的OCR错误
+var (
+	// ErrUnsupportedFormat 表示引擎不支持该文件格式，引擎链会跳过该引擎
+	ErrUnsupportedFormat = errors.New("不支持的文件格式")
+	// ErrUnavailable 表示引擎依赖的程序未安装或服务未配置
+	ErrUnavailable = errors.New("OCR引擎不可用")
+	// ErrEmptyText 表示所有引擎都没有识别出文本
+	ErrEmptyText = errors.New("OCR提取的文本为空")
+)
+
+// Error 是OCR处理失败的错误，Errs按顺序记录每个引擎失败的原因
+// 可以用errors.As判断错误来自OCR，用errors.Is判断具体原因，如ErrUnavailable
+type Error struct {
+	Errs []error
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		messages[i] = err.Error()
+	}
+	return "OCR处理失败: " + strings.Join(messages, "; ")
+}
+
+// Unwrap 返回各引擎的错误
+func (e *Error) Unwrap() []error {
+	return e.Errs
+}
+
+// EngineError 是一个引擎识别失败的错误
+type EngineError struct {
+	Engine string
+	Err    error
+}
+
+func (e *EngineError) Error() string {
+	return e.Engine + ": " + e.Err.Error()
+}
+
+// Unwrap 返回引擎返回的原始错误
+func (e *EngineError) Unwrap() error {
+	return e.Err
+}
+
+// Page 是一页的识别结果
+type Page struct {
+	Number  int     // 页号，从1开始，图像只有第1页
+	Text    string  // 识别出的文本
+	Quality float64 // 识别质量，0到1之间，见Score
+	Engine  string  // 识别该页的引擎
+}
+
+// newPage 创建一页识别结果并评估识别质量
+func newPage(number int, text, engine string) Page {
+	return Page{Number: number, Text: text, Quality: Score(text), Engine: engine}
+}
+
+// Engine 是OCR引擎链中的一个引擎，按页识别PDF或图像
+type Engine interface {
+	// Name 返回引擎名称，如tesseract
+	Name() string
+
+	// RecognizePages 识别文件中的指定页，pages为nil时识别全部页
+	// 不支持该文件格式时返回ErrUnsupportedFormat
+	RecognizePages(ctx context.Context, filePath string, pages []int) ([]Page, error)
+}
+
+// minPageChars 是一页正常识别结果至少应有的有效字符数，少于该数时按比例降低质量分
+const minPageChars = 20
+
+// Score 估计一页识别结果的质量，取值0到1，空文本为0
+// 按非空白字符中文字和数字的比例计分，标点计一半，乱码、替换字符和零散符号拉低分数；
+// 有效字符少于minPageChars时按比例降低，避免只识别出页码或几个字符的页被当作识别成功
+func Score(text string) float64 {
+	score, valid := charScore(text)
+	if valid < minPageChars {
+		score *= valid / minPageChars
+	}
+	return score
+}
+
+// charScore 返回非空白字符中有效字符的比例和有效字符数，见Score
+func charScore(text string) (float64, float64) {
+	var total int
+	var valid float64
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			continue
+		case r == unicode.ReplacementChar || unicode.Is(unicode.Co, r) || unicode.IsControl(r):
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			valid++
+		case unicode.IsPunct(r):
+			valid += 0.5
+		}
+		total++
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return valid / float64(total), valid
+}
+
+// joinPages 按页号顺序拼接各页的文本
+func joinPages(pages []Page) string {
+	var text strings.Builder
+	for _, page := range pages {
+		text.WriteString(page.Text)
+		text.WriteString("\n")
+	}
+	return text.String()
+}
+
+// wantPage 返回是否需要识别指定页，pages为nil表示全部页
+func wantPage(pages []int, number int) bool {
+	if pages == nil {
+		return true
+	}
+	for _, p := range pages {
+		if p == number {
+			return true
+		}
+	}
+	return false
+}
+
+// fileKind 返回文件是PDF还是图像，其他格式返回ErrUnsupportedFormat
+func fileKind(filePath string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(filePath)); ext {
+	case ".pdf":
+		return "pdf", nil
+	case ".png", ".jpg", ".jpeg":
+		return "image", nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, ext)
+	}
+}
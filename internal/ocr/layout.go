@@ -0,0 +1,327 @@
+package ocr
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// minColumnRatio 是一栏的宽度占所在区域宽度的比例下限，更窄的一组块（如右侧对齐的日期）不当作单独的一栏
+const minColumnRatio = 0.25
+
+// box 是文本在图像中的位置，单位为像素
+type box struct {
+	left, top, right, bottom int
+}
+
+// union 返回同时包含b和o的最小矩形
+func (b box) union(o box) box {
+	return box{min(b.left, o.left), min(b.top, o.top), max(b.right, o.right), max(b.bottom, o.bottom)}
+}
+
+// word 是Tesseract识别出的一个词
+type word struct {
+	block int     // 所在文本块的序号
+	line  int     // 所在行的序号，整页连续编号
+	box   box     // 位置
+	conf  float64 // 置信度，0到100，未知时为-1
+	text  string
+}
+
+// textBlock 是一个文本块，由若干行组成
+type textBlock struct {
+	box   box
+	lines [][]word
+}
+
+// layout 是一页的版面，词按识别顺序排列
+type layout struct {
+	words []word
+}
+
+// parseTSV 解析tesseract的TSV输出
+// 每行依次是level、page_num、block_num、par_num、line_num、word_num、left、top、width、height、conf、text，level为5的行是词
+func parseTSV(r io.Reader) (*layout, error) {
+	page := &layout{}
+	lineKeys := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for row := 0; scanner.Scan(); row++ {
+		fields := strings.SplitN(scanner.Text(), "\t", 12)
+		if row == 0 || len(fields) < 11 {
+			continue // 表头或空行
+		}
+		nums := make([]int, 10)
+		for i := range nums {
+			n, err := strconv.Atoi(fields[i])
+			if err != nil {
+				return nil, fmt.Errorf("TSV第%d行格式错误: %w", row+1, err)
+			}
+			nums[i] = n
+		}
+		level, block, par, line := nums[0], nums[2], nums[3], nums[4]
+		position := box{nums[6], nums[7], nums[6] + nums[8], nums[7] + nums[9]}
+		if level != 5 || len(fields) < 12 || strings.TrimSpace(fields[11]) == "" {
+			continue
+		}
+		conf, err := strconv.ParseFloat(fields[10], 64)
+		if err != nil {
+			return nil, fmt.Errorf("TSV第%d行置信度格式错误: %w", row+1, err)
+		}
+		key := fmt.Sprintf("%d/%d/%d", block, par, line)
+		if _, ok := lineKeys[key]; !ok {
+			lineKeys[key] = len(lineKeys)
+		}
+		page.words = append(page.words, word{block: block, line: lineKeys[key], box: position, conf: conf, text: strings.TrimSpace(fields[11])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取TSV失败: %w", err)
+	}
+	return page, nil
+}
+
+// parseHOCR 解析tesseract的hOCR输出
+// ocr_carea是文本块，ocr_line等是行，ocrx_word是词，位置和置信度在title属性中
+func parseHOCR(r io.Reader) (*layout, error) {
+	page := &layout{}
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	block, line := 0, -1
+	var current *word // 正在读取的词
+	depth := 0        // 当前词内嵌套的元素层数，如<strong>
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析hOCR失败: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if current != nil {
+				depth++
+				continue
+			}
+			class, title := attr(t, "class"), attr(t, "title")
+			switch class {
+			case "ocr_carea":
+				block++
+			case "ocr_line", "ocr_header", "ocr_caption", "ocr_textfloat":
+				line++
+			case "ocrx_word":
+				position, conf := hocrTitle(title)
+				current = &word{block: block, line: line, box: position, conf: conf}
+				depth = 0
+			}
+		case xml.CharData:
+			if current != nil {
+				current.text += string(t)
+			}
+		case xml.EndElement:
+			if current == nil {
+				continue
+			}
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if current.text = strings.TrimSpace(current.text); current.text != "" {
+				page.words = append(page.words, *current)
+			}
+			current = nil
+		}
+	}
+	return page, nil
+}
+
+// attr 返回元素的属性值
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// hocrTitle 从hOCR的title属性中解析位置和置信度，如"bbox 296 254 520 300; x_wconf 96"，没有置信度时为-1
+func hocrTitle(title string) (box, float64) {
+	var position box
+	conf := -1.0
+	for _, property := range strings.Split(title, ";") {
+		fields := strings.Fields(property)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "bbox":
+			if len(fields) == 5 {
+				coords := make([]int, 4)
+				for i := range coords {
+					coords[i], _ = strconv.Atoi(fields[i+1])
+				}
+				position = box{coords[0], coords[1], coords[2], coords[3]}
+			}
+		case "x_wconf":
+			if len(fields) == 2 {
+				if c, err := strconv.ParseFloat(fields[1], 64); err == nil {
+					conf = c
+				}
+			}
+		}
+	}
+	return position, conf
+}
+
+// text 按阅读顺序重建页面文本：块内各行用换行连接，块之间空一行
+// 多栏版面中每一栏的文本块排在一起，不会把左右两栏的行交错拼接
+func (l *layout) text() string {
+	blocks := l.blocks()
+	parts := make([]string, 0, len(blocks))
+	for _, b := range readingOrder(blocks) {
+		lines := make([]string, len(b.lines))
+		for i, line := range b.lines {
+			lines[i] = joinWords(line)
+		}
+		parts = append(parts, strings.Join(lines, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// confidence 返回各词置信度的平均值，取值0到1，没有词时为0
+func (l *layout) confidence() float64 {
+	var sum float64
+	var n int
+	for _, w := range l.words {
+		if w.conf >= 0 {
+			sum += w.conf
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n) / 100
+}
+
+// blocks 把词按文本块和行分组，保持识别顺序
+func (l *layout) blocks() []*textBlock {
+	var blocks []*textBlock
+	index := map[int]*textBlock{}
+	lastLine := map[int]int{}
+	for _, w := range l.words {
+		b, ok := index[w.block]
+		if !ok {
+			b = &textBlock{box: w.box}
+			index[w.block] = b
+			blocks = append(blocks, b)
+		}
+		b.box = b.box.union(w.box)
+		if len(b.lines) == 0 || lastLine[w.block] != w.line {
+			b.lines = append(b.lines, nil)
+			lastLine[w.block] = w.line
+		}
+		b.lines[len(b.lines)-1] = append(b.lines[len(b.lines)-1], w)
+	}
+	return blocks
+}
+
+// readingOrder 按递归的XY切分返回文本块的阅读顺序
+// 能在竖直方向切开且两侧都足够宽时按栏从左到右，否则在水平方向切开按行从上到下；
+// 一行中切不开的窄块（如标题和右侧的日期）从左到右排列
+func readingOrder(blocks []*textBlock) []*textBlock {
+	if len(blocks) <= 1 {
+		return blocks
+	}
+	columns := split(blocks, func(b box) (int, int) { return b.left, b.right })
+	rows := split(blocks, func(b box) (int, int) { return b.top, b.bottom })
+	var groups [][]*textBlock
+	switch {
+	case len(columns) > 1 && wideColumns(columns):
+		groups = columns
+	case len(rows) > 1:
+		groups = rows
+	case len(columns) > 1:
+		groups = columns
+	default:
+		sorted := append([]*textBlock(nil), blocks...)
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].box.top < sorted[j].box.top })
+		return sorted
+	}
+	var ordered []*textBlock
+	for _, group := range groups {
+		ordered = append(ordered, readingOrder(group)...)
+	}
+	return ordered
+}
+
+// split 按span给出的投影区间把块分组，投影不重叠的块分在不同组，组按区间起点排序
+func split(blocks []*textBlock, span func(box) (int, int)) [][]*textBlock {
+	sorted := append([]*textBlock(nil), blocks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, _ := span(sorted[i].box)
+		b, _ := span(sorted[j].box)
+		return a < b
+	})
+	var groups [][]*textBlock
+	end := 0
+	for _, b := range sorted {
+		start, stop := span(b.box)
+		if len(groups) == 0 || start >= end {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], b)
+		end = max(end, stop)
+	}
+	return groups
+}
+
+// wideColumns 返回各栏的宽度是否都不小于整个区域宽度的minColumnRatio
+func wideColumns(columns [][]*textBlock) bool {
+	left, right := columns[0][0].box.left, 0
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		columnLeft, columnRight := column[0].box.left, 0
+		for _, b := range column {
+			columnLeft, columnRight = min(columnLeft, b.box.left), max(columnRight, b.box.right)
+		}
+		widths[i] = columnRight - columnLeft
+		left, right = min(left, columnLeft), max(right, columnRight)
+	}
+	for _, width := range widths {
+		if float64(width) < float64(right-left)*minColumnRatio {
+			return false
+		}
+	}
+	return true
+}
+
+// joinWords 用空格连接一行中的词，相邻的中日韩文字之间不加空格
+func joinWords(words []word) string {
+	var line strings.Builder
+	for i, w := range words {
+		if i > 0 {
+			prev := []rune(words[i-1].text)
+			if !isCJK(prev[len(prev)-1]) || !isCJK([]rune(w.text)[0]) {
+				line.WriteString(" ")
+			}
+		}
+		line.WriteString(w.text)
+	}
+	return line.String()
+}
+
+// isCJK 返回字符是否为中日韩文字或全角标点
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xffef)
+}
@@ -1,19 +1,19 @@
 package ocr
 
+import "context"
+
 // OCRProcessor 定义了OCR文本提取处理器的接口
+// ctx用于取消外部命令和HTTP请求，并传递追踪span
 type OCRProcessor interface {
 	// ExtractTextFromPDF 从PDF文件中提取文本
-	ExtractTextFromPDF(pdfPath string) (string, error)
+	ExtractTextFromPDF(ctx context.Context, pdfPath string) (string, error)
 
 	// ExtractTextFromImage 从图像文件中提取文本
-	ExtractTextFromImage(imagePath string) (string, error)
+	ExtractTextFromImage(ctx context.Context, imagePath string) (string, error)
 }
 
-// GetOCRProcessor 根据配置返回合适的OCR处理器
-// 如果有OCR.space API密钥，优先使用云端OCR，否则使用本地Tesseract
+// GetOCRProcessor 返回使用默认引擎顺序和质量阈值的引擎链
+// 依次使用PDF文本层、本地Tesseract和OCR.space（有API密钥时），见GetOCRChain
 func GetOCRProcessor(ocrAPIKey string, tesseractPath string) OCRProcessor {
-	if ocrAPIKey != "" {
-		return NewOCRSpaceAPI(ocrAPIKey)
-	}
-	return NewTesseractOCR(tesseractPath)
+	return GetOCRChain(Options{Engines: DefaultEngines, MinQuality: DefaultMinQuality, TesseractPath: tesseractPath, OCRSpaceKey: ocrAPIKey})
 }
@@ -1,9 +1,23 @@
 package ocr
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestOCR(t *testing.T) {
@@ -69,3 +83,431 @@ func TestOCR(t *testing.T) {
 		t.Logf("成功测试GetOCRProcessor")
 	})
 }
+
+// stubEngine 按页号返回预设的文本，记录每次被请求识别的页号
+type stubEngine struct {
+	name      string
+	texts     map[int]string
+	err       error
+	requested [][]int
+}
+
+func (e *stubEngine) Name() string { return e.name }
+
+func (e *stubEngine) RecognizePages(ctx context.Context, filePath string, pages []int) ([]Page, error) {
+	e.requested = append(e.requested, pages)
+	if e.err != nil {
+		return nil, e.err
+	}
+	var result []Page
+	for number := 1; number <= len(e.texts); number++ {
+		if wantPage(pages, number) {
+			result = append(result, newPage(number, e.texts[number], e.name))
+		}
+	}
+	return result, nil
+}
+
+func TestScore(t *testing.T) {
+	good := Score("张三，5年Go后端开发经验，熟悉微服务架构和分布式系统设计。")
+	garbled := Score("�� ~|^ `\\ �� ¦¦ ~~ ^^ �� || ¬¬ ~~")
+	short := Score("- 2 -")
+	if good < DefaultMinQuality || garbled >= DefaultMinQuality || short >= DefaultMinQuality || Score("  \n ") != 0 {
+		t.Fatalf("质量分不正确: 正常%.2f 乱码%.2f 过短%.2f", good, garbled, short)
+	}
+}
+
+func TestChainRetriesLowQualityPages(t *testing.T) {
+	text := "熟悉Go语言、MySQL和Redis，负责过日均千万级请求的订单系统"
+	pdfText := &stubEngine{name: EnginePDFText, texts: map[int]string{1: text, 2: "", 3: text}}
+	tesseract := &stubEngine{name: EngineTesseract, texts: map[int]string{1: "", 2: "~|^ ¦", 3: ""}}
+	ocrSpace := &stubEngine{name: EngineOCRSpace, texts: map[int]string{1: "", 2: text + "（扫描页）", 3: ""}}
+
+	pages, err := NewChain(DefaultMinQuality, pdfText, tesseract, ocrSpace).Recognize(context.Background(), "resume.pdf")
+	if err != nil {
+		t.Fatalf("识别失败: %v", err)
+	}
+	if len(pages) != 3 || pages[1].Engine != EngineOCRSpace || pages[0].Engine != EnginePDFText || pages[2].Engine != EnginePDFText {
+		t.Fatalf("每页应保留质量最高的结果: %+v", pages)
+	}
+	if len(tesseract.requested) != 1 || fmt.Sprint(tesseract.requested[0]) != "[2]" || fmt.Sprint(ocrSpace.requested[0]) != "[2]" {
+		t.Fatalf("只有质量低的页应交给下一个引擎: %v %v", tesseract.requested, ocrSpace.requested)
+	}
+
+	// 前一个引擎失败时下一个引擎识别全部页，质量都合格时不再调用后面的引擎
+	failing := &stubEngine{name: EngineTesseract, err: fmt.Errorf("%w: 未安装", ErrUnavailable)}
+	last := &stubEngine{name: EngineOCRSpace, texts: map[int]string{1: text}}
+	pages, err = NewChain(DefaultMinQuality, failing, &stubEngine{name: "backup", texts: map[int]string{1: text}}, last).Recognize(context.Background(), "scan.png")
+	if err != nil || len(pages) != 1 || pages[0].Engine != "backup" || len(last.requested) != 0 {
+		t.Fatalf("失败的引擎应由下一个引擎代替: %v %+v", err, pages)
+	}
+}
+
+func TestChainErrors(t *testing.T) {
+	unavailable := &stubEngine{name: EngineTesseract, err: fmt.Errorf("%w: 未安装", ErrUnavailable)}
+	empty := &stubEngine{name: EngineOCRSpace, texts: map[int]string{1: " "}}
+
+	_, err := NewChain(DefaultMinQuality, unavailable, empty).Recognize(context.Background(), "scan.png")
+	var ocrErr *Error
+	if !errors.As(err, &ocrErr) || !errors.Is(err, ErrUnavailable) || !errors.Is(err, ErrEmptyText) {
+		t.Fatalf("应返回包含各引擎错误的*Error，得到%v", err)
+	}
+	var engineErr *EngineError
+	if !errors.As(err, &engineErr) || engineErr.Engine != EngineTesseract {
+		t.Fatalf("应记录失败的引擎，得到%v", err)
+	}
+
+	_, err = ProcessFile(context.Background(), NewPDFText(), "scan.png")
+	if !errors.As(err, &ocrErr) || !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("不支持的格式应返回ErrUnsupportedFormat，得到%v", err)
+	}
+}
+
+func TestPageImagesNumericOrder(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"page-10.png", "page-2.png", "page-1.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatalf("创建图像文件失败: %v", err)
+		}
+	}
+	images, err := pageImages(filepath.Join(dir, "page"))
+	if err != nil {
+		t.Fatalf("查找图像失败: %v", err)
+	}
+	if len(images) != 3 || images[0].number != 1 || images[1].number != 2 || images[2].number != 10 {
+		t.Fatalf("图像应按页号排序: %+v", images)
+	}
+}
+
+// writeTestPDF 生成一个简单的PDF，contents中每项是一页的内容流，所有页共用Helvetica字体和一张1x1的图像
+func writeTestPDF(t *testing.T, contents ...string) string {
+	t.Helper()
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // 页面树，页面对象确定后再填写
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8 /Length 1 >>\nstream\n\x80\nendstream",
+	}
+	var kids []string
+	for _, content := range contents {
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> /XObject << /Im1 4 0 R >> >> /Contents %d 0 R >>", len(objects)))
+		kids = append(kids, fmt.Sprintf("%d 0 R", len(objects)))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var buf strings.Builder
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	path := filepath.Join(t.TempDir(), "resume.pdf")
+	if err := os.WriteFile(path, []byte(buf.String()), 0600); err != nil {
+		t.Fatalf("写入PDF失败: %v", err)
+	}
+	return path
+}
+
+func TestProcessFileOCRsOnlyScannedPages(t *testing.T) {
+	path := writeTestPDF(t,
+		"BT /F1 12 Tf 72 720 Td (Zhang San - Senior Go Engineer, 5 years of backend experience) Tj ET",
+		"q 612 0 0 792 0 0 cm /Im1 Do Q",
+		"BT /F1 12 Tf 72 720 Td (Thanks) Tj ET",
+	)
+	scanned := "Project: order system serving ten million requests per day"
+	tesseract := &stubEngine{name: EngineTesseract, texts: map[int]string{1: "", 2: scanned, 3: ""}}
+
+	// 引擎链中没有pdftext时也先读取文本层
+	text, err := ProcessFile(context.Background(), NewChain(DefaultMinQuality, tesseract), path)
+	if err != nil {
+		t.Fatalf("处理PDF失败: %v", err)
+	}
+	if len(tesseract.requested) != 1 || fmt.Sprint(tesseract.requested[0]) != "[2]" {
+		t.Fatalf("只有没有文本层的第2页应交给OCR识别，得到%v", tesseract.requested)
+	}
+	first, second, third := strings.Index(text, "Senior Go Engineer"), strings.Index(text, scanned), strings.Index(text, "Thanks")
+	if first < 0 || second < first || third < second {
+		t.Fatalf("各页的文本应按页号合并: %q", text)
+	}
+}
+
+// tsvWord 生成TSV中一个词的行
+func tsvWord(block, line int, left, top, width int, conf float64, text string) string {
+	return fmt.Sprintf("5\t1\t%d\t1\t%d\t1\t%d\t%d\t%d\t30\t%g\t%s", block, line, left, top, width, conf, text)
+}
+
+func TestParseTSVReadingOrder(t *testing.T) {
+	// 通栏的姓名，下面左栏是联系方式，右栏是工作经历，工作经历的标题和日期在同一行的两个块中
+	rows := []string{
+		"level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext",
+		"1\t1\t0\t0\t0\t0\t0\t0\t2000\t2800\t-1\t",
+		tsvWord(1, 1, 100, 50, 1800, 96, "张三 - 高级后端工程师"),
+		tsvWord(2, 1, 100, 200, 500, 90, "电话"),
+		tsvWord(2, 1, 620, 200, 100, 90, "13800138000"),
+		tsvWord(2, 2, 100, 260, 500, 90, "邮箱"),
+		tsvWord(3, 1, 900, 200, 600, 94, "未来科技"),
+		tsvWord(4, 1, 1600, 195, 300, 88, "2019-2023"),
+		tsvWord(5, 1, 900, 260, 1000, 92, "负责订单系统"),
+		tsvWord(5, 1, 1500, 260, 200, 92, "Go"),
+		"5\t1\t5\t1\t1\t3\t1750\t260\t10\t30\t-1\t ",
+	}
+	page, err := parseTSV(strings.NewReader(strings.Join(rows, "\n")))
+	if err != nil {
+		t.Fatalf("解析TSV失败: %v", err)
+	}
+	want := "张三 - 高级后端工程师\n\n电话 13800138000\n邮箱\n\n未来科技\n\n2019-2023\n\n负责订单系统 Go"
+	if text := page.text(); text != want {
+		t.Fatalf("应按栏重建阅读顺序，得到\n%s", text)
+	}
+	if conf := page.confidence(); conf < 0.91 || conf > 0.92 {
+		t.Fatalf("平均置信度应忽略非词的行，得到%.3f", conf)
+	}
+}
+
+func TestParseHOCR(t *testing.T) {
+	hocr := `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<div class='ocr_page' id='page_1' title='image "page-1.png"; bbox 0 0 2000 2800; ppageno 0'>
+ <div class='ocr_carea' id='block_1_1' title="bbox 100 100 900 300">
+  <p class='ocr_par' id='par_1_1'>
+   <span class='ocr_line' id='line_1_1' title="bbox 100 100 900 140; baseline 0 -8">
+    <span class='ocrx_word' id='word_1_1' title='bbox 100 100 160 140; x_wconf 95'>熟悉</span>
+    <span class='ocrx_word' id='word_1_2' title='bbox 170 100 300 140; x_wconf 85'><strong>Kubernetes</strong></span>
+   </span>
+   <span class='ocr_line' id='line_1_2' title="bbox 100 160 900 200">
+    <span class='ocrx_word' id='word_1_3' title='bbox 100 160 200 200; x_wconf 90'>R&amp;D</span>
+   </span>
+  </p>
+ </div>
+</div></body></html>`
+	page, err := parseHOCR(strings.NewReader(hocr))
+	if err != nil {
+		t.Fatalf("解析hOCR失败: %v", err)
+	}
+	if text := page.text(); text != "熟悉 Kubernetes\nR&D" {
+		t.Fatalf("hOCR重建的文本不正确: %q", text)
+	}
+	if conf := page.confidence(); conf != 0.9 {
+		t.Fatalf("平均置信度应为0.9，得到%v", conf)
+	}
+}
+
+func TestTesseractOptions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("需要sh")
+	}
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	// 模拟tesseract：记录参数，把TSV写到第二个参数指定的输出文件
+	script := "#!/bin/sh\n" +
+		"[ \"$1\" = --version ] && exit 0\n" +
+		"echo \"$@\" > " + argsFile + "\n" +
+		"printf 'level\\tpage_num\\tblock_num\\tpar_num\\tline_num\\tword_num\\tleft\\ttop\\twidth\\theight\\tconf\\ttext\\n" +
+		"5\\t1\\t1\\t1\\t1\\t1\\t10\\t10\\t50\\t20\\t93\\t五年Go后端开发经验，熟悉微服务架构和分布式系统\\n' > \"$2.tsv\"\n"
+	tesseractPath := filepath.Join(dir, "tesseract")
+	if err := os.WriteFile(tesseractPath, []byte(script), 0700); err != nil {
+		t.Fatalf("写入模拟的tesseract失败: %v", err)
+	}
+	image := filepath.Join(dir, "scan.png")
+	if err := os.WriteFile(image, nil, 0600); err != nil {
+		t.Fatalf("创建图像失败: %v", err)
+	}
+
+	engine := NewTesseractOCR(tesseractPath).WithOptions(TesseractOptions{Languages: "chi_sim+eng", PSM: 4, OEM: 1})
+	pages, err := engine.RecognizePages(context.Background(), image, nil)
+	if err != nil {
+		t.Fatalf("识别失败: %v", err)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("读取参数失败: %v", err)
+	}
+	if !strings.Contains(string(args), "-l chi_sim+eng --psm 4 --oem 1") || !strings.HasSuffix(strings.TrimSpace(string(args)), "tsv") {
+		t.Fatalf("应传递配置的语言、分割模式、引擎模式和输出格式: %s", args)
+	}
+	if len(pages) != 1 || !strings.Contains(pages[0].Text, "微服务") || pages[0].Quality > 0.93 {
+		t.Fatalf("应从TSV重建文本，质量分不超过置信度: %+v", pages)
+	}
+}
+
+func TestRecognizeAllKeepsOrderAndLimit(t *testing.T) {
+	engine := NewTesseractOCR("").WithOptions(TesseractOptions{Workers: 2})
+
+	var running, peak atomic.Int32
+	var tasks []pageTask
+	for number := 1; number <= 6; number++ {
+		tasks = append(tasks, func(ctx context.Context) (Page, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			// 页号越小耗时越长，结果仍应按页号排列
+			time.Sleep(time.Duration(7-number) * 5 * time.Millisecond)
+			return Page{Number: number}, nil
+		})
+	}
+	pages, err := engine.recognizeAll(context.Background(), tasks)
+	if err != nil {
+		t.Fatalf("并发识别失败: %v", err)
+	}
+	for i, page := range pages {
+		if page.Number != i+1 {
+			t.Fatalf("第%d个结果的页号为%d，应按页号排列", i+1, page.Number)
+		}
+	}
+	if peak.Load() > 2 {
+		t.Fatalf("同时识别了%d页，超过上限2", peak.Load())
+	}
+
+	// 一页失败时取消其余页，返回该页的错误
+	failure := errors.New("第1页识别失败")
+	var cancelled atomic.Int32
+	started := make(chan struct{})
+	tasks = []pageTask{
+		func(ctx context.Context) (Page, error) {
+			<-started
+			return Page{}, failure
+		},
+		func(ctx context.Context) (Page, error) {
+			close(started)
+			select {
+			case <-ctx.Done():
+				cancelled.Add(1)
+				return Page{}, ctx.Err()
+			case <-time.After(5 * time.Second):
+				return Page{Number: 2}, nil
+			}
+		},
+		func(ctx context.Context) (Page, error) { return Page{Number: 3}, nil },
+	}
+	if _, err := engine.recognizeAll(context.Background(), tasks); !errors.Is(err, failure) {
+		t.Fatalf("应返回最先发生的错误，实际为: %v", err)
+	}
+	if cancelled.Load() != 1 {
+		t.Fatalf("一页失败后其余进行中的页应被取消")
+	}
+}
+
+// drawPage 画一页模拟的左对齐文字：每行由若干深色的词组成，行尾参差，背景从上到下逐渐变暗
+func drawPage(w, h int) *image.Gray {
+	page := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			page.Pix[y*page.Stride+x] = uint8(220 - 80*y/h)
+		}
+	}
+	for line, top := 0, 60; top+20 < h-60; line, top = line+1, top+45 {
+		right := w - 80 - (line*137)%(w/2)
+		for x := 60; x < right; {
+			width := 20 + (x*7+line*13)%50
+			for dy := 0; dy < 20; dy++ {
+				for dx := 0; dx < width && x+dx < right; dx++ {
+					page.Pix[(top+dy)*page.Stride+x+dx] = uint8(90 - 40*top/h)
+				}
+			}
+			x += width + 12
+		}
+	}
+	return page
+}
+
+func TestPreprocessSteps(t *testing.T) {
+	page := binarize(drawPage(800, 1000), 31)
+	if page.Pix[70*page.Stride+65] != 0 || page.Pix[40*page.Stride+400] != 255 || page.Pix[950*page.Stride+400] != 255 {
+		t.Fatalf("二值化后文字应为黑色，渐暗的背景应为白色")
+	}
+	if sideways(page) || rightAligned(page) {
+		t.Fatalf("正常的页面不应判定为横置或颠倒")
+	}
+
+	// 横置的页面：逆时针横置的转一次，顺时针横置的转正后是颠倒的
+	if !sideways(rotate(page, 270)) || rightAligned(rotate(rotate(page, 270), 90)) {
+		t.Fatalf("逆时针横置的页面顺时针转90度后应为正")
+	}
+	if !sideways(rotate(page, 90)) || !rightAligned(rotate(rotate(page, 90), 90)) {
+		t.Fatalf("顺时针横置的页面顺时针转90度后应判定为颠倒")
+	}
+
+	// deskew(page, -3)把页面顺时针旋转3度
+	if skew := detectSkew(deskew(page, -3)); math.Abs(skew-3) > 0.3 {
+		t.Fatalf("应检测到约3度的倾斜，实际%.1f度", skew)
+	}
+	if skew := detectSkew(deskew(deskew(page, -3), 3)); math.Abs(skew) > 0.3 {
+		t.Fatalf("纠偏后不应再有倾斜，实际%.1f度", skew)
+	}
+
+	if r := resize(page, 0.5).Rect; r.Dx() != 400 || r.Dy() != 500 {
+		t.Fatalf("缩放后的尺寸错误: %v", r)
+	}
+}
+
+func TestPreprocessImage(t *testing.T) {
+	dir := t.TempDir()
+	// 按传感器方向保存、EXIF方向为6（需顺时针旋转90度）的手机照片
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, rotate(drawPage(800, 1000), 270), &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("编码JPEG失败: %v", err)
+	}
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00\x00\x00\x00\x00")
+	app1 := append([]byte{0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+	data := append(append([]byte{0xFF, 0xD8}, app1...), buf.Bytes()[2:]...)
+	photo := filepath.Join(dir, "photo.jpg")
+	os.WriteFile(photo, data, 0o644)
+	if o := exifOrientation(data); o != 6 {
+		t.Fatalf("应读取到EXIF方向6，实际%d", o)
+	}
+
+	out := filepath.Join(dir, "out.png")
+	result, err := preprocessImage(context.Background(), photo, out, 0, 300)
+	if err != nil {
+		t.Fatalf("预处理失败: %v", err)
+	}
+	// 没有分辨率信息，按A4宽度估计约97DPI，放大到300DPI
+	if result.Rotation != 90 || result.DPI != 300 || math.Abs(result.Scale-300/math.Round(800/a4WidthInches)) > 0.01 {
+		t.Fatalf("应按EXIF转正并放大到300DPI: %+v", result)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatalf("打开预处理结果失败: %v", err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("预处理结果应为PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() >= b.Dy() {
+		t.Fatalf("转正后应为竖版页面: %v", b)
+	}
+
+	// 无法解码的图像返回错误，由调用方改用原图
+	broken := filepath.Join(dir, "broken.png")
+	os.WriteFile(broken, []byte("not an image"), 0o644)
+	if _, err := preprocessImage(context.Background(), broken, out, 0, 300); err == nil {
+		t.Fatalf("无法解码的图像应返回错误")
+	}
+
+	// 文件头声明的尺寸超过上限时不解码，只有文件头的PNG也应在读取像素前返回错误
+	ihdr := binary.BigEndian.AppendUint32([]byte("IHDR"), 20000)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 20000)
+	ihdr = append(ihdr, 8, 0, 0, 0, 0)
+	header := binary.BigEndian.AppendUint32([]byte("\x89PNG\r\n\x1a\n"), 13)
+	header = binary.BigEndian.AppendUint32(append(header, ihdr...), crc32.ChecksumIEEE(ihdr))
+	huge := filepath.Join(dir, "huge.png")
+	os.WriteFile(huge, header, 0o644)
+	if _, err := preprocessImage(context.Background(), huge, out, 0, 300); err == nil || !strings.Contains(err.Error(), "图像过大") {
+		t.Fatalf("像素数超过上限的图像应在解码前返回错误，实际为%v", err)
+	}
+}
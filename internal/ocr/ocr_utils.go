@@ -1,11 +1,16 @@
 package ocr
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/10yihang/resume-ai-interview/internal/tokenizer"
+	"github.com/10yihang/resume-ai-interview/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // OCRResult 表示OCR处理结果
@@ -16,59 +21,86 @@ type OCRResult struct {
 	Source      string // 使用的OCR引擎
 }
 
-// 估计token数量（简单实现，实际上tokens约等于单词数的1.3倍）
+// EstimateTokenCount 用通用的分词器统计文本的token数，用于日志和识别结果；
+// 发送给大模型前按实际使用的模型重新统计，见parser.AITextParser
 func EstimateTokenCount(text string) int {
-	// 分割文本统计单词数
-	words := strings.Fields(text)
-	// 估算token数（简单估计为单词数的1.3倍）
-	return int(float64(len(words)) * 1.3)
+	return tokenizer.Count("", text)
 }
 
-// ProcessFile 处理文件并提取文本，带有错误重试和日志
-func ProcessFile(processor OCRProcessor, filePath string) (string, error) {
-	start := time.Now()
+// chainOf 返回处理器对应的引擎链，单个引擎包装为只有一个引擎的引擎链
+func chainOf(processor OCRProcessor) *Chain {
+	switch p := processor.(type) {
+	case *Chain:
+		return p
+	case Engine:
+		return NewChain(0, p)
+	default:
+		return NewChain(0, wholeFile{p})
+	}
+}
+
+// wholeFile 把不支持按页识别的处理器包装为引擎，整个文件作为一页
+type wholeFile struct {
+	processor OCRProcessor
+}
+
+// Name 返回处理器的类型名
+func (w wholeFile) Name() string {
+	return fmt.Sprintf("%T", w.processor)
+}
+
+// RecognizePages 识别整个文件，结果作为第1页
+func (w wholeFile) RecognizePages(ctx context.Context, filePath string, pages []int) ([]Page, error) {
+	kind, err := fileKind(filePath)
+	if err != nil || !wantPage(pages, 1) {
+		return nil, err
+	}
 	var text string
-	var err error
+	if kind == "pdf" {
+		text, err = w.processor.ExtractTextFromPDF(ctx, filePath)
+	} else {
+		text, err = w.processor.ExtractTextFromImage(ctx, filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []Page{newPage(1, text, w.Name())}, nil
+}
 
-	ext := strings.ToLower(filepath.Ext(filePath))
-	source := fmt.Sprintf("%T", processor)
+// ProcessFile 处理文件并提取文本，日志带有ctx中的请求ID
+// processor是引擎链时依次尝试各引擎，识别质量低的页改用下一个引擎；失败时返回*Error
+// PDF总是先逐页读取文本层，只有扫描页和文本层乱码的页才交给OCR引擎，结果按页号合并
+// 整个处理过程记录为一个span，各页的识别是它的子span
+func ProcessFile(ctx context.Context, processor OCRProcessor, filePath string) (text string, err error) {
+	start := time.Now()
+	chain := chainOf(processor)
+	if kind, _ := fileKind(filePath); kind == "pdf" {
+		chain = chain.textLayerFirst()
+	}
+	ctx, span := tracing.Start(ctx, "ocr.ProcessFile",
+		attribute.StringSlice("ocr.engines", chain.Engines()), attribute.String("file.extension", strings.ToLower(filepath.Ext(filePath))))
+	defer func() {
+		tracing.End(span, err)
+	}()
 
-	// 根据文件类型选择合适的处理方法
-	switch ext {
-	case ".pdf":
-		text, err = processor.ExtractTextFromPDF(filePath)
-		if err != nil {
-			log.Printf("PDF OCR处理失败 (%s): %v", source, err)
-			return "", fmt.Errorf("OCR处理失败: %w", err)
-		}
-	case ".png", ".jpg", ".jpeg":
-		text, err = processor.ExtractTextFromImage(filePath)
-		if err != nil {
-			log.Printf("图像OCR处理失败 (%s): %v", source, err)
-			return "", fmt.Errorf("OCR处理失败: %w", err)
-		}
-	default:
-		return "", fmt.Errorf("不支持的文件类型: %s", ext)
+	pages, err := chain.Recognize(ctx, filePath)
+	if err != nil {
+		slog.WarnContext(ctx, "OCR处理失败", "file", filepath.Base(filePath), "error", err)
+		return "", err
 	}
+	text = joinPages(pages)
 
 	duration := time.Since(start)
 	tokens := EstimateTokenCount(text)
 
-	log.Printf("OCR处理完成 (%s): 文件=%s, 耗时=%v, 估计tokens=%d",
-		source, filepath.Base(filePath), duration, tokens)
-
-	// 检查提取的文本是否为空
-	if strings.TrimSpace(text) == "" {
-		return "", fmt.Errorf("OCR提取的文本为空")
+	engines := make([]string, len(pages))
+	for i, page := range pages {
+		engines[i] = page.Engine
 	}
+	slog.InfoContext(ctx, "OCR处理完成", "engines", engines, "file", filepath.Base(filePath),
+		"pages", len(pages), "duration_ms", duration.Milliseconds(), "tokens", tokens)
 
-	// 如果token数量过大，截断文本
-	if tokens > 4000 {
-		log.Printf("警告: OCR提取的文本token数(%d)过多，将被截断", tokens)
-		// 简单截断为原来的一半长度
-		words := strings.Fields(text)
-		text = strings.Join(words[:len(words)/2], " ")
-	}
+	// 不在这里截断长文本，由AI解析器按模型分块解析
 
 	return text, nil
 }
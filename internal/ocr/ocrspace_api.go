@@ -2,6 +2,7 @@ package ocr
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,8 +10,14 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+
+	"github.com/10yihang/resume-ai-interview/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// ocrSpaceURL 是OCR.space识别接口的地址
+const ocrSpaceURL = "https://api.ocr.space/parse/image"
+
 // OCRSpaceAPI 使用免费的OCR.space API进行文字识别
 // https://ocr.space/OCRAPI
 type OCRSpaceAPI struct {
@@ -33,20 +40,57 @@ func NewOCRSpaceAPI(apiKey string) *OCRSpaceAPI {
 	}
 }
 
+// Name 返回引擎名称
+func (o *OCRSpaceAPI) Name() string {
+	return EngineOCRSpace
+}
+
 // ExtractTextFromPDF 从PDF文件中提取文本
-func (o *OCRSpaceAPI) ExtractTextFromPDF(pdfPath string) (string, error) {
-	return o.extractTextFromFile(pdfPath)
+func (o *OCRSpaceAPI) ExtractTextFromPDF(ctx context.Context, pdfPath string) (string, error) {
+	pages, err := o.RecognizePages(ctx, pdfPath, nil)
+	return joinPages(pages), err
 }
 
 // ExtractTextFromImage 从图像文件中提取文本
-func (o *OCRSpaceAPI) ExtractTextFromImage(imagePath string) (string, error) {
-	return o.extractTextFromFile(imagePath)
+func (o *OCRSpaceAPI) ExtractTextFromImage(ctx context.Context, imagePath string) (string, error) {
+	pages, err := o.RecognizePages(ctx, imagePath, nil)
+	return joinPages(pages), err
 }
 
-// extractTextFromFile 从文件中提取文本（支持PDF、PNG、JPG等）
-func (o *OCRSpaceAPI) extractTextFromFile(filePath string) (string, error) {
+// RecognizePages 上传整个文件识别，返回其中的指定页；OCR.space对PDF按页返回识别结果
+func (o *OCRSpaceAPI) RecognizePages(ctx context.Context, filePath string, pages []int) ([]Page, error) {
+	if _, err := fileKind(filePath); err != nil {
+		return nil, err
+	}
+	if o.apiKey == "" {
+		return nil, fmt.Errorf("%w: 未配置OCR.space的API密钥", ErrUnavailable)
+	}
+	texts, err := o.extractTextFromFile(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	var result []Page
+	for i, text := range texts {
+		if wantPage(pages, i+1) {
+			result = append(result, newPage(i+1, text, EngineOCRSpace))
+		}
+	}
+	return result, nil
+}
+
+// extractTextFromFile 从文件中提取各页的文本（支持PDF、PNG、JPG等），整个文件作为一页记录span
+func (o *OCRSpaceAPI) extractTextFromFile(ctx context.Context, filePath string) (texts []string, err error) {
+	ctx, span := tracing.Start(ctx, "ocr.page", attribute.String("ocr.engine", EngineOCRSpace))
+	defer func() {
+		length := 0
+		for _, text := range texts {
+			length += len(text)
+		}
+		span.SetAttributes(attribute.Int("ocr.text_length", length))
+		tracing.End(span, err)
+	}()
+
 	// 准备请求
-	url := "https://api.ocr.space/parse/image"
 	method := "POST"
 
 	// 创建multipart表单
@@ -64,29 +108,29 @@ func (o *OCRSpaceAPI) extractTextFromFile(filePath string) (string, error) {
 	// 添加文件
 	file, err := os.Open(filePath)
 	if err != nil {
-		return "", fmt.Errorf("打开文件失败: %w", err)
+		return nil, fmt.Errorf("打开文件失败: %w", err)
 	}
 	defer file.Close()
 
 	part, err := writer.CreateFormFile("file", filepath.Base(filePath))
 	if err != nil {
-		return "", fmt.Errorf("创建表单文件失败: %w", err)
+		return nil, fmt.Errorf("创建表单文件失败: %w", err)
 	}
 
 	_, err = io.Copy(part, file)
 	if err != nil {
-		return "", fmt.Errorf("复制文件内容失败: %w", err)
+		return nil, fmt.Errorf("复制文件内容失败: %w", err)
 	}
 
 	err = writer.Close()
 	if err != nil {
-		return "", fmt.Errorf("关闭表单写入器失败: %w", err)
+		return nil, fmt.Errorf("关闭表单写入器失败: %w", err)
 	}
 
 	// 创建HTTP请求
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, ocrSpaceURL, body)
 	if err != nil {
-		return "", fmt.Errorf("创建HTTP请求失败: %w", err)
+		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
 	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
@@ -95,33 +139,50 @@ func (o *OCRSpaceAPI) extractTextFromFile(filePath string) (string, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("发送HTTP请求失败: %w", err)
+		return nil, fmt.Errorf("发送HTTP请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %w", err)
+		return nil, fmt.Errorf("读取响应失败: %w", err)
 	}
 
 	// 解析JSON响应
 	var ocrResp OCRSpaceResponse
 	err = json.Unmarshal(respBody, &ocrResp)
 	if err != nil {
-		return "", fmt.Errorf("解析JSON响应失败: %w", err)
+		return nil, fmt.Errorf("解析JSON响应失败: %w", err)
 	}
 
 	// 检查是否有错误
 	if ocrResp.IsErroredOnProcessing {
-		return "", fmt.Errorf("OCR处理错误: %s", ocrResp.ErrorMessage)
+		return nil, fmt.Errorf("OCR处理错误: %s", ocrResp.ErrorMessage)
 	}
 
-	// 提取文本
-	var allText string
-	for _, result := range ocrResp.ParsedResults {
-		allText += result.ParsedText
+	// 提取文本，PDF的每一页对应一个结果
+	texts = make([]string, len(ocrResp.ParsedResults))
+	for i, result := range ocrResp.ParsedResults {
+		texts[i] = result.ParsedText
 	}
 
-	return allText, nil
+	return texts, nil
+}
+
+// Ping 检查OCR.space接口是否可达，不上传文件也不消耗识别次数
+func (o *OCRSpaceAPI) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ocrSpaceURL, nil)
+	if err != nil {
+		return fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("无法连接OCR.space: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("OCR.space返回状态码%d", resp.StatusCode)
+	}
+	return nil
 }
@@ -0,0 +1,153 @@
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/10yihang/resume-ai-interview/internal/tracing"
+	"github.com/ledongthuc/pdf"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// PDFText 直接读取PDF的文本层，不需要外部程序
+// 逐页判断是否有文本层：有文本层的页直接使用其中的文本，扫描页的质量分为0，由引擎链交给OCR引擎识别
+type PDFText struct{}
+
+// NewPDFText 创建读取PDF文本层的引擎
+func NewPDFText() *PDFText {
+	return &PDFText{}
+}
+
+// Name 返回引擎名称
+func (p *PDFText) Name() string {
+	return EnginePDFText
+}
+
+// ExtractTextFromPDF 读取PDF各页的文本层
+func (p *PDFText) ExtractTextFromPDF(ctx context.Context, pdfPath string) (string, error) {
+	pages, err := p.RecognizePages(ctx, pdfPath, nil)
+	return joinPages(pages), err
+}
+
+// ExtractTextFromImage 图像没有文本层，总是返回ErrUnsupportedFormat
+func (p *PDFText) ExtractTextFromImage(ctx context.Context, imagePath string) (string, error) {
+	_, err := p.RecognizePages(ctx, imagePath, nil)
+	return "", err
+}
+
+// RecognizePages 按页读取PDF的文本层，只支持PDF
+func (p *PDFText) RecognizePages(ctx context.Context, filePath string, pages []int) (result []Page, err error) {
+	kind, err := fileKind(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "pdf" {
+		return nil, fmt.Errorf("%w: 图像没有文本层", ErrUnsupportedFormat)
+	}
+
+	f, r, err := pdf.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("打开PDF失败: %w", err)
+	}
+	defer f.Close()
+
+	// 多页共用字体缓存，避免重复解析字符映射表
+	fonts := make(map[string]*pdf.Font)
+	for number := 1; number <= r.NumPage(); number++ {
+		if !wantPage(pages, number) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		page := r.Page(number)
+		for _, name := range page.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				font := page.Font(name)
+				fonts[name] = &font
+			}
+		}
+		text, err := readPageText(ctx, page, number, fonts)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, textLayerPage(number, text, hasImages(page)))
+	}
+	return result, nil
+}
+
+// textLayerPage 根据一页的文本层创建识别结果
+// 没有文本，或者有图像而文字少于minPageChars的页（如带页眉的扫描页）视为扫描页，质量分为0；
+// 其余的页直接使用文本层，质量分只看乱码的比例，不因文字少而降低，避免把文字少的页转换为图像识别
+func textLayerPage(number int, text string, images bool) Page {
+	score, valid := charScore(text)
+	if valid == 0 || (images && valid < minPageChars) {
+		score = 0
+	}
+	return Page{Number: number, Text: text, Quality: score, Engine: EnginePDFText}
+}
+
+// doOperator 匹配内容流中绘制XObject的Do操作符，如 /Im1 Do
+var doOperator = regexp.MustCompile(`/([^\s/\[\]<>()]+)\s+Do\b`)
+
+// hasImages 返回页面的内容流是否绘制了图像
+// 只看资源中是否有图像不够准确，很多PDF的各页共用同一份资源；PDF结构损坏时视为没有图像
+func hasImages(page pdf.Page) (drawn bool) {
+	defer func() {
+		if recover() != nil {
+			drawn = false
+		}
+	}()
+	xobjects := page.Resources().Key("XObject")
+	images := map[string]bool{}
+	for _, name := range xobjects.Keys() {
+		if xobjects.Key(name).Key("Subtype").Name() == "Image" {
+			images[name] = true
+		}
+	}
+	if len(images) == 0 {
+		return false
+	}
+
+	// 内容流可以是单个流，也可以是流的数组
+	contents := page.V.Key("Contents")
+	streams := []pdf.Value{contents}
+	if contents.Kind() == pdf.Array {
+		streams = streams[:0]
+		for i := 0; i < contents.Len(); i++ {
+			streams = append(streams, contents.Index(i))
+		}
+	}
+	for _, stream := range streams {
+		reader := stream.Reader()
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			continue
+		}
+		for _, match := range doOperator.FindAllSubmatch(data, -1) {
+			if images[string(match[1])] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readPageText 读取一页的文本层，每页记录一个span
+func readPageText(ctx context.Context, page pdf.Page, number int, fonts map[string]*pdf.Font) (text string, err error) {
+	_, span := tracing.Start(ctx, "ocr.page",
+		attribute.String("ocr.engine", EnginePDFText), attribute.Int("ocr.page", number))
+	defer func() {
+		span.SetAttributes(attribute.Int("ocr.text_length", len(text)))
+		tracing.End(span, err)
+	}()
+
+	text, err = page.GetPlainText(fonts)
+	if err != nil {
+		return "", fmt.Errorf("读取第%d页的文本层失败: %w", number, err)
+	}
+	return text, nil
+}
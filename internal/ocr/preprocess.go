@@ -0,0 +1,572 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/jpeg" // 注册JPEG解码器
+	"image/png"
+	"iter"
+	"math"
+	"os"
+
+	"github.com/10yihang/resume-ai-interview/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 图像预处理的参数
+const (
+	a4WidthInches   = 8.27        // 照片没有可信的分辨率信息时，按页面宽度为A4估计分辨率
+	minMetadataDPI  = 150         // 低于该值的分辨率信息视为相机写入的默认值（如72），不可信
+	minRescale      = 0.1         // 缩放比例与1相差不到该值时不缩放
+	maxPixels       = 40_000_000  // 缩放后的像素数上限，避免超大图像耗尽内存
+	maxDecodePixels = 100_000_000 // 解码前检查的像素数上限，超过时不预处理，避免解码时耗尽内存
+	sauvolaK        = 0.2         // Sauvola二值化的灵敏度，越大越多像素判为背景
+	sidewaysRatio   = 1.5         // 按列的墨迹分布比按行的起伏大这么多倍时，判定页面横置
+	maxSkewDegrees  = 10.0        // 检测的最大倾斜角度
+	minSkewDegrees  = 0.3         // 小于该角度的倾斜不纠正
+	maxSkewPoints   = 100_000     // 检测倾斜时采样的墨迹点数上限
+)
+
+// preprocessResult 记录预处理对图像做的调整
+type preprocessResult struct {
+	Rotation int     // 校正方向时顺时针旋转的角度：0、90、180或270
+	Skew     float64 // 检测到的倾斜角度（度），顺时针为正，已纠正
+	Scale    float64 // 缩放比例
+	DPI      int     // 处理后图像的分辨率
+}
+
+// preprocessImage 在OCR前处理图像文件，结果以PNG写入outPath：
+// 按EXIF方向转正、灰度化、缩放到targetDPI、Sauvola局部阈值二值化、把横置的页面转正、纠正倾斜
+// sourceDPI为0表示分辨率未知，从图像的元数据读取，没有可信的分辨率时按A4宽度估计
+func preprocessImage(ctx context.Context, inPath, outPath string, sourceDPI, targetDPI int) (result preprocessResult, err error) {
+	_, span := tracing.Start(ctx, "ocr.preprocess")
+	defer func() {
+		span.SetAttributes(attribute.Int("ocr.rotation", result.Rotation), attribute.Float64("ocr.skew", result.Skew),
+			attribute.Float64("ocr.scale", result.Scale), attribute.Int("ocr.dpi", result.DPI))
+		tracing.End(span, err)
+	}()
+
+	data, err := os.ReadFile(inPath)
+	if err != nil {
+		return result, fmt.Errorf("读取图像失败: %w", err)
+	}
+	// 先只读取文件头中的尺寸，像素过多的图像不解码，由调用方改用原图识别
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return result, fmt.Errorf("解码图像失败: %w", err)
+	}
+	if pixels := int64(config.Width) * int64(config.Height); pixels > maxDecodePixels {
+		return result, fmt.Errorf("图像过大: %dx%d超过%d像素", config.Width, config.Height, maxDecodePixels)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return result, fmt.Errorf("解码图像失败: %w", err)
+	}
+
+	// 手机照片通常按传感器方向保存像素，再用EXIF标记显示时的方向
+	page := grayscale(img)
+	if format == "jpeg" {
+		page, result.Rotation = orient(page, exifOrientation(data))
+	}
+
+	dpi := sourceDPI
+	if dpi == 0 {
+		dpi = metadataDPI(data, format)
+	}
+	if dpi < minMetadataDPI {
+		dpi = max(1, int(math.Round(float64(min(page.Rect.Dx(), page.Rect.Dy()))/a4WidthInches)))
+	}
+	result.Scale, result.DPI = 1, dpi
+	if scale := float64(targetDPI) / float64(dpi); math.Abs(scale-1) >= minRescale {
+		pixels := float64(page.Rect.Dx()) * float64(page.Rect.Dy()) * scale * scale
+		if pixels > maxPixels {
+			scale *= math.Sqrt(maxPixels / pixels)
+		}
+		page = resize(page, scale)
+		result.Scale, result.DPI = scale, int(math.Round(float64(dpi)*scale))
+	}
+
+	window := max(15, result.DPI/10) | 1 // 约0.1英寸，比正文的笔画宽得多
+	page = binarize(page, window)
+
+	if sideways(page) {
+		page = rotate(page, 90)
+		rotation := 90
+		if rightAligned(page) {
+			page = rotate(page, 180)
+			rotation = 270
+		}
+		result.Rotation = (result.Rotation + rotation) % 360
+	}
+
+	result.Skew = detectSkew(page)
+	if math.Abs(result.Skew) >= minSkewDegrees {
+		page = deskew(page, result.Skew)
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return result, fmt.Errorf("创建预处理图像失败: %w", err)
+	}
+	defer out.Close()
+	if err := png.Encode(out, page); err != nil {
+		return result, fmt.Errorf("写入预处理图像失败: %w", err)
+	}
+	return result, out.Close()
+}
+
+// grayscale 把图像转换为灰度图，透明的部分视为白色
+func grayscale(img image.Image) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	// JPEG的亮度分量就是灰度，直接复制
+	if ycc, ok := img.(*image.YCbCr); ok {
+		for y := 0; y < b.Dy(); y++ {
+			offset := ycc.YOffset(b.Min.X, b.Min.Y+y)
+			copy(gray.Pix[y*gray.Stride:y*gray.Stride+b.Dx()], ycc.Y[offset:offset+b.Dx()])
+		}
+		return gray
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			luma := (19595*r + 38470*g + 7471*bl + 1<<15) >> 16
+			gray.Pix[y*gray.Stride+x] = uint8((luma + 0xffff - a) >> 8)
+		}
+	}
+	return gray
+}
+
+// jpegSegments 依次返回JPEG中图像数据之前的各个段的标记和内容
+func jpegSegments(data []byte) iter.Seq2[byte, []byte] {
+	return func(yield func(byte, []byte) bool) {
+		if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+			return
+		}
+		for i := 2; i+4 <= len(data); {
+			if data[i] != 0xFF {
+				return
+			}
+			marker := data[i+1]
+			if marker == 0xFF { // 填充字节
+				i++
+				continue
+			}
+			if marker == 0xDA || marker == 0xD9 { // 图像数据开始，之后没有元数据
+				return
+			}
+			size := int(binary.BigEndian.Uint16(data[i+2:]))
+			if size < 2 || i+2+size > len(data) {
+				return
+			}
+			if !yield(marker, data[i+4:i+2+size]) {
+				return
+			}
+			i += 2 + size
+		}
+	}
+}
+
+// exifOrientation 返回JPEG的EXIF方向标签，取值1到8，没有时返回1
+func exifOrientation(data []byte) int {
+	for marker, segment := range jpegSegments(data) {
+		if marker != 0xE1 || !bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			continue
+		}
+		tiff := segment[6:]
+		if len(tiff) < 8 {
+			return 1
+		}
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return 1
+		}
+		offset := int(order.Uint32(tiff[4:]))
+		if offset < 0 || offset+2 > len(tiff) {
+			return 1
+		}
+		count := int(order.Uint16(tiff[offset:]))
+		for i := 0; i < count; i++ {
+			entry := offset + 2 + i*12
+			if entry+12 > len(tiff) {
+				return 1
+			}
+			if order.Uint16(tiff[entry:]) == 0x0112 {
+				if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+					return orientation
+				}
+				return 1
+			}
+		}
+		return 1
+	}
+	return 1
+}
+
+// metadataDPI 返回图像元数据中的分辨率，JPEG读取JFIF段，PNG读取pHYs块，没有时返回0
+func metadataDPI(data []byte, format string) int {
+	switch format {
+	case "jpeg":
+		for marker, segment := range jpegSegments(data) {
+			if marker != 0xE0 || len(segment) < 12 || !bytes.HasPrefix(segment, []byte("JFIF\x00")) {
+				continue
+			}
+			density := float64(binary.BigEndian.Uint16(segment[8:]))
+			switch segment[7] {
+			case 1: // 每英寸
+				return int(density)
+			case 2: // 每厘米
+				return int(math.Round(density * 2.54))
+			}
+			return 0
+		}
+	case "png":
+		for i := 8; i+8 <= len(data); {
+			length := int(binary.BigEndian.Uint32(data[i:]))
+			kind := string(data[i+4 : i+8])
+			if kind == "IDAT" || length < 0 || i+12+length > len(data) {
+				return 0
+			}
+			if kind == "pHYs" && length == 9 && data[i+16] == 1 { // 单位为米
+				return int(math.Round(float64(binary.BigEndian.Uint32(data[i+8:])) * 0.0254))
+			}
+			i += 12 + length
+		}
+	}
+	return 0
+}
+
+// orient 按EXIF方向标签把图像转正，返回转正后的图像和顺时针旋转的角度，镜像的方向同时水平翻转
+func orient(gray *image.Gray, orientation int) (*image.Gray, int) {
+	switch orientation {
+	case 2:
+		return flip(gray), 0
+	case 3:
+		return rotate(gray, 180), 180
+	case 4:
+		return flip(rotate(gray, 180)), 180
+	case 5:
+		return flip(rotate(gray, 90)), 90
+	case 6:
+		return rotate(gray, 90), 90
+	case 7:
+		return flip(rotate(gray, 270)), 270
+	case 8:
+		return rotate(gray, 270), 270
+	default:
+		return gray, 0
+	}
+}
+
+// rotate 把图像顺时针旋转degrees度，degrees为90的整数倍
+func rotate(gray *image.Gray, degrees int) *image.Gray {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	var out *image.Gray
+	switch (degrees%360 + 360) % 360 {
+	case 90:
+		out = image.NewGray(image.Rect(0, 0, h, w))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				out.Pix[x*out.Stride+h-1-y] = gray.Pix[y*gray.Stride+x]
+			}
+		}
+	case 180:
+		out = image.NewGray(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				out.Pix[(h-1-y)*out.Stride+w-1-x] = gray.Pix[y*gray.Stride+x]
+			}
+		}
+	case 270:
+		out = image.NewGray(image.Rect(0, 0, h, w))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				out.Pix[(w-1-x)*out.Stride+y] = gray.Pix[y*gray.Stride+x]
+			}
+		}
+	default:
+		return gray
+	}
+	return out
+}
+
+// flip 水平翻转图像
+func flip(gray *image.Gray) *image.Gray {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			out.Pix[y*out.Stride+w-1-x] = gray.Pix[y*gray.Stride+x]
+		}
+	}
+	return out
+}
+
+// resize 把图像缩放为原来的scale倍，缩小时取覆盖区域的平均值，放大时双线性插值
+func resize(gray *image.Gray, scale float64) *image.Gray {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	nw, nh := max(1, int(math.Round(float64(w)*scale))), max(1, int(math.Round(float64(h)*scale)))
+	out := image.NewGray(image.Rect(0, 0, nw, nh))
+	if scale < 1 {
+		for y := 0; y < nh; y++ {
+			y0 := y * h / nh
+			y1 := max(y0+1, (y+1)*h/nh)
+			for x := 0; x < nw; x++ {
+				x0 := x * w / nw
+				x1 := max(x0+1, (x+1)*w/nw)
+				sum := 0
+				for sy := y0; sy < y1; sy++ {
+					for _, v := range gray.Pix[sy*gray.Stride+x0 : sy*gray.Stride+x1] {
+						sum += int(v)
+					}
+				}
+				out.Pix[y*out.Stride+x] = uint8(sum / ((y1 - y0) * (x1 - x0)))
+			}
+		}
+		return out
+	}
+
+	for y := 0; y < nh; y++ {
+		sy := min(max((float64(y)+0.5)/scale-0.5, 0), float64(h-1))
+		y0 := int(sy)
+		y1, fy := min(y0+1, h-1), sy-float64(y0)
+		for x := 0; x < nw; x++ {
+			sx := min(max((float64(x)+0.5)/scale-0.5, 0), float64(w-1))
+			x0 := int(sx)
+			x1, fx := min(x0+1, w-1), sx-float64(x0)
+			top := float64(gray.Pix[y0*gray.Stride+x0])*(1-fx) + float64(gray.Pix[y0*gray.Stride+x1])*fx
+			bottom := float64(gray.Pix[y1*gray.Stride+x0])*(1-fx) + float64(gray.Pix[y1*gray.Stride+x1])*fx
+			out.Pix[y*out.Stride+x] = uint8(math.Round(top*(1-fy) + bottom*fy))
+		}
+	}
+	return out
+}
+
+// binarize 用Sauvola局部阈值把灰度图转换为黑白图，文字为0，背景为255
+// 阈值由window×window邻域的均值和标准差决定，光照不均和低对比度的照片也能分开文字和背景；
+// 按行滑动维护各列的和，内存只与图像宽度成正比
+func binarize(gray *image.Gray, window int) *image.Gray {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	r := window / 2
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	colSum := make([]int64, w)
+	colSq := make([]int64, w)
+	addRow := func(y int, sign int64) {
+		for x, v := range gray.Pix[y*gray.Stride : y*gray.Stride+w] {
+			colSum[x] += sign * int64(v)
+			colSq[x] += sign * int64(v) * int64(v)
+		}
+	}
+	for y := 0; y <= min(r, h-1); y++ {
+		addRow(y, 1)
+	}
+
+	for y := 0; y < h; y++ {
+		if y > 0 {
+			if y+r < h {
+				addRow(y+r, 1)
+			}
+			if y-r-1 >= 0 {
+				addRow(y-r-1, -1)
+			}
+		}
+		rows := min(y+r, h-1) - max(y-r, 0) + 1
+
+		var sum, sq int64
+		for x := 0; x <= min(r, w-1); x++ {
+			sum += colSum[x]
+			sq += colSq[x]
+		}
+		for x := 0; x < w; x++ {
+			if x > 0 {
+				if x+r < w {
+					sum += colSum[x+r]
+					sq += colSq[x+r]
+				}
+				if x-r-1 >= 0 {
+					sum -= colSum[x-r-1]
+					sq -= colSq[x-r-1]
+				}
+			}
+			n := float64(rows * (min(x+r, w-1) - max(x-r, 0) + 1))
+			mean := float64(sum) / n
+			std := math.Sqrt(max(0, float64(sq)/n-mean*mean))
+			threshold := mean * (1 + sauvolaK*(std/128-1))
+			if float64(gray.Pix[y*gray.Stride+x]) > threshold {
+				out.Pix[y*out.Stride+x] = 255
+			}
+		}
+	}
+	return out
+}
+
+// profiles 返回黑白图按行和按列统计的墨迹像素数，只统计墨迹所在的矩形，不受页边距影响
+func profiles(bw *image.Gray) (rows, cols []float64) {
+	w, h := bw.Rect.Dx(), bw.Rect.Dy()
+	rows, cols = make([]float64, h), make([]float64, w)
+	for y := 0; y < h; y++ {
+		for x, v := range bw.Pix[y*bw.Stride : y*bw.Stride+w] {
+			if v == 0 {
+				rows[y]++
+				cols[x]++
+			}
+		}
+	}
+	return trim(rows), trim(cols)
+}
+
+// trim 去掉投影两端为0的部分
+func trim(profile []float64) []float64 {
+	start, end := 0, len(profile)
+	for start < end && profile[start] == 0 {
+		start++
+	}
+	for end > start && profile[end-1] == 0 {
+		end--
+	}
+	return profile[start:end]
+}
+
+// spread 返回投影的起伏程度：平方和与和的平方之比乘以长度，均匀分布时为1，文字行与行距交替时较大
+func spread(profile []float64) float64 {
+	var sum, sq float64
+	for _, v := range profile {
+		sum += v
+		sq += v * v
+	}
+	if sum == 0 {
+		return 0
+	}
+	return sq / (sum * sum) * float64(len(profile))
+}
+
+// sideways 返回页面是否横置：文字行竖直排列时，按列统计的墨迹起伏明显大于按行统计的
+func sideways(bw *image.Gray) bool {
+	rows, cols := profiles(bw)
+	return spread(cols) > sidewaysRatio*spread(rows)
+}
+
+// rightAligned 返回各行文字是否右端对齐而左端参差，即页面上下颠倒
+// 简历和大多数文档左对齐，横置的页面转正后如果变成右对齐，说明转反了
+func rightAligned(bw *image.Gray) bool {
+	w, h := bw.Rect.Dx(), bw.Rect.Dy()
+	var lefts, rights []int
+	left, right := w, -1
+	for y := 0; y <= h; y++ {
+		ink := false
+		if y < h {
+			for x, v := range bw.Pix[y*bw.Stride : y*bw.Stride+w] {
+				if v == 0 {
+					ink = true
+					left, right = min(left, x), max(right, x)
+				}
+			}
+		}
+		// 一行文字结束
+		if !ink && right >= 0 {
+			lefts, rights = append(lefts, left), append(rights, w-1-right)
+			left, right = w, -1
+		}
+	}
+	tolerance := max(1, w/50)
+	return aligned(rights, tolerance) > aligned(lefts, tolerance)
+}
+
+// aligned 返回与最小边距相差不超过tolerance的行数
+func aligned(margins []int, tolerance int) int {
+	if len(margins) == 0 {
+		return 0
+	}
+	least := margins[0]
+	for _, m := range margins {
+		least = min(least, m)
+	}
+	count := 0
+	for _, m := range margins {
+		if m-least <= tolerance {
+			count++
+		}
+	}
+	return count
+}
+
+// detectSkew 用投影法检测文字行的倾斜角度（度），顺时针为正
+// 按候选角度投影墨迹点，文字行与投影方向一致时各行的墨迹集中，平方和最大；先粗后细搜索
+func detectSkew(bw *image.Gray) float64 {
+	w, h := bw.Rect.Dx(), bw.Rect.Dy()
+	var total int
+	for _, v := range bw.Pix {
+		if v == 0 {
+			total++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	step := max(1, int(math.Ceil(math.Sqrt(float64(total)/maxSkewPoints))))
+	var points [][2]float64
+	for y := 0; y < h; y += step {
+		for x := 0; x < w; x += step {
+			if bw.Pix[y*bw.Stride+x] == 0 {
+				points = append(points, [2]float64{float64(x), float64(y)})
+			}
+		}
+	}
+
+	// 投影的范围在-w到h+w之间；每step个像素一格，与采样间隔一致，避免0度附近只落在部分格中而得分偏高
+	bins := make([]float64, 2*w+h+2)
+	size := float64(step)
+	score := func(degrees float64) float64 {
+		clear(bins)
+		sin, cos := math.Sincos(degrees * math.Pi / 180)
+		for _, p := range points {
+			bins[int(math.Floor((p[1]*cos-p[0]*sin)/size))+w+1]++
+		}
+		var sq float64
+		for _, c := range bins {
+			sq += c * c
+		}
+		return sq
+	}
+	search := func(from, to, step float64) float64 {
+		best, bestScore := 0.0, -1.0
+		for degrees := from; degrees <= to+step/2; degrees += step {
+			if s := score(degrees); s > bestScore {
+				best, bestScore = degrees, s
+			}
+		}
+		return best
+	}
+	coarse := search(-maxSkewDegrees, maxSkewDegrees, 0.5)
+	return math.Round(search(coarse-0.5, coarse+0.5, 0.1)*10) / 10
+}
+
+// deskew 把图像逆时针旋转degrees度以纠正倾斜，图像大小不变，旋出的部分填充白色
+func deskew(bw *image.Gray, degrees float64) *image.Gray {
+	w, h := bw.Rect.Dx(), bw.Rect.Dy()
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	cx, cy := float64(w)/2, float64(h)/2
+	for y := 0; y < h; y++ {
+		dy := float64(y) + 0.5 - cy
+		for x := 0; x < w; x++ {
+			dx := float64(x) + 0.5 - cx
+			sx := int(math.Floor(cx + dx*cos - dy*sin))
+			sy := int(math.Floor(cy + dx*sin + dy*cos))
+			value := uint8(255)
+			if sx >= 0 && sx < w && sy >= 0 && sy < h {
+				value = bw.Pix[sy*bw.Stride+sx]
+			}
+			out.Pix[y*out.Stride+x] = value
+		}
+	}
+	return out
+}
@@ -2,21 +2,55 @@
 package ocr
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
-	"time"
+	"sync"
+
+	"github.com/10yihang/resume-ai-interview/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Tesseract的输出格式
+const (
+	OutputText = "text" // 纯文本，由Tesseract决定阅读顺序
+	OutputTSV  = "tsv"  // 带位置和置信度的词表，按版面重建阅读顺序
+	OutputHOCR = "hocr" // 带位置和置信度的HTML，按版面重建阅读顺序
 )
 
+// TesseractOptions 是Tesseract的识别参数
+type TesseractOptions struct {
+	Languages string // 语言包，多个用+连接，如chi_sim+eng
+	PSM       int    // 页面分割模式（--psm），3为全自动分割
+	OEM       int    // 识别引擎模式（--oem），3为默认
+	DPI       int    // pdftoppm把PDF转换为图像的分辨率
+	Output    string // 输出格式：text、tsv或hocr
+	Workers   int    // 同时识别的页数上限
+	// Preprocess 识别前是否预处理图像：转正、灰度化、缩放到DPI、二值化和纠偏，见preprocessImage
+	Preprocess bool
+}
+
+// DefaultTesseractOptions 返回默认的识别参数：中英文、全自动分割、300DPI，按TSV输出重建版面，同时识别2页，识别前预处理图像
+func DefaultTesseractOptions() TesseractOptions {
+	return TesseractOptions{Languages: "chi_sim+eng", PSM: 3, OEM: 3, DPI: 300, Output: OutputTSV, Workers: 2, Preprocess: true}
+}
+
 // TesseractOCR 使用Tesseract OCR进行文字识别
 type TesseractOCR struct {
 	tesseractPath string // Tesseract可执行文件路径
 	tempDir       string // 临时文件目录
+	options       TesseractOptions
 }
 
-// NewTesseractOCR 创建一个新的TesseractOCR实例
+// NewTesseractOCR 创建一个新的TesseractOCR实例，使用DefaultTesseractOptions
 func NewTesseractOCR(tesseractPath string) *TesseractOCR {
 	// 如果未指定路径，尝试使用默认路径
 	if tesseractPath == "" {
@@ -26,105 +60,337 @@ func NewTesseractOCR(tesseractPath string) *TesseractOCR {
 	return &TesseractOCR{
 		tesseractPath: tesseractPath,
 		tempDir:       os.TempDir(),
+		options:       DefaultTesseractOptions(),
 	}
 }
 
-// ExtractTextFromPDF 从PDF文件中提取文本
-func (t *TesseractOCR) ExtractTextFromPDF(pdfPath string) (string, error) {
-	// 第1步：确认Tesseract是否已安装
-	err := t.checkTesseractInstallation()
-	if err != nil {
-		return "", fmt.Errorf("Tesseract OCR未正确安装: %w", err)
+// WithOptions 设置识别参数，Languages、PSM、DPI、Output和Workers为零值时使用默认值，OEM和Preprocess按给定的值
+func (t *TesseractOCR) WithOptions(options TesseractOptions) *TesseractOCR {
+	defaults := DefaultTesseractOptions()
+	if options.Languages == "" {
+		options.Languages = defaults.Languages
+	}
+	if options.PSM == 0 {
+		options.PSM = defaults.PSM
+	}
+	if options.DPI == 0 {
+		options.DPI = defaults.DPI
 	}
+	if options.Output == "" {
+		options.Output = defaults.Output
+	}
+	if options.Workers <= 0 {
+		options.Workers = defaults.Workers
+	}
+	t.options = options
+	return t
+}
 
-	// 第2步：将PDF转换为图像（需要使用额外的工具如pdftoppm或Ghostscript）
-	// 这里使用了临时目录
-	timestamp := time.Now().UnixNano()
-	tempImagePrefix := filepath.Join(t.tempDir, fmt.Sprintf("pdf_image_%d", timestamp))
+// Name 返回引擎名称
+func (t *TesseractOCR) Name() string {
+	return EngineTesseract
+}
 
-	// 调用PDF转图像工具（默认使用pdftoppm）
-	// pdftoppm是一个常见工具，通常安装了poppler-utils就会有
-	cmd := exec.Command("pdftoppm", "-png", pdfPath, tempImagePrefix)
-	err = cmd.Run()
+// ExtractTextFromPDF 从PDF文件中提取文本
+func (t *TesseractOCR) ExtractTextFromPDF(ctx context.Context, pdfPath string) (string, error) {
+	pages, err := t.RecognizePages(ctx, pdfPath, nil)
+	return joinPages(pages), err
+}
+
+// ExtractTextFromImage 从图像文件中提取文本
+func (t *TesseractOCR) ExtractTextFromImage(ctx context.Context, imagePath string) (string, error) {
+	pages, err := t.RecognizePages(ctx, imagePath, nil)
+	return joinPages(pages), err
+}
+
+// RecognizePages 识别PDF或图像中的指定页，PDF先用pdftoppm转换为图像
+func (t *TesseractOCR) RecognizePages(ctx context.Context, filePath string, pages []int) ([]Page, error) {
+	kind, err := fileKind(filePath)
 	if err != nil {
-		return "", fmt.Errorf("将PDF转换为图像失败: %w", err)
+		return nil, err
+	}
+	if kind == "image" && !wantPage(pages, 1) {
+		return nil, nil
 	}
 
-	// 第3步：使用Tesseract OCR处理所有生成的图像文件
-	var allText strings.Builder
-
-	// 查找所有生成的PNG文件
-	imagePattern := tempImagePrefix + "*.png"
-	imageFiles, err := filepath.Glob(imagePattern)
+	// 第1步：确认Tesseract是否已安装
+	err = t.checkTesseractInstallation(ctx)
 	if err != nil {
-		return "", fmt.Errorf("查找生成的图像文件失败: %w", err)
+		return nil, fmt.Errorf("%w: Tesseract OCR未正确安装: %w", ErrUnavailable, err)
 	}
 
-	// 处理每个图像文件
-	for _, imgFile := range imageFiles {
-		// 为每个图像创建一个临时输出文件
-		outputBase := imgFile + "_ocr"
-		outputFile := outputBase + ".txt"
+	// 每次调用使用独立的临时目录，无论成功与否都整体删除，避免出错时遗留图像和识别结果
+	workDir, err := os.MkdirTemp(t.tempDir, kind+"_ocr_")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(workDir)
 
-		// 执行Tesseract OCR
-		cmd = exec.Command(t.tesseractPath, imgFile, outputBase)
-		err = cmd.Run()
+	// 图像只有一页，分辨率由图像本身决定
+	if kind == "image" {
+		page, err := t.recognizePage(ctx, filePath, filepath.Join(workDir, "output"), 1, 0)
 		if err != nil {
-			return "", fmt.Errorf("在图像上执行OCR失败: %w", err)
+			return nil, err
 		}
+		return []Page{page}, nil
+	}
 
-		// 读取OCR结果
-		textBytes, err := os.ReadFile(outputFile)
+	// 第2步：将PDF转换为图像（需要使用额外的工具如pdftoppm或Ghostscript）
+	// pdftoppm是一个常见工具，通常安装了poppler-utils就会有
+	var tasks []pageTask
+	if pages == nil {
+		// 识别全部页时一次转换整个文件，再并发识别各页
+		tempImagePrefix := filepath.Join(workDir, "page")
+		if err := t.renderPages(ctx, t.pdftoppmArgs(filePath, tempImagePrefix)...); err != nil {
+			return nil, err
+		}
+		images, err := pageImages(tempImagePrefix)
 		if err != nil {
-			return "", fmt.Errorf("读取OCR结果失败: %w", err)
+			return nil, err
+		}
+		for _, image := range images {
+			tasks = append(tasks, func(ctx context.Context) (Page, error) {
+				return t.recognizePage(ctx, image.path, image.path+"_ocr", image.number, t.options.DPI)
+			})
 		}
+	}
+	for _, number := range pages {
+		// 只识别部分页时每页单独转换，各页使用不同的文件名前缀，避免并发时互相干扰
+		tasks = append(tasks, func(ctx context.Context) (Page, error) {
+			prefix := filepath.Join(workDir, fmt.Sprintf("p%d", number))
+			if err := t.renderPages(ctx, t.pdftoppmArgs(filePath, prefix, number)...); err != nil {
+				return Page{}, err
+			}
+			images, err := pageImages(prefix)
+			if err != nil {
+				return Page{}, err
+			}
+			if len(images) == 0 {
+				return Page{}, fmt.Errorf("PDF没有第%d页", number)
+			}
+			return t.recognizePage(ctx, images[0].path, images[0].path+"_ocr", number, t.options.DPI)
+		})
+	}
+
+	// 第3步：使用Tesseract OCR并发处理各页
+	return t.recognizeAll(ctx, tasks)
+}
+
+// pageTask 转换并识别一页，返回识别结果
+type pageTask func(ctx context.Context) (Page, error)
 
-		// 追加到总文本
-		allText.Write(textBytes)
-		allText.WriteString("\n")
+// recognizeAll 以最多Workers个并发执行tasks，结果按tasks的顺序即页号排列
+// 任一页失败时取消其余页并终止进行中的pdftoppm和tesseract子进程，返回最先发生的错误；调用方取消ctx时同样终止
+func (t *TesseractOCR) recognizeAll(ctx context.Context, tasks []pageTask) ([]Page, error) {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-		// 清理临时文件
-		os.Remove(outputFile)
-		os.Remove(imgFile)
+	results := make([]Page, len(tasks))
+	sem := make(chan struct{}, t.options.Workers)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
 	}
 
-	return allText.String(), nil
+	for i, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			// 等待期间其他页已经失败时不再开始
+			if ctx.Err() != nil {
+				return
+			}
+			page, err := task(ctx)
+			if err != nil {
+				fail(err)
+				return
+			}
+			results[i] = page
+		}()
+	}
+	wg.Wait()
+
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
 }
 
-// ExtractTextFromImage 从图像文件中提取文本
-func (t *TesseractOCR) ExtractTextFromImage(imagePath string) (string, error) {
-	// 确认Tesseract是否已安装
-	err := t.checkTesseractInstallation()
+// pdftoppmArgs 返回pdftoppm的参数，指定page时只转换该页
+func (t *TesseractOCR) pdftoppmArgs(pdfPath, prefix string, page ...int) []string {
+	args := []string{"-png", "-r", strconv.Itoa(t.options.DPI)}
+	for _, p := range page {
+		args = append(args, "-f", strconv.Itoa(p), "-l", strconv.Itoa(p))
+	}
+	return append(args, pdfPath, prefix)
+}
+
+// renderPages 调用pdftoppm把PDF转换为图像，记录一个span
+func (t *TesseractOCR) renderPages(ctx context.Context, args ...string) error {
+	_, span := tracing.Start(ctx, "ocr.pdftoppm")
+	err := exec.CommandContext(ctx, "pdftoppm", args...).Run()
+	tracing.End(span, err)
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%w: 未安装pdftoppm", ErrUnavailable)
+	}
 	if err != nil {
-		return "", fmt.Errorf("Tesseract OCR未正确安装: %w", err)
+		return fmt.Errorf("将PDF转换为图像失败: %w", err)
 	}
+	return nil
+}
 
-	// 创建临时输出文件
-	timestamp := time.Now().UnixNano()
-	outputBase := filepath.Join(t.tempDir, fmt.Sprintf("img_ocr_%d", timestamp))
-	outputFile := outputBase + ".txt"
+// pageImage 是pdftoppm转换出的一页图像
+type pageImage struct {
+	path   string
+	number int
+}
 
-	// 执行Tesseract OCR
-	cmd := exec.Command(t.tesseractPath, imagePath, outputBase)
-	err = cmd.Run()
+// pageImages 查找pdftoppm生成的图像，从文件名prefix-01.png中解析页号，按页号排序
+func pageImages(prefix string) ([]pageImage, error) {
+	paths, err := filepath.Glob(prefix + "-*.png")
 	if err != nil {
-		return "", fmt.Errorf("执行OCR失败: %w", err)
+		return nil, fmt.Errorf("查找生成的图像文件失败: %w", err)
+	}
+	images := make([]pageImage, 0, len(paths))
+	for _, path := range paths {
+		number, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path, prefix+"-"), ".png"))
+		if err != nil {
+			return nil, fmt.Errorf("无法从图像文件名%s中解析页号", filepath.Base(path))
+		}
+		images = append(images, pageImage{path: path, number: number})
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].number < images[j].number })
+	return images, nil
+}
+
+// tesseractArgs 返回识别一张图像的参数，dpi为0时由Tesseract从图像中读取
+func (t *TesseractOCR) tesseractArgs(imgFile, outputBase string, dpi int) []string {
+	args := []string{imgFile, outputBase,
+		"-l", t.options.Languages, "--psm", strconv.Itoa(t.options.PSM), "--oem", strconv.Itoa(t.options.OEM)}
+	if dpi > 0 {
+		args = append(args, "--dpi", strconv.Itoa(dpi))
+	}
+	if t.options.Output != OutputText {
+		args = append(args, t.options.Output)
+	}
+	return args
+}
+
+// recognizePage 对一页图像执行OCR，每页记录一个span
+// 输出TSV或hOCR时按版面重建阅读顺序，质量分取文本质量和Tesseract平均置信度中较低的一个
+func (t *TesseractOCR) recognizePage(ctx context.Context, imgFile, outputBase string, number, dpi int) (page Page, err error) {
+	ctx, span := tracing.Start(ctx, "ocr.page",
+		attribute.String("ocr.engine", EngineTesseract), attribute.Int("ocr.page", number))
+	defer func() {
+		span.SetAttributes(attribute.Int("ocr.text_length", len(page.Text)), attribute.Float64("ocr.quality", page.Quality))
+		tracing.End(span, err)
+	}()
+
+	if t.options.Preprocess {
+		imgFile, dpi = t.preprocess(ctx, imgFile, outputBase, dpi)
+	}
+
+	// 执行Tesseract OCR
+	cmd := exec.CommandContext(ctx, t.tesseractPath, t.tesseractArgs(imgFile, outputBase, dpi)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return Page{}, fmt.Errorf("在图像上执行OCR失败: %w: %s", err, strings.TrimSpace(string(output)))
 	}
 
 	// 读取OCR结果
-	textBytes, err := os.ReadFile(outputFile)
+	outputFile := outputBase + ".txt"
+	if t.options.Output != OutputText {
+		outputFile = outputBase + "." + t.options.Output
+	}
+	data, err := os.ReadFile(outputFile)
 	if err != nil {
-		return "", fmt.Errorf("读取OCR结果失败: %w", err)
+		return Page{}, fmt.Errorf("读取OCR结果失败: %w", err)
+	}
+	if t.options.Output == OutputText {
+		return newPage(number, string(data), EngineTesseract), nil
 	}
 
-	// 清理临时文件
-	os.Remove(outputFile)
+	var words *layout
+	if t.options.Output == OutputHOCR {
+		words, err = parseHOCR(bytes.NewReader(data))
+	} else {
+		words, err = parseTSV(bytes.NewReader(data))
+	}
+	if err != nil {
+		return Page{}, err
+	}
+	page = newPage(number, words.text(), EngineTesseract)
+	page.Quality = min(page.Quality, words.confidence())
+	return page, nil
+}
 
-	return string(textBytes), nil
+// preprocess 预处理页面图像，返回处理后的图像和分辨率，dpi为0表示分辨率未知
+// 预处理失败（如图像格式无法解码）时记录警告，仍用原图识别
+func (t *TesseractOCR) preprocess(ctx context.Context, imgFile, outputBase string, dpi int) (string, int) {
+	processed := outputBase + "_pre.png"
+	result, err := preprocessImage(ctx, imgFile, processed, dpi, t.options.DPI)
+	if err != nil {
+		slog.WarnContext(ctx, "图像预处理失败，使用原图识别", "file", filepath.Base(imgFile), "error", err)
+		return imgFile, dpi
+	}
+	slog.DebugContext(ctx, "图像预处理完成", "file", filepath.Base(imgFile),
+		"rotation", result.Rotation, "skew", result.Skew, "scale", result.Scale, "dpi", result.DPI)
+	return processed, result.DPI
 }
 
 // checkTesseractInstallation 检查Tesseract OCR是否已安装
-func (t *TesseractOCR) checkTesseractInstallation() error {
-	cmd := exec.Command(t.tesseractPath, "--version")
+func (t *TesseractOCR) checkTesseractInstallation(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, t.tesseractPath, "--version")
 	return cmd.Run()
 }
+
+// Version 返回Tesseract的版本号，用于诊断
+func (t *TesseractOCR) Version(ctx context.Context) (string, error) {
+	output, err := exec.CommandContext(ctx, t.tesseractPath, "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("执行%s失败: %w", t.tesseractPath, err)
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(line), nil
+}
+
+// Languages 返回Tesseract已安装的语言包，如chi_sim、eng
+func (t *TesseractOCR) Languages(ctx context.Context) ([]string, error) {
+	output, err := exec.CommandContext(ctx, t.tesseractPath, "--list-langs").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("列出Tesseract语言包失败: %w", err)
+	}
+	// 第一行是"List of available languages ..."说明
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	languages := []string{}
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line != "" {
+			languages = append(languages, line)
+		}
+	}
+	return languages, nil
+}
+
+// PDFToPPMVersion 返回PDF转图像工具pdftoppm的版本号，未安装时返回错误
+func PDFToPPMVersion(ctx context.Context) (string, error) {
+	output, err := exec.CommandContext(ctx, "pdftoppm", "-v").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("执行pdftoppm失败: %w", err)
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(line), nil
+}
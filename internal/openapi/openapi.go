@@ -0,0 +1,427 @@
+// Package openapi 根据路由表生成OpenAPI 3文档
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Document 是OpenAPI 3文档的根对象
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]*PathItem  `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+}
+
+// Info 描述API的基本信息
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem 描述一个路径上的所有操作，键为小写的HTTP方法
+type PathItem map[string]*Operation
+
+// Operation 描述一个API操作
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+	// Security 为空列表时表示该操作不需要认证，为nil时使用文档级的要求
+	Security *[]SecurityRequirement `json:"security,omitempty"`
+	// Roles 是允许调用该操作的角色，为空表示不限角色
+	Roles []string `json:"x-roles,omitempty"`
+}
+
+// Parameter 描述路径或查询参数
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody 描述请求体
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response 描述一种响应
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType 描述某种内容类型的数据结构
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components 保存可复用的数据结构和认证方式
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme 描述一种认证方式
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// SecurityRequirement 列出需要满足的认证方式，键为认证方式名
+type SecurityRequirement map[string][]string
+
+// Schema 是JSON Schema的子集
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Additional  *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Param 描述路由的查询参数，路径参数会从路径中自动提取
+type Param struct {
+	Name        string
+	Description string
+	Type        string // string 或 integer，默认为string
+	Required    bool
+}
+
+// FormField 描述multipart表单中的字段
+type FormField struct {
+	Name        string
+	Description string
+	File        bool
+	Required    bool
+}
+
+// Route 描述一个API路由，用于生成文档
+type Route struct {
+	Method      string
+	Path        string // gin格式的路径，如 /resumes/:id
+	OperationID string
+	Summary     string
+	Tag         string
+	Query       []Param
+	Request     any         // JSON请求体的示例值，nil表示没有JSON请求体
+	Form        []FormField // multipart表单字段
+	Response    any         // 成功响应体的示例值，nil表示没有响应体
+	List        bool        // 响应为分页列表，元素类型为Response
+	Status      int         // 成功时的状态码，默认200
+	Errors      []int       // 可能返回的错误状态码
+	Public      bool        // 无需认证即可访问
+	Roles       []string    // 允许访问的角色，为空表示不限角色
+}
+
+// Builder 根据路由逐步构建文档
+type Builder struct {
+	doc *Document
+	// names 记录Go类型对应的组件名，避免同名类型互相覆盖
+	names map[reflect.Type]string
+}
+
+// NewBuilder 创建文档构建器，errorBody为所有错误响应共用的结构
+func NewBuilder(title, version, description string, errorBody any) *Builder {
+	b := &Builder{
+		doc: &Document{
+			OpenAPI: "3.0.3",
+			Info:    Info{Title: title, Version: version, Description: description},
+			Paths:   make(map[string]*PathItem),
+			Components: Components{
+				Schemas: make(map[string]*Schema),
+			},
+		},
+		names: make(map[reflect.Type]string),
+	}
+	b.doc.Components.Schemas["Error"] = b.SchemaOf(reflect.TypeOf(errorBody))
+	return b
+}
+
+// SetSecurity 注册认证方式，满足其中任意一种即可访问非公开接口
+// 需要在Add之前调用，之后添加的非公开接口会自动带上401响应
+func (b *Builder) SetSecurity(schemes map[string]SecurityScheme) {
+	b.doc.Components.SecuritySchemes = schemes
+	b.doc.Security = nil
+
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.doc.Security = append(b.doc.Security, SecurityRequirement{name: {}})
+	}
+}
+
+// Add 添加一个路由，basePath会加在路由路径之前
+func (b *Builder) Add(basePath string, r Route) {
+	path := basePath + toOpenAPIPath(r.Path)
+	item, ok := b.doc.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		b.doc.Paths[path] = item
+	}
+
+	op := &Operation{
+		OperationID: r.OperationID,
+		Summary:     r.Summary,
+		Responses:   make(map[string]Response),
+	}
+	if r.Tag != "" {
+		op.Tags = []string{r.Tag}
+	}
+	if r.Public {
+		op.Security = &[]SecurityRequirement{}
+	}
+	op.Roles = r.Roles
+
+	for _, name := range pathParams(r.Path) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	for _, p := range r.Query {
+		typ := p.Type
+		if typ == "" {
+			typ = "string"
+		}
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:        p.Name,
+			In:          "query",
+			Required:    p.Required,
+			Description: p.Description,
+			Schema:      &Schema{Type: typ},
+		})
+	}
+
+	if r.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				"application/json": {Schema: b.SchemaOf(reflect.TypeOf(r.Request))},
+			},
+		}
+	} else if len(r.Form) > 0 {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				"multipart/form-data": {Schema: formSchema(r.Form)},
+			},
+		}
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	if r.Response != nil {
+		schema := b.SchemaOf(reflect.TypeOf(r.Response))
+		if r.List {
+			schema = pageSchema(schema)
+		}
+		success.Content = map[string]MediaType{"application/json": {Schema: schema}}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+
+	errs := r.Errors
+	if len(r.Roles) > 0 {
+		errs = append([]int{http.StatusForbidden}, errs...)
+	}
+	if !r.Public && len(b.doc.Security) > 0 {
+		errs = append([]int{http.StatusUnauthorized}, errs...)
+	}
+	for _, code := range errs {
+		op.Responses[strconv.Itoa(code)] = Response{
+			Description: http.StatusText(code),
+			Content: map[string]MediaType{
+				"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}},
+			},
+		}
+	}
+
+	(*item)[strings.ToLower(r.Method)] = op
+}
+
+// Document 返回构建好的文档
+func (b *Builder) Document() *Document {
+	return b.doc
+}
+
+// SchemaOf 生成Go类型对应的结构，具名结构体注册为组件并返回引用
+func (b *Builder) SchemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.SchemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", Additional: b.SchemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name, ok := b.names[t]
+		if !ok {
+			name = b.componentName(t)
+			b.names[t] = name
+			// 先占位，防止递归类型无限展开
+			b.doc.Components.Schemas[name] = &Schema{Type: "object"}
+			b.doc.Components.Schemas[name] = b.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		// json.RawMessage、interface等任意JSON值
+		return &Schema{}
+	}
+}
+
+// componentName 生成不重复的组件名
+func (b *Builder) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := b.doc.Components.Schemas[name]; !taken {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := name + strconv.Itoa(i)
+		if _, taken := b.doc.Components.Schemas[candidate]; !taken {
+			return candidate
+		}
+	}
+}
+
+// structSchema 根据结构体的json标签生成对象结构
+// 带有binding:"required"标签的字段列为必填
+func (b *Builder) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := b.structSchema(field.Type)
+			for k, v := range embedded.Properties {
+				schema.Properties[k] = v
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := b.SchemaOf(field.Type)
+		if desc := field.Tag.Get("doc"); desc != "" && prop.Ref == "" {
+			prop.Description = desc
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			prop.Enum = strings.Split(enum, ",")
+		}
+		schema.Properties[name] = prop
+
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// pageSchema 生成分页列表的结构
+func pageSchema(item *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"items":    {Type: "array", Items: item},
+			"page":     {Type: "integer"},
+			"pageSize": {Type: "integer"},
+			"total":    {Type: "integer"},
+		},
+		Required: []string{"items", "page", "pageSize", "total"},
+	}
+}
+
+// formSchema 生成multipart表单的结构
+func formSchema(fields []FormField) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range fields {
+		prop := &Schema{Type: "string", Description: f.Description}
+		if f.File {
+			prop.Format = "binary"
+		}
+		schema.Properties[f.Name] = prop
+		if f.Required {
+			schema.Required = append(schema.Required, f.Name)
+		}
+	}
+	return schema
+}
+
+// toOpenAPIPath 将gin的 :id 路径参数转换为OpenAPI的 {id} 格式
+func toOpenAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathParams 提取gin路径中的参数名
+func pathParams(path string) []string {
+	var names []string
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			names = append(names, seg[1:])
+		}
+	}
+	return names
+}
@@ -0,0 +1,107 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testError struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type testItem struct {
+	ID        string            `json:"id" binding:"required"`
+	Tags      []string          `json:"tags"`
+	Score     int               `json:"score" doc:"分数"`
+	Child     *testItem         `json:"child,omitempty"`
+	Labels    map[string]string `json:"labels"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Secret    string            `json:"-"`
+	internal  string
+}
+
+func TestBuilderGeneratesPathsAndSchemas(t *testing.T) {
+	b := NewBuilder("测试", "1.0.0", "", testError{})
+	b.Add("/api/v1", Route{Method: http.MethodGet, Path: "/items/:id", OperationID: "getItem", Response: testItem{}, Errors: []int{http.StatusNotFound}})
+	b.Add("/api/v1", Route{Method: http.MethodGet, Path: "/items", OperationID: "listItems", Response: testItem{}, List: true})
+	b.Add("/api/v1", Route{Method: http.MethodPost, Path: "/items", OperationID: "createItem", Request: testItem{}, Response: testItem{}, Status: http.StatusCreated})
+	doc := b.Document()
+
+	item, ok := doc.Paths["/api/v1/items/{id}"]
+	if !ok {
+		t.Fatalf("缺少路径 /api/v1/items/{id}: %v", doc.Paths)
+	}
+	get := (*item)["get"]
+	if len(get.Parameters) != 1 || get.Parameters[0].Name != "id" || get.Parameters[0].In != "path" {
+		t.Fatalf("路径参数不正确: %+v", get.Parameters)
+	}
+	if get.Responses["404"].Content["application/json"].Schema.Ref != "#/components/schemas/Error" {
+		t.Fatalf("错误响应未引用Error结构")
+	}
+
+	list := (*doc.Paths["/api/v1/items"])["get"].Responses["200"].Content["application/json"].Schema
+	if list.Properties["items"].Items.Ref != "#/components/schemas/testItem" {
+		t.Fatalf("分页列表元素结构不正确: %+v", list.Properties["items"])
+	}
+	if _, ok := (*doc.Paths["/api/v1/items"])["post"].Responses["201"]; !ok {
+		t.Fatalf("缺少201响应")
+	}
+
+	schema := doc.Components.Schemas["testItem"]
+	if schema == nil {
+		t.Fatalf("testItem未注册为组件")
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"id"}) {
+		t.Fatalf("必填字段 = %v，期望 [id]", schema.Required)
+	}
+	if _, ok := schema.Properties["Secret"]; ok {
+		t.Fatalf("json:\"-\"字段不应出现在结构中")
+	}
+	if len(schema.Properties) != 6 {
+		t.Fatalf("字段数 = %d，期望 6: %v", len(schema.Properties), schema.Properties)
+	}
+	if schema.Properties["createdAt"].Format != "date-time" || schema.Properties["score"].Description != "分数" {
+		t.Fatalf("字段类型或描述不正确")
+	}
+	if schema.Properties["child"].Ref != "#/components/schemas/testItem" {
+		t.Fatalf("递归类型应引用自身")
+	}
+	if schema.Properties["tags"].Items.Type != "string" || schema.Properties["labels"].Additional.Type != "string" {
+		t.Fatalf("数组或映射字段结构不正确")
+	}
+}
+
+func TestBuilderSecurityAndRoles(t *testing.T) {
+	b := NewBuilder("测试", "1.0.0", "", testError{})
+	b.SetSecurity(map[string]SecurityScheme{
+		"bearer": {Type: "http", Scheme: "bearer"},
+		"cookie": {Type: "apiKey", In: "cookie", Name: "session"},
+	})
+	b.Add("/api/v1", Route{Method: http.MethodPost, Path: "/login", OperationID: "login", Public: true})
+	b.Add("/api/v1", Route{Method: http.MethodDelete, Path: "/items/:id", OperationID: "deleteItem", Roles: []string{"admin"}})
+	doc := b.Document()
+
+	if len(doc.Security) != 2 || len(doc.Components.SecuritySchemes) != 2 {
+		t.Fatalf("认证方式不正确: %+v", doc.Security)
+	}
+
+	login := (*doc.Paths["/api/v1/login"])["post"]
+	if login.Security == nil || len(*login.Security) != 0 {
+		t.Fatalf("公开接口应声明空的认证要求")
+	}
+	if _, ok := login.Responses["401"]; ok {
+		t.Fatalf("公开接口不应有401响应")
+	}
+
+	del := (*doc.Paths["/api/v1/items/{id}"])["delete"]
+	if del.Security != nil || !reflect.DeepEqual(del.Roles, []string{"admin"}) {
+		t.Fatalf("限定角色的接口不正确: %+v", del)
+	}
+	for _, code := range []string{"401", "403"} {
+		if _, ok := del.Responses[code]; !ok {
+			t.Fatalf("限定角色的接口缺少%s响应", code)
+		}
+	}
+}
@@ -4,78 +4,254 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
 
+	"github.com/10yihang/resume-ai-interview/config"
 	"github.com/10yihang/resume-ai-interview/internal/ai"
+	"github.com/10yihang/resume-ai-interview/internal/metrics"
+	"github.com/10yihang/resume-ai-interview/internal/redact"
 	"github.com/10yihang/resume-ai-interview/models"
 )
 
 // AITextParser 使用AI解析文本
 type AITextParser struct {
-	client     interface{} // 可以是OpenAI或Grok3客户端
-	useGrok    bool
-	apiKey     string
-	fileParser FileParser // 文件解析器
+	providers  []config.Provider // 按优先级使用的AI服务，为空时只保留原文
+	timeout    time.Duration     // 每次调用AI服务的超时时间，0表示不限
+	served     int               // 最近一次实际提供服务的AI服务在providers中的序号
+	fileParser FileParser        // 文件解析器
+	redaction  *redact.Policy    // 发送给AI前的个人信息遮蔽策略
+	chunkSize  int               // 分块解析时每块简历文本的token数上限
+	usage      []*models.TokenUsage
 }
 
-// NewAITextParser 创建一个新的AI文本解析器
+// NewAITextParser 创建一个新的AI文本解析器，apiKey为空时只保留原文
 func NewAITextParser(apiKey string, useGrok bool, fileParser FileParser) *AITextParser {
-	return &AITextParser{
-		apiKey:     apiKey,
-		useGrok:    useGrok,
-		fileParser: fileParser,
+	p := &AITextParser{fileParser: fileParser}
+	if apiKey != "" {
+		name := ai.ProviderOpenAI
+		if useGrok {
+			name = ai.ProviderGrok
+		}
+		p.providers = []config.Provider{{Name: name, APIKey: apiKey}}
+	}
+	return p
+}
+
+// WithProviders 设置按优先级使用的AI服务，前一个调用失败或超时时改用下一个，timeout为0表示不限
+func (p *AITextParser) WithProviders(providers []config.Provider, timeout time.Duration) *AITextParser {
+	p.providers = providers
+	p.timeout = timeout
+	p.served = 0
+	return p
+}
+
+// WithRedaction 设置个人信息遮蔽策略，对当前使用的AI服务启用遮蔽时，
+// 发送的文本中的个人信息会被替换为占位符，解析结果中再还原
+func (p *AITextParser) WithRedaction(policy *redact.Policy) *AITextParser {
+	p.redaction = policy
+	return p
+}
+
+// WithChunkTokens 设置分块解析时每块简历文本的token数上限，超过上限的简历分块解析后合并，n为0时使用默认值
+func (p *AITextParser) WithChunkTokens(n int) *AITextParser {
+	p.chunkSize = n
+	return p
+}
+
+// WithModel 设置首选AI服务解析使用的模型，model为空时使用该服务的默认模型
+func (p *AITextParser) WithModel(model string) *AITextParser {
+	if len(p.providers) > 0 {
+		p.providers[0].ParseModel = model
+	}
+	return p
+}
+
+// 解析时默认使用的模型
+const (
+	grokParseModel   = "grok-3-latest"
+	openAIParseModel = "gpt-4o"
+)
+
+// Provider 返回最近一次实际提供服务的AI服务名称，还没有调用时返回首选服务，未配置API密钥时返回空字符串
+func (p *AITextParser) Provider() string {
+	if len(p.providers) == 0 {
+		return ""
 	}
+	return p.providers[p.served].Name
+}
+
+// Model 返回最近一次实际提供服务的模型，还没有调用时返回首选服务的模型，未配置API密钥时返回空字符串
+func (p *AITextParser) Model() string {
+	if len(p.providers) == 0 {
+		return ""
+	}
+	return parseModel(p.providers[p.served])
+}
+
+// parseModel 返回AI服务解析时使用的模型，没有配置时使用默认模型
+func parseModel(provider config.Provider) string {
+	switch {
+	case provider.ParseModel != "":
+		return provider.ParseModel
+	case provider.Name == ai.ProviderGrok:
+		return grokParseModel
+	default:
+		return openAIParseModel
+	}
+}
+
+// reply 是AI服务的回复和发送前遮蔽文本使用的遮蔽器，解析结果用同一个遮蔽器还原
+type reply struct {
+	content  string
+	redactor *redact.Redactor
+}
+
+// complete 按优先级调用AI服务直到成功，buildPrompt按该服务的遮蔽策略构建提示词
+// redactors为各服务已登记过个人信息的遮蔽器，为nil时每次调用使用新的遮蔽器
+func (p *AITextParser) complete(ctx context.Context, redactors map[string]*redact.Redactor, buildPrompt func(redactor *redact.Redactor) string) (reply, error) {
+	attempts := make([]ai.Attempt[reply], len(p.providers))
+	for i, provider := range p.providers {
+		attempts[i] = ai.Attempt[reply]{Provider: provider.Name, Model: parseModel(provider), Call: func(ctx context.Context) (reply, error) {
+			redactor, ok := redactors[provider.Name]
+			if !ok {
+				redactor = p.redaction.For(provider.Name)
+			}
+			prompt := buildPrompt(redactor)
+			var content string
+			var err error
+			// 根据服务选择使用Grok3或OpenAI
+			if provider.Name == ai.ProviderGrok {
+				content, err = p.callGrok3API(ctx, provider, prompt)
+			} else {
+				content, err = p.callOpenAIAPI(ctx, provider, prompt)
+			}
+			return reply{content: content, redactor: redactor}, err
+		}}
+	}
+	result, served, err := ai.Failover(ctx, p.timeout, attempts, nil)
+	if served >= 0 {
+		p.served = served
+	}
+	return result, err
+}
+
+// Usage 返回最近一次解析调用AI返回的token用量，每个服务和模型一条，分块解析时为各块用量之和
+// 没有调用AI或接口没有返回用量时返回nil
+func (p *AITextParser) Usage() []*models.TokenUsage {
+	return p.usage
+}
+
+// addUsage 累加一次解析中各次调用AI的用量，分块解析中途切换服务时按服务和模型分别累加
+func (p *AITextParser) addUsage(usage *models.TokenUsage) {
+	if usage == nil {
+		return
+	}
+	for _, u := range p.usage {
+		if u.Provider == usage.Provider && u.Model == usage.Model {
+			u.PromptTokens += usage.PromptTokens
+			u.CompletionTokens += usage.CompletionTokens
+			return
+		}
+	}
+	p.usage = append(p.usage, usage)
+}
+
+// documentRedactors 为一份简历创建各AI服务使用的遮蔽器，姓名按全文的第一行登记一次，
+// 分块解析时各块共用同一个遮蔽器遮蔽和还原，后面各块中的姓名也会被遮蔽
+func (p *AITextParser) documentRedactors(text string) map[string]*redact.Redactor {
+	redactors := make(map[string]*redact.Redactor, len(p.providers))
+	for _, provider := range p.providers {
+		redactor := p.redaction.For(provider.Name)
+		redactor.MaskDocument(text)
+		redactors[provider.Name] = redactor
+	}
+	return redactors
 }
 
 // ParseResumeText 使用AI解析简历文本
-func (p *AITextParser) ParseResumeText(text string) (*models.Resume, error) {
-	if p.apiKey == "" {
+// 文本超过分块上限时按段落分块，各块分别解析后合并为一份简历，长简历的经历不会被截断
+func (p *AITextParser) ParseResumeText(ctx context.Context, text string) (*models.Resume, error) {
+	if len(p.providers) == 0 {
 		// 如果没有API密钥，仅返回原始文本
 		return &models.Resume{
 			RawText: text,
 		}, nil
 	}
+	p.usage = nil
 
-	// 构建提示词
-	prompt := buildResumeParsePrompt(text)
-
-	var content string
-	var err error
+	limit := p.chunkSize
+	if limit <= 0 {
+		limit = defaultChunkTokens
+	}
+	chunks := splitChunks(p.Model(), text, limit)
+	redactors := p.documentRedactors(text)
+	if len(chunks) == 1 {
+		resume, err := p.parseResumeChunk(ctx, redactors, text, buildResumeParsePrompt)
+		if err != nil {
+			return nil, fmt.Errorf("AI解析简历失败: %w", err)
+		}
+		resume.RawText = text
+		return resume, nil
+	}
 
-	// 根据配置选择使用Grok3或OpenAI
-	if p.useGrok {
-		content, err = p.callGrok3API(prompt)
-	} else {
-		content, err = p.callOpenAIAPI(prompt)
+	slog.InfoContext(ctx, "简历文本较长，分块解析", "chunks", len(chunks), "model", p.Model())
+	parts := make([]*models.Resume, len(chunks))
+	for i, chunk := range chunks {
+		resume, err := p.parseResumeChunk(ctx, redactors, chunk, func(masked string) string {
+			return buildResumeChunkPrompt(masked, i+1, len(chunks))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("AI解析简历第%d部分失败: %w", i+1, err)
+		}
+		parts[i] = resume
 	}
+	resume := mergeResumes(parts)
+	resume.RawText = text
+	return resume, nil
+}
 
+// parseResumeChunk 解析一块简历文本，用整份简历的遮蔽器遮蔽和还原，buildPrompt用遮蔽后的文本构建提示词
+func (p *AITextParser) parseResumeChunk(ctx context.Context, redactors map[string]*redact.Redactor, text string, buildPrompt func(masked string) string) (*models.Resume, error) {
+	// 构建提示词，需要时先遮蔽个人信息
+	reply, err := p.complete(ctx, redactors, func(redactor *redact.Redactor) string {
+		return buildPrompt(redactor.Mask(text))
+	})
 	if err != nil {
-		return nil, fmt.Errorf("AI解析简历失败: %w", err)
+		return nil, err
 	}
 
 	// 解析AI返回的JSON
-	resume, err := parseResumeJSON(content, text)
+	redactor := reply.redactor
+	resume, err := parseResumeJSON(ctx, reply.content, text)
 	if err != nil {
 		return nil, fmt.Errorf("解析AI返回的JSON失败: %w", err)
 	}
 
+	resume.Name = redactor.Restore(resume.Name)
+	resume.Email = redactor.Restore(resume.Email)
+	resume.Phone = redactor.Restore(resume.Phone)
+	resume.Education = redactor.RestoreAll(resume.Education)
+	resume.Experience = redactor.RestoreAll(resume.Experience)
+	resume.Skills = redactor.RestoreAll(resume.Skills)
 	return resume, nil
 }
 
 // ParseResumeFile 使用AI解析简历文件
-func (p *AITextParser) ParseResumeFile(filePath string) (*models.Resume, error) {
+func (p *AITextParser) ParseResumeFile(ctx context.Context, filePath string) (*models.Resume, error) {
 	if p.fileParser == nil {
 		return nil, fmt.Errorf("文件解析器未初始化")
 	}
 
 	// 从文件中提取文本
-	text, err := p.fileParser.ParseFile(filePath)
+	text, err := p.fileParser.ParseFile(ctx, filePath)
 	if err != nil {
 		return nil, fmt.Errorf("文件解析失败: %w", err)
 	}
 
 	// 使用提取的文本解析简历
-	resume, err := p.ParseResumeText(text)
+	resume, err := p.ParseResumeText(ctx, text)
 	if err != nil {
 		return nil, err
 	}
@@ -86,54 +262,51 @@ func (p *AITextParser) ParseResumeFile(filePath string) (*models.Resume, error)
 }
 
 // ParseJDText 使用AI解析职位描述文本
-func (p *AITextParser) ParseJDText(text string) (*models.JobDescription, error) {
-	if p.apiKey == "" {
+func (p *AITextParser) ParseJDText(ctx context.Context, text string) (*models.JobDescription, error) {
+	if len(p.providers) == 0 {
 		// 如果没有API密钥，仅返回原始文本
 		return &models.JobDescription{
 			RawText: text,
 		}, nil
 	}
+	p.usage = nil
 
-	// 构建提示词
-	prompt := buildJDParsePrompt(text)
-
-	var content string
-	var err error
-
-	// 根据配置选择使用Grok3或OpenAI
-	if p.useGrok {
-		content, err = p.callGrok3API(prompt)
-	} else {
-		content, err = p.callOpenAIAPI(prompt)
-	}
-
+	// 构建提示词，需要时先遮蔽JD中的联系人信息
+	reply, err := p.complete(ctx, nil, func(redactor *redact.Redactor) string {
+		return buildJDParsePrompt(redactor.Mask(text))
+	})
 	if err != nil {
 		return nil, fmt.Errorf("AI解析职位描述失败: %w", err)
 	}
 
 	// 解析AI返回的JSON
-	jd, err := parseJDJSON(content, text)
+	redactor := reply.redactor
+	jd, err := parseJDJSON(ctx, reply.content, text)
 	if err != nil {
 		return nil, fmt.Errorf("解析AI返回的JSON失败: %w", err)
 	}
 
+	jd.Title = redactor.Restore(jd.Title)
+	jd.Company = redactor.Restore(jd.Company)
+	jd.Description = redactor.Restore(jd.Description)
+	jd.Requirements = redactor.RestoreAll(jd.Requirements)
 	return jd, nil
 }
 
 // ParseJDFile 使用AI解析JD文件
-func (p *AITextParser) ParseJDFile(filePath string) (*models.JobDescription, error) {
+func (p *AITextParser) ParseJDFile(ctx context.Context, filePath string) (*models.JobDescription, error) {
 	if p.fileParser == nil {
 		return nil, fmt.Errorf("文件解析器未初始化")
 	}
 
 	// 从文件中提取文本
-	text, err := p.fileParser.ParseFile(filePath)
+	text, err := p.fileParser.ParseFile(ctx, filePath)
 	if err != nil {
 		return nil, fmt.Errorf("文件解析失败: %w", err)
 	}
 
 	// 使用提取的文本解析JD
-	jd, err := p.ParseJDText(text)
+	jd, err := p.ParseJDText(ctx, text)
 	if err != nil {
 		return nil, err
 	}
@@ -144,17 +317,13 @@ func (p *AITextParser) ParseJDFile(filePath string) (*models.JobDescription, err
 }
 
 // callGrok3API 调用Grok3 API
-func (p *AITextParser) callGrok3API(prompt string) (string, error) {
-	// 延迟初始化客户端
-	if p.client == nil {
-		p.client = ai.NewGrok3Client(p.apiKey)
-	}
-
-	client := p.client.(*ai.Grok3Client)
+func (p *AITextParser) callGrok3API(ctx context.Context, provider config.Provider, prompt string) (string, error) {
+	model := parseModel(provider)
+	client := ai.NewGrok3Client(provider.APIKey)
 	resp, err := client.CreateChatCompletion(
-		context.Background(),
+		ctx,
 		ai.Grok3ChatRequest{
-			Model: "grok-3-latest",
+			Model: model,
 			Messages: []ai.Grok3Message{
 				{
 					Role:    "system",
@@ -174,6 +343,7 @@ func (p *AITextParser) callGrok3API(prompt string) (string, error) {
 		return "", err
 	}
 
+	p.addUsage(resp.Usage.TokenUsage(model))
 	if len(resp.Choices) == 0 {
 		return "", fmt.Errorf("Grok3返回了空的回复")
 	}
@@ -182,17 +352,13 @@ func (p *AITextParser) callGrok3API(prompt string) (string, error) {
 }
 
 // callOpenAIAPI 调用OpenAI API
-func (p *AITextParser) callOpenAIAPI(prompt string) (string, error) {
-	// 延迟初始化客户端
-	if p.client == nil {
-		p.client = ai.NewOpenAIClient(p.apiKey)
-	}
-
-	client := p.client.(*ai.OpenAIClient)
+func (p *AITextParser) callOpenAIAPI(ctx context.Context, provider config.Provider, prompt string) (string, error) {
+	model := parseModel(provider)
+	client := ai.NewOpenAIClient(provider.APIKey)
 	resp, err := client.CreateChatCompletion(
-		context.Background(),
+		ctx,
 		ai.OpenAIChatRequest{
-			Model: "gpt-4o",
+			Model: model,
 			Messages: []ai.OpenAIMessage{
 				{
 					Role:    "system",
@@ -212,6 +378,7 @@ func (p *AITextParser) callOpenAIAPI(prompt string) (string, error) {
 		return "", err
 	}
 
+	p.addUsage(resp.Usage.TokenUsage(model))
 	if len(resp.Choices) == 0 {
 		return "", fmt.Errorf("OpenAI返回了空的回复")
 	}
@@ -249,6 +416,12 @@ func buildResumeParsePrompt(text string) string {
 `, text)
 }
 
+// 构建分块解析时一块简历文本的提示词
+func buildResumeChunkPrompt(text string, index, total int) string {
+	return fmt.Sprintf("\n以下是一份较长简历的第%d部分（共%d部分），只提取这一部分中出现的信息，其余字段返回空字符串或空数组。\n", index, total) +
+		buildResumeParsePrompt(text)
+}
+
 // 构建JD解析的提示词
 func buildJDParsePrompt(text string) string {
 	return fmt.Sprintf(`
@@ -276,7 +449,7 @@ func buildJDParsePrompt(text string) string {
 }
 
 // 解析AI返回的简历JSON
-func parseResumeJSON(content string, originalText string) (*models.Resume, error) {
+func parseResumeJSON(ctx context.Context, content string, originalText string) (*models.Resume, error) {
 	// 提取并清理JSON内容
 	jsonContent := extractJSONFromText(content)
 
@@ -295,10 +468,13 @@ func parseResumeJSON(content string, originalText string) (*models.Resume, error
 		// 如果解析失败，尝试修复常见的JSON格式错误
 		fixedJSON := tryFixJSONFormat(jsonContent)
 		err = json.Unmarshal([]byte(fixedJSON), &result)
+		metrics.RecordRepair("parser", err)
 
 		if err != nil {
+			metrics.Fallbacks.Inc(metrics.FallbackRawText)
 			// 如果仍然失败，返回一个包含原始文本的简单Resume对象
-			fmt.Printf("JSON解析错误: %v\nJSON内容: %s\n", err, jsonContent)
+			// 模型输出可能包含个人信息，日志中只记录长度
+			slog.WarnContext(ctx, "解析模型返回的简历JSON失败，只保留原文", "error", err, "length", len(jsonContent))
 			return &models.Resume{
 				RawText: originalText,
 			}, nil
@@ -320,7 +496,7 @@ func parseResumeJSON(content string, originalText string) (*models.Resume, error
 }
 
 // 解析AI返回的JD JSON
-func parseJDJSON(content string, originalText string) (*models.JobDescription, error) {
+func parseJDJSON(ctx context.Context, content string, originalText string) (*models.JobDescription, error) {
 	// 提取并清理JSON内容
 	jsonContent := extractJSONFromText(content)
 
@@ -337,10 +513,12 @@ func parseJDJSON(content string, originalText string) (*models.JobDescription, e
 		// 如果解析失败，尝试修复常见的JSON格式错误
 		fixedJSON := tryFixJSONFormat(jsonContent)
 		err = json.Unmarshal([]byte(fixedJSON), &result)
+		metrics.RecordRepair("parser", err)
 
 		if err != nil {
+			metrics.Fallbacks.Inc(metrics.FallbackRawText)
 			// 如果仍然失败，返回一个包含原始文本的简单JD对象
-			fmt.Printf("JSON解析错误: %v\nJSON内容: %s\n", err, jsonContent)
+			slog.WarnContext(ctx, "解析模型返回的JD JSON失败，只保留原文", "error", err, "length", len(jsonContent))
 			return &models.JobDescription{
 				RawText: originalText,
 			}, nil
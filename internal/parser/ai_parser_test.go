@@ -1,13 +1,22 @@
 package parser
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/10yihang/resume-ai-interview/config"
 	"github.com/10yihang/resume-ai-interview/internal/ocr"
+	"github.com/10yihang/resume-ai-interview/internal/redact"
+	"github.com/10yihang/resume-ai-interview/internal/tokenizer"
 	"github.com/10yihang/resume-ai-interview/models"
 )
 
@@ -68,7 +77,7 @@ Go, Python, JavaScript, React, Docker, Kubernetes, MySQL, MongoDB
 `
 
 	// 解析简历
-	resume, err := parser.ParseResumeText(resumeText)
+	resume, err := parser.ParseResumeText(context.Background(), resumeText)
 	if err != nil {
 		t.Fatalf("解析简历失败: %v", err)
 	}
@@ -83,7 +92,7 @@ Go, Python, JavaScript, React, Docker, Kubernetes, MySQL, MongoDB
 	fmt.Printf("技能: %v\n\n", resume.Skills)
 
 	// 解析JD
-	jd, err := parser.ParseJDText(jdText)
+	jd, err := parser.ParseJDText(context.Background(), jdText)
 	if err != nil {
 		t.Fatalf("解析JD失败: %v", err)
 	}
@@ -160,7 +169,7 @@ func TestFileParserWithOCR(t *testing.T) {
 		}
 
 		// 解析文件
-		text, err := fileParser.ParseFile(textFile)
+		text, err := fileParser.ParseFile(context.Background(), textFile)
 		if err != nil {
 			t.Fatalf("解析文本文件失败: %v", err)
 		}
@@ -210,7 +219,7 @@ func TestFileParserWithOCR(t *testing.T) {
 		}
 
 		// 解析文件
-		resume, err := aiParser.ParseResumeFile(textFile)
+		resume, err := aiParser.ParseResumeFile(context.Background(), textFile)
 		if err != nil {
 			t.Fatalf("AI解析简历文件失败: %v", err)
 		}
@@ -238,3 +247,103 @@ func TestFileParserWithOCR(t *testing.T) {
 		}
 	})
 }
+
+func TestSplitChunks(t *testing.T) {
+	var sections []string
+	for i := 1; i <= 12; i++ {
+		sections = append(sections, fmt.Sprintf("公司%d，高级工程师，2010-2012\n- 负责订单系统的设计与开发，支撑日均百万订单\n- 带领五人团队完成微服务改造", i))
+	}
+	text := strings.Join(sections, "\n\n")
+
+	if chunks := splitChunks("gpt-4o", text, 100000); len(chunks) != 1 || chunks[0] != text {
+		t.Fatalf("不超过上限时应只有一块")
+	}
+	chunks := splitChunks("gpt-4o", text, 120)
+	if len(chunks) < 2 {
+		t.Fatalf("超过上限时应分块，实际%d块", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if n := tokenizer.Count("gpt-4o", chunk); n > 120 {
+			t.Fatalf("每块不应超过120个token，实际%d", n)
+		}
+	}
+	// 段落不被拆开，合并后与原文相同
+	if joined := strings.Join(chunks, "\n\n"); joined != text {
+		t.Fatalf("分块后应按段落完整保留原文，得到:\n%s", joined)
+	}
+
+	// 没有空行的超长文本按行和token继续切分
+	long := strings.Repeat("熟悉Go、Kubernetes和分布式系统设计", 40)
+	for _, chunk := range splitChunks("grok-3-latest", long, 50) {
+		if n := tokenizer.Count("grok-3-latest", chunk); n > 50 || n == 0 {
+			t.Fatalf("超长的一行应按token截断，得到%d个token的块", n)
+		}
+	}
+}
+
+func TestParseResumeTextInChunks(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		prompt := request.Messages[len(request.Messages)-1].Content
+		calls.Add(1)
+		if strings.Contains(prompt, "张三") {
+			t.Errorf("每一部分中的姓名都应被遮蔽")
+		}
+		if !strings.Contains(prompt, "项目经历") {
+			t.Errorf("各部分开头的标题不应被当作姓名遮蔽")
+		}
+
+		// 第1部分有联系方式，其余部分只有经历，各部分都有Go
+		companies, _ := json.Marshal(regexp.MustCompile(`公司\d+`).FindAllString(prompt, -1))
+		content := `{"name":"","email":"","phone":"","education":[],"experience":%s,"skills":["Go"]}`
+		switch {
+		case strings.Contains(prompt, "第1部分"):
+			content = `{"name":"[NAME_1]","email":"zs@example.com","phone":"","education":["北京大学"],"experience":%s,"skills":["go","Docker"]}`
+		case !strings.Contains(prompt, "部分（共"):
+			t.Errorf("长简历应分块解析")
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": fmt.Sprintf(content, companies)}}},
+			"usage":   map[string]int{"prompt_tokens": 100, "completion_tokens": 10, "total_tokens": 110},
+		})
+	}))
+	defer server.Close()
+	t.Setenv("GROK3_API_URL", server.URL)
+
+	var sections []string
+	for i := 1; i <= 4; i++ {
+		sections = append(sections, fmt.Sprintf("项目经历\n公司%d，高级工程师，张三\n%s", i, strings.Repeat("负责核心交易系统的设计、开发和性能优化。", 20)))
+	}
+	text := "张三\n" + strings.Join(sections, "\n\n")
+	aiParser := NewAITextParser("", false, nil).
+		WithProviders([]config.Provider{{Name: "grok", APIKey: "test"}}, 0).
+		WithChunkTokens(500).
+		WithRedaction(redact.NewPolicy([]string{"grok"}))
+
+	resume, err := aiParser.ParseResumeText(context.Background(), text)
+	if err != nil {
+		t.Fatalf("分块解析失败: %v", err)
+	}
+	n := int(calls.Load())
+	if n < 2 {
+		t.Fatalf("应分块调用多次，实际%d次", n)
+	}
+	if resume.Name != "张三" || resume.Email != "zs@example.com" || resume.RawText != text {
+		t.Fatalf("合并结果应保留第1部分的联系方式和完整原文: %+v", resume)
+	}
+	if len(resume.Experience) != 4 || resume.Experience[3] != "公司4" {
+		t.Fatalf("应按顺序合并各部分的工作经验: %v", resume.Experience)
+	}
+	if len(resume.Skills) != 2 {
+		t.Fatalf("技能应忽略大小写去重: %v", resume.Skills)
+	}
+	if usage := aiParser.Usage(); len(usage) != 1 || usage[0].PromptTokens != 100*n || usage[0].CompletionTokens != 10*n {
+		t.Fatalf("同一服务和模型的用量应为各块之和: %+v", usage)
+	}
+}
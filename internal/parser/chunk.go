@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/10yihang/resume-ai-interview/internal/tokenizer"
+	"github.com/10yihang/resume-ai-interview/models"
+)
+
+// defaultChunkTokens 是分块解析时每块文本的默认token数上限
+// 单次解析的回复最多1024个token，文本过长时即使模型能读完，提取的经历也写不下
+const defaultChunkTokens = 3000
+
+// blankLines 匹配段落之间的空行，空行中可能有空格
+var blankLines = regexp.MustCompile(`\n[ \t\r]*\n`)
+
+// splitChunks 按模型的分词器把文本切分为不超过limit个token的块，不超过上限时只有一块
+// 优先在段落之间（空行）切分，相邻的段落尽量放在同一块，一段经历不会被拆到两块中；
+// 超过上限的段落按行切分，超过上限的一行按token截断
+func splitChunks(model, text string, limit int) []string {
+	if tokenizer.Count(model, text) <= limit {
+		return []string{text}
+	}
+	text = blankLines.ReplaceAllString(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n")
+	return pack(model, text, limit, []string{"\n\n", "\n"})
+}
+
+// pack 用seps中的第一个分隔符切分文本，再把相邻的部分合并为不超过limit个token的块
+func pack(model, text string, limit int, seps []string) []string {
+	if tokenizer.Count(model, text) <= limit {
+		return []string{text}
+	}
+	if len(seps) == 0 {
+		var chunks []string
+		for text != "" {
+			head := tokenizer.Truncate(model, text, limit)
+			if head == "" {
+				break
+			}
+			chunks = append(chunks, head)
+			text = text[len(head):]
+		}
+		return chunks
+	}
+
+	var chunks []string
+	current := ""
+	for _, part := range strings.Split(text, seps[0]) {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		if current != "" {
+			if candidate := current + seps[0] + part; tokenizer.Count(model, candidate) <= limit {
+				current = candidate
+				continue
+			}
+			chunks = append(chunks, current)
+			current = ""
+		}
+		if tokenizer.Count(model, part) <= limit {
+			current = part
+			continue
+		}
+		chunks = append(chunks, pack(model, part, limit, seps[1:])...)
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// mergeResumes 合并各块的解析结果：姓名和联系方式取第一个非空值，
+// 教育经历、工作经验和技能按出现顺序合并，去掉忽略大小写后重复的项
+func mergeResumes(parts []*models.Resume) *models.Resume {
+	merged := &models.Resume{}
+	for _, part := range parts {
+		if merged.Name == "" {
+			merged.Name = part.Name
+		}
+		if merged.Email == "" {
+			merged.Email = part.Email
+		}
+		if merged.Phone == "" {
+			merged.Phone = part.Phone
+		}
+		merged.Education = appendUnique(merged.Education, part.Education)
+		merged.Experience = appendUnique(merged.Experience, part.Experience)
+		merged.Skills = appendUnique(merged.Skills, part.Skills)
+	}
+	return merged
+}
+
+// appendUnique 把items中list里还没有的项追加到list
+func appendUnique(list, items []string) []string {
+	for _, item := range items {
+		duplicate := false
+		for _, existing := range list {
+			if strings.EqualFold(strings.TrimSpace(existing), strings.TrimSpace(item)) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -7,17 +7,24 @@
 package parser
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/10yihang/resume-ai-interview/config"
+	"github.com/10yihang/resume-ai-interview/internal/metrics"
 	"github.com/10yihang/resume-ai-interview/internal/ocr"
+	"github.com/10yihang/resume-ai-interview/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // FileParser 定义了文件解析器的接口
 type FileParser interface {
 	// ParseFile 从文件中解析内容
-	ParseFile(filePath string) (string, error)
+	ParseFile(ctx context.Context, filePath string) (string, error)
 }
 
 // ResumeFileParser 使用OCR和传统方法解析简历文件
@@ -34,22 +41,49 @@ func NewResumeFileParser(ocrProcessor ocr.OCRProcessor, useOCR bool) *ResumeFile
 	}
 }
 
+// NewOCRChain 按配置创建OCR引擎链：引擎顺序、页质量阈值、Tesseract的识别参数和并发数
+func NewOCRChain(cfg *config.Config) *ocr.Chain {
+	return ocr.GetOCRChain(ocr.Options{
+		Engines:       cfg.OCREngineOrder(),
+		MinQuality:    cfg.OCRMinQuality,
+		TesseractPath: cfg.TesseractPath,
+		Tesseract: ocr.TesseractOptions{
+			Languages:  cfg.TesseractLangs,
+			PSM:        cfg.TesseractPSM,
+			OEM:        cfg.TesseractOEM,
+			DPI:        cfg.OCRDPI,
+			Output:     strings.ToLower(cfg.TesseractOutput),
+			Workers:    cfg.OCRWorkers,
+			Preprocess: cfg.OCRPreprocess,
+		},
+		OCRSpaceKey: cfg.OCRSpaceKey(),
+	})
+}
+
 // ParseFile 解析简历文件
-func (p *ResumeFileParser) ParseFile(filePath string) (string, error) {
+func (p *ResumeFileParser) ParseFile(ctx context.Context, filePath string) (text string, err error) {
 	ext := strings.ToLower(filepath.Ext(filePath))
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "parser.ParseFile",
+		attribute.String("file.extension", ext), attribute.Bool("parser.ocr", p.useOCR && p.ocrProcessor != nil))
+	defer func() {
+		metrics.FileParseDuration.ObserveSince(start, formatLabel(ext), metrics.Result(err))
+		span.SetAttributes(attribute.Int("parser.text_length", len(text)))
+		tracing.End(span, err)
+	}()
 
 	// 根据文件扩展名选择处理方式
 	switch ext {
 	case ".pdf":
-		// 优先使用OCR处理PDF以解决token限制问题
+		// 先逐页读取PDF的文本层，只有扫描页和文本层乱码的页交给OCR引擎识别，结果按页号合并
 		if p.useOCR && p.ocrProcessor != nil {
-			// 使用增强的OCR处理功能
-			text, err := ocr.ProcessFile(p.ocrProcessor, filePath)
+			text, err := ocr.ProcessFile(ctx, p.ocrProcessor, filePath)
 			if err == nil {
 				return text, nil
 			}
 			// OCR失败时记录错误并使用传统方法
-			fmt.Printf("OCR处理PDF失败: %v，尝试使用传统解析方法\n", err)
+			slog.WarnContext(ctx, "OCR处理PDF失败，尝试使用传统解析方法", "file", filepath.Base(filePath), "error", err)
+			metrics.Fallbacks.Inc(metrics.FallbackPDFText)
 		}
 		// 当OCR未启用或失败时，使用传统方法
 		return extractTextFromPDF(filePath)
@@ -59,10 +93,20 @@ func (p *ResumeFileParser) ParseFile(filePath string) (string, error) {
 	case ".png", ".jpg", ".jpeg":
 		// 图像文件使用OCR
 		if p.ocrProcessor != nil {
-			return ocr.ProcessFile(p.ocrProcessor, filePath)
+			return ocr.ProcessFile(ctx, p.ocrProcessor, filePath)
 		}
 		return "", fmt.Errorf("无法处理图像文件：OCR处理器未初始化")
 	default:
 		return "", fmt.Errorf("不支持的文件格式: %s", ext)
 	}
 }
+
+// formatLabel 返回指标中的文件格式标签，不支持的格式统一为other，避免标签取值无限增长
+func formatLabel(ext string) string {
+	switch ext {
+	case ".pdf", ".txt", ".png", ".jpg", ".jpeg":
+		return strings.TrimPrefix(ext, ".")
+	default:
+		return "other"
+	}
+}
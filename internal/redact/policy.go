@@ -0,0 +1,39 @@
+package redact
+
+import "strings"
+
+// 外部服务名称，用于按服务配置是否遮蔽个人信息
+const (
+	ProviderOpenAI   = "openai"
+	ProviderGrok     = "grok"
+	ProviderOCRSpace = "ocrspace"
+)
+
+// Policy 记录哪些外部服务需要遮蔽个人信息，nil的Policy不遮蔽任何服务
+type Policy struct {
+	providers map[string]bool
+}
+
+// NewPolicy 创建遮蔽策略，providers为需要遮蔽的外部服务名称
+func NewPolicy(providers []string) *Policy {
+	p := &Policy{providers: make(map[string]bool)}
+	for _, provider := range providers {
+		if provider = strings.ToLower(strings.TrimSpace(provider)); provider != "" {
+			p.providers[provider] = true
+		}
+	}
+	return p
+}
+
+// Enabled 判断发送给provider前是否需要遮蔽
+func (p *Policy) Enabled(provider string) bool {
+	return p != nil && p.providers[provider]
+}
+
+// For 为一次发送给provider的调用创建Redactor，不需要遮蔽时返回nil
+func (p *Policy) For(provider string) *Redactor {
+	if !p.Enabled(provider) {
+		return nil
+	}
+	return New()
+}
@@ -0,0 +1,227 @@
+// Package redact 在把文本发送给外部大模型和OCR服务之前遮蔽个人信息
+// 个人信息被替换为可还原的占位符，如 [EMAIL_1]，外部服务返回后再用Restore还原
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kind 表示一类个人信息
+type Kind string
+
+// 支持遮蔽的个人信息类型
+const (
+	KindName    Kind = "NAME"
+	KindEmail   Kind = "EMAIL"
+	KindPhone   Kind = "PHONE"
+	KindIDCard  Kind = "ID"
+	KindAddress Kind = "ADDRESS"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// 18位身份证号，校验出生日期的格式
+	idCardPattern = regexp.MustCompile(`\b[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`)
+	// 手机号（可带+86）、带区号的座机号和其他国际号码
+	phonePattern = regexp.MustCompile(`(?:\+?86[\s-]?)?\b1[3-9]\d[\s-]?\d{4}[\s-]?\d{4}\b|\b0\d{2,3}-\d{7,8}\b|\+\d{1,3}[\s-]?\(?\d{1,4}\)?(?:[\s-]?\d{2,4}){2,4}\b`)
+	// 带标签的地址，遮蔽标签后的整行
+	labeledAddressPattern = regexp.MustCompile(`(?i)((?:家庭|现居|通讯|联系|居住)?地址|住址|现居住地|address)(\s*[:：]\s*)([^\n]+)`)
+	// 不带标签的中文地址，从省市区一直到门牌号
+	addressPattern = regexp.MustCompile(`\p{Han}{2,8}(?:省|自治区)?\p{Han}{1,8}市\p{Han}{1,8}(?:区|县)[\p{Han}\d]{1,20}?(?:路|街|大道|巷|弄|村)\d{1,5}号(?:[\d\-]{1,8}(?:室|号))?`)
+	// 带标签的姓名
+	labeledNamePattern = regexp.MustCompile(`(姓\s*名|[Nn]ame)(\s*[:：]\s*)(\p{Han}{2,4}|[A-Z][a-z]+(?: [A-Z][a-z]+){1,2})`)
+	// 单独一行的2到4个汉字，简历第一行通常是姓名
+	nameLinePattern = regexp.MustCompile(`^\p{Han}{2,4}$`)
+	// 占位符，Restore时只替换自己生成的占位符
+	placeholderPattern = regexp.MustCompile(`\[(?:NAME|EMAIL|PHONE|ID|ADDRESS)_\d+\]`)
+)
+
+// maxPlaceholderLen 是占位符的最大长度，流式还原时据此判断是否需要等待后续文本
+const maxPlaceholderLen = 16
+
+// Redactor 遮蔽一次请求中的个人信息并记录占位符与原文的对应关系
+// 同一原文在同一个Redactor中总是对应同一个占位符，nil的Redactor不做任何遮蔽
+type Redactor struct {
+	byValue map[string]string
+	byToken map[string]string
+	counts  map[Kind]int
+	names   []string
+}
+
+// New 创建Redactor，每次外部调用使用一个新的Redactor
+func New() *Redactor {
+	return &Redactor{
+		byValue: make(map[string]string),
+		byToken: make(map[string]string),
+		counts:  make(map[Kind]int),
+	}
+}
+
+// AddNames 登记已知的姓名，之后遮蔽的文本中出现的这些姓名都会被替换
+func (r *Redactor) AddNames(names ...string) {
+	if r == nil {
+		return
+	}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if utf8.RuneCountInString(name) < 2 {
+			continue
+		}
+		r.addName(name)
+	}
+}
+
+// addName 登记姓名，已登记过的忽略
+func (r *Redactor) addName(name string) {
+	for _, known := range r.names {
+		if known == name {
+			return
+		}
+	}
+	r.names = append(r.names, name)
+	r.token(KindName, name)
+}
+
+// token 返回原文对应的占位符，第一次出现时分配新的占位符
+func (r *Redactor) token(kind Kind, value string) string {
+	if token, ok := r.byValue[value]; ok {
+		return token
+	}
+	r.counts[kind]++
+	token := fmt.Sprintf("[%s_%d]", kind, r.counts[kind])
+	r.byValue[value] = token
+	r.byToken[token] = value
+	return token
+}
+
+// Mask 遮蔽文本中的个人信息
+func (r *Redactor) Mask(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+
+	// 先找出姓名，后面统一替换文本中所有出现的位置
+	for _, m := range labeledNamePattern.FindAllStringSubmatch(text, -1) {
+		r.addName(m[3])
+	}
+
+	// 身份证号要在电话号码之前处理，避免其中一段被当成手机号
+	text = idCardPattern.ReplaceAllStringFunc(text, func(s string) string { return r.token(KindIDCard, s) })
+	text = emailPattern.ReplaceAllStringFunc(text, func(s string) string { return r.token(KindEmail, s) })
+	text = phonePattern.ReplaceAllStringFunc(text, func(s string) string { return r.token(KindPhone, s) })
+	text = labeledAddressPattern.ReplaceAllStringFunc(text, func(s string) string {
+		m := labeledAddressPattern.FindStringSubmatch(s)
+		value := strings.TrimSpace(m[3])
+		if placeholderPattern.FindString(value) == value {
+			return s
+		}
+		// 地址中已遮蔽的部分先还原，保证还原时得到完整的原文
+		return m[1] + m[2] + r.token(KindAddress, r.Restore(value))
+	})
+	text = addressPattern.ReplaceAllStringFunc(text, func(s string) string { return r.token(KindAddress, s) })
+
+	if len(r.names) > 0 {
+		// 长的姓名优先，避免"张三丰"被"张三"部分替换
+		names := append([]string(nil), r.names...)
+		sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+		pairs := make([]string, 0, len(names)*2)
+		for _, name := range names {
+			pairs = append(pairs, name, r.byValue[name])
+		}
+		text = strings.NewReplacer(pairs...).Replace(text)
+	}
+	return text
+}
+
+// MaskDocument 遮蔽一份完整的简历文本
+// 除Mask的规则外，单独一行的2到4个汉字的第一行也视为姓名
+func (r *Redactor) MaskDocument(text string) string {
+	if r == nil {
+		return text
+	}
+	first, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	if first = strings.TrimSpace(first); nameLinePattern.MatchString(first) {
+		r.addName(first)
+	}
+	return r.Mask(text)
+}
+
+// MaskAll 遮蔽多个字符串
+func (r *Redactor) MaskAll(values []string) []string {
+	if r == nil || values == nil {
+		return values
+	}
+	masked := make([]string, len(values))
+	for i, v := range values {
+		masked[i] = r.Mask(v)
+	}
+	return masked
+}
+
+// Restore 把文本中的占位符还原为原文，不认识的占位符保持不变
+func (r *Redactor) Restore(text string) string {
+	if r == nil || len(r.byToken) == 0 || text == "" {
+		return text
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(token string) string {
+		if value, ok := r.byToken[token]; ok {
+			return value
+		}
+		return token
+	})
+}
+
+// RestoreAll 还原多个字符串
+func (r *Redactor) RestoreAll(values []string) []string {
+	if r == nil || values == nil {
+		return values
+	}
+	restored := make([]string, len(values))
+	for i, v := range values {
+		restored[i] = r.Restore(v)
+	}
+	return restored
+}
+
+// Count 返回已遮蔽的个人信息数量
+func (r *Redactor) Count() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.byToken)
+}
+
+// StreamRestorer 还原流式输出中的占位符
+// 占位符可能被拆到两个片段中，可能属于占位符的结尾部分会留到下一次输出
+type StreamRestorer struct {
+	r       *Redactor
+	pending string
+}
+
+// NewStreamRestorer 创建流式还原器
+func (r *Redactor) NewStreamRestorer() *StreamRestorer {
+	return &StreamRestorer{r: r}
+}
+
+// Write 写入一个片段，返回可以输出的已还原文本
+func (s *StreamRestorer) Write(chunk string) string {
+	text := s.pending + chunk
+	s.pending = ""
+
+	if i := strings.LastIndexByte(text, '['); i >= 0 && !strings.Contains(text[i:], "]") && len(text)-i < maxPlaceholderLen {
+		s.pending = text[i:]
+		text = text[:i]
+	}
+	return s.r.Restore(text)
+}
+
+// Flush 返回剩余的文本
+func (s *StreamRestorer) Flush() string {
+	text := s.pending
+	s.pending = ""
+	return s.r.Restore(text)
+}
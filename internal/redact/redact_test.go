@@ -0,0 +1,98 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaskAndRestore(t *testing.T) {
+	text := "张三\n电话：13812345678 邮箱：zhangsan@example.com\n身份证号：110101199003071234\n地址：北京市海淀区中关村大街1号\n张三负责后端开发"
+
+	r := New()
+	masked := r.MaskDocument(text)
+
+	for _, secret := range []string{"张三", "13812345678", "zhangsan@example.com", "110101199003071234", "中关村大街"} {
+		if strings.Contains(masked, secret) {
+			t.Fatalf("遮蔽后的文本仍包含 %q: %s", secret, masked)
+		}
+	}
+	for _, token := range []string{"[NAME_1]", "[PHONE_1]", "[EMAIL_1]", "[ID_1]", "[ADDRESS_1]"} {
+		if !strings.Contains(masked, token) {
+			t.Fatalf("遮蔽后的文本缺少占位符 %s: %s", token, masked)
+		}
+	}
+	if got := r.Restore(masked); got != text {
+		t.Fatalf("还原结果不一致:\n%s\n期望:\n%s", got, text)
+	}
+
+	// 同一原文总是对应同一个占位符
+	if again := r.Mask("联系张三: 13812345678"); again != "联系[NAME_1]: [PHONE_1]" {
+		t.Fatalf("重复遮蔽结果错误: %s", again)
+	}
+
+	// 不认识的占位符保持不变
+	if got := r.Restore("[PHONE_9] [NOTE_1]"); got != "[PHONE_9] [NOTE_1]" {
+		t.Fatalf("不应还原未知占位符: %s", got)
+	}
+}
+
+func TestKnownNames(t *testing.T) {
+	r := New()
+	r.AddNames("李四", "李四光")
+	masked := r.Mask("李四光和李四在同一个项目组")
+	if masked != "[NAME_2]和[NAME_1]在同一个项目组" {
+		t.Fatalf("姓名遮蔽错误: %s", masked)
+	}
+
+	// 只有简历第一行视为姓名，列表中的学校名称不受影响
+	if got := New().Mask("清华大学"); got != "清华大学" {
+		t.Fatalf("不应遮蔽普通文本: %s", got)
+	}
+}
+
+func TestNilRedactor(t *testing.T) {
+	var r *Redactor
+	text := "邮箱 a@b.com"
+	if r.Mask(text) != text || r.Restore(text) != text || r.Count() != 0 {
+		t.Fatalf("nil的Redactor不应修改文本")
+	}
+	if r.NewStreamRestorer().Write(text) != text {
+		t.Fatalf("nil的Redactor流式还原不应修改文本")
+	}
+}
+
+func TestStreamRestorer(t *testing.T) {
+	r := New()
+	r.Mask("邮箱 wang@example.com 电话 13900001111")
+
+	chunks := []string{"请联系[EM", "AIL_1]或拨打", "[PHONE_1", "]，数组[", "0] 结尾["}
+	stream := r.NewStreamRestorer()
+	var out strings.Builder
+	for _, chunk := range chunks {
+		out.WriteString(stream.Write(chunk))
+	}
+	out.WriteString(stream.Flush())
+
+	want := "请联系wang@example.com或拨打13900001111，数组[0] 结尾["
+	if out.String() != want {
+		t.Fatalf("流式还原结果错误: %s，期望: %s", out.String(), want)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy := NewPolicy([]string{" OpenAI ", "grok", ""})
+	if !policy.Enabled(ProviderOpenAI) || !policy.Enabled(ProviderGrok) || policy.Enabled(ProviderOCRSpace) {
+		t.Fatalf("策略启用状态错误")
+	}
+	if policy.For(ProviderOCRSpace) != nil {
+		t.Fatalf("未启用的服务不应返回Redactor")
+	}
+	if policy.For(ProviderGrok) == nil {
+		t.Fatalf("启用的服务应返回Redactor")
+	}
+
+	var nilPolicy *Policy
+	if nilPolicy.Enabled(ProviderOpenAI) || nilPolicy.For(ProviderOpenAI) != nil {
+		t.Fatalf("nil的Policy不应启用遮蔽")
+	}
+}
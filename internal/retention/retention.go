@@ -0,0 +1,71 @@
+// Package retention 按保留期限清理上传的原始文件
+package retention
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Sweep 删除dir下修改时间早于cutoff的文件，以及清理后为空的子目录，返回被删除的文件路径
+// 单个文件删除失败不会中止清理，所有错误合并返回
+func Sweep(dir string, cutoff time.Time) ([]string, error) {
+	var removed []string
+	var errs []error
+	var dirs []string
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			errs = append(errs, err)
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(path); err != nil {
+				errs = append(errs, err)
+				return nil
+			}
+			removed = append(removed, path)
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// 从最深的目录开始删除本次清空的或过期的空目录，刚创建还没写入文件的目录保留
+	emptied := make(map[string]bool)
+	for _, path := range removed {
+		emptied[filepath.Dir(path)] = true
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		info, err := os.Stat(dirs[i])
+		if err != nil || !(emptied[dirs[i]] || info.ModTime().Before(cutoff)) {
+			continue
+		}
+		if entries, err := os.ReadDir(dirs[i]); err == nil && len(entries) == 0 && os.Remove(dirs[i]) == nil {
+			emptied[filepath.Dir(dirs[i])] = true
+		}
+	}
+
+	if len(errs) > 0 {
+		return removed, fmt.Errorf("清理过期文件时出现%d个错误，第一个错误: %w", len(errs), errs[0])
+	}
+	return removed, nil
+}
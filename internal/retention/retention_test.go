@@ -0,0 +1,64 @@
+package retention
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFile 创建文件并设置修改时间
+func writeFile(t *testing.T, path string, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("内容"), 0600); err != nil {
+		t.Fatalf("写入文件失败: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("设置修改时间失败: %v", err)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+
+	oldResume := filepath.Join(dir, "resumes", "old.pdf")
+	newResume := filepath.Join(dir, "resumes", "new.pdf")
+	oldBatch := filepath.Join(dir, "batch", "1-abc", "archive", "a.txt")
+	writeFile(t, oldResume, old)
+	writeFile(t, newResume, now)
+	writeFile(t, oldBatch, old)
+	// 刚创建的空目录不应被删除
+	if err := os.MkdirAll(filepath.Join(dir, "batch", "2-new"), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+
+	removed, err := Sweep(dir, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("清理失败: %v", err)
+	}
+	if len(removed) != 2 {
+		t.Fatalf("应删除2个文件，实际删除: %v", removed)
+	}
+	if _, err := os.Stat(oldResume); !os.IsNotExist(err) {
+		t.Fatalf("过期文件未被删除")
+	}
+	if _, err := os.Stat(newResume); err != nil {
+		t.Fatalf("未过期的文件被删除: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "batch", "1-abc")); !os.IsNotExist(err) {
+		t.Fatalf("清空的批次目录未被删除")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "batch", "2-new")); err != nil {
+		t.Fatalf("新建的空目录被删除: %v", err)
+	}
+
+	// 目录不存在时不报错
+	if _, err := Sweep(filepath.Join(dir, "missing"), now); err != nil {
+		t.Fatalf("目录不存在时不应报错: %v", err)
+	}
+}
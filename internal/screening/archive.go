@@ -0,0 +1,142 @@
+package screening
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SupportedExtensions 批量筛选支持的简历文件格式
+var SupportedExtensions = map[string]bool{
+	".pdf":  true,
+	".txt":  true,
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+}
+
+// ErrArchiveTooLarge 表示压缩包中的文件数量或大小超过限制
+var ErrArchiveTooLarge = errors.New("压缩包超出限制")
+
+// SaveFunc 保存压缩包中的一个文件，name是去掉目录并去重后的文件名，返回保存后的路径
+type SaveFunc func(name string, r io.Reader) (string, error)
+
+// ExtractZip 将zip压缩包中的简历解压到destDir
+// 目录结构会被展平，同名文件自动加序号；maxFiles和maxFileSize限制文件数量和单个文件大小
+func ExtractZip(zipPath, destDir string, maxFiles int, maxFileSize int64) ([]Candidate, error) {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建解压目录失败: %w", err)
+	}
+	return ExtractZipWith(zipPath, maxFiles, maxFileSize, func(name string, r io.Reader) (string, error) {
+		path := filepath.Join(destDir, name)
+		dst, err := os.Create(path)
+		if err != nil {
+			return "", fmt.Errorf("创建文件%s失败: %w", path, err)
+		}
+		defer dst.Close()
+		if _, err := io.Copy(dst, r); err != nil {
+			return "", err
+		}
+		return path, nil
+	})
+}
+
+// ExtractZipWith 逐个读取zip压缩包中的文件并交给save保存，save可以加密后写入
+// 不支持的格式不解压，作为没有文件路径的候选人返回，筛选结果中记为失败；
+// 这些文件同样计入maxFiles，maxFileSize按实际读取的字节数限制单个文件大小
+func ExtractZipWith(zipPath string, maxFiles int, maxFileSize int64, save SaveFunc) ([]Candidate, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开压缩包失败: %w", err)
+	}
+	defer reader.Close()
+
+	var candidates []Candidate
+	used := make(map[string]bool)
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		// 只取文件名，避免压缩包中的路径穿越
+		name := filepath.Base(filepath.FromSlash(f.Name))
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+
+		if maxFiles > 0 && len(candidates) >= maxFiles {
+			return nil, fmt.Errorf("%w: 最多支持%d个文件", ErrArchiveTooLarge, maxFiles)
+		}
+		name = uniqueName(name, used)
+		if !SupportedExtensions[strings.ToLower(filepath.Ext(name))] {
+			candidates = append(candidates, Candidate{FileName: name})
+			continue
+		}
+		if maxFileSize > 0 && f.UncompressedSize64 > uint64(maxFileSize) {
+			return nil, fmt.Errorf("%w: 文件%s超过%d字节", ErrArchiveTooLarge, name, maxFileSize)
+		}
+
+		path, err := extractFile(f, name, maxFileSize, save)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, Candidate{FileName: name, FilePath: path})
+	}
+
+	return candidates, nil
+}
+
+// extractFile 解压单个文件，实际大小超过限制时报错
+func extractFile(f *zip.File, name string, maxFileSize int64, save SaveFunc) (string, error) {
+	src, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("读取压缩文件%s失败: %w", f.Name, err)
+	}
+	defer src.Close()
+
+	// 不信任压缩包头中声明的大小，按实际读取的字节数限制
+	var reader io.Reader = src
+	if maxFileSize > 0 {
+		reader = &limitReader{r: src, remaining: maxFileSize, name: f.Name}
+	}
+	path, err := save(name, reader)
+	if err != nil {
+		return "", fmt.Errorf("解压文件%s失败: %w", f.Name, err)
+	}
+	return path, nil
+}
+
+// limitReader 读取的字节数超过上限时返回ErrArchiveTooLarge
+type limitReader struct {
+	r         io.Reader
+	remaining int64
+	name      string
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, fmt.Errorf("%w: 文件%s超过大小限制", ErrArchiveTooLarge, l.name)
+	}
+	return n, err
+}
+
+// uniqueName 为重名文件追加序号
+func uniqueName(name string, used map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s_%d%s", base, i, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
@@ -0,0 +1,110 @@
+package screening
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/10yihang/resume-ai-interview/models"
+)
+
+// Candidate 表示批量筛选中的一份简历文件
+type Candidate struct {
+	FileName string // 原始文件名
+	FilePath string // 本地文件路径
+}
+
+// ParseFunc 将简历文件解析为结构化简历
+type ParseFunc func(ctx context.Context, candidate Candidate) (*models.Resume, error)
+
+// Result 表示单个候选人的筛选结果
+type Result struct {
+	Rank     int            `json:"rank"` // 排名，解析失败的候选人为0
+	FileName string         `json:"fileName"`
+	ResumeID string         `json:"resumeId,omitempty"` // 解析出的简历ID，由解析函数设置
+	Name     string         `json:"name"`
+	Score    int            `json:"score"`
+	Matched  []string       `json:"matched"`
+	Missing  []string       `json:"missing"`
+	Error    string         `json:"error,omitempty"` // 解析失败原因
+	Resume   *models.Resume `json:"-"`
+}
+
+// Shortlist 表示一次批量筛选的排名结果
+type Shortlist struct {
+	Total     int      `json:"total"`
+	Succeeded int      `json:"succeeded"`
+	Failed    int      `json:"failed"`
+	Results   []Result `json:"results"`
+}
+
+// Screen 以最多parallelism个并发解析并匹配所有候选人，返回按分数排序的结果
+// 单个候选人解析失败不会影响其他候选人，失败原因记录在结果中并排在最后
+func Screen(ctx context.Context, candidates []Candidate, jd *models.JobDescription, parse ParseFunc, matcher Matcher, parallelism int) *Shortlist {
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+
+	results := make([]Result, len(candidates))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+
+	for i, candidate := range candidates {
+		wg.Add(1)
+		go func(i int, candidate Candidate) {
+			defer wg.Done()
+			results[i] = Result{FileName: candidate.FileName}
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i].Error = fmt.Sprintf("筛选已取消: %v", ctx.Err())
+				return
+			}
+
+			resume, err := parse(ctx, candidate)
+			if err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+
+			match := matcher.Match(resume, jd)
+			results[i].ResumeID = resume.ID
+			results[i].Name = resume.Name
+			results[i].Score = match.Score
+			results[i].Matched = match.Matched
+			results[i].Missing = match.Missing
+			results[i].Resume = resume
+		}(i, candidate)
+	}
+	wg.Wait()
+
+	return rank(results)
+}
+
+// rank 对结果排序并填写排名
+func rank(results []Result) *Shortlist {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if (a.Error == "") != (b.Error == "") {
+			return a.Error == ""
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.FileName < b.FileName
+	})
+
+	shortlist := &Shortlist{Total: len(results), Results: results}
+	for i := range results {
+		if results[i].Error != "" {
+			shortlist.Failed++
+			continue
+		}
+		shortlist.Succeeded++
+		results[i].Rank = shortlist.Succeeded
+	}
+	return shortlist
+}
@@ -0,0 +1,168 @@
+// Package screening 提供简历与岗位JD的匹配打分和批量筛选功能
+package screening
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/10yihang/resume-ai-interview/models"
+)
+
+// MatchResult 表示一份简历与JD的匹配结果
+type MatchResult struct {
+	Score   int      `json:"score"`   // 匹配分数，0-100
+	Matched []string `json:"matched"` // 满足的职位要求
+	Missing []string `json:"missing"` // 未体现的职位要求
+}
+
+// Matcher 定义了简历与JD的匹配器
+type Matcher interface {
+	Match(resume *models.Resume, jd *models.JobDescription) MatchResult
+}
+
+// KeywordMatcher 基于关键词覆盖率的匹配器
+// 将每条职位要求拆分为英文单词和中文双字词，简历中出现的比例达到阈值即视为满足
+type KeywordMatcher struct {
+	Threshold float64 // 单条要求被视为满足的关键词覆盖率，默认0.5
+}
+
+// NewKeywordMatcher 创建关键词匹配器
+func NewKeywordMatcher() *KeywordMatcher {
+	return &KeywordMatcher{Threshold: 0.5}
+}
+
+// Match 计算简历对JD要求的覆盖情况
+func (m *KeywordMatcher) Match(resume *models.Resume, jd *models.JobDescription) MatchResult {
+	result := MatchResult{
+		Matched: []string{},
+		Missing: []string{},
+	}
+
+	resumeText := strings.ToLower(strings.Join([]string{
+		strings.Join(resume.Skills, " "),
+		strings.Join(resume.Experience, " "),
+		strings.Join(resume.Education, " "),
+		resume.RawText,
+	}, "\n"))
+
+	requirements := jd.Requirements
+	if len(requirements) == 0 && strings.TrimSpace(jd.RawText) != "" {
+		// JD未解析出要求列表时，按行使用原始文本
+		requirements = splitLines(jd.RawText)
+	}
+	if len(requirements) == 0 {
+		return result
+	}
+
+	var totalCoverage float64
+	for _, requirement := range requirements {
+		terms := extractTerms(requirement)
+		if len(terms) == 0 {
+			continue
+		}
+
+		found := 0
+		for _, term := range terms {
+			if strings.Contains(resumeText, term) {
+				found++
+			}
+		}
+
+		coverage := float64(found) / float64(len(terms))
+		totalCoverage += coverage
+		if coverage >= m.Threshold {
+			result.Matched = append(result.Matched, requirement)
+		} else {
+			result.Missing = append(result.Missing, requirement)
+		}
+	}
+
+	counted := len(result.Matched) + len(result.Missing)
+	if counted == 0 {
+		return result
+	}
+
+	// 分数由满足的要求比例和整体关键词覆盖率各占一半
+	matchedRatio := float64(len(result.Matched)) / float64(counted)
+	coverageRatio := totalCoverage / float64(counted)
+	result.Score = int(math.Round((matchedRatio*0.5 + coverageRatio*0.5) * 100))
+	return result
+}
+
+// stopTerms 在要求中出现但不具区分度的常见词
+var stopTerms = map[string]bool{
+	"以上": true, "熟悉": true, "精通": true, "了解": true, "掌握": true, "具有": true, "具备": true,
+	"经验": true, "能力": true, "良好": true, "相关": true, "优先": true, "年以": true, "工作": true,
+	"and": true, "the": true, "with": true, "for": true, "experience": true, "years": true,
+}
+
+// extractTerms 将一条要求拆分为用于匹配的关键词
+// 连续的字母数字作为一个英文词，连续的中文切分为相邻双字词
+func extractTerms(text string) []string {
+	text = strings.ToLower(text)
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		if term == "" || stopTerms[term] || seen[term] {
+			return
+		}
+		seen[term] = true
+		terms = append(terms, term)
+	}
+
+	var word []rune
+	var han []rune
+	flushWord := func() {
+		// 单个字母（如C）在简历中几乎必然出现，不作为关键词
+		if len(word) >= 2 {
+			add(string(word))
+		}
+		word = word[:0]
+	}
+	flushHan := func() {
+		if len(han) == 1 {
+			add(string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			add(string(han[i : i+2]))
+		}
+		han = han[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '+' || r == '#':
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+
+	// 纯数字（如“5年”中的5）不具备区分度
+	filtered := terms[:0]
+	for _, term := range terms {
+		if strings.TrimFunc(term, unicode.IsDigit) != "" {
+			filtered = append(filtered, term)
+		}
+	}
+	return filtered
+}
+
+// splitLines 将文本按行拆分并去掉空行
+func splitLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,132 @@
+package screening
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/10yihang/resume-ai-interview/models"
+)
+
+func TestKeywordMatcher(t *testing.T) {
+	jd := &models.JobDescription{
+		Requirements: []string{"5年以上Go语言开发经验", "熟悉Kubernetes和Docker", "精通机器学习算法"},
+	}
+	resume := &models.Resume{
+		Skills:     []string{"Go", "Docker", "Kubernetes"},
+		Experience: []string{"云智科技，后端开发，使用Go语言开发微服务"},
+	}
+
+	result := NewKeywordMatcher().Match(resume, jd)
+	if len(result.Matched) != 2 || len(result.Missing) != 1 || result.Missing[0] != "精通机器学习算法" {
+		t.Errorf("匹配结果不正确: %+v", result)
+	}
+	if result.Score <= 50 || result.Score >= 100 {
+		t.Errorf("部分匹配的分数应在50到100之间，实际为%d", result.Score)
+	}
+
+	empty := NewKeywordMatcher().Match(&models.Resume{}, jd)
+	if empty.Score != 0 || len(empty.Missing) != 3 {
+		t.Errorf("空简历应得0分: %+v", empty)
+	}
+}
+
+func TestScreenRanksAndLimitsParallelism(t *testing.T) {
+	jd := &models.JobDescription{Requirements: []string{"Go", "Docker", "Kubernetes"}}
+	skills := map[string][]string{
+		"a.pdf": {"Go"},
+		"b.pdf": {"Go", "Docker", "Kubernetes"},
+		"c.pdf": {"Go", "Docker"},
+	}
+
+	var running, maxRunning int32
+	parse := func(ctx context.Context, c Candidate) (*models.Resume, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		if c.FileName == "broken.pdf" {
+			return nil, errors.New("文件损坏")
+		}
+		return &models.Resume{Name: c.FileName, Skills: skills[c.FileName]}, nil
+	}
+
+	candidates := []Candidate{{FileName: "a.pdf"}, {FileName: "broken.pdf"}, {FileName: "b.pdf"}, {FileName: "c.pdf"}}
+	shortlist := Screen(context.Background(), candidates, jd, parse, NewKeywordMatcher(), 2)
+
+	if maxRunning > 2 {
+		t.Errorf("并发数不应超过2，实际为%d", maxRunning)
+	}
+	if shortlist.Total != 4 || shortlist.Succeeded != 3 || shortlist.Failed != 1 {
+		t.Fatalf("统计不正确: %+v", shortlist)
+	}
+
+	order := []string{"b.pdf", "c.pdf", "a.pdf", "broken.pdf"}
+	for i, name := range order {
+		if shortlist.Results[i].FileName != name {
+			t.Fatalf("第%d名应为%s，实际为%s", i+1, name, shortlist.Results[i].FileName)
+		}
+	}
+	if last := shortlist.Results[3]; last.Rank != 0 || last.Error != "文件损坏" {
+		t.Errorf("失败的候选人应记录原因且不参与排名: %+v", last)
+	}
+}
+
+func TestExtractZip(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "resumes.zip")
+
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatalf("创建压缩包失败: %v", err)
+	}
+	w := zip.NewWriter(f)
+	files := map[string]string{
+		"张三.txt":            "张三的简历",
+		"team/张三.txt":       "另一个张三",
+		"../../escape.txt":  "路径穿越",
+		"notes.docx":        "不支持的格式",
+		"__MACOSX/._张三.txt": "系统文件",
+	}
+	for name, content := range files {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("写入压缩包失败: %v", err)
+		}
+		entry.Write([]byte(content))
+	}
+	w.Close()
+	f.Close()
+
+	destDir := filepath.Join(dir, "out")
+	candidates, err := ExtractZip(zipPath, destDir, 10, 1024)
+	if err != nil {
+		t.Fatalf("解压失败: %v", err)
+	}
+	if len(candidates) != 4 {
+		t.Fatalf("应返回3个解压的文件和1个不支持的文件，实际为%d: %+v", len(candidates), candidates)
+	}
+	for _, c := range candidates {
+		if c.FileName == "notes.docx" {
+			if c.FilePath != "" {
+				t.Errorf("不支持的格式不应解压: %s", c.FilePath)
+			}
+			continue
+		}
+		if filepath.Dir(c.FilePath) != destDir {
+			t.Errorf("文件不应解压到目标目录之外: %s", c.FilePath)
+		}
+	}
+
+	if _, err := ExtractZip(zipPath, filepath.Join(dir, "limited"), 3, 1024); !errors.Is(err, ErrArchiveTooLarge) {
+		t.Errorf("超过文件数量限制应返回ErrArchiveTooLarge，实际为%v", err)
+	}
+}
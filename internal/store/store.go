@@ -0,0 +1,81 @@
+// Package store 提供并发安全的内存数据存储
+package store
+
+import (
+	"sort"
+	"sync"
+)
+
+// Store 是以字符串ID为键、读写加锁保护的存储
+type Store[T any] struct {
+	mu    sync.RWMutex
+	items map[string]T
+}
+
+// New 创建一个空的存储
+func New[T any]() *Store[T] {
+	return &Store[T]{
+		items: make(map[string]T),
+	}
+}
+
+// Get 获取指定ID的数据
+func (s *Store[T]) Get(id string) (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[id]
+	return item, ok
+}
+
+// Put 保存数据，已存在时覆盖
+func (s *Store[T]) Put(id string, item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[id] = item
+}
+
+// Update 在写锁内根据旧数据计算并保存新数据，返回新数据和数据是否存在
+// 数据为指针时fn应返回修改后的副本，避免与持有旧数据的读者产生竞争
+func (s *Store[T]) Update(id string, fn func(T) T) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	item = fn(item)
+	s.items[id] = item
+	return item, true
+}
+
+// Delete 删除指定ID的数据，返回数据是否存在
+func (s *Store[T]) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.items[id]
+	delete(s.items, id)
+	return ok
+}
+
+// Values 返回按ID字典序排列的所有数据
+func (s *Store[T]) Values() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.items))
+	for id := range s.items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	values := make([]T, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, s.items[id])
+	}
+	return values
+}
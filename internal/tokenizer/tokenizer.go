@@ -0,0 +1,81 @@
+// Package tokenizer 按模型的分词器统计文本的token数，用于判断文本是否需要分块发送给大模型
+package tokenizer
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// fallbackEncoding 是没有公开分词器的模型（如Grok）使用的编码，多语言文本的切分与新模型接近
+const fallbackEncoding = tiktoken.MODEL_O200K_BASE
+
+func init() {
+	// 使用随程序打包的词表，不在运行时下载
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+var (
+	mu       sync.Mutex
+	encoders = map[string]*tiktoken.Tiktoken{}
+)
+
+// Count 返回文本在模型分词器下的token数
+// OpenAI的模型使用对应的编码，如gpt-4o使用o200k_base，其他模型使用o200k_base近似；
+// 分词器加载失败时按每个token约3字节估算
+func Count(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	encoder := encoderFor(model)
+	if encoder == nil {
+		return (len(text) + 2) / 3
+	}
+	return len(encoder.EncodeOrdinary(text))
+}
+
+// Truncate 返回text中不超过limit个token的最长前缀，不会切开一个多字节字符
+func Truncate(model, text string, limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	if Count(model, text) <= limit {
+		return text
+	}
+	var starts []int // 各字符的起始位置
+	for i := range text {
+		starts = append(starts, i)
+	}
+	n := sort.Search(len(starts), func(i int) bool { return Count(model, text[:starts[i]]) > limit })
+	return text[:starts[n-1]]
+}
+
+// encoderFor 返回模型使用的编码器，同一编码的编码器只创建一次
+func encoderFor(model string) *tiktoken.Tiktoken {
+	name := fallbackEncoding
+	if encoding, ok := tiktoken.MODEL_TO_ENCODING[model]; ok {
+		name = encoding
+	} else {
+		for prefix, encoding := range tiktoken.MODEL_PREFIX_TO_ENCODING {
+			if strings.HasPrefix(model, prefix) {
+				name = encoding
+				break
+			}
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if encoder, ok := encoders[name]; ok {
+		return encoder
+	}
+	encoder, err := tiktoken.GetEncoding(name)
+	if err != nil {
+		encoder = nil
+	}
+	encoders[name] = encoder
+	return encoder
+}
@@ -0,0 +1,38 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCount(t *testing.T) {
+	if n := Count("gpt-4o", "hello world"); n != 2 {
+		t.Fatalf("gpt-4o下hello world应为2个token，实际%d", n)
+	}
+	if n := Count("gpt-4", "hello world"); n != 2 {
+		t.Fatalf("gpt-4下hello world应为2个token，实际%d", n)
+	}
+	if Count("grok-3-latest", "") != 0 {
+		t.Fatalf("空文本应为0个token")
+	}
+	// 中文按字节切分，token数远多于按空格切分的词数
+	text := strings.Repeat("负责核心交易系统的设计与开发", 10)
+	if n := Count("grok-3-latest", text); n <= len(strings.Fields(text))*2 {
+		t.Fatalf("中文文本的token数不应按词数估计，实际%d", n)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	text := strings.Repeat("熟悉Go和分布式系统，", 50)
+	head := Truncate("gpt-4o", text, 30)
+	if n := Count("gpt-4o", head); n > 30 || n < 25 {
+		t.Fatalf("截断后应接近且不超过30个token，实际%d", n)
+	}
+	if !utf8.ValidString(head) || !strings.HasPrefix(text, head) {
+		t.Fatalf("截断结果应是原文按字符截断的前缀")
+	}
+	if Truncate("gpt-4o", "短文本", 30) != "短文本" {
+		t.Fatalf("不超过上限时应返回原文")
+	}
+}
@@ -0,0 +1,113 @@
+// Package tracing 配置OpenTelemetry链路追踪，为请求、文件解析、OCR和大模型调用创建span
+// 未启用导出时使用OpenTelemetry默认的空实现，创建span几乎没有开销
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName 是创建span时使用的Tracer名称
+const instrumentationName = "github.com/10yihang/resume-ai-interview"
+
+// 导出方式
+const (
+	ExporterNone   = "none"   // 不导出
+	ExporterStdout = "stdout" // 以JSON格式输出到标准输出
+	ExporterOTLP   = "otlp"   // 通过OTLP/HTTP发送到采集器
+)
+
+// traceParentHeader 是W3C Trace Context中传递父span的请求头
+const traceParentHeader = "traceparent"
+
+// propagator 使用W3C Trace Context在服务之间传递追踪上下文
+var propagator = propagation.TraceContext{}
+
+// Setup 按exporter配置全局的TracerProvider，返回关闭时导出剩余span的函数
+// exporter为空或none时不导出；OTLP的地址、请求头等通过标准的OTEL_EXPORTER_OTLP_*环境变量配置，
+// 服务名默认为serviceName，可通过OTEL_SERVICE_NAME覆盖
+func Setup(ctx context.Context, exporter, serviceName string, sampleRatio float64) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagator)
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(exporter) {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("不支持的追踪导出方式: %s", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("创建追踪导出器失败: %w", err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建追踪资源失败: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start 开始一个内部span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartRequest 为收到的HTTP请求开始一个服务端span，请求头带有traceparent时作为其子span
+func StartRequest(ctx context.Context, header http.Header, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx = propagator.Extract(ctx, propagation.HeaderCarrier(header))
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+}
+
+// End 结束span，err不为nil时记录错误并把状态设为Error
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceParent 返回ctx中span的W3C traceparent，没有采样的span时返回空字符串
+// 用于把后台任务关联到提交它的请求
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier[traceParentHeader]
+}
+
+// WithTraceParent 返回以traceParent为父span的context，traceParent为空时原样返回
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier{traceParentHeader: traceParent})
+}
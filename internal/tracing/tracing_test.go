@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// useRecorder 把全局TracerProvider换成记录span的实现，测试结束后恢复
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestStartRequestContinuesIncomingTrace(t *testing.T) {
+	recorder := useRecorder(t)
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, span := StartRequest(context.Background(), header, "POST /api/v1/resumes")
+	_, child := Start(ctx, "parser.ParseFile")
+	End(child, errors.New("解析失败"))
+	End(span, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("期望记录2个span，得到%d个", len(spans))
+	}
+	parsed, request := spans[0], spans[1]
+	if request.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("请求span应沿用传入的trace id，得到%s", request.SpanContext().TraceID())
+	}
+	if parsed.Parent().SpanID() != request.SpanContext().SpanID() {
+		t.Fatal("解析span应是请求span的子span")
+	}
+	if parsed.Status().Code != codes.Error || len(parsed.Events()) == 0 {
+		t.Fatalf("出错的span应记录错误和Error状态，得到%v", parsed.Status())
+	}
+	if request.Status().Code == codes.Error {
+		t.Fatal("成功的span不应标记为Error")
+	}
+}
+
+func TestTraceParentRoundTrip(t *testing.T) {
+	recorder := useRecorder(t)
+
+	if TraceParent(context.Background()) != "" {
+		t.Fatal("没有span的context不应有traceparent")
+	}
+	ctx, span := Start(context.Background(), "submit")
+	traceParent := TraceParent(ctx)
+	span.End()
+	if traceParent == "" {
+		t.Fatal("采样的span应有traceparent")
+	}
+
+	// 模拟后台任务在另一个context中继续同一条链路
+	_, job := Start(WithTraceParent(context.Background(), traceParent), "job.resume")
+	job.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[1].Parent().SpanID() != spans[0].SpanContext().SpanID() {
+		t.Fatal("任务span应挂在提交请求的span下")
+	}
+}
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), ExporterNone, "test", 1)
+	if err != nil || shutdown(context.Background()) != nil {
+		t.Fatalf("不导出时不应出错: %v", err)
+	}
+	if _, err := Setup(context.Background(), "zipkin", "test", 1); err == nil {
+		t.Fatal("不支持的导出方式应返回错误")
+	}
+}
@@ -0,0 +1,388 @@
+// Package usage 记录大模型调用的token用量和费用，按用户、会话、日期和模型汇总，并检查预算
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded 表示用户或会话的费用已超出预算
+var ErrBudgetExceeded = errors.New("已超出大模型费用预算")
+
+// 汇总的维度
+const (
+	GroupByUser    = "user"
+	GroupBySession = "session"
+	GroupByDay     = "day"
+	GroupByModel   = "model"
+)
+
+// dayLayout 是按日期汇总时的键格式，使用服务器本地时区
+const dayLayout = "2006-01-02"
+
+// memoryLimit 是不写文件时内存中保留的最近记录数，预算按累计费用检查，不受此限制
+const memoryLimit = 10000
+
+// Price 是一个模型的价格，单位为美元/百万token
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// Prices 是按模型名称索引的价格表
+type Prices map[string]Price
+
+// DefaultPrices 是内置的价格表，实际价格以服务商公布的为准，可通过ParsePrices覆盖
+var DefaultPrices = Prices{
+	"grok-3":              {Prompt: 3, Completion: 15},
+	"grok-3-latest":       {Prompt: 3, Completion: 15},
+	"gpt-4o":              {Prompt: 2.5, Completion: 10},
+	"gpt-4-turbo-preview": {Prompt: 10, Completion: 30},
+}
+
+// ParsePrices 在内置价格表的基础上解析自定义价格
+// 格式为 模型=输入价格:输出价格，多个用逗号分隔，如 "gpt-4o=2.5:10,grok-3=3:15"
+func ParsePrices(spec string) (Prices, error) {
+	prices := make(Prices, len(DefaultPrices))
+	for model, price := range DefaultPrices {
+		prices[model] = price
+	}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		model, value, ok := strings.Cut(item, "=")
+		prompt, completion, ok2 := strings.Cut(value, ":")
+		if !ok || !ok2 || strings.TrimSpace(model) == "" {
+			return nil, fmt.Errorf("价格格式错误，应为 模型=输入价格:输出价格: %s", item)
+		}
+		p, err := strconv.ParseFloat(strings.TrimSpace(prompt), 64)
+		if err != nil || p < 0 {
+			return nil, fmt.Errorf("输入价格无效: %s", item)
+		}
+		c, err := strconv.ParseFloat(strings.TrimSpace(completion), 64)
+		if err != nil || c < 0 {
+			return nil, fmt.Errorf("输出价格无效: %s", item)
+		}
+		prices[strings.TrimSpace(model)] = Price{Prompt: p, Completion: c}
+	}
+	return prices, nil
+}
+
+// Unpriced 返回models中价格表里没有的模型，这些模型的费用记为0，不会触发预算
+func (p Prices) Unpriced(models ...string) []string {
+	var unpriced []string
+	for _, model := range models {
+		if _, ok := p[model]; !ok && model != "" && !slices.Contains(unpriced, model) {
+			unpriced = append(unpriced, model)
+		}
+	}
+	return unpriced
+}
+
+// Cost 计算一次调用的费用（美元），价格表中没有的模型费用为0
+func (p Prices) Cost(model string, promptTokens, completionTokens int) float64 {
+	price := p[model]
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1e6
+}
+
+// Record 是一次大模型调用的用量记录
+type Record struct {
+	Time             time.Time `json:"time" binding:"required"`
+	UserID           string    `json:"userId" binding:"required"`
+	TeamID           string    `json:"teamId,omitempty"`
+	SessionID        string    `json:"sessionId,omitempty" doc:"面试会话，不属于会话的调用为空"`
+	Operation        string    `json:"operation" binding:"required" doc:"触发调用的操作，如createQuestionSet、parseResume"`
+	Provider         string    `json:"provider" binding:"required"`
+	Model            string    `json:"model" binding:"required"`
+	PromptTokens     int       `json:"promptTokens"`
+	CompletionTokens int       `json:"completionTokens"`
+	Cost             float64   `json:"cost" doc:"按价格表计算的费用（美元）"`
+}
+
+// Filter 是查询用量记录的条件，空字段表示不限
+type Filter struct {
+	TeamID    string
+	UserID    string
+	SessionID string
+	Since     time.Time
+	Until     time.Time
+}
+
+// Match 判断记录是否满足查询条件
+func (f Filter) Match(r Record) bool {
+	switch {
+	case f.TeamID != "" && r.TeamID != f.TeamID:
+		return false
+	case f.UserID != "" && r.UserID != f.UserID:
+		return false
+	case f.SessionID != "" && r.SessionID != f.SessionID:
+		return false
+	case !f.Since.IsZero() && r.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !r.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// Summary 是一组用量记录的汇总
+type Summary struct {
+	Key              string  `json:"key" binding:"required" doc:"汇总维度的取值：用户ID、会话ID、日期（YYYY-MM-DD）或模型"`
+	Calls            int     `json:"calls" binding:"required"`
+	PromptTokens     int     `json:"promptTokens" binding:"required"`
+	CompletionTokens int     `json:"completionTokens" binding:"required"`
+	TotalTokens      int     `json:"totalTokens" binding:"required"`
+	Cost             float64 `json:"cost" binding:"required" doc:"费用（美元）"`
+}
+
+// add 把一条记录计入汇总
+func (s *Summary) add(r Record) {
+	s.Calls++
+	s.PromptTokens += r.PromptTokens
+	s.CompletionTokens += r.CompletionTokens
+	s.TotalTokens += r.PromptTokens + r.CompletionTokens
+	s.Cost += r.Cost
+}
+
+// Budget 是费用预算（美元），0表示不限
+type Budget struct {
+	UserDaily float64 // 每个用户每天
+	Session   float64 // 每场面试会话
+}
+
+// Ledger 保存用量记录，并发安全
+// 配置了文件路径时记录以JSON Lines格式追加到文件，查询时读取整个文件，不写文件时在内存中保留最近的记录
+// 每个用户当天和每场会话的费用单独累计，检查预算时不需要扫描记录，启动时从文件恢复
+type Ledger struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	recent   []Record
+	prices   Prices
+	budget   Budget
+	today    string             // daily中累计的日期，更早的记录不计入每日费用
+	daily    map[string]float64 // 当天各用户的费用
+	sessions map[string]float64 // 各会话的费用
+}
+
+// NewLedger 创建用量账本，path为空时只保存在内存中
+func NewLedger(path string, prices Prices, budget Budget) (*Ledger, error) {
+	if prices == nil {
+		prices = DefaultPrices
+	}
+	l := &Ledger{path: path, prices: prices, budget: budget, daily: make(map[string]float64), sessions: make(map[string]float64)}
+	if path == "" {
+		return l, nil
+	}
+
+	if err := l.load(path); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建用量记录目录失败: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开用量记录文件失败: %w", err)
+	}
+	l.file = file
+	return l, nil
+}
+
+// load 从文件中的历史记录恢复当天和各会话的累计费用，文件不存在时忽略
+func (l *Ledger) load(path string) error {
+	return scan(path, func(r Record) { l.accumulate(r) })
+}
+
+// scan 按顺序读取文件中的每条记录，文件不存在时忽略
+func scan(path string, fn func(Record)) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取用量记录失败: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// 跳过写到一半的记录
+			continue
+		}
+		fn(r)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取用量记录失败: %w", err)
+	}
+	return nil
+}
+
+// accumulate 把一条记录的费用计入用户当天和会话的累计费用，进入新的一天时清空前一天的累计
+func (l *Ledger) accumulate(r Record) {
+	if r.SessionID != "" {
+		l.sessions[r.SessionID] += r.Cost
+	}
+	switch day := r.Time.Local().Format(dayLayout); {
+	case day > l.today:
+		l.today = day
+		clear(l.daily)
+		fallthrough
+	case day == l.today:
+		l.daily[r.UserID] += r.Cost
+	}
+}
+
+// Record 按价格表计算费用并保存一条记录，未设置时间时使用当前时间
+// 写文件失败不影响业务操作，只打印日志
+func (l *Ledger) Record(r Record) Record {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	r.Cost = l.prices.Cost(r.Model, r.PromptTokens, r.CompletionTokens)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.accumulate(r)
+	if l.path == "" {
+		if len(l.recent) >= memoryLimit {
+			l.recent = append(l.recent[:0], l.recent[len(l.recent)-memoryLimit/2:]...)
+		}
+		l.recent = append(l.recent, r)
+	}
+	if l.file != nil {
+		data, err := json.Marshal(r)
+		if err == nil {
+			_, err = l.file.Write(append(data, '\n'))
+		}
+		if err != nil {
+			slog.Error("写入用量记录失败", "error", err)
+		}
+	}
+	return r
+}
+
+// Query 按时间顺序返回满足条件的记录
+// 写文件时另外以只读方式打开文件扫描，不持有写入的锁
+func (l *Ledger) Query(filter Filter) ([]Record, error) {
+	var records []Record
+	collect := func(r Record) {
+		if filter.Match(r) {
+			records = append(records, r)
+		}
+	}
+	if l.path != "" {
+		if err := scan(l.path, collect); err != nil {
+			return nil, err
+		}
+		return records, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, r := range l.recent {
+		collect(r)
+	}
+	return records, nil
+}
+
+// Summarize 按维度汇总满足条件的记录，按日期汇总时按日期倒序，其余按费用从高到低排序
+func (l *Ledger) Summarize(filter Filter, groupBy string) ([]Summary, error) {
+	var keyOf func(Record) string
+	switch groupBy {
+	case GroupByUser:
+		keyOf = func(r Record) string { return r.UserID }
+	case GroupBySession:
+		keyOf = func(r Record) string { return r.SessionID }
+	case GroupByDay:
+		keyOf = func(r Record) string { return r.Time.Local().Format(dayLayout) }
+	case GroupByModel:
+		keyOf = func(r Record) string { return r.Model }
+	default:
+		return nil, fmt.Errorf("不支持的汇总维度: %s", groupBy)
+	}
+
+	records, err := l.Query(filter)
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]*Summary)
+	for _, r := range records {
+		key := keyOf(r)
+		if key == "" {
+			// 按会话汇总时忽略不属于会话的调用
+			continue
+		}
+		s, ok := byKey[key]
+		if !ok {
+			s = &Summary{Key: key}
+			byKey[key] = s
+		}
+		s.add(r)
+	}
+
+	summaries := make([]Summary, 0, len(byKey))
+	for _, s := range byKey {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if groupBy == GroupByDay {
+			return summaries[i].Key > summaries[j].Key
+		}
+		if summaries[i].Cost != summaries[j].Cost {
+			return summaries[i].Cost > summaries[j].Cost
+		}
+		return summaries[i].Key < summaries[j].Key
+	})
+	return summaries, nil
+}
+
+// CheckBudget 检查用户当天和会话的费用是否已达到预算，达到时返回包装了ErrBudgetExceeded的错误
+// sessionID为空时只检查用户预算
+func (l *Ledger) CheckBudget(userID, sessionID string, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.budget.UserDaily > 0 && userID != "" {
+		var spent float64
+		if now.Local().Format(dayLayout) == l.today {
+			spent = l.daily[userID]
+		}
+		if spent >= l.budget.UserDaily {
+			return fmt.Errorf("%w: 今日已使用%.4f美元，每日上限%.4f美元", ErrBudgetExceeded, spent, l.budget.UserDaily)
+		}
+	}
+	if l.budget.Session > 0 && sessionID != "" {
+		if spent := l.sessions[sessionID]; spent >= l.budget.Session {
+			return fmt.Errorf("%w: 本场面试已使用%.4f美元，上限%.4f美元", ErrBudgetExceeded, spent, l.budget.Session)
+		}
+	}
+	return nil
+}
+
+// Close 关闭用量记录文件
+func (l *Ledger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,102 @@
+package usage
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParsePrices(t *testing.T) {
+	prices, err := ParsePrices("gpt-4o=1:2, custom-model=0.5:1.5")
+	if err != nil {
+		t.Fatalf("解析价格失败: %v", err)
+	}
+	if prices["gpt-4o"] != (Price{Prompt: 1, Completion: 2}) {
+		t.Fatalf("自定义价格应覆盖内置价格: %+v", prices["gpt-4o"])
+	}
+	if prices["grok-3"] != DefaultPrices["grok-3"] {
+		t.Fatalf("未配置的模型应保留内置价格: %+v", prices["grok-3"])
+	}
+	if got := prices.Cost("custom-model", 1_000_000, 2_000_000); math.Abs(got-3.5) > 1e-9 {
+		t.Fatalf("费用计算错误: %v", got)
+	}
+	if got := prices.Cost("unknown", 1000, 1000); got != 0 {
+		t.Fatalf("价格表中没有的模型费用应为0: %v", got)
+	}
+	if got := prices.Unpriced("gpt-4o", "unknown", "", "unknown"); len(got) != 1 || got[0] != "unknown" {
+		t.Fatalf("应列出价格表中没有的模型: %v", got)
+	}
+
+	for _, spec := range []string{"gpt-4o", "gpt-4o=1", "=1:2", "gpt-4o=a:2", "gpt-4o=1:-2"} {
+		if _, err := ParsePrices(spec); err == nil {
+			t.Fatalf("应拒绝格式错误的价格: %q", spec)
+		}
+	}
+}
+
+func TestLedgerSummarizeAndBudget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	prices := Prices{"m": {Prompt: 1, Completion: 1}}
+	budget := Budget{UserDaily: 2, Session: 1}
+	l, err := NewLedger(path, prices, budget)
+	if err != nil {
+		t.Fatalf("创建用量账本失败: %v", err)
+	}
+
+	now := time.Now()
+	yesterday := now.Add(-24 * time.Hour)
+	l.Record(Record{Time: yesterday, UserID: "u1", TeamID: "t1", Operation: "parseResume", Provider: "openai", Model: "m", PromptTokens: 3_000_000})
+	l.Record(Record{Time: now, UserID: "u1", TeamID: "t1", SessionID: "s1", Operation: "createQuestionSet", Provider: "openai", Model: "m", PromptTokens: 400_000, CompletionTokens: 600_000})
+	l.Record(Record{Time: now, UserID: "u2", TeamID: "t2", Operation: "createQuestionSet", Provider: "grok", Model: "m", PromptTokens: 500_000})
+
+	byUser, err := l.Summarize(Filter{TeamID: "t1"}, GroupByUser)
+	if err != nil {
+		t.Fatalf("汇总失败: %v", err)
+	}
+	if len(byUser) != 1 || byUser[0].Key != "u1" || byUser[0].Calls != 2 || byUser[0].TotalTokens != 4_000_000 || math.Abs(byUser[0].Cost-4) > 1e-9 {
+		t.Fatalf("按用户汇总结果不正确: %+v", byUser)
+	}
+	byDay, _ := l.Summarize(Filter{UserID: "u1"}, GroupByDay)
+	if len(byDay) != 2 || byDay[0].Key != now.Local().Format(dayLayout) {
+		t.Fatalf("按日期汇总应按日期倒序: %+v", byDay)
+	}
+	bySession, _ := l.Summarize(Filter{}, GroupBySession)
+	if len(bySession) != 1 || bySession[0].Key != "s1" {
+		t.Fatalf("按会话汇总应忽略不属于会话的调用: %+v", bySession)
+	}
+	if _, err := l.Summarize(Filter{}, "team"); err == nil {
+		t.Fatalf("应拒绝不支持的汇总维度")
+	}
+
+	// u1今天只用了1美元，昨天的费用不计入每日预算，但会话s1已达到上限
+	if err := l.CheckBudget("u1", "", now); err != nil {
+		t.Fatalf("未超出每日预算时不应拒绝: %v", err)
+	}
+	if err := l.CheckBudget("u1", "s1", now); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("会话费用达到上限时应返回ErrBudgetExceeded: %v", err)
+	}
+	l.Record(Record{Time: now, UserID: "u1", Operation: "parseJD", Provider: "openai", Model: "m", CompletionTokens: 1_000_000})
+	if err := l.CheckBudget("u1", "", now); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("每日费用达到上限时应返回ErrBudgetExceeded: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("关闭用量账本失败: %v", err)
+	}
+
+	reopened, err := NewLedger(path, prices, budget)
+	if err != nil {
+		t.Fatalf("重新打开用量账本失败: %v", err)
+	}
+	defer reopened.Close()
+	if records, err := reopened.Query(Filter{}); err != nil || len(records) != 4 || records[3].Operation != "parseJD" {
+		t.Fatalf("重新打开后应能查询历史记录: %+v %v", records, err)
+	}
+	if err := reopened.CheckBudget("u1", "s1", now); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("重新打开后应从历史记录恢复累计费用: %v", err)
+	}
+	if err := reopened.CheckBudget("u1", "", now.Add(24*time.Hour)); err != nil {
+		t.Fatalf("第二天不应计入前一天的费用: %v", err)
+	}
+}
@@ -1,39 +1,69 @@
 package models
 
+import "time"
+
 // Resume 表示解析后的简历
 type Resume struct {
-	Name       string   `json:"name"`
-	Email      string   `json:"email"`
-	Phone      string   `json:"phone"`
-	Education  []string `json:"education"`
-	Experience []string `json:"experience"`
-	Skills     []string `json:"skills"`
-	RawText    string   `json:"rawText"`
-	FilePath   string   `json:"filePath"`
+	ID         string    `json:"id,omitempty"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	Phone      string    `json:"phone"`
+	Education  []string  `json:"education"`
+	Experience []string  `json:"experience"`
+	Skills     []string  `json:"skills"`
+	RawText    string    `json:"rawText"`
+	FilePath   string    `json:"filePath"`
+	CreatedAt  time.Time `json:"createdAt"`
+	OwnerID    string    `json:"ownerId,omitempty"` // 创建者的用户ID
+	TeamID     string    `json:"teamId,omitempty"`  // 创建者所在团队，团队成员均可访问
 }
 
 // JobDescription 表示岗位JD
 type JobDescription struct {
-	Title        string   `json:"title"`
-	Company      string   `json:"company"`
-	Description  string   `json:"description"`
-	Requirements []string `json:"requirements"`
-	RawText      string   `json:"rawText"`
-	FilePath     string   `json:"filePath"`
+	ID           string    `json:"id,omitempty"`
+	Title        string    `json:"title"`
+	Company      string    `json:"company"`
+	Description  string    `json:"description"`
+	Requirements []string  `json:"requirements"`
+	RawText      string    `json:"rawText"`
+	FilePath     string    `json:"filePath"`
+	CreatedAt    time.Time `json:"createdAt"`
+	OwnerID      string    `json:"ownerId,omitempty"`
+	TeamID       string    `json:"teamId,omitempty"`
 }
 
 // Question 表示面试问题
+// ReferenceAnswer和Rubric只对招聘者和面试官可见，候选人练习时会被隐藏
 type Question struct {
-	ID       int    `json:"id"`
-	Content  string `json:"content"`
-	Category string `json:"category"`
+	ID              int      `json:"id"`
+	Content         string   `json:"content"`
+	Category        string   `json:"category"`
+	ReferenceAnswer string   `json:"referenceAnswer,omitempty"` // 参考答案
+	Rubric          []string `json:"rubric,omitempty"`          // 评分要点
 }
 
 // QuestionSet 表示一组面试问题
 type QuestionSet struct {
+	ID        string     `json:"id,omitempty"`
 	ResumeID  string     `json:"resumeId"`
 	JDID      string     `json:"jdId"`
 	Questions []Question `json:"questions"`
+	CreatedAt time.Time  `json:"createdAt"`
+	OwnerID   string     `json:"ownerId,omitempty"`
+	TeamID    string     `json:"teamId,omitempty"`
+	// Provider和Model是实际生成问题的AI服务和模型，配置了多个AI服务时可能不是首选服务
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// Usage 是生成问题时大模型返回的token用量，只用于计费，不返回给客户端
+	Usage *TokenUsage `json:"-"`
+}
+
+// TokenUsage 表示一次大模型调用的token用量
+type TokenUsage struct {
+	Provider         string `json:"provider"`
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"promptTokens"`
+	CompletionTokens int    `json:"completionTokens"`
 }
 
 // Answer 表示面试回答
@@ -44,8 +74,44 @@ type Answer struct {
 
 // Evaluation 表示面试评估
 type Evaluation struct {
-	AnswerID    int    `json:"answerId"`
-	Score       int    `json:"score"`
-	Feedback    string `json:"feedback"`
-	Suggestions string `json:"suggestions"`
+	ID            string    `json:"id,omitempty"`
+	SessionID     string    `json:"sessionId,omitempty"`
+	QuestionSetID string    `json:"questionSetId,omitempty"`
+	AnswerID      int       `json:"answerId"`
+	Answer        string    `json:"answer,omitempty"`
+	Score         int       `json:"score"`
+	Feedback      string    `json:"feedback"`
+	Suggestions   string    `json:"suggestions"`
+	CreatedAt     time.Time `json:"createdAt"`
+	OwnerID       string    `json:"ownerId,omitempty"`
+	TeamID        string    `json:"teamId,omitempty"`
+	// Provider和Model是实际评估回答的AI服务和模型，配置了多个AI服务时可能不是首选服务
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// Usage 是评估时大模型返回的token用量，只用于计费，不返回给客户端
+	Usage *TokenUsage `json:"-"`
+}
+
+// SessionStatus 表示面试会话的状态
+type SessionStatus string
+
+// 面试会话状态
+const (
+	SessionActive    SessionStatus = "active"
+	SessionCompleted SessionStatus = "completed"
+)
+
+// Session 表示一次面试会话，记录基于某个问题集的所有回答评估
+type Session struct {
+	ID            string        `json:"id"`
+	QuestionSetID string        `json:"questionSetId"`
+	ResumeID      string        `json:"resumeId"`
+	JDID          string        `json:"jdId"`
+	InterviewerID string        `json:"interviewerId,omitempty"` // 分配的面试官，面试官只能看到分配给自己的会话
+	Status        SessionStatus `json:"status"`
+	EvaluationIDs []string      `json:"evaluationIds"`
+	CreatedAt     time.Time     `json:"createdAt"`
+	UpdatedAt     time.Time     `json:"updatedAt"`
+	OwnerID       string        `json:"ownerId,omitempty"`
+	TeamID        string        `json:"teamId,omitempty"`
 }
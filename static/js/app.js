@@ -23,10 +23,30 @@ document.addEventListener('DOMContentLoaded', () => {
     const submitAnswerBtn = document.getElementById('submitAnswerBtn');
     submitAnswerBtn.addEventListener('click', handleSubmitAnswer);
 
+    // 退出登录按钮，仅在启用认证时显示
+    const logoutBtn = document.getElementById('logoutBtn');
+    if (logoutBtn) {
+        logoutBtn.addEventListener('click', handleLogout);
+    }
+
     // 检查按钮状态
     checkGenerateButtonStatus();
 });
 
+// 登录过期时跳转到登录页
+function redirectIfUnauthorized(response) {
+    if (response.status === 401) {
+        window.location.href = '/login';
+        throw new Error('登录已过期，请重新登录');
+    }
+}
+
+// 退出登录
+async function handleLogout() {
+    await fetch('/api/v1/auth/logout', { method: 'POST' });
+    window.location.href = '/login';
+}
+
 // 处理简历上传
 async function handleResumeUpload(event) {
     event.preventDefault();
@@ -44,18 +64,24 @@ async function handleResumeUpload(event) {
             method: 'POST',
             body: formData
         });
+        redirectIfUnauthorized(response);
 
         const data = await response.json();
 
-        if (response.ok) {
-            resumeId = data.resumeId;
-            resumeInfoEl.classList.remove('d-none', 'alert-danger');
-            resumeInfoEl.classList.add('alert-success');
-            resumeInfoEl.textContent = `简历上传成功: ${data.resume.name || '未识别姓名'}`;
-            checkGenerateButtonStatus();
-        } else {
+        if (!response.ok) {
             throw new Error(data.error || '上传失败');
         }
+
+        // 文件在后台解析，等待任务完成
+        const job = await waitForJob(data.jobId, status => {
+            uploadBtn.innerHTML = `<span class="spinner-border spinner-border-sm" role="status" aria-hidden="true"></span> ${jobStatusText(status)}...`;
+        });
+
+        resumeId = job.resultId;
+        resumeInfoEl.classList.remove('d-none', 'alert-danger');
+        resumeInfoEl.classList.add('alert-success');
+        resumeInfoEl.textContent = `简历上传成功: ${job.result.name || '未识别姓名'}`;
+        checkGenerateButtonStatus();
     } catch (error) {
         resumeInfoEl.classList.remove('d-none', 'alert-success');
         resumeInfoEl.classList.add('alert-danger');
@@ -83,18 +109,24 @@ async function handleJDUpload(event) {
             method: 'POST',
             body: formData
         });
+        redirectIfUnauthorized(response);
 
         const data = await response.json();
 
-        if (response.ok) {
-            jdId = data.jdId;
-            jdInfoEl.classList.remove('d-none', 'alert-danger');
-            jdInfoEl.classList.add('alert-success');
-            jdInfoEl.textContent = `JD上传成功: ${data.jd.title || '未识别职位名称'}`;
-            checkGenerateButtonStatus();
-        } else {
+        if (!response.ok) {
             throw new Error(data.error || '上传失败');
         }
+
+        // 文件在后台解析，等待任务完成
+        const job = await waitForJob(data.jobId, status => {
+            uploadBtn.innerHTML = `<span class="spinner-border spinner-border-sm" role="status" aria-hidden="true"></span> ${jobStatusText(status)}...`;
+        });
+
+        jdId = job.resultId;
+        jdInfoEl.classList.remove('d-none', 'alert-danger');
+        jdInfoEl.classList.add('alert-success');
+        jdInfoEl.textContent = `JD上传成功: ${job.result.title || '未识别职位名称'}`;
+        checkGenerateButtonStatus();
     } catch (error) {
         jdInfoEl.classList.remove('d-none', 'alert-success');
         jdInfoEl.classList.add('alert-danger');
@@ -105,12 +137,108 @@ async function handleJDUpload(event) {
     }
 }
 
+// 后台任务状态的显示文本
+function jobStatusText(status) {
+    switch (status) {
+        case 'queued': return '排队中';
+        case 'ocr': return '识别文件中';
+        case 'parsing': return 'AI解析中';
+        default: return '处理中';
+    }
+}
+
+// 订阅后台任务状态，任务完成时返回任务信息，失败时抛出错误
+function waitForJob(jobId, onStatus) {
+    return new Promise((resolve, reject) => {
+        const source = new EventSource(`/jobs/${jobId}/events`);
+        source.addEventListener('status', event => {
+            const job = JSON.parse(event.data);
+            if (job.status === 'done') {
+                source.close();
+                resolve(job);
+            } else if (job.status === 'failed') {
+                source.close();
+                reject(new Error(job.error || '解析失败'));
+            } else {
+                onStatus(job.status);
+            }
+        });
+        source.onerror = () => {
+            source.close();
+            reject(new Error('无法获取解析进度'));
+        };
+    });
+}
+
 // 检查生成按钮状态
 function checkGenerateButtonStatus() {
     const generateBtn = document.getElementById('generateBtn');
     generateBtn.disabled = !(resumeId && jdId);
 }
 
+// 读取SSE事件流，每收到一个事件调用onEvent(event, data)
+async function readEventStream(response, onEvent) {
+    const reader = response.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = '';
+
+    while (true) {
+        const { value, done } = await reader.read();
+        if (done) break;
+        buffer += decoder.decode(value, { stream: true });
+
+        // 事件之间以空行分隔
+        let boundary;
+        while ((boundary = buffer.indexOf('\n\n')) >= 0) {
+            const rawEvent = buffer.slice(0, boundary);
+            buffer = buffer.slice(boundary + 2);
+
+            let event = 'message';
+            const dataLines = [];
+            rawEvent.split('\n').forEach(line => {
+                if (line.startsWith('event:')) {
+                    event = line.slice(6).trim();
+                } else if (line.startsWith('data:')) {
+                    dataLines.push(line.slice(5));
+                }
+            });
+            if (dataLines.length > 0) {
+                onEvent(event, JSON.parse(dataLines.join('\n')));
+            }
+        }
+    }
+}
+
+// 发送POST请求并以SSE方式处理响应
+async function postEventStream(url, body, onEvent) {
+    const response = await fetch(url, {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json',
+            'Accept': 'text/event-stream',
+        },
+        body: JSON.stringify(body)
+    });
+    redirectIfUnauthorized(response);
+
+    if (!response.ok) {
+        const data = await response.json();
+        throw new Error(data.error || '请求失败');
+    }
+
+    let streamError = null;
+    await readEventStream(response, (event, data) => {
+        if (event === 'error') {
+            streamError = new Error(data.error || '请求失败');
+            return;
+        }
+        onEvent(event, data);
+    });
+    if (streamError) {
+        throw streamError;
+    }
+}
+
 // 处理生成问题
 async function handleGenerateQuestions() {
     if (!resumeId || !jdId) {
@@ -123,27 +251,24 @@ async function handleGenerateQuestions() {
     generateBtn.innerHTML = '<span class="spinner-border spinner-border-sm" role="status" aria-hidden="true"></span> 生成中...';
 
     try {
-        const response = await fetch('/generate/questions', {
-            method: 'POST',
-            headers: {
-                'Content-Type': 'application/json',
-            },
-            body: JSON.stringify({
-                resumeId: resumeId,
-                jdId: jdId
-            })
-        });
-
-        const data = await response.json();
+        // 问题逐个到达时立即显示
+        currentQuestions = [];
+        displayQuestions(currentQuestions);
 
-        if (response.ok) {
-            // 显示问题列表
-            questionSetId = `${resumeId}_${jdId}`;
-            currentQuestions = data.questions.questions;
-            displayQuestions(currentQuestions);
-        } else {
-            throw new Error(data.error || '生成问题失败');
-        }
+        await postEventStream('/generate/questions/stream', {
+            resumeId: resumeId,
+            jdId: jdId
+        }, (event, data) => {
+            if (event === 'question') {
+                currentQuestions.push(data);
+                appendQuestion(data);
+            } else if (event === 'done') {
+                // 以最终解析结果为准
+                questionSetId = data.questionSetId;
+                currentQuestions = data.questions.questions;
+                displayQuestions(currentQuestions);
+            }
+        });
     } catch (error) {
         alert(`错误: ${error.message}`);
     } finally {
@@ -161,25 +286,30 @@ function displayQuestions(questions) {
     questionsList.innerHTML = '';
     
     // 添加问题到列表
-    questions.forEach(question => {
-        const item = document.createElement('a');
-        item.href = '#';
-        item.className = 'list-group-item list-group-item-action question-item';
-        item.dataset.id = question.id;
-        item.innerHTML = `
-            <div class="d-flex w-100 justify-content-between">
-                <h6 class="mb-1">${question.content}</h6>
-                <small>${question.category}</small>
-            </div>
-        `;
-        item.addEventListener('click', () => selectQuestion(question));
-        questionsList.appendChild(item);
-    });
+    questions.forEach(appendQuestion);
     
     // 显示问题容器
     questionsContainer.classList.remove('d-none');
 }
 
+// 向列表追加一个问题
+function appendQuestion(question) {
+    const questionsList = document.getElementById('questionsList');
+    const item = document.createElement('a');
+    item.href = '#';
+    item.className = 'list-group-item list-group-item-action question-item';
+    item.dataset.id = question.id;
+    item.innerHTML = `
+        <div class="d-flex w-100 justify-content-between">
+            <h6 class="mb-1">${question.content}</h6>
+            <small>${question.category}</small>
+        </div>
+        ${question.referenceAnswer ? `<p class="mb-1 small text-muted">参考答案：${question.referenceAnswer}</p>` : ''}
+    `;
+    item.addEventListener('click', () => selectQuestion(question));
+    questionsList.appendChild(item);
+}
+
 // 选择问题
 function selectQuestion(question) {
     // 高亮选中的问题
@@ -221,28 +351,25 @@ async function handleSubmitAnswer() {
     submitBtn.innerHTML = '<span class="spinner-border spinner-border-sm" role="status" aria-hidden="true"></span> 评估中...';
 
     try {
-        const response = await fetch('/evaluate/answer', {
-            method: 'POST',
-            headers: {
-                'Content-Type': 'application/json',
-            },
-            body: JSON.stringify({
-                questionSetId: questionSetId,
+        // 评估内容逐字显示
+        const partial = { score: '…', feedback: '', suggestions: '' };
+        displayEvaluation(partial);
+
+        await postEventStream('/evaluate/answer/stream', {
+            questionSetId: questionSetId,
+            questionId: currentQuestionId,
+            answer: {
                 questionId: currentQuestionId,
-                answer: {
-                    questionId: currentQuestionId,
-                    content: answerText.value
-                }
-            })
+                content: answerText.value
+            }
+        }, (event, data) => {
+            if (event === 'delta') {
+                partial[data.field] += data.text;
+                updateEvaluationText(partial);
+            } else if (event === 'done') {
+                displayEvaluation(data.evaluation);
+            }
         });
-
-        const data = await response.json();
-
-        if (response.ok) {
-            displayEvaluation(data.evaluation);
-        } else {
-            throw new Error(data.error || '评估失败');
-        }
     } catch (error) {
         alert(`错误: ${error.message}`);
     } finally {
@@ -269,10 +396,10 @@ function displayEvaluation(evaluation) {
                     </div>
                     <div class="col-md-9">
                         <h5>评价</h5>
-                        <p>${evaluation.feedback}</p>
+                        <p id="evaluationFeedback">${evaluation.feedback}</p>
                         <div class="feedback-section">
                             <h5>改进建议</h5>
-                            <p>${evaluation.suggestions}</p>
+                            <p id="evaluationSuggestions">${evaluation.suggestions}</p>
                         </div>
                     </div>
                 </div>
@@ -287,8 +414,15 @@ function displayEvaluation(evaluation) {
     evaluationResult.scrollIntoView({ behavior: 'smooth' });
 }
 
+// 流式评估过程中更新反馈和建议文本
+function updateEvaluationText(evaluation) {
+    document.getElementById('evaluationFeedback').textContent = evaluation.feedback;
+    document.getElementById('evaluationSuggestions').textContent = evaluation.suggestions;
+}
+
 // 根据分数获取评价描述
 function getScoreDescription(score) {
+    if (typeof score !== 'number') return '评估中';
     if (score >= 9) return '优秀';
     if (score >= 7) return '良好';
     if (score >= 5) return '一般';
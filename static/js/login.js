@@ -0,0 +1,88 @@
+// DOM加载完成后执行
+document.addEventListener('DOMContentLoaded', () => {
+    document.getElementById('loginForm').addEventListener('submit', handleLogin);
+    document.getElementById('registerForm').addEventListener('submit', handleRegister);
+
+    // 登录和注册表单切换
+    document.getElementById('showRegister').addEventListener('click', event => {
+        event.preventDefault();
+        showCard('registerCard');
+    });
+    document.getElementById('showLogin').addEventListener('click', event => {
+        event.preventDefault();
+        showCard('loginCard');
+    });
+
+    // 使用邀请码时角色由邀请码决定
+    document.getElementById('inviteCode').addEventListener('input', toggleRoleGroup);
+
+    // 邀请链接带有invite参数时直接显示注册表单
+    const invite = new URLSearchParams(window.location.search).get('invite');
+    if (invite) {
+        document.getElementById('inviteCode').value = invite;
+        toggleRoleGroup();
+        showCard('registerCard');
+    }
+});
+
+// 填写了邀请码时隐藏角色选择
+function toggleRoleGroup() {
+    const roleGroup = document.getElementById('roleGroup');
+    if (roleGroup) {
+        roleGroup.classList.toggle('d-none', document.getElementById('inviteCode').value.trim() !== '');
+    }
+}
+
+// 显示登录或注册卡片
+function showCard(id) {
+    document.getElementById('loginCard').classList.toggle('d-none', id !== 'loginCard');
+    document.getElementById('registerCard').classList.toggle('d-none', id !== 'registerCard');
+}
+
+// 提交JSON请求，失败时抛出服务端返回的错误信息
+async function postJSON(url, body) {
+    const response = await fetch(url, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body)
+    });
+    const data = await response.json();
+    if (!response.ok) {
+        throw new Error((data.error && data.error.message) || '请求失败');
+    }
+    return data;
+}
+
+// 处理登录
+async function handleLogin(event) {
+    event.preventDefault();
+    await submitForm(event.target, 'loginBtn', 'loginError', '/api/v1/auth/login');
+}
+
+// 处理注册
+async function handleRegister(event) {
+    event.preventDefault();
+    await submitForm(event.target, 'registerBtn', 'registerError', '/api/v1/auth/register');
+}
+
+// 提交表单，成功后跳转到首页
+async function submitForm(form, buttonId, errorId, url) {
+    const button = document.getElementById(buttonId);
+    const errorEl = document.getElementById(errorId);
+    const text = button.textContent;
+
+    button.disabled = true;
+    button.innerHTML = '<span class="spinner-border spinner-border-sm" role="status" aria-hidden="true"></span> 请稍候...';
+    errorEl.classList.add('d-none');
+
+    try {
+        await postJSON(url, Object.fromEntries(new FormData(form)));
+        window.location.href = '/';
+    } catch (error) {
+        errorEl.classList.remove('d-none');
+        errorEl.textContent = `错误: ${error.message}`;
+    } finally {
+        button.disabled = false;
+        button.textContent = text;
+    }
+}